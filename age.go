@@ -0,0 +1,19 @@
+// GoEconGo project age.go
+package main
+
+//lifespan draws how many ticks a new agent may trade for, uniformly between
+//MinAge and MaxAge.  A MaxAge of 0 is forever, and returns 0.
+func (config *SimulationConfig) lifespan() int {
+	if config.MaxAge <= 0 {
+		return 0
+	}
+	if config.MinAge >= config.MaxAge {
+		return config.MaxAge
+	}
+	return config.MinAge + config.random().Intn(config.MaxAge-config.MinAge+1)
+}
+
+//retired reports whether an agent has reached its maxAge.
+func retired(agent *traderAgent) bool {
+	return agent.maxAge > 0 && agent.age >= agent.maxAge
+}
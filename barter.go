@@ -0,0 +1,195 @@
+// GoEconGo project barter.go
+package main
+
+import "fmt"
+
+//A BarterOffer is an agent's offer to swap goods directly with another agent,
+//without any cash changing hands.
+//give - what the offeror hands over
+//receive - what the offeror wants in return
+//offerorID - the id of the agent making the offer
+//expiryTick - the last tick the offer stands
+type BarterOffer struct {
+	give       []commoditySet
+	receive    []commoditySet
+	offerorID  uint64
+	expiryTick int
+}
+
+//A BarterMarket matches complementary BarterOffers: one agent's give is the
+//other's receive and the other way around.
+//market - the market whose agents are bartering
+//offers - the offers standing
+type BarterMarket struct {
+	market *Market
+	offers []BarterOffer
+}
+
+//A BarterEvent is raised whenever two agents swap goods.
+type BarterEvent struct {
+	Tick       int
+	FirstID    uint64
+	SecondID   uint64
+	FirstGave  []commoditySet
+	SecondGave []commoditySet
+}
+
+func (e BarterEvent) String() string {
+	return fmt.Sprintf("Barter at tick %v! Agent %v swapped %v with agent %v for %v", e.Tick, e.FirstID, describeSets(e.FirstGave), e.SecondID, describeSets(e.SecondGave))
+}
+
+//describeSets writes out commodity sets as, e.g., "[2 Food 1 Wood]".
+func describeSets(sets []commoditySet) string {
+	var out []interface{}
+	for _, set := range sets {
+		out = append(out, set.quantity, set.item.name)
+	}
+	return fmt.Sprint(out)
+}
+
+//newBarterMarket builds an empty barter market for a market's agents.
+func newBarterMarket(m *Market) *BarterMarket {
+	bm := new(BarterMarket)
+	bm.market = m
+	return bm
+}
+
+//PostOffer puts up an offer to be matched.
+func (bm *BarterMarket) PostOffer(offer BarterOffer) {
+	bm.offers = append(bm.offers, offer)
+}
+
+//sameSets reports whether two slices of commoditySets hold the same goods,
+//whatever order they are in.
+func sameSets(a []commoditySet, b []commoditySet) bool {
+	counts := make(map[*commodity]int)
+	for _, set := range a {
+		counts[set.item] += set.quantity
+	}
+	for _, set := range b {
+		counts[set.item] -= set.quantity
+	}
+	for _, num := range counts {
+		if num != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//holds reports whether an agent has all of a slice of commoditySets on hand.
+func holds(agent *traderAgent, sets []commoditySet) bool {
+	for _, set := range sets {
+		if agent.inventory[set.item] < set.quantity {
+			return false
+		}
+	}
+	return true
+}
+
+//MatchBarters drops expired offers and then swaps the goods of every pair of
+//complementary offers whose agents can still hand over what they promised.  Each
+//offer is matched at most once.  Funds are never touched.
+func (bm *BarterMarket) MatchBarters() {
+	m := bm.market
+	var standing []BarterOffer
+	for _, offer := range bm.offers {
+		if offer.expiryTick >= m.tick {
+			standing = append(standing, offer)
+		}
+	}
+	matched := make([]bool, len(standing))
+	for i := range standing {
+		for j := i + 1; j < len(standing) && !matched[i]; j++ {
+			a, b := standing[i], standing[j]
+			if matched[j] || a.offerorID == b.offerorID ||
+				!sameSets(a.give, b.receive) || !sameSets(a.receive, b.give) {
+				continue
+			}
+			if bm.swap(a, b) {
+				matched[i], matched[j] = true, true
+			}
+		}
+	}
+	bm.offers = nil
+	for i, offer := range standing {
+		if !matched[i] {
+			bm.offers = append(bm.offers, offer)
+		}
+	}
+}
+
+//swap carries out a matched pair of offers, if both agents are still running and
+//still have what they offered.
+func (bm *BarterMarket) swap(a BarterOffer, b BarterOffer) bool {
+	m := bm.market
+	first, _, ok := m.agentByID(a.offerorID)
+	if !ok {
+		return false
+	}
+	second, _, ok := m.agentByID(b.offerorID)
+	if !ok {
+		return false
+	}
+	//Always lock the lower id first so two swaps can't deadlock.
+	if a.offerorID > b.offerorID {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+	giver, taker := first, second
+	if a.offerorID > b.offerorID {
+		giver, taker = second, first
+	}
+	if !holds(giver, a.give) || !holds(taker, b.give) {
+		return false
+	}
+	for _, set := range a.give {
+		giver.inventory[set.item] -= set.quantity
+		taker.inventory[set.item] += set.quantity
+	}
+	for _, set := range b.give {
+		taker.inventory[set.item] -= set.quantity
+		giver.inventory[set.item] += set.quantity
+	}
+	m.Emit(BarterEvent{Tick: m.tick, FirstID: a.offerorID, SecondID: b.offerorID, FirstGave: a.give, SecondGave: b.give})
+	return true
+}
+
+//postBarterOffers has every agent short of cash (below BarterFundsThreshold) offer
+//to swap a unit of anything it doesn't need for a unit of anything it is short
+//of.
+func (m *Market) postBarterOffers() {
+	coms := m.sortedCommodities()
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		if agent.funds >= m.config.BarterFundsThreshold || agent.job == nil {
+			agent.mu.Unlock()
+			continue
+		}
+		needs := gatherAllRequirements(agent)
+		var spare, short []*commodity
+		for _, com := range coms {
+			if _, needed := needs[com]; !needed && agent.inventory[com] > 0 && !com.IsExternality {
+				spare = append(spare, com)
+			}
+			if num, needed := needs[com]; needed && agent.inventory[com] < num && !com.IsCommonPool {
+				short = append(short, com)
+			}
+		}
+		agent.mu.Unlock()
+		for _, give := range spare {
+			for _, receive := range short {
+				var offer BarterOffer
+				offer.give = []commoditySet{{item: give, quantity: 1}}
+				offer.receive = []commoditySet{{item: receive, quantity: 1}}
+				offer.offerorID = agent.id
+				offer.expiryTick = m.tick + m.config.BarterOfferTicks
+				m.barter.PostOffer(offer)
+			}
+		}
+	}
+}
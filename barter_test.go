@@ -0,0 +1,37 @@
+// GoEconGo project barter_test.go
+package main
+
+import "testing"
+
+func TestMatchBarters(t *testing.T) {
+	food, ore := &commodity{name: "Food", averagePrice: 3}, &commodity{name: "Ore", averagePrice: 3}
+	config := testConfig()
+	config.KeepLedger = true
+	m := newMarket(map[string]*commodity{"Food": food, "Ore": ore}, config)
+	//Neither can afford to buy anything, but each has plenty of what the other wants.
+	farmer := newTestAgent(&m.config, nil, 1, map[*commodity]int{food: 10})
+	miner := newTestAgent(&m.config, nil, 1, map[*commodity]int{ore: 10})
+	placeTestAgent(m, 0, farmer)
+	placeTestAgent(m, 1, miner)
+	barters := recordEvents[BarterEvent](m)
+	entries := len(m.Ledger.Entries())
+
+	m.barter.PostOffer(BarterOffer{give: []commoditySet{{item: food, quantity: 4}}, receive: []commoditySet{{item: ore, quantity: 6}}, offerorID: farmer.id, expiryTick: m.tick})
+	m.barter.PostOffer(BarterOffer{give: []commoditySet{{item: ore, quantity: 6}}, receive: []commoditySet{{item: food, quantity: 4}}, offerorID: miner.id, expiryTick: m.tick})
+	m.barter.MatchBarters()
+	if len(*barters) != 1 {
+		t.Fatalf("%v barters, want 1", len(*barters))
+	}
+	if farmer.inventory[food] != 6 || farmer.inventory[ore] != 6 {
+		t.Errorf("the farmer holds %v food and %v ore, want 6 of each", farmer.inventory[food], farmer.inventory[ore])
+	}
+	if miner.inventory[food] != 4 || miner.inventory[ore] != 4 {
+		t.Errorf("the miner holds %v food and %v ore, want 4 of each", miner.inventory[food], miner.inventory[ore])
+	}
+	if farmer.funds != 1 || miner.funds != 1 || len(m.Ledger.Entries()) != entries {
+		t.Errorf("cash changed hands: the farmer holds %v and the miner %v", farmer.funds, miner.funds)
+	}
+	if len(m.barter.offers) != 0 {
+		t.Errorf("%v offers still standing after they matched", len(m.barter.offers))
+	}
+}
@@ -0,0 +1,123 @@
+// GoEconGo project belief.go
+package main
+
+import "math"
+
+//A BeliefUpdater decides how an agent's belief of a commodity's price changes once
+//it hears how an offer fared.
+//current - the agent's belief before the offer
+//accepted - how many of the offered lots traded
+//offered - how many lots were offered
+//marketAvg - the price the agent steers towards
+type BeliefUpdater interface {
+	Update(current priceRange, accepted int, offered int, marketAvg float64) priceRange
+}
+
+//The rates the DefaultBeliefUpdater moves beliefs by, unless configured otherwise.
+const (
+	defaultBeliefAdjustBig   = 0.2
+	defaultBeliefAdjustSmall = 0.01
+)
+
+//BeliefAdjustRates are how fast a role's agents learn prices.
+//Big - how far [0.0,1.0] a belief on the wrong side of the market moves
+//Small - how far [0.0,1.0] any other belief moves
+type BeliefAdjustRates struct {
+	Big   float64
+	Small float64
+}
+
+//beliefAdjustRates returns how fast agents of a role learn prices: the configured
+//rates for the role, or the defaults for any left out.
+func (config *SimulationConfig) beliefAdjustRates(role string) (big, small float64) {
+	big, small = defaultBeliefAdjustBig, defaultBeliefAdjustSmall
+	if rates, ok := config.BeliefAdjustRates[role]; ok {
+		if rates.Big > 0 {
+			big = rates.Big
+		}
+		if rates.Small > 0 {
+			small = rates.Small
+		}
+	}
+	return big, small
+}
+
+//DefaultBeliefUpdater moves a belief towards the market average - a lot when the
+//belief is on the wrong side of it, a little otherwise.  A seller whose ask traded
+//raises its belief, and lowers it if the ask didn't; a buyer does the opposite.
+//BigPercent - how far [0.0,1.0] a belief on the wrong side moves (0 is
+//defaultBeliefAdjustBig)
+//LittlePercent - how far [0.0,1.0] any other belief moves (0 is
+//defaultBeliefAdjustSmall)
+//Buying - whether the offers are bids
+type DefaultBeliefUpdater struct {
+	BigPercent    float64
+	LittlePercent float64
+	Buying        bool
+}
+
+//Update moves the belief.
+func (u DefaultBeliefUpdater) Update(current priceRange, accepted int, offered int, marketAvg float64) priceRange {
+	bigPercent := u.BigPercent
+	if bigPercent == 0 {
+		bigPercent = defaultBeliefAdjustBig
+	}
+	littlePercent := u.LittlePercent
+	if littlePercent == 0 {
+		littlePercent = defaultBeliefAdjustSmall
+	}
+	agentHigh := current.high
+	agentLow := current.low
+	agentAvg := (agentHigh + agentLow) / 2
+	//A seller raises its prices when it sells, and a buyer when it doesn't buy.
+	if (accepted > 0) != u.Buying {
+		//Consider raising our prices - a lot if we're under the average, a little if we're over.
+		if agentAvg <= marketAvg {
+			//Agent Average under Average - Raise a lot!
+			agentHigh = agentHigh + math.Abs(agentHigh-marketAvg)*bigPercent
+			agentLow = agentLow + math.Abs(agentLow-marketAvg)*bigPercent
+			//Bring it back down if too big.
+			agentLow, agentHigh = clampPriceRange(agentLow, agentHigh, marketAvg, bigPercent)
+		} else {
+			//Overaverage!  Raise just a bit.
+			agentHigh = agentHigh + math.Abs(agentHigh-marketAvg)*littlePercent
+			agentLow = agentLow + math.Abs(agentLow-marketAvg)*littlePercent
+			agentLow, agentHigh = clampPriceRange(agentLow, agentHigh, marketAvg, littlePercent)
+		}
+	} else {
+		//Consider lowering our prices - a lot if we're over the average, a little if we're under.
+		if agentAvg >= marketAvg {
+			//Agent Average over Average - Lower a lot!
+			agentHigh = agentHigh - math.Abs(agentHigh-marketAvg)*bigPercent
+			agentLow = agentLow - math.Abs(agentLow-marketAvg)*bigPercent
+			agentLow, agentHigh = clampPriceRange(agentLow, agentHigh, marketAvg, bigPercent)
+		} else {
+			//Under Average
+			agentHigh = agentHigh - math.Abs(agentHigh-marketAvg)*littlePercent
+			agentLow = agentLow - math.Abs(agentLow-marketAvg)*littlePercent
+			agentLow, agentHigh = clampPriceRange(agentLow, agentHigh, marketAvg, littlePercent)
+		}
+	}
+	if agentLow < 0 {
+		agentLow = 0.5 //Totally arbitrary
+	}
+	return priceRange{low: agentLow, high: agentHigh}
+}
+
+//askUpdater returns the BeliefUpdater the agent updates its beliefs with after
+//asking.
+func (agent *traderAgent) askUpdater() BeliefUpdater {
+	if agent.AskBeliefUpdater == nil {
+		return DefaultBeliefUpdater{BigPercent: agent.beliefAdjustBig, LittlePercent: agent.beliefAdjustSmall}
+	}
+	return agent.AskBeliefUpdater
+}
+
+//bidUpdater returns the BeliefUpdater the agent updates its beliefs with after
+//bidding.
+func (agent *traderAgent) bidUpdater() BeliefUpdater {
+	if agent.BidBeliefUpdater == nil {
+		return DefaultBeliefUpdater{BigPercent: agent.beliefAdjustBig, LittlePercent: agent.beliefAdjustSmall, Buying: true}
+	}
+	return agent.BidBeliefUpdater
+}
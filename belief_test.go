@@ -0,0 +1,97 @@
+// GoEconGo project belief_test.go
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestClampPriceRange(t *testing.T) {
+	tests := []struct {
+		name                        string
+		low, high, itemAvg, percent float64
+		wantLow, wantHigh           float64
+	}{
+		{"in order", 1, 3, 2, 0.2, 1, 3},
+		{"equal, off the average", 3, 3, 2, 0.2, 2.8, 3},
+		{"equal, on the average", 3, 3, 3, 0.2, 2.4, 3},
+		{"inverted, low on the average", 3, 2, 3, 0.2, 1.6, 2},
+		{"inverted past zero", 2, -1, 3, 0.2, 2.4, 3},
+		{"all zero", 0, 0, 0, 0.2, 0.8, 1},
+		{"no adjustment", 3, 3, 3, 0, 1.5, 3},
+	}
+	for _, test := range tests {
+		low, high := clampPriceRange(test.low, test.high, test.itemAvg, test.percent)
+		if math.Abs(low-test.wantLow) > 1e-9 || math.Abs(high-test.wantHigh) > 1e-9 {
+			t.Errorf("%v: got [%v, %v], want [%v, %v]", test.name, low, high, test.wantLow, test.wantHigh)
+		}
+	}
+}
+
+func TestBeliefUpdateNeverInverts(t *testing.T) {
+	//The eight ways a belief moves: asking or bidding, traded or not, and a belief
+	//under or over the market average of 3 - each starting from a belief with no
+	//width, and from one turned inside out.
+	starts := []struct {
+		name   string
+		belief priceRange
+	}{
+		{"under, equal", priceRange{low: 2, high: 2}},
+		{"over, equal", priceRange{low: 4, high: 4}},
+		{"under, inverted", priceRange{low: 2.5, high: 1.5}},
+		{"over, inverted", priceRange{low: 4.5, high: 3.5}},
+	}
+	for _, buying := range []bool{false, true} {
+		for _, accepted := range []int{0, 1} {
+			for _, start := range starts {
+				updater := DefaultBeliefUpdater{Buying: buying}
+				got := updater.Update(start.belief, accepted, 1, 3)
+				if !(got.low < got.high) || got.low < 0 || math.IsNaN(got.low) || math.IsInf(got.high, 0) {
+					t.Errorf("buying %v, %v accepted, %v: got [%v, %v]", buying, accepted, start.name, got.low, got.high)
+				}
+			}
+		}
+	}
+}
+
+func TestInitialPriceBelief(t *testing.T) {
+	prices := []float64{0.01, 1, 3, 1000}
+	commodities := make(map[string]*commodity)
+	for _, price := range prices {
+		name := fmt.Sprint(price)
+		commodities[name] = &commodity{name: name, averagePrice: price}
+	}
+	for _, spread := range []float64{0.1, 0.3, 0.5, 0.99, 0, -1, 1, 2} {
+		want := spread
+		if spread <= 0 || spread >= 1 {
+			want = defaultBeliefInitSpread
+		}
+		beliefs := initialPriceBelief(commodities, spread)
+		for _, com := range commodities {
+			belief := beliefs[com]
+			if !(belief.high > belief.low) {
+				t.Errorf("spread %v, price %v: belief [%v, %v] has no width", spread, com.averagePrice, belief.low, belief.high)
+			}
+			if got := (belief.high - belief.low) / (2 * com.averagePrice); math.Abs(got-want) > 1e-9 {
+				t.Errorf("spread %v, price %v: belief [%v, %v] spreads %v, want %v", spread, com.averagePrice, belief.low, belief.high, got, want)
+			}
+			if mid := (belief.high + belief.low) / 2; math.Abs(mid-com.averagePrice) > 1e-9*com.averagePrice {
+				t.Errorf("spread %v, price %v: belief [%v, %v] is centred on %v", spread, com.averagePrice, belief.low, belief.high, mid)
+			}
+		}
+	}
+}
+
+func TestBeliefInitSpread(t *testing.T) {
+	config := testConfig()
+	config.BeliefInitSpread = 0.1
+	sim := newTestSimulation(t, config, 2)
+	for _, agent := range sim.Market.snapshotAgents() {
+		for com, belief := range agent.priceBelief {
+			if math.Abs(belief.low-0.9*com.averagePrice) > 1e-9 || math.Abs(belief.high-1.1*com.averagePrice) > 1e-9 {
+				t.Errorf("agent %v starts out believing %v is worth [%v, %v], want 10%% either side of %v", agent.id, com.name, belief.low, belief.high, com.averagePrice)
+			}
+		}
+	}
+}
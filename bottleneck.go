@@ -0,0 +1,69 @@
+// GoEconGo project bottleneck.go
+package main
+
+//How many ticks of bids the market report, and the BottleneckStrategy picked on
+//the command line, look back over.
+const bottleneckWindow = 10
+
+//recordBidFill records how many units of a commodity were bid for this tick, and
+//how many of those went unbought.
+func (m *Market) recordBidFill(com *commodity, bidsCom []*bids) {
+	bid, unfilled := 0, 0
+	for _, bidSet := range bidsCom {
+		bid += bidSet.numberOffered
+		unfilled += bidSet.numberOffered - bidSet.numberAccepted
+	}
+	com.bidRing.push(bid)
+	com.unfilledRing.push(unfilled)
+}
+
+//DetectBottleneck scores how much of a bottleneck each commodity is: the share of
+//all the units bid for over the last windowTicks ticks that went unbought.  A
+//score near 1 means buyers can hardly get any; a commodity nobody bid for
+//scores 0.
+//Returns the score [0.0,1.0] of every commodity, by name.
+func DetectBottleneck(sim *Simulation, windowTicks int) map[string]float64 {
+	scores := make(map[string]float64)
+	for name, com := range sim.Market.commodities {
+		bid, unfilled := 0, 0
+		for _, num := range com.bidRing.last(windowTicks) {
+			bid += num
+		}
+		for _, num := range com.unfilledRing.last(windowTicks) {
+			unfilled += num
+		}
+		if bid > 0 {
+			scores[name] = float64(unfilled) / float64(bid)
+		} else {
+			scores[name] = 0
+		}
+	}
+	return scores
+}
+
+//BottleneckStrategy replaces the dead with an agent of the role making whatever
+//commodity is the worst bottleneck for lack of supply (see BottleneckCauses).
+//Window - how many ticks of bids to score bottlenecks over (see DetectBottleneck)
+type BottleneckStrategy struct {
+	Window int
+}
+
+//Resurrect makes an agent of the role whose product scores highest as a
+//bottleneck, passing over products short of demand rather than supply - more
+//producers won't help those.  With nothing short of supply it falls back to the
+//MostExpensiveCommodityStrategy.
+func (s BottleneckStrategy) Resurrect(dead traderAgent, sim *Simulation) traderAgent {
+	m := sim.Market
+	scores := DetectBottleneck(sim, s.Window)
+	causes := BottleneckCauses(sim, s.Window)
+	best := ""
+	for _, role := range m.roleOrder {
+		if causes[m.products[role].name] == "supply" && (best == "" || scores[m.products[role].name] > scores[m.products[best].name]) {
+			best = role
+		}
+	}
+	if best == "" {
+		return MostExpensiveCommodityStrategy{}.Resurrect(dead, sim)
+	}
+	return m.roles[best]()
+}
@@ -0,0 +1,91 @@
+// GoEconGo project cancel.go
+package main
+
+import "container/heap"
+
+//How many cancellations a market's CancelChannel holds.  An agent finding it full
+//doesn't wait, and its orders stand.
+const cancelBuffer = 1024
+
+//cancelOrders asks the market to take an agent's open orders off the books, ahead
+//of the agent dying with them still open.
+//cancel - the market's CancelChannel
+func cancelOrders(agent *traderAgent, cancel chan<- uint64) {
+	select {
+	case cancel <- agent.id:
+	default:
+	}
+}
+
+//takeCancels gathers up every cancellation sent to the market since the last.
+func (m *Market) takeCancels() {
+	for {
+		select {
+		case id := <-m.CancelChannel:
+			m.cancelled[id] = true
+		default:
+			return
+		}
+	}
+}
+
+//cancelledAt reports whether the agent in a slot has cancelled its orders.
+func (m *Market) cancelledAt(slot uint64) bool {
+	return m.cancelled[m.idAt(slot)]
+}
+
+//dropCancelled takes the orders of every agent that has cancelled them off the
+//books, before anything trades.
+func (m *Market) dropCancelled() {
+	m.takeCancels()
+	if len(m.cancelled) == 0 {
+		return
+	}
+	for com, book := range m.asksTyped {
+		kept := book[:0]
+		for _, askSet := range book {
+			if !m.cancelled[askSet.offeredAsk.id] {
+				kept = append(kept, askSet)
+			}
+		}
+		heap.Init(&kept)
+		m.asksTyped[com] = kept
+	}
+	for com, book := range m.bidsTyped {
+		kept := book[:0]
+		for _, bidSet := range book {
+			if !m.cancelled[bidSet.offeredBid.id] {
+				kept = append(kept, bidSet)
+			}
+		}
+		heap.Init(&kept)
+		m.bidsTyped[com] = kept
+	}
+	futureAsks := m.futures.asks[:0]
+	for _, askSet := range m.futures.asks {
+		if !m.cancelled[askSet.offeredAsk.id] {
+			futureAsks = append(futureAsks, askSet)
+		}
+	}
+	m.futures.asks = futureAsks
+	futureBids := m.futures.bids[:0]
+	for _, bidSet := range m.futures.bids {
+		if !m.cancelled[bidSet.offeredBid.id] {
+			futureBids = append(futureBids, bidSet)
+		}
+	}
+	m.futures.bids = futureBids
+}
+
+//settleCancelled settles whatever of a dying agent's orders traded before its
+//cancellation reached the market, as the agent is no longer listening for the
+//results.  The dead agent the market takes off its dead channel carries them.
+func (m *Market) settleCancelled(slot uint64, asksOut []asks, bidsOut []bids) {
+	agent, ok := m.agents[slot]
+	if !ok || (len(asksOut) == 0 && len(bidsOut) == 0) {
+		return
+	}
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	agentUpdate(agent, &asksOut, &bidsOut)
+}
@@ -0,0 +1,69 @@
+// GoEconGo project capacity.go
+package main
+
+import "sort"
+
+//How many units of everything together agents can hold, unless configured
+//otherwise.
+const defaultMaxInventory = 200
+
+//The share of its maxInventory past which an agent hurries to sell.
+const nearCapacityShare = 0.8
+
+//maxInventory returns how many units agents of a role can hold: the configured
+//capacity for the role, or defaultMaxInventory for roles left out.  A capacity of
+//0 or less is no limit.
+func (config *SimulationConfig) maxInventory(role string) int {
+	if capacity, ok := config.MaxInventory[role]; ok {
+		return capacity
+	}
+	return defaultMaxInventory
+}
+
+//inventoryHeld returns how many units of everything together an agent holds.
+func inventoryHeld(agent *traderAgent) int {
+	held := 0
+	for _, num := range agent.inventory {
+		held += num
+	}
+	return held
+}
+
+//fitsInventory reports whether an agent would still be within its maxInventory
+//after running a production method.
+//inputs - the inputs the method takes this cycle
+func fitsInventory(agent *traderAgent, method *productionMethod, inputs []commoditySet) bool {
+	if agent.maxInventory <= 0 {
+		return true
+	}
+	after := inventoryHeld(agent)
+	for _, input := range inputs {
+		if !input.item.IsCommonPool {
+			after -= input.quantity
+		}
+	}
+	for _, output := range skilledOutputs(agent, method) {
+		after += output.quantity
+	}
+	return after <= agent.maxInventory
+}
+
+//nearCapacity reports whether an agent holds more than nearCapacityShare of its
+//maxInventory.
+func nearCapacity(agent *traderAgent) bool {
+	return agent.maxInventory > 0 && float64(inventoryHeld(agent)) > nearCapacityShare*float64(agent.maxInventory)
+}
+
+//prioritizeAsks puts an agent's asks of whatever it holds most of first, and asks
+//the bottom of its belief for the biggest, so that it goes.
+func prioritizeAsks(agent *traderAgent, askSlice []asks) {
+	sort.SliceStable(askSlice, func(i, j int) bool {
+		if askSlice[i].numberOffered != askSlice[j].numberOffered {
+			return askSlice[i].numberOffered > askSlice[j].numberOffered
+		}
+		return askSlice[i].offeredAsk.item.name < askSlice[j].offeredAsk.item.name
+	})
+	if len(askSlice) > 0 {
+		askSlice[0].offeredAsk.sellFor = agent.priceBelief[askSlice[0].offeredAsk.item].low
+	}
+}
@@ -0,0 +1,41 @@
+// GoEconGo project carrying.go
+package main
+
+import "math"
+
+//carryingCost returns what an agent pays each tick to keep everything it holds.
+func carryingCost(agent *traderAgent) float64 {
+	cost := 0.0
+	for com, num := range agent.inventory {
+		cost += float64(num) * com.CarryingCostPerUnit
+	}
+	return cost
+}
+
+//chargeCarryingCost takes what it costs to keep everything an agent holds out of
+//its funds.
+func chargeCarryingCost(agent *traderAgent) {
+	cost := carryingCost(agent)
+	agent.funds = agent.funds - cost
+	agent.ledger.Record(agent.id, 0, cost, agent.tick, ledgerCarrying)
+	agent.Cost = agent.Cost + cost
+}
+
+//bufferedUnits cuts how many units of a commodity an agent buffers by what
+//keeping them costs.  A unit bought for the buffer is kept for up to ticksHeld
+//ticks, so the buffer is cut by the share of the unit's worth that keeping it that
+//long would cost - a commodity costing nothing to keep is buffered in full, and
+//one costing its whole worth isn't buffered at all.
+//num - the units the agent would buffer if keeping them were free
+//ticksHeld - how many ticks the buffer is meant to last
+func bufferedUnits(agent *traderAgent, com *commodity, num int, ticksHeld int) int {
+	if com.CarryingCostPerUnit <= 0 || num <= 0 {
+		return num
+	}
+	worth := (agent.priceBelief[com].high + agent.priceBelief[com].low) / 2
+	share := 0.0
+	if worth > 0 {
+		share = 1 - math.Min(1, com.CarryingCostPerUnit*float64(ticksHeld)/worth)
+	}
+	return int(math.Ceil(float64(num) * share))
+}
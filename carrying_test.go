@@ -0,0 +1,69 @@
+// GoEconGo project carrying_test.go
+package main
+
+import "testing"
+
+//farmersWoodAt runs the test economy, with woodcutters enough to keep farmers in
+//all the wood they want, for 60 ticks under a carrying cost on wood.  It returns
+//how much wood farmers held on average over the last 40.
+func farmersWoodAt(t *testing.T, cost float64) float64 {
+	economy := testSimConfig(t, 10)
+	setCohort(economy, "Woodcutter", 40)
+	wood := economy.CommodityList()["Wood"]
+	wood.CarryingCostPerUnit = cost
+	sim := simulateEconomy(t, testConfig(), economy)
+	RunTicks(20, sim)
+	held, farmers := 0, 0
+	for tick := 0; tick < 40; tick++ {
+		RunTicks(1, sim)
+		for _, agent := range sim.Market.snapshotAgents() {
+			if agent.role == "Farmer" {
+				held += agent.inventory[wood]
+				farmers++
+			}
+		}
+	}
+	return float64(held) / float64(farmers)
+}
+
+func TestCarryingCostLowersInventory(t *testing.T) {
+	free, costly := farmersWoodAt(t, 0), farmersWoodAt(t, 1)
+	if free == 0 {
+		t.Fatal("farmers held no wood, even for free")
+	}
+	if costly >= free {
+		t.Errorf("farmers paying 1 a tick to keep wood held %v, want less than the %v they held for free", costly, free)
+	}
+}
+
+func TestCarryingCostBuffersLess(t *testing.T) {
+	wood, food := &commodity{name: "Wood", averagePrice: 3}, &commodity{name: "Food", averagePrice: 3}
+	method := &productionMethod{inputs: []commoditySet{{item: wood, quantity: 1}}, outputs: []commoditySet{{item: food, quantity: 2}}}
+	config := testConfig()
+	//woodBid returns how much wood a farmer bids for, and what it pays a tick to
+	//keep 4 wood, when wood costs some amount a unit to keep.
+	woodBid := func(cost float64) (int, float64) {
+		wood.CarryingCostPerUnit = cost
+		farmer := newTestAgent(&config, &productionSet{methods: []*productionMethod{method}, penalty: 2}, 100, map[*commodity]int{wood: 4})
+		farmer.riskAversion = 4
+		farmer.priceBelief[wood] = priceRange{low: 2, high: 4}
+		farmer.priceBelief[food] = priceRange{low: 2, high: 4}
+		bid := 0
+		for _, bidSet := range generateBids(farmer) {
+			if bidSet.offeredBid.item == wood {
+				bid += bidSet.numberOffered
+			}
+		}
+		funds := farmer.funds
+		chargeCarryingCost(farmer)
+		return bid, funds - farmer.funds
+	}
+	freeBid, freePaid := woodBid(0)
+	costlyBid, costlyPaid := woodBid(1)
+	if freePaid != 0 || costlyPaid != 4 {
+		t.Errorf("keeping 4 wood cost %v for free and %v at 1 a unit, want 0 and 4", freePaid, costlyPaid)
+	}
+	if freeBid <= 0 || costlyBid >= freeBid {
+		t.Errorf("a farmer bid for %v wood costing 1 a unit to keep, want less than the %v it bids for free", costlyBid, freeBid)
+	}
+}
@@ -0,0 +1,134 @@
+// GoEconGo project centralbank.go
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+//A centralBankAgent manages the money supply.  It neither produces nor trades:
+//after the market clears each tick it looks at the average funds of every role's
+//agents, paying a stimulus to the agents of roles that have too little and taxing
+//the agents of roles that have too much.
+//reserves - the money the bank holds, which stimulus is paid out of and taxes go
+//into
+//StimulusThreshold - the average funds below which a role's agents are paid a
+//stimulus (0 is never)
+//Stimulus - how much each agent of such a role is paid, while reserves last
+//AusterityCeiling - the average funds above which a role's agents are taxed (0 is
+//never)
+//AusterityRate - the share [0.0,1.0] of its funds each agent of such a role pays
+type centralBankAgent struct {
+	reserves          float64
+	StimulusThreshold float64
+	Stimulus          float64
+	AusterityCeiling  float64
+	AusterityRate     float64
+}
+
+//newCentralBank builds a central bank holding the given reserves, with no policy
+//set.
+func newCentralBank(reserves float64) *centralBankAgent {
+	bank := new(centralBankAgent)
+	bank.reserves = reserves
+	return bank
+}
+
+//Reserves returns the money the bank holds.
+func (bank *centralBankAgent) Reserves() float64 {
+	return bank.reserves
+}
+
+//A MonetaryPolicyEvent records the central bank moving money into (or, when
+//Amount is negative, out of) a role's agents.
+type MonetaryPolicyEvent struct {
+	Role   string
+	Tick   int
+	Amount float64
+}
+
+func (e MonetaryPolicyEvent) String() string {
+	if e.Amount < 0 {
+		return fmt.Sprintf("Central bank taxed %vs %v at tick %v", e.Role, -e.Amount, e.Tick)
+	}
+	return fmt.Sprintf("Central bank paid %vs %v at tick %v", e.Role, e.Amount, e.Tick)
+}
+
+//FundsTransfer moves money between the central bank and an agent: a positive
+//amount is paid to the agent out of the reserves, a negative one taken from the
+//agent into them.
+//agentID - the agent's id
+func (sim *Simulation) FundsTransfer(agentID uint64, amount float64) error {
+	return sim.Market.fundsTransfer(agentID, amount)
+}
+
+//SetCentralBank puts a central bank in charge of the market's money supply (nil
+//is none).
+func (sim *Simulation) SetCentralBank(bank *centralBankAgent) {
+	sim.Market.centralBank = bank
+}
+
+//fundsTransfer is FundsTransfer on the market itself.
+func (m *Market) fundsTransfer(agentID uint64, amount float64) error {
+	if m.centralBank == nil {
+		return errors.New("the market has no central bank")
+	}
+	agent, _, ok := m.agentByID(agentID)
+	if !ok {
+		return fmt.Errorf("no running agent with id %v", agentID)
+	}
+	if amount > m.centralBank.reserves {
+		return fmt.Errorf("the central bank's reserves of %v cannot cover %v", m.centralBank.reserves, amount)
+	}
+	agent.mu.Lock()
+	agent.funds = agent.funds + amount
+	m.Ledger.Record(0, agent.id, amount, m.tick, ledgerCentralBank)
+	agent.mu.Unlock()
+	m.centralBank.reserves = m.centralBank.reserves - amount
+	return nil
+}
+
+//runMonetaryPolicy lets the central bank, if there is one, pay its stimulus and
+//levy its taxes for the tick.
+func (m *Market) runMonetaryPolicy() {
+	bank := m.centralBank
+	if bank == nil {
+		return
+	}
+	members := make(map[string][]uint64)
+	totals := make(map[string]float64)
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		members[agent.role] = append(members[agent.role], agent.id)
+		totals[agent.role] = totals[agent.role] + agent.funds
+		agent.mu.Unlock()
+	}
+	for _, role := range m.roleOrder {
+		if len(members[role]) == 0 {
+			continue
+		}
+		average := totals[role] / float64(len(members[role]))
+		var moved float64
+		if bank.StimulusThreshold > 0 && average < bank.StimulusThreshold {
+			for _, id := range members[role] {
+				if m.fundsTransfer(id, bank.Stimulus) == nil {
+					moved = moved + bank.Stimulus
+				}
+			}
+		} else if bank.AusterityCeiling > 0 && average > bank.AusterityCeiling {
+			for _, id := range members[role] {
+				agent, _, _ := m.agentByID(id)
+				agent.mu.Lock()
+				tax := agent.funds * bank.AusterityRate
+				agent.mu.Unlock()
+				if tax > 0 && m.fundsTransfer(id, -tax) == nil {
+					moved = moved - tax
+				}
+			}
+		}
+		if moved != 0 {
+			m.Emit(MonetaryPolicyEvent{Role: role, Tick: m.tick, Amount: moved})
+		}
+	}
+}
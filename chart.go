@@ -0,0 +1,117 @@
+// GoEconGo project chart.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+//The size of the price charts the market report draws.
+const (
+	reportChartWidth  = 40
+	reportChartHeight = 8
+)
+
+//How many buckets the market report's wealth histogram sorts agents into.
+const reportHistogramBuckets = 10
+
+//PrintPriceChart draws a commodity's recent prices as a line chart of text, with
+//a tick per column and the y-axis scaled to the lowest and highest price shown.
+//The axes are drawn with box-drawing characters, and the highest and lowest prices
+//label the top and bottom of the y-axis.
+//com - the commodity to chart
+//width - how many of the most recent ticks to chart (at least 1)
+//height - how many rows tall the chart is (at least 2)
+//w - where to draw it
+func PrintPriceChart(com *commodity, width int, height int, w io.Writer) {
+	if width < 1 {
+		width = 1
+	}
+	if height < 2 {
+		height = 2
+	}
+	prices := com.PriceHistory(width)
+	fmt.Fprintln(w, com.name)
+	if len(prices) == 0 {
+		fmt.Fprintln(w, "(no prices yet)")
+		return
+	}
+	low, high := prices[0], prices[0]
+	for _, price := range prices {
+		if price < low {
+			low = price
+		}
+		if price > high {
+			high = price
+		}
+	}
+	//Work out which row each tick's price falls on, the top row being 0.
+	rows := make([]int, len(prices))
+	for i, price := range prices {
+		if high == low {
+			rows[i] = (height - 1) / 2
+			continue
+		}
+		rows[i] = int(math.Round((high - price) / (high - low) * float64(height-1)))
+	}
+	highLabel := fmt.Sprintf("%.2f", high)
+	lowLabel := fmt.Sprintf("%.2f", low)
+	labelWidth := len(highLabel)
+	if len(lowLabel) > labelWidth {
+		labelWidth = len(lowLabel)
+	}
+	for row := 0; row < height; row++ {
+		label, axis := "", "│"
+		switch row {
+		case 0:
+			label, axis = highLabel, "┤"
+		case height - 1:
+			label, axis = lowLabel, "┤"
+		}
+		var line strings.Builder
+		for i := range prices {
+			if rows[i] == row {
+				line.WriteString("*")
+			} else {
+				line.WriteString(" ")
+			}
+		}
+		fmt.Fprintf(w, "%*s %v%v\n", labelWidth, label, axis, strings.TrimRight(line.String(), " "))
+	}
+	fmt.Fprintf(w, "%*s └%v\n", labelWidth, "", strings.Repeat("─", len(prices)))
+}
+
+//PrintHistogram draws a histogram as a bar chart of text, a bucket to a row,
+//labelled with its range and count.  The longest bar is width characters long,
+//and the others are scaled to it.
+//histogram - the buckets to draw, in the order to draw them
+//width - how long the longest bar is (at least 1)
+//w - where to draw it
+func PrintHistogram(histogram []HistogramBucket, width int, w io.Writer) {
+	if width < 1 {
+		width = 1
+	}
+	if len(histogram) == 0 {
+		fmt.Fprintln(w, "(nothing to count)")
+		return
+	}
+	most := 0.0
+	labelWidth := 0
+	labels := make([]string, len(histogram))
+	for i, bucket := range histogram {
+		most = math.Max(most, bucket.Count)
+		labels[i] = fmt.Sprintf("%.2f-%.2f", bucket.Low, bucket.High)
+		if len(labels[i]) > labelWidth {
+			labelWidth = len(labels[i])
+		}
+	}
+	for i, bucket := range histogram {
+		bar := 0
+		if most > 0 {
+			bar = int(math.Round(bucket.Count / most * float64(width)))
+		}
+		fmt.Fprintf(w, "%*s │%v %v\n", labelWidth, labels[i], strings.Repeat("█", bar), bucket.Count)
+	}
+}
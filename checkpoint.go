@@ -0,0 +1,557 @@
+// GoEconGo project checkpoint.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+//A checkpoint is everything needed to carry a simulation on from where it was
+//saved.
+//Economy - the economy the simulation was set up from
+//Config - the market's configuration, without the hooks, handlers and strategies
+//that can't be written down (LoadCheckpoint is given those again)
+//PricingRule - the market's PricingRule
+//Tick - the last tick the market ran
+//Slots - how many agent slots the market has
+//FeePool - the fees the market has taken
+//Commodities - the state of each commodity
+//Agents - the state of each agent
+//Cohorts - what the market remembers of each cohort
+//Contracts - the futures contracts not yet delivered
+//Barters - the barter offers still standing
+//Random - how far the market's source of randomness had got
+type checkpoint struct {
+	Economy     SimConfig             `json:"economy"`
+	Config      SimulationConfig      `json:"config"`
+	PricingRule ClearingPriceRule     `json:"pricingRule"`
+	Tick        int                   `json:"tick"`
+	Slots       int                   `json:"slots"`
+	FeePool     float64               `json:"feePool"`
+	Commodities []commodityCheckpoint `json:"commodities"`
+	Agents      []agentCheckpoint     `json:"agents"`
+	Cohorts     []cohortCheckpoint    `json:"cohorts"`
+	Contracts   []contractCheckpoint  `json:"contracts"`
+	Barters     []barterCheckpoint    `json:"barters"`
+	Random      randomState           `json:"random"`
+}
+
+//A commodityCheckpoint is a saved commodity.
+//Name - the commodity's name
+//AveragePrice - its average price
+//PriceHistory - its remembered closing prices, oldest first
+//VolumeHistory - its remembered traded volumes, oldest first
+type commodityCheckpoint struct {
+	Name          string    `json:"name"`
+	AveragePrice  float64   `json:"averagePrice"`
+	PriceHistory  []float64 `json:"priceHistory"`
+	VolumeHistory []int     `json:"volumeHistory"`
+}
+
+//An agentCheckpoint is a saved agent.
+//ID - the agent's id
+//Slot - the index of the agent's channels
+//Role - the agent's role
+//Funds, RiskAversion, CohortID, LifetimeAskVolume, LifetimeBidVolume - as in
+//traderAgent
+//BeliefAdjustBig, BeliefAdjustSmall - how fast the agent learns prices
+//Age, MaxAge - how long the agent has traded, and may trade for
+//StartingFunds - the funds the agent was launched with
+//RoleSwitches - every change of role the agent has made
+//LookbackTicks - a speculator's lookbackTicks
+//TargetSpreadPct - a market maker's targetSpreadPct
+//Skill - the agent's skill at each of its job's methods, by method index
+//Research - what the agent has invested in each of its job's research methods, by
+//research index (unlocked methods are unlocked again from it)
+//WorkQueue - the production the agent had started but not finished
+//CyclesToCover, HeldLastTick, InformationLevel - as in traderAgent
+//Penalized - how many ticks in a row the agent has been fined for idling
+//Revenue, Cost, Penalties - the agent's Revenue, Cost and ProductionPenalties
+//Inventory - how many units of each commodity the agent holds, by name
+//PriceBelief - the agent's belief of each commodity's price, by name
+//Asks, Bids - the offers the agent had made for the next tick
+//Dead - whether the agent had died instead of making any
+//Random - how far the agent's own source of randomness had got
+type agentCheckpoint struct {
+	ID                uint64                      `json:"id"`
+	Slot              uint64                      `json:"slot"`
+	Role              string                      `json:"role"`
+	Funds             float64                     `json:"funds"`
+	RiskAversion      int                         `json:"riskAversion"`
+	CohortID          uint32                      `json:"cohortID"`
+	LifetimeAskVolume int                         `json:"lifetimeAskVolume"`
+	LifetimeBidVolume int                         `json:"lifetimeBidVolume"`
+	BeliefAdjustBig   float64                     `json:"beliefAdjustBig"`
+	BeliefAdjustSmall float64                     `json:"beliefAdjustSmall"`
+	Age               int                         `json:"age"`
+	MaxAge            int                         `json:"maxAge"`
+	StartingFunds     float64                     `json:"startingFunds"`
+	RoleSwitches      []RoleSwitch                `json:"roleSwitches"`
+	LookbackTicks     int                         `json:"lookbackTicks"`
+	TargetSpreadPct   float64                     `json:"targetSpreadPct"`
+	Skill             map[int]float64             `json:"skill"`
+	Research          map[int]float64             `json:"research"`
+	WorkQueue         []workCheckpoint            `json:"workQueue"`
+	CyclesToCover     int                         `json:"cyclesToCover"`
+	HeldLastTick      int                         `json:"heldLastTick"`
+	InformationLevel  float64                     `json:"informationLevel"`
+	Penalized         int                         `json:"consecutivePenalties"`
+	Revenue           float64                     `json:"revenue"`
+	Cost              float64                     `json:"cost"`
+	Penalties         float64                     `json:"productionPenalties"`
+	Inventory         map[string]int              `json:"inventory"`
+	PriceBelief       map[string]beliefCheckpoint `json:"priceBelief"`
+	Asks              []offerCheckpoint           `json:"asks"`
+	Bids              []offerCheckpoint           `json:"bids"`
+	Dead              bool                        `json:"dead"`
+	Random            randomState                 `json:"random"`
+}
+
+//A beliefCheckpoint is a saved priceRange.
+type beliefCheckpoint struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+//A workCheckpoint is saved unfinished production.
+//Method - the index of the method in the agent's job
+//Remaining - how many more ticks until it is done
+//Outputs - what it will make, by commodity name
+type workCheckpoint struct {
+	Method    int            `json:"method"`
+	Remaining int            `json:"remaining"`
+	Outputs   map[string]int `json:"outputs"`
+}
+
+//An offerCheckpoint is a saved ask or bid.
+//Commodity - the name of the commodity offered
+//Quantity - the units in each lot
+//Price - the price asked or bid
+//Offered - how many lots
+//DeliveryTick - the tick a futures offer delivers on (0 is spot)
+//MarketOrder - whether the offer takes whatever price it meets
+type offerCheckpoint struct {
+	Commodity    string  `json:"commodity"`
+	Quantity     int     `json:"quantity"`
+	Price        float64 `json:"price"`
+	Offered      int     `json:"offered"`
+	DeliveryTick int     `json:"deliveryTick"`
+	MarketOrder  bool    `json:"marketOrder"`
+}
+
+//A contractCheckpoint is a saved FuturesContract, with its commodity by name.
+type contractCheckpoint struct {
+	Commodity    string  `json:"commodity"`
+	DeliveryTick int     `json:"deliveryTick"`
+	Quantity     int     `json:"quantity"`
+	Price        float64 `json:"price"`
+	BuyerID      uint64  `json:"buyerID"`
+	SellerID     uint64  `json:"sellerID"`
+}
+
+//A barterCheckpoint is a saved BarterOffer, with its goods by commodity name.
+type barterCheckpoint struct {
+	Give       []QuantitySpec `json:"give"`
+	Receive    []QuantitySpec `json:"receive"`
+	OfferorID  uint64         `json:"offerorID"`
+	ExpiryTick int            `json:"expiryTick"`
+}
+
+//quantitySpecs describes commoditySets by commodity name.
+func quantitySpecs(sets []commoditySet) []QuantitySpec {
+	var specs []QuantitySpec
+	for _, set := range sets {
+		specs = append(specs, QuantitySpec{Commodity: set.item.name, Quantity: set.quantity})
+	}
+	return specs
+}
+
+//A cohortCheckpoint is a saved cohortRecord.
+type cohortCheckpoint struct {
+	ID             uint32  `json:"id"`
+	Spawned        int     `json:"spawned"`
+	Departed       int     `json:"departed"`
+	DepartedFunds  float64 `json:"departedFunds"`
+	DepartedAge    float64 `json:"departedAge"`
+	DepartedVolume float64 `json:"departedVolume"`
+}
+
+//SaveCheckpoint writes a simulation to a file, to be carried on later with
+//LoadCheckpoint.  Only a simulation set up from an economy (see LoadSimConfig) and
+//run with RunTicks can be saved, and it must not be ticking while it is.  Saving
+//waits for every agent to make its offers for the next tick, and those are saved
+//too, so the loaded simulation picks up exactly where this one is.
+func SaveCheckpoint(path string, sim *Simulation) error {
+	m := sim.Market
+	if sim.Economy == nil {
+		return errors.New("only a simulation set up from an economy can be saved")
+	}
+	if !m.synchronous {
+		return errors.New("only a simulation run with RunTicks can be saved")
+	}
+	m.holdOffers()
+
+	var cp checkpoint
+	cp.Economy = *sim.Economy
+	cp.Config = m.config
+	cp.Config.SpoilageHandlers, cp.Config.Oracle, cp.Config.InitHooks = nil, nil, nil
+	cp.Config.ProductionSelector, cp.Config.Resurrection, cp.Config.Tracer = nil, nil, nil
+	cp.PricingRule = m.PricingRule
+	cp.Tick = m.tick
+	cp.FeePool = m.FeePool
+	cp.Slots = len(m.askChannels)
+	for _, com := range m.sortedCommodities() {
+		cp.Commodities = append(cp.Commodities, commodityCheckpoint{
+			Name:          com.name,
+			AveragePrice:  com.averagePrice,
+			PriceHistory:  com.PriceHistory(m.config.PriceHistoryCapacity),
+			VolumeHistory: com.VolumeHistory(m.config.PriceHistoryCapacity),
+		})
+	}
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		saved := agentCheckpoint{
+			ID:                agent.id,
+			Slot:              slot,
+			Role:              agent.role,
+			Funds:             agent.funds,
+			RiskAversion:      agent.riskAversion,
+			CohortID:          agent.CohortID,
+			LifetimeAskVolume: agent.lifetimeAskVolume,
+			LifetimeBidVolume: agent.lifetimeBidVolume,
+			BeliefAdjustBig:   agent.beliefAdjustBig,
+			BeliefAdjustSmall: agent.beliefAdjustSmall,
+			Age:               agent.age,
+			MaxAge:            agent.maxAge,
+			StartingFunds:     agent.startingFunds,
+			RoleSwitches:      append([]RoleSwitch(nil), agent.RoleSwitches...),
+			LookbackTicks:     agent.lookbackTicks,
+			TargetSpreadPct:   agent.targetSpreadPct,
+			CyclesToCover:     agent.cyclesToCover,
+			HeldLastTick:      agent.heldLastTick,
+			InformationLevel:  agent.InformationLevel,
+			Penalized:         agent.consecutivePenalties,
+			Revenue:           agent.Revenue,
+			Cost:              agent.Cost,
+			Penalties:         agent.ProductionPenalties,
+			Inventory:         make(map[string]int),
+			PriceBelief:       make(map[string]beliefCheckpoint),
+		}
+		if agent.rng != nil {
+			saved.Random = agent.rng.state()
+		}
+		for com, num := range agent.inventory {
+			saved.Inventory[com.name] = num
+		}
+		for com, belief := range agent.priceBelief {
+			saved.PriceBelief[com.name] = beliefCheckpoint{Low: belief.low, High: belief.high}
+		}
+		if agent.job != nil && len(agent.research) > 0 {
+			saved.Research = make(map[int]float64)
+			for index, method := range agent.job.research {
+				if invested, ok := agent.research[method]; ok {
+					saved.Research[index] = invested
+				}
+			}
+		}
+		if agent.job != nil && len(agent.skill) > 0 {
+			saved.Skill = make(map[int]float64)
+			for index, method := range agent.job.methods {
+				if skill, ok := agent.skill[method]; ok {
+					saved.Skill[index] = skill
+				}
+			}
+		}
+		for _, work := range agent.workQueue {
+			savedWork := workCheckpoint{Method: -1, Remaining: work.remaining, Outputs: make(map[string]int)}
+			if agent.job != nil {
+				for index, method := range agent.job.methods {
+					if method == work.method {
+						savedWork.Method = index
+					}
+				}
+			}
+			for _, output := range work.outputs {
+				savedWork.Outputs[output.item.name] += output.quantity
+			}
+			saved.WorkQueue = append(saved.WorkQueue, savedWork)
+		}
+		agent.mu.Unlock()
+		offers := m.held[slot].offers
+		saved.Dead = offers.dead
+		for _, askSet := range offers.asks {
+			saved.Asks = append(saved.Asks, offerCheckpoint{
+				Commodity:    askSet.offeredAsk.item.name,
+				Quantity:     askSet.offeredAsk.quantity,
+				Price:        askSet.offeredAsk.sellFor,
+				Offered:      askSet.numberOffered,
+				DeliveryTick: askSet.offeredAsk.deliveryTick,
+				MarketOrder:  askSet.offeredAsk.marketOrder,
+			})
+		}
+		for _, bidSet := range offers.bids {
+			saved.Bids = append(saved.Bids, offerCheckpoint{
+				Commodity:    bidSet.offeredBid.item.name,
+				Quantity:     bidSet.offeredBid.quantity,
+				Price:        bidSet.offeredBid.buyFor,
+				Offered:      bidSet.numberOffered,
+				DeliveryTick: bidSet.offeredBid.deliveryTick,
+				MarketOrder:  bidSet.offeredBid.marketOrder,
+			})
+		}
+		cp.Agents = append(cp.Agents, saved)
+	}
+	for cohortID, record := range m.cohorts {
+		cp.Cohorts = append(cp.Cohorts, cohortCheckpoint{
+			ID:             cohortID,
+			Spawned:        record.spawned,
+			Departed:       record.departed,
+			DepartedFunds:  record.departedFunds,
+			DepartedAge:    record.departedAge,
+			DepartedVolume: record.departedVolume,
+		})
+	}
+	for _, contract := range m.futures.contracts {
+		cp.Contracts = append(cp.Contracts, contractCheckpoint{
+			Commodity:    contract.commodity.name,
+			DeliveryTick: contract.deliveryTick,
+			Quantity:     contract.quantity,
+			Price:        contract.price,
+			BuyerID:      contract.buyerID,
+			SellerID:     contract.sellerID,
+		})
+	}
+
+	for _, offer := range m.barter.offers {
+		cp.Barters = append(cp.Barters, barterCheckpoint{
+			Give:       quantitySpecs(offer.give),
+			Receive:    quantitySpecs(offer.receive),
+			OfferorID:  offer.offerorID,
+			ExpiryTick: offer.expiryTick,
+		})
+	}
+	cp.Random = m.config.random().state()
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+//LoadCheckpoint rebuilds a simulation saved by SaveCheckpoint: the market gets
+//its slots and configuration back, and every agent is relaunched in its old slot,
+//ready to send the offers it had made.  The loaded simulation runs with RunTicks.
+//The market and every agent draw on from where their randomness had got to, so
+//under a seed the loaded simulation runs just as the saved one would have.
+//path - the file the simulation was saved to
+//config - supplies the SpoilageHandlers, Oracle, InitHooks, ProductionSelector,
+//Resurrection and Tracer the saved simulation ran with, which a checkpoint can't
+//hold; everything else it configures is taken from the checkpoint
+func LoadCheckpoint(path string, config SimulationConfig) (*Simulation, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	economy := &cp.Economy
+	if err := economy.build(); err != nil {
+		return nil, err
+	}
+	commodityList := economy.CommodityList()
+	addPermits(commodityList)
+	cp.Config.SpoilageHandlers, cp.Config.Oracle, cp.Config.InitHooks = config.SpoilageHandlers, config.Oracle, config.InitHooks
+	cp.Config.ProductionSelector, cp.Config.Resurrection, cp.Config.Tracer = config.ProductionSelector, config.Resurrection, config.Tracer
+	m := newMarket(commodityList, cp.Config)
+	m.synchronous = true
+	m.PricingRule = cp.PricingRule
+	economy.addRoles(m)
+	m.tick = cp.Tick
+	m.FeePool = cp.FeePool
+
+	for _, saved := range cp.Commodities {
+		com, ok := commodityList[saved.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown commodity %v", saved.Name)
+		}
+		com.averagePrice = saved.AveragePrice
+		for _, price := range saved.PriceHistory {
+			com.priceRing.push(price)
+		}
+		for _, volume := range saved.VolumeHistory {
+			m.recordVolume(com, volume)
+		}
+	}
+
+	m.askChannels = make([]chan []asks, cp.Slots)
+	m.bidChannels = make([]chan []bids, cp.Slots)
+	m.deadChannels = make([]chan traderAgent, cp.Slots)
+	for _, saved := range cp.Agents {
+		factory, ok := m.roles[saved.Role]
+		if !ok && saved.Role == speculatorRole {
+			lookbackTicks := saved.LookbackTicks
+			factory, ok = func() traderAgent { return makeSpeculator(commodityList, lookbackTicks, m.config) }, true
+		}
+		if !ok && saved.Role == marketMakerRole {
+			targetSpreadPct := saved.TargetSpreadPct
+			factory, ok = func() traderAgent { return makeMarketMaker(commodityList, targetSpreadPct, m.config) }, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("agent %v has unknown role %v", saved.ID, saved.Role)
+		}
+		if saved.Slot >= uint64(cp.Slots) {
+			return nil, fmt.Errorf("agent %v is outside the market's %v slots", saved.ID, cp.Slots)
+		}
+		agent := factory()
+		agent.id = saved.ID
+		reserveAgentID(saved.ID)
+		agent.funds = saved.Funds
+		agent.riskAversion = saved.RiskAversion
+		agent.lifetimeAskVolume = saved.LifetimeAskVolume
+		agent.lifetimeBidVolume = saved.LifetimeBidVolume
+		agent.beliefAdjustBig = saved.BeliefAdjustBig
+		agent.beliefAdjustSmall = saved.BeliefAdjustSmall
+		agent.age, agent.maxAge = saved.Age, saved.MaxAge
+		agent.startingFunds = saved.StartingFunds
+		agent.RoleSwitches = saved.RoleSwitches
+		agent.cyclesToCover, agent.heldLastTick = saved.CyclesToCover, saved.HeldLastTick
+		agent.InformationLevel = saved.InformationLevel
+		agent.consecutivePenalties = saved.Penalized
+		agent.Revenue, agent.Cost, agent.ProductionPenalties = saved.Revenue, saved.Cost, saved.Penalties
+		for index, invested := range saved.Research {
+			if agent.job == nil || index < 0 || index >= len(agent.job.research) {
+				return nil, fmt.Errorf("agent %v researched a method its role doesn't have", saved.ID)
+			}
+			if agent.research == nil {
+				agent.research = make(map[*productionMethod]float64)
+			}
+			agent.research[agent.job.research[index]] = invested
+		}
+		if len(agent.research) > 0 {
+			unlockResearched(&agent)
+		}
+		for index, skill := range saved.Skill {
+			if agent.job == nil || index < 0 || index >= len(agent.job.methods) {
+				return nil, fmt.Errorf("agent %v is skilled at a method its role doesn't have", saved.ID)
+			}
+			if agent.skill == nil {
+				agent.skill = make(map[*productionMethod]float64)
+			}
+			agent.skill[agent.job.methods[index]] = skill
+		}
+		for _, savedWork := range saved.WorkQueue {
+			work := pendingWork{remaining: savedWork.Remaining}
+			if agent.job != nil && savedWork.Method >= 0 && savedWork.Method < len(agent.job.methods) {
+				work.method = agent.job.methods[savedWork.Method]
+			}
+			for _, name := range sortedKeys(savedWork.Outputs) {
+				com, ok := commodityList[name]
+				if !ok {
+					return nil, fmt.Errorf("agent %v is making unknown commodity %v", saved.ID, name)
+				}
+				work.outputs = append(work.outputs, commoditySet{item: com, quantity: savedWork.Outputs[name]})
+			}
+			agent.workQueue = append(agent.workQueue, work)
+		}
+		agent.inventory = make(map[*commodity]int)
+		for name, num := range saved.Inventory {
+			com, ok := commodityList[name]
+			if !ok {
+				return nil, fmt.Errorf("agent %v holds unknown commodity %v", saved.ID, name)
+			}
+			agent.inventory[com] = num
+		}
+		agent.priceBelief = make(map[*commodity]priceRange)
+		for name, belief := range saved.PriceBelief {
+			com, ok := commodityList[name]
+			if !ok {
+				return nil, fmt.Errorf("agent %v believes in unknown commodity %v", saved.ID, name)
+			}
+			agent.priceBelief[com] = priceRange{low: belief.Low, high: belief.High}
+		}
+		agent.resume = new(pendingOffers)
+		agent.resume.dead = saved.Dead
+		for _, offer := range saved.Asks {
+			com, ok := commodityList[offer.Commodity]
+			if !ok {
+				return nil, fmt.Errorf("agent %v asks for unknown commodity %v", saved.ID, offer.Commodity)
+			}
+			var askSet asks
+			askSet.offeredAsk = ask{item: com, quantity: offer.Quantity, sellFor: offer.Price, deliveryTick: offer.DeliveryTick, marketOrder: offer.MarketOrder}
+			askSet.numberOffered = offer.Offered
+			agent.resume.asks = append(agent.resume.asks, askSet)
+		}
+		for _, offer := range saved.Bids {
+			com, ok := commodityList[offer.Commodity]
+			if !ok {
+				return nil, fmt.Errorf("agent %v bids for unknown commodity %v", saved.ID, offer.Commodity)
+			}
+			var bidSet bids
+			bidSet.offeredBid = bid{item: com, quantity: offer.Quantity, buyFor: offer.Price, deliveryTick: offer.DeliveryTick, marketOrder: offer.MarketOrder}
+			bidSet.numberOffered = offer.Offered
+			agent.resume.bids = append(agent.resume.bids, bidSet)
+		}
+		m.respawnAgent(saved.Slot, agent)
+		running := m.agents[saved.Slot]
+		running.mu.Lock()
+		running.CohortID = saved.CohortID
+		if saved.Random.Seed != 0 {
+			running.rng = restoreRandom(saved.Random)
+		}
+		running.mu.Unlock()
+	}
+
+	//Launching the agents drew on the market's randomness - pick it up from where it
+	//was saved.
+	if cp.Random.Seed != 0 {
+		m.config.rng = restoreRandom(cp.Random)
+	}
+	//Launching the agents put them all in this tick's cohort - put the cohorts back
+	//as they were.
+	m.cohorts = make(map[uint32]*cohortRecord)
+	for _, saved := range cp.Cohorts {
+		m.cohorts[saved.ID] = &cohortRecord{
+			spawned:        saved.Spawned,
+			departed:       saved.Departed,
+			departedFunds:  saved.DepartedFunds,
+			departedAge:    saved.DepartedAge,
+			departedVolume: saved.DepartedVolume,
+		}
+	}
+	for _, saved := range cp.Contracts {
+		com, ok := commodityList[saved.Commodity]
+		if !ok {
+			return nil, fmt.Errorf("a futures contract is for unknown commodity %v", saved.Commodity)
+		}
+		m.futures.contracts = append(m.futures.contracts, FuturesContract{
+			commodity:    com,
+			deliveryTick: saved.DeliveryTick,
+			quantity:     saved.Quantity,
+			price:        saved.Price,
+			buyerID:      saved.BuyerID,
+			sellerID:     saved.SellerID,
+		})
+	}
+
+	for _, saved := range cp.Barters {
+		give, err := economy.buildSets(saved.Give)
+		if err != nil {
+			return nil, fmt.Errorf("a barter offer gives an %v", err)
+		}
+		receive, err := economy.buildSets(saved.Receive)
+		if err != nil {
+			return nil, fmt.Errorf("a barter offer asks for an %v", err)
+		}
+		m.barter.PostOffer(BarterOffer{give: give, receive: receive, offerorID: saved.OfferorID, expiryTick: saved.ExpiryTick})
+	}
+
+	sim := newSimulation(m)
+	sim.Economy = economy
+	return sim, nil
+}
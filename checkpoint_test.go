@@ -0,0 +1,67 @@
+// GoEconGo project checkpoint_test.go
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointResumesRun(t *testing.T) {
+	tuned := testConfig()
+	tuned.PriceSmoothing = 0.9
+	tuned.TransactionFeePercent = 0.2
+	tuned.AllowShortSelling = true
+	tuned.PricingRule = WeightedMidpoint
+	tuned.MidpointWeight = 0.3
+	tests := []struct {
+		name   string
+		config SimulationConfig
+	}{
+		{"default config", testConfig()},
+		{"tuned config", tuned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//seeded sets up the test economy under seed 1.
+			seeded := func() *Simulation {
+				economy := testSimConfig(t, 10)
+				economy.Seed = 1
+				return simulateEconomy(t, tt.config, economy)
+			}
+			const before, after = 12, 30
+			whole := seeded()
+			RunTicks(before+after, whole)
+
+			saved := seeded()
+			RunTicks(before, saved)
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+			if err := SaveCheckpoint(path, saved); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadCheckpoint(path, testConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { loaded.Market.Shutdown() })
+			if fee := loaded.Market.config.TransactionFeePercent; fee != tt.config.TransactionFeePercent {
+				t.Errorf("loaded run takes a %v%% fee, want %v%%", fee, tt.config.TransactionFeePercent)
+			}
+			if rule := loaded.Market.PricingRule; rule != tt.config.PricingRule {
+				t.Errorf("loaded run prices by rule %v, want %v", rule, tt.config.PricingRule)
+			}
+			if tick := RunTicks(after, loaded); tick != before+after {
+				t.Fatalf("loaded run reached tick %v, want %v", tick, before+after)
+			}
+			for name, com := range whole.Market.commodities {
+				want := com.PriceHistory(after)
+				got := loaded.Market.commodities[name].PriceHistory(after)
+				for tick := range want {
+					if got[tick] != want[tick] {
+						t.Errorf("%v closed at %v on tick %v of the loaded run, want %v", name, got[tick], before+tick+1, want[tick])
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// GoEconGo project circuit.go
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//A CircuitBreakEvent records a commodity's averagePrice being held where it was
+//because a tick's trading would have moved it too far.
+type CircuitBreakEvent struct {
+	CommodityName string
+	Tick          int
+	Price         float64
+	Rejected      float64
+}
+
+func (e CircuitBreakEvent) String() string {
+	return fmt.Sprintf("Circuit breaker on %v at tick %v! Held %v instead of %v", e.CommodityName, e.Tick, e.Price, e.Rejected)
+}
+
+//tripsCircuit reports whether moving a commodity's averagePrice to newPrice
+//would change it by more than its MaxTickChangePct.  If it would, the break is
+//logged on the commodity and raised on the market.
+func (m *Market) tripsCircuit(com *commodity, newPrice float64) bool {
+	if com.MaxTickChangePct <= 0 || com.averagePrice <= 0 {
+		return false
+	}
+	if math.Abs(newPrice-com.averagePrice)/com.averagePrice <= com.MaxTickChangePct {
+		return false
+	}
+	event := CircuitBreakEvent{CommodityName: com.name, Tick: m.tick, Price: com.averagePrice, Rejected: newPrice}
+	com.eventLog = append(com.eventLog, event)
+	m.Emit(event)
+	return true
+}
+
+//EventLog returns everything that has happened to the commodity, oldest first.
+func (com *commodity) EventLog() []MarketEvent {
+	return com.eventLog
+}
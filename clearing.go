@@ -0,0 +1,50 @@
+// GoEconGo project clearing.go
+package main
+
+//splitAsk splits a partly filled ask in two, so each part can be told its own
+//result: the filled part, marked complete, stays at index and the unfilled
+//remainder follows it as a new ask with nothing accepted.  It returns a new slice
+//and leaves asksCom and its asks as they were.  An ask filled completely - or not
+//at all - is left whole.
+//asksCom - one commodity's asks
+//index - the index of the ask to split
+func splitAsk(asksCom []*asks, index int) []*asks {
+	split := make([]*asks, 0, len(asksCom)+1)
+	split = append(split, asksCom[:index]...)
+	current := asksCom[index]
+	if current.numberAccepted <= 0 || current.numberAccepted >= current.numberOffered {
+		split = append(split, current)
+		return append(split, asksCom[index+1:]...)
+	}
+	filled := *current
+	filled.numberOffered = current.numberAccepted
+	remainder := *current
+	remainder.numberAccepted = 0
+	remainder.numberOffered = current.numberOffered - current.numberAccepted
+	split = append(split, &filled, &remainder)
+	return append(split, asksCom[index+1:]...)
+}
+
+//splitBid splits a partly filled bid in two, so each part can be told its own
+//result: the filled part, marked complete, stays at index and the unfilled
+//remainder follows it as a new bid with nothing accepted.  It returns a new slice
+//and leaves bidsCom and its bids as they were.  A bid filled completely - or not
+//at all - is left whole.
+//bidsCom - one commodity's bids
+//index - the index of the bid to split
+func splitBid(bidsCom []*bids, index int) []*bids {
+	split := make([]*bids, 0, len(bidsCom)+1)
+	split = append(split, bidsCom[:index]...)
+	current := bidsCom[index]
+	if current.numberAccepted <= 0 || current.numberAccepted >= current.numberOffered {
+		split = append(split, current)
+		return append(split, bidsCom[index+1:]...)
+	}
+	filled := *current
+	filled.numberOffered = current.numberAccepted
+	remainder := *current
+	remainder.numberAccepted = 0
+	remainder.numberOffered = current.numberOffered - current.numberAccepted
+	split = append(split, &filled, &remainder)
+	return append(split, bidsCom[index+1:]...)
+}
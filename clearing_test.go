@@ -0,0 +1,120 @@
+// GoEconGo project clearing_test.go
+package main
+
+import "testing"
+
+//splitTests are offers, some filled, and the parts splitting the one at index
+//should leave there: how many units each part offers and had accepted.
+var splitTests = []struct {
+	name     string
+	offered  []int
+	accepted []int
+	index    int
+	parts    [][2]int
+}{
+	{"full fill", []int{5}, []int{5}, 0, [][2]int{{5, 5}}},
+	{"no fill", []int{5}, []int{0}, 0, [][2]int{{5, 0}}},
+	{"partial fill", []int{10}, []int{4}, 0, [][2]int{{4, 4}, {6, 0}}},
+	{"partial fill between others", []int{3, 10, 2}, []int{3, 7, 0}, 1, [][2]int{{7, 7}, {3, 0}}},
+	{"single unit filled", []int{1}, []int{1}, 0, [][2]int{{1, 1}}},
+	{"single unit unfilled", []int{1}, []int{0}, 0, [][2]int{{1, 0}}},
+}
+
+func TestSplitAsk(t *testing.T) {
+	for _, test := range splitTests {
+		var asksCom []*asks
+		for i := range test.offered {
+			asksCom = append(asksCom, &asks{offeredAsk: ask{id: uint64(i + 1), sellFor: 2}, numberOffered: test.offered[i], numberAccepted: test.accepted[i]})
+		}
+		split := splitAsk(asksCom, test.index)
+		if len(split) != len(asksCom)+len(test.parts)-1 {
+			t.Errorf("%v: split into %v asks, want %v", test.name, len(split), len(asksCom)+len(test.parts)-1)
+			continue
+		}
+		for i, part := range test.parts {
+			got := split[test.index+i]
+			if got.numberOffered != part[0] || got.numberAccepted != part[1] || got.offeredAsk != asksCom[test.index].offeredAsk {
+				t.Errorf("%v: part %v offers %v with %v accepted, want %v with %v", test.name, i, got.numberOffered, got.numberAccepted, part[0], part[1])
+			}
+		}
+		//Everything else is left where it was, and nothing is changed in place.
+		for i := range asksCom {
+			at := i
+			if i > test.index {
+				at = i + len(test.parts) - 1
+			}
+			if i != test.index && split[at] != asksCom[i] {
+				t.Errorf("%v: ask %v moved", test.name, i)
+			}
+			if asksCom[i].numberOffered != test.offered[i] || asksCom[i].numberAccepted != test.accepted[i] {
+				t.Errorf("%v: ask %v changed in place", test.name, i)
+			}
+		}
+	}
+}
+
+func TestSplitBid(t *testing.T) {
+	for _, test := range splitTests {
+		var bidsCom []*bids
+		for i := range test.offered {
+			bidsCom = append(bidsCom, &bids{offeredBid: bid{id: uint64(i + 1), buyFor: 4}, numberOffered: test.offered[i], numberAccepted: test.accepted[i]})
+		}
+		split := splitBid(bidsCom, test.index)
+		if len(split) != len(bidsCom)+len(test.parts)-1 {
+			t.Errorf("%v: split into %v bids, want %v", test.name, len(split), len(bidsCom)+len(test.parts)-1)
+			continue
+		}
+		for i, part := range test.parts {
+			got := split[test.index+i]
+			if got.numberOffered != part[0] || got.numberAccepted != part[1] || got.offeredBid != bidsCom[test.index].offeredBid {
+				t.Errorf("%v: part %v offers %v with %v accepted, want %v with %v", test.name, i, got.numberOffered, got.numberAccepted, part[0], part[1])
+			}
+		}
+		for i := range bidsCom {
+			at := i
+			if i > test.index {
+				at = i + len(test.parts) - 1
+			}
+			if i != test.index && split[at] != bidsCom[i] {
+				t.Errorf("%v: bid %v moved", test.name, i)
+			}
+			if bidsCom[i].numberOffered != test.offered[i] || bidsCom[i].numberAccepted != test.accepted[i] {
+				t.Errorf("%v: bid %v changed in place", test.name, i)
+			}
+		}
+	}
+}
+
+func TestClearPartialFills(t *testing.T) {
+	tests := []struct {
+		name       string
+		asked, bid int
+		asks, bids [][2]int
+	}{
+		{"full fill", 5, 5, [][2]int{{5, 5}}, [][2]int{{5, 5}}},
+		{"asks > bids", 10, 4, [][2]int{{4, 4}, {6, 0}}, [][2]int{{4, 4}}},
+		{"bids > asks", 3, 10, [][2]int{{3, 3}}, [][2]int{{3, 3}, {7, 0}}},
+		{"single unit", 1, 1, [][2]int{{1, 1}}, [][2]int{{1, 1}}},
+	}
+	for _, test := range tests {
+		food := &commodity{name: "Food", averagePrice: 3}
+		m := newBookMarket(testConfig(), food,
+			[]*asks{{offeredAsk: ask{id: 1, item: food, quantity: 1, sellFor: 2}, numberOffered: test.asked}},
+			[]*bids{{offeredBid: bid{id: 2, item: food, quantity: 1, buyFor: 4}, numberOffered: test.bid}})
+		m.clear(food)
+		if len(m.asksTyped[food]) != len(test.asks) || len(m.bidsTyped[food]) != len(test.bids) {
+			t.Errorf("%v: cleared into %v asks and %v bids, want %v and %v", test.name, len(m.asksTyped[food]), len(m.bidsTyped[food]), len(test.asks), len(test.bids))
+			continue
+		}
+		for i, part := range test.asks {
+			if got := m.asksTyped[food][i]; got.numberOffered != part[0] || got.numberAccepted != part[1] {
+				t.Errorf("%v: ask %v offers %v with %v accepted, want %v with %v", test.name, i, got.numberOffered, got.numberAccepted, part[0], part[1])
+			}
+		}
+		for i, part := range test.bids {
+			if got := m.bidsTyped[food][i]; got.numberOffered != part[0] || got.numberAccepted != part[1] {
+				t.Errorf("%v: bid %v offers %v with %v accepted, want %v with %v", test.name, i, got.numberOffered, got.numberAccepted, part[0], part[1])
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// GoEconGo project cohort.go
+package main
+
+//CohortStats measures how a cohort - the agents spawned on the same tick - has
+//fared.  Agents that have died or left the market count with the funds,
+//age and volume they had when they went.
+//MeanFunds - the cohort's average funds
+//MeanAge - the cohort's average age, in ticks
+//SurvivalRate - the share [0.0,1.0] of the cohort still running
+//MeanLifetimeVolume - the cohort's average units bought and sold
+type CohortStats struct {
+	MeanFunds          float64
+	MeanAge            float64
+	SurvivalRate       float64
+	MeanLifetimeVolume float64
+}
+
+//A cohortRecord keeps what the market remembers of a cohort's departed agents.
+//spawned - how many agents the cohort started with
+//departed - how many of them are no longer running
+//departedFunds, departedAge, departedVolume - totals of the departed agents' funds,
+//ages and lifetime volumes when they went
+type cohortRecord struct {
+	spawned        int
+	departed       int
+	departedFunds  float64
+	departedAge    float64
+	departedVolume float64
+}
+
+//joinCohort puts a newly launched agent in the cohort of the current tick.
+func (m *Market) joinCohort(agent *traderAgent) {
+	agent.CohortID = uint32(m.tick)
+	record, ok := m.cohorts[agent.CohortID]
+	if !ok {
+		record = new(cohortRecord)
+		m.cohorts[agent.CohortID] = record
+	}
+	record.spawned++
+}
+
+//leaveCohort records an agent leaving the market in its cohort.
+func (m *Market) leaveCohort(agent *traderAgent) {
+	record, ok := m.cohorts[agent.CohortID]
+	if !ok {
+		return
+	}
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	record.departed++
+	record.departedFunds = record.departedFunds + agent.funds
+	record.departedAge = record.departedAge + float64(m.tick-int(agent.CohortID))
+	record.departedVolume = record.departedVolume + float64(agent.lifetimeAskVolume+agent.lifetimeBidVolume)
+}
+
+//CohortStats measures the cohort spawned on a tick.  A cohort nobody was spawned
+//in measures all zeroes.
+func (m *Market) CohortStats(cohortID uint32) CohortStats {
+	var stats CohortStats
+	record, ok := m.cohorts[cohortID]
+	if !ok || record.spawned == 0 {
+		return stats
+	}
+	funds, age, volume := record.departedFunds, record.departedAge, record.departedVolume
+	for _, agent := range m.snapshotAgents() {
+		if agent.CohortID != cohortID {
+			continue
+		}
+		funds = funds + agent.funds
+		age = age + float64(m.tick-int(cohortID))
+		volume = volume + float64(agent.lifetimeAskVolume+agent.lifetimeBidVolume)
+	}
+	members := float64(record.spawned)
+	stats.MeanFunds = funds / members
+	stats.MeanAge = age / members
+	stats.SurvivalRate = float64(record.spawned-record.departed) / members
+	stats.MeanLifetimeVolume = volume / members
+	return stats
+}
@@ -0,0 +1,42 @@
+// GoEconGo project cohort_test.go
+package main
+
+import "testing"
+
+func TestCohortStats(t *testing.T) {
+	sim := newTestSimulation(t, testConfig(), 5)
+	m := sim.Market
+	RunTicks(10, sim)
+	late := uint32(m.tick)
+	for i := 0; i < 5; i++ {
+		m.spawn("Farmer")
+	}
+	RunTicks(10, sim)
+	//One of the late farmers leaves, and the cohort remembers what it had.
+	var leaving *traderAgent
+	for _, agent := range agentsOf(m, "Farmer") {
+		if agent.CohortID == late {
+			leaving = agent
+		}
+	}
+	if err := m.KillAgent(leaving.id); err != nil {
+		t.Fatal(err)
+	}
+
+	early, later := m.CohortStats(0), m.CohortStats(late)
+	if early.MeanAge != 20 || later.MeanAge != 10 {
+		t.Errorf("cohorts spawned 20 and 10 ticks ago are on average %v and %v ticks old", early.MeanAge, later.MeanAge)
+	}
+	if early.SurvivalRate != 1 || later.SurvivalRate != 0.8 {
+		t.Errorf("%v of the first cohort and %v of the later one survive, want all and 4 of 5", early.SurvivalRate, later.SurvivalRate)
+	}
+	if early.MeanLifetimeVolume <= later.MeanLifetimeVolume {
+		t.Errorf("the first cohort has traded %v each, want more than the %v of the cohort half its age", early.MeanLifetimeVolume, later.MeanLifetimeVolume)
+	}
+	if later.MeanFunds <= 0 {
+		t.Errorf("the later cohort has %v each", later.MeanFunds)
+	}
+	if none := m.CohortStats(1000); none != (CohortStats{}) {
+		t.Errorf("a cohort nobody was spawned in measures %+v", none)
+	}
+}
@@ -0,0 +1,264 @@
+// GoEconGo project competition.go
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+//An OligopolyAlert is raised when a handful of agents control the supply of a
+//commodity.
+type OligopolyAlert struct {
+	CommodityName string
+	Tick          int
+	AgentIDs      []uint64
+}
+
+func (e OligopolyAlert) String() string {
+	return fmt.Sprintf("Oligopoly on %v at tick %v! Controlled by %v agents: %v", e.CommodityName, e.Tick, len(e.AgentIDs), e.AgentIDs)
+}
+
+//askVolumeByAgent totals up the units each agent has on offer in this tick's ask
+//book for a commodity, along with the total on offer.
+func (m *Market) askVolumeByAgent(com *commodity) (map[uint64]int, int) {
+	volumes := make(map[uint64]int)
+	total := 0
+	for _, askSet := range m.asksTyped[com] {
+		volumes[askSet.offeredAsk.id] += askSet.numberOffered
+		total += askSet.numberOffered
+	}
+	return volumes, total
+}
+
+//DetectOligopoly checks whether fewer than agentCountThreshold agents supply more
+//than shareThreshold of this tick's ask volume for a commodity.  If they do, it
+//returns true along with the controlling agents' IDs, biggest first (the lowest id
+//first among equals), and raises an OligopolyAlert.
+//com - a pointer to the commodity to check
+//agentCountThreshold - how few agents count as an oligopoly
+//shareThreshold - the fraction [0.0,1.0] of ask volume those agents must control
+func (m *Market) DetectOligopoly(com *commodity, agentCountThreshold int, shareThreshold float64) (bool, []uint64) {
+	volumes, total := m.askVolumeByAgent(com)
+	if total == 0 {
+		return false, nil
+	}
+	sellers := make([]uint64, 0, len(volumes))
+	for id := range volumes {
+		sellers = append(sellers, id)
+	}
+	sort.Slice(sellers, func(i, j int) bool {
+		if volumes[sellers[i]] != volumes[sellers[j]] {
+			return volumes[sellers[i]] > volumes[sellers[j]]
+		}
+		return sellers[i] < sellers[j]
+	})
+	//Add up the biggest sellers until they pass the share threshold.
+	controlled := 0
+	for count, id := range sellers {
+		if count+1 >= agentCountThreshold {
+			break
+		}
+		controlled += volumes[id]
+		if float64(controlled)/float64(total) > shareThreshold {
+			controllers := sellers[:count+1]
+			m.Emit(OligopolyAlert{CommodityName: com.name, Tick: m.tick, AgentIDs: controllers})
+			return true, controllers
+		}
+	}
+	return false, nil
+}
+
+//CheckMonopoly looks for a single agent controlling more than the
+//MonopolyShareThreshold of a commodity's ask volume.  Once the same agent has held
+//on for MonopolyTriggerTicks ticks in a row, AntiMonopolySpawnCount new agents
+//are spawned in its role to compete with it.  It is called once a tick, on the
+//fresh books before they clear.
+func (m *Market) CheckMonopoly() {
+	if !m.config.AntiMonopolyEnabled {
+		return
+	}
+	for _, com := range m.sortedCommodities() {
+		volumes, total := m.askVolumeByAgent(com)
+		monopolist, held := uint64(0), 0
+		//Ties go to the lowest id, so the same agent counts as the monopolist however
+		//the map is walked.
+		for id, volume := range volumes {
+			if volume > held || (volume == held && id < monopolist) {
+				monopolist, held = id, volume
+			}
+		}
+		if total == 0 || float64(held)/float64(total) <= m.config.MonopolyShareThreshold {
+			delete(m.monopolyTicks, com)
+			continue
+		}
+		if last, ok := m.monopolists[com]; !ok || last != monopolist {
+			m.monopolists[com] = monopolist
+			m.monopolyTicks[com] = 0
+		}
+		m.monopolyTicks[com]++
+		if m.monopolyTicks[com] < m.config.MonopolyTriggerTicks {
+			continue
+		}
+		//Break it up!
+		agent, _, ok := m.agentByID(monopolist)
+		if !ok {
+			continue
+		}
+		for i := 0; i < m.config.AntiMonopolySpawnCount; i++ {
+			m.spawn(agent.role)
+		}
+		m.statistics.AntiMonopolyInterventions++
+		delete(m.monopolyTicks, com)
+		delete(m.monopolists, com)
+	}
+}
+
+//BreakEvenAskPrice is the lowest price per unit an agent can sell a commodity
+//made by a production method for without losing money on it.  Inputs and expected
+//catalyst losses are costed at the agent's own belief of their price, and spread
+//over the units of the commodity the method puts out.  A method that doesn't make
+//the commodity has no break even price, and returns -1.
+//agent - a pointer to a traderAgent dataset
+//method - a pointer to the productionMethod making the commodity
+//com - a pointer to the commodity being sold
+func BreakEvenAskPrice(agent *traderAgent, method *productionMethod, com *commodity) float64 {
+	produced := 0
+	for _, output := range method.outputs {
+		if output.item == com {
+			produced = produced + output.quantity
+		}
+	}
+	if produced == 0 {
+		return -1
+	}
+	var cost float64
+	for _, input := range method.inputs {
+		cost = cost + float64(input.quantity)*
+			((agent.priceBelief[input.item].high+agent.priceBelief[input.item].low)/2)
+	}
+	for index, catalyst := range method.catalysts {
+		cost = cost + float64(catalyst.quantity)*method.consumption[index]*
+			((agent.priceBelief[catalyst.item].high+agent.priceBelief[catalyst.item].low)/2)
+	}
+	return cost / float64(produced)
+}
+
+//MarginalCost is the cheapest BreakEvenAskPrice of all the agent's methods that
+//make a commodity, or -1 if its job doesn't make it at all.
+func MarginalCost(agent *traderAgent, com *commodity) float64 {
+	marginal := -1.0
+	if agent.job == nil {
+		return marginal
+	}
+	for _, method := range agent.job.methods {
+		breakEven := BreakEvenAskPrice(agent, method, com)
+		if breakEven >= 0 && (marginal < 0 || breakEven < marginal) {
+			marginal = breakEven
+		}
+	}
+	return marginal
+}
+
+//A PredatoryPricingAlert is raised for an agent asking less for a commodity than
+//it costs them to make it.
+type PredatoryPricingAlert struct {
+	AgentID       uint64
+	CommodityName string
+	Tick          int
+	SellFor       float64
+	MarginalCost  float64
+}
+
+func (e PredatoryPricingAlert) String() string {
+	return fmt.Sprintf("Predatory pricing on %v at tick %v! Agent %v asks %v under a cost of %v", e.CommodityName, e.Tick, e.AgentID, e.SellFor, e.MarginalCost)
+}
+
+//DetectPredatoryPricing returns the IDs of every agent asking less for a commodity
+//in this tick's ask book than its MarginalCost of making it, and raises a
+//PredatoryPricingAlert for each of them.  Agents that don't make the commodity are
+//just selling off stock, and are never predatory.
+//com - a pointer to the commodity to check
+func (m *Market) DetectPredatoryPricing(com *commodity) []uint64 {
+	var predators []uint64
+	flagged := make(map[uint64]bool)
+	for _, askSet := range m.asksTyped[com] {
+		id := askSet.offeredAsk.id
+		agent, _, ok := m.agentByID(id)
+		if !ok || flagged[id] {
+			continue
+		}
+		agent.mu.Lock()
+		marginal := MarginalCost(agent, com)
+		agent.mu.Unlock()
+		if marginal < 0 || askSet.offeredAsk.sellFor >= marginal {
+			continue
+		}
+		flagged[id] = true
+		predators = append(predators, id)
+		m.Emit(PredatoryPricingAlert{AgentID: id, CommodityName: com.name, Tick: m.tick, SellFor: askSet.offeredAsk.sellFor, MarginalCost: marginal})
+	}
+	return predators
+}
+
+//The Herfindahl-Hirschman Index over which a market counts as highly concentrated,
+//as antitrust regulators reckon it.
+const defaultHHIAlertThreshold = 2500
+
+//A ConcentrationAlert is raised when the sales of a commodity are concentrated in
+//few hands (see HHI).
+type ConcentrationAlert struct {
+	CommodityName string
+	Tick          int
+	HHI           float64
+}
+
+func (e ConcentrationAlert) String() string {
+	return fmt.Sprintf("Concentration on %v at tick %v! HHI of %v", e.CommodityName, e.Tick, e.HHI)
+}
+
+//HHI computes the Herfindahl-Hirschman Index of who sold a commodity in the last
+//tick: the sum of the squares of each agent's share, in percent, of the units
+//sold.  Many small sellers score near 0, and a monopoly scores 10000.  A commodity
+//nothing sold of, or that isn't traded, scores 0.
+func HHI(sim *Simulation, commodityName string) float64 {
+	com, ok := sim.Market.commodities[commodityName]
+	if !ok {
+		return 0
+	}
+	return sim.Market.hhi(com)
+}
+
+//hhi computes the HHI of a commodity's sales from its ask book, once it has
+//cleared.
+func (m *Market) hhi(com *commodity) float64 {
+	sold := make(map[uint64]int)
+	total := 0
+	for _, askSet := range m.asksTyped[com] {
+		units := askSet.offeredAsk.quantity * askSet.numberAccepted
+		sold[askSet.offeredAsk.id] += units
+		total += units
+	}
+	if total == 0 {
+		return 0
+	}
+	index := 0.0
+	for _, units := range sold {
+		share := 100 * float64(units) / float64(total)
+		index += share * share
+	}
+	return index
+}
+
+//CheckConcentration raises a ConcentrationAlert for every commodity whose HHI
+//passed the HHIAlertThreshold this tick.  It is called once a tick, on the books
+//once they have cleared.
+func (m *Market) CheckConcentration() {
+	if m.config.HHIAlertThreshold <= 0 {
+		return
+	}
+	for _, com := range m.sortedCommodities() {
+		if index := m.hhi(com); index > m.config.HHIAlertThreshold {
+			m.Emit(ConcentrationAlert{CommodityName: com.name, Tick: m.tick, HHI: index})
+		}
+	}
+}
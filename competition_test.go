@@ -0,0 +1,140 @@
+// GoEconGo project competition_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckMonopolySpawnsCompetitors(t *testing.T) {
+	config := testConfig()
+	config.AntiMonopolyEnabled = true
+	economy := testSimConfig(t, 10)
+	setCohort(economy, "Blacksmith", 1)
+	sim := simulateEconomy(t, config, economy)
+	m := sim.Market
+	for tick := 0; tick < config.MonopolyTriggerTicks; tick++ {
+		RunTicks(1, sim)
+		if m.population["Blacksmith"] > 1 {
+			if m.statistics.AntiMonopolyInterventions == 0 {
+				t.Fatal("blacksmiths spawned without an intervention")
+			}
+			return
+		}
+	}
+	t.Errorf("still %v blacksmith after %v ticks", m.population["Blacksmith"], m.tick)
+}
+
+func TestCheckMonopolyOffByDefault(t *testing.T) {
+	economy := testSimConfig(t, 10)
+	setCohort(economy, "Blacksmith", 1)
+	sim := simulateEconomy(t, testConfig(), economy)
+	RunTicks(3*sim.Market.config.MonopolyTriggerTicks, sim)
+	if interventions := sim.Market.statistics.AntiMonopolyInterventions; interventions != 0 {
+		t.Errorf("%v interventions with the check off", interventions)
+	}
+}
+
+func TestCheckMonopolyTieGoesToLowestID(t *testing.T) {
+	config := testConfig()
+	config.AntiMonopolyEnabled = true
+	config.MonopolyShareThreshold = 0.3
+	config.MonopolyTriggerTicks = 1000
+	food := &commodity{name: "Food", averagePrice: 3}
+	//Two sellers with 40% of the food each, and a third with the rest.
+	m := newBookMarket(config, food, []*asks{
+		{offeredAsk: ask{id: 9, item: food, quantity: 5, sellFor: 3}, numberOffered: 5},
+		{offeredAsk: ask{id: 4, item: food, quantity: 5, sellFor: 3}, numberOffered: 5},
+		{offeredAsk: ask{id: 7, item: food, quantity: 5, sellFor: 3}, numberOffered: 5},
+		{offeredAsk: ask{id: 7, item: food, quantity: 5, sellFor: 3}, numberOffered: 5},
+		{offeredAsk: ask{id: 4, item: food, quantity: 5, sellFor: 3}, numberOffered: 5},
+	}, nil)
+	for i := 1; i <= 20; i++ {
+		m.CheckMonopoly()
+		if m.monopolists[food] != 4 || m.monopolyTicks[food] != i {
+			t.Fatalf("check %v: agent %v held food for %v ticks, want agent 4 for %v", i, m.monopolists[food], m.monopolyTicks[food], i)
+		}
+	}
+}
+
+func TestDetectOligopolyTiesGoToLowestID(t *testing.T) {
+	food := &commodity{name: "Food", averagePrice: 3}
+	//Two sellers with a third of the food each, and two more with the rest.
+	m := newBookMarket(testConfig(), food, []*asks{
+		{offeredAsk: ask{id: 9, item: food, quantity: 5, sellFor: 3}, numberOffered: 5},
+		{offeredAsk: ask{id: 7, item: food, quantity: 5, sellFor: 3}, numberOffered: 10},
+		{offeredAsk: ask{id: 4, item: food, quantity: 5, sellFor: 3}, numberOffered: 10},
+		{offeredAsk: ask{id: 2, item: food, quantity: 5, sellFor: 3}, numberOffered: 5},
+	}, nil)
+	for i := 0; i < 20; i++ {
+		found, controllers := m.DetectOligopoly(food, 3, 0.3)
+		if !found || !reflect.DeepEqual(controllers, []uint64{4}) {
+			t.Fatalf("check %v: got oligopoly %v of %v, want agent 4 alone", i+1, found, controllers)
+		}
+	}
+}
+
+//toolsOligopolies runs the test economy with 100 agents of every role but some
+//number of blacksmiths for 5 ticks, and returns the oligopoly alerts raised on
+//tools.
+func toolsOligopolies(t *testing.T, blacksmiths int) (*Market, []OligopolyAlert) {
+	economy := testSimConfig(t, 100)
+	setCohort(economy, "Blacksmith", blacksmiths)
+	sim := simulateEconomy(t, testConfig(), economy)
+	alerts := recordEvents[OligopolyAlert](sim.Market)
+	RunTicks(5, sim)
+	var tools []OligopolyAlert
+	for _, alert := range *alerts {
+		if alert.CommodityName == "Tools" {
+			tools = append(tools, alert)
+		}
+	}
+	return sim.Market, tools
+}
+
+func TestDetectOligopoly(t *testing.T) {
+	m, alerts := toolsOligopolies(t, 1)
+	if len(alerts) != 5 {
+		t.Fatalf("a lone blacksmith raised %v alerts in 5 ticks, want one a tick", len(alerts))
+	}
+	blacksmith := agentsOf(m, "Blacksmith")[0].id
+	for _, alert := range alerts {
+		if len(alert.AgentIDs) != 1 || alert.AgentIDs[0] != blacksmith {
+			t.Errorf("tick %v: tools controlled by %v, want just the blacksmith %v", alert.Tick, alert.AgentIDs, blacksmith)
+		}
+	}
+	//Given long enough, a few blacksmiths end up holding all the metal, and those
+	//few are an oligopoly; to start with, ten share the market.
+	if _, alerts := toolsOligopolies(t, 10); len(alerts) > 0 {
+		t.Errorf("ten blacksmiths raised %v", alerts)
+	}
+}
+
+func TestDetectPredatoryPricing(t *testing.T) {
+	m, smelter, _, _ := smeltingMarket(testConfig())
+	ore, metal := m.commodities["Ore"], m.commodities["Metal"]
+	placeTestAgent(m, 0, smelter)
+	//A unit of metal costs the smelter a unit of ore, which it reckons is worth 3.
+	smelter.priceBelief[ore] = priceRange{low: 2, high: 4}
+	if cost := MarginalCost(smelter, metal); cost != 3 {
+		t.Fatalf("metal costs the smelter %v, want 3", cost)
+	}
+	alerts := recordEvents[PredatoryPricingAlert](m)
+	offer := func(sellFor float64) []uint64 {
+		m.asksTyped[metal] = AsksLowToHigh{{offeredAsk: ask{id: smelter.id, item: metal, quantity: 1, sellFor: sellFor}, numberOffered: 5}}
+		return m.DetectPredatoryPricing(metal)
+	}
+	for tick := 0; tick < 5; tick++ {
+		if predators := offer(0.4 * 3); len(predators) != 1 || predators[0] != smelter.id {
+			t.Errorf("tick %v: asking 40%% of cost flagged %v, want the smelter %v", tick, predators, smelter.id)
+		}
+	}
+	for _, sellFor := range []float64{3, 4} {
+		if predators := offer(sellFor); len(predators) > 0 {
+			t.Errorf("asking %v for a cost of 3 flagged %v", sellFor, predators)
+		}
+	}
+	if len(*alerts) != 5 {
+		t.Errorf("raised %v alerts, want 5", len(*alerts))
+	}
+}
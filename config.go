@@ -0,0 +1,266 @@
+// GoEconGo project config.go
+package main
+
+import (
+	"time"
+)
+
+//A SimulationConfig holds the knobs that tune how a simulation behaves.
+//RecalibrationTriggerTicks - how many consecutive ticks a commodity may sit on its
+//PriceFloor or PriceCeiling before every agent's belief of it is reset (0 is never)
+//LargeOrderThreshold - orders of more units than this pay their commodity's
+//MarketImpactCoeff
+//ExternalityCostPerUnit - what every agent pays each tick for each unit of an
+//externality held anywhere in the market
+//CarbonTaxRate - what a producer pays for each unit of an externality it produces
+//OligopolyAgentThreshold - a market supplied by fewer agents than this is watched
+//for oligopoly
+//OligopolyShareThreshold - the share of ask volume those few agents must hold
+//AntiMonopolyEnabled - whether the market spawns competitors for monopolists
+//MonopolyShareThreshold - the share of ask volume one agent must hold to be a
+//monopolist
+//MonopolyTriggerTicks - how many ticks in a row a monopolist is tolerated
+//AntiMonopolySpawnCount - how many competitors are spawned to break a monopoly
+//HHIAlertThreshold - the HHI of a commodity's sales over which a
+//ConcentrationAlert is raised (0 is never)
+//ElasticityWindow - how many ticks of history CheckElasticity fits demand and
+//supply to, raising a GiffenAlert when either slopes the wrong way (0 is never)
+//RoleSlots - the most live agents a role may have (roles left out are uncapped)
+//AutoExpandRoleSlots - whether sustained high prices open more slots in the role
+//making the commodity
+//ExpansionTriggerSigmas - how many standard deviations above its rolling mean a
+//price must be to count as high
+//ExpansionTriggerTicks - how many ticks in a row a price must be high
+//MaxRoleSlots - the most slots expansion will open up to in a role
+//ReproductionEnabled - whether producers split in two once they are rich enough
+//(see reproduce)
+//ReproductionThreshold - the funds above which a producer splits
+//PricingRule - how the price of a matched ask and bid is settled, to begin with
+//(see Market.PricingRule)
+//MidpointWeight - for the WeightedMidpoint PricingRule, how far [0.0,1.0] from the
+//bid towards the ask the price is settled
+//BarterFundsThreshold - agents with less cash than this offer to barter (0 is
+//never)
+//BarterOfferTicks - how many ticks a barter offer stands
+//SpoilageHandlers - what is done with spoiled units of each commodity, by name
+//(commodities left out are discarded)
+//UtilityWeights - for each role, how much its agents weight the value of each
+//commodity, by name (commodities left out are weighted 1)
+//Oracle - where oracle-subscribing agents get their reference prices (nil is none)
+//ProductionCyclesPerTick - the most times an agent produces each tick
+//AllowShortSelling - whether agents may sell what they don't hold
+//ShortCoverCost - what a short seller pays for each unit it failed to buy back
+//InitHooks - hooks run on every new agent of a role, by role, once its factory has
+//set it up
+//ProductionSelector - decides the order agents try their production methods in
+//Resurrection - decides what takes a dead agent's place (nil is the
+//MostExpensiveCommodityStrategy)
+//Tracer - wraps the market's and agents' work in spans (nil is no tracing)
+//BeliefInitSpread - how far either side of averagePrice new agents' price beliefs
+//start, as a fraction of it
+//UninformedShare - the share [0.0,1.0] of new producers that know less of the
+//market than the rest (0 is none, see informAgent)
+//UninformedLevel - the InformationLevel (0.0,1.0] those producers have
+//InformationNoise - the standard deviation, as a share of the price, of the noise
+//an agent knowing nothing hears prices with (see heardPrice)
+//MaxConsecutivePenalties - how many ticks in a row an agent may idle before it is
+//put out of business (0 is never)
+//PriceHistoryCapacity - how many closing prices (and traded volumes) each
+//commodity remembers
+//ShutdownTimeout - how long Shutdown waits for the agents to stop
+//BeliefAdjustRates - how fast each role's agents learn prices, by role (roles left
+//out learn at the default rates)
+//PriceSmoothing - how much weight (0.0,1.0] each tick's trading gets in the
+//averagePrice of commodities without a PriceSmoothing of their own (1 is none)
+//DeathThreshold - agents whose NetWorth falls below this die
+//MarketOrderMargin - agents worth less than this above the DeathThreshold sell
+//with market orders, to be sure of selling (0 is never, see urgent)
+//SwitchThreshold - the share of its starting funds an agent can fall to before it
+//looks for a more profitable role (0 is never)
+//ResearchShare - the share [0.0,1.0] of what agents have made beyond their starting
+//funds that they invest in research each tick (0 is none, see doResearch)
+//TradeHistoryLength - how many of its most recent trades each agent remembers (0
+//is none)
+//TransactionFeePercent - the fee the market takes on every trade, as a percent of
+//its price, split evenly between seller and buyer (0 is none)
+//TradeFutures - whether each role's agents trade futures, by role
+//FuturesDeliveryTicks - how many ticks ahead futures are traded for
+//Speculators - how many speculators join the market at the start
+//SpeculatorLookback - how many ticks of prices speculators' moving averages span
+//MarketMakers - how many market makers join the market at the start
+//MarketMakerSpread - the spread market makers quote, as a fraction of the price
+//MarketMakerQuoteSize - how many units market makers quote on each side
+//DirectTradeWeight - how much weight [0.0,1.0] a direct trade's price gets in
+//averagePrice (see DirectTrade)
+//OpportunityCostBids - whether agents bid less for inputs when their cash could
+//make more elsewhere (see opportunityCostAdjustedBid)
+//AdaptiveCyclesToCover - whether agents adjust how many cycles of inputs they
+//stock as they go (see adaptCyclesToCover)
+//SkillMultiplier - how much more a fully skilled agent makes than a novice, as a
+//fraction of a method's outputs (0 is no difference, see skilledOutputs)
+//MinTickInterval, MaxTickInterval - the band an AdaptiveTicker keeps the time
+//between real-time ticks within (the same for both ticks at a steady rate)
+//CSVFlushRows - how many rows a CSV export writes between flushes to disk (see
+//ExportCSV)
+//KeepLedger - whether the market keeps a Ledger of every movement of money
+//MinAge, MaxAge - the range of ticks new agents may trade for before they retire
+//(a MaxAge of 0 is forever)
+//MaxInventory - how many units of everything together each role's agents can hold,
+//by role (roles left out hold defaultMaxInventory; 0 is no limit)
+//Seed - seeds all of the simulation's randomness, so runs with the same seed, set
+//up the same way and run with RunTicks are identical (0 is a different run every
+//time)
+//rng - the source of randomness made from Seed
+type SimulationConfig struct {
+	RecalibrationTriggerTicks int
+	LargeOrderThreshold       int
+	ExternalityCostPerUnit    float64
+	CarbonTaxRate             float64
+	OligopolyAgentThreshold   int
+	OligopolyShareThreshold   float64
+	AntiMonopolyEnabled       bool
+	MonopolyShareThreshold    float64
+	MonopolyTriggerTicks      int
+	AntiMonopolySpawnCount    int
+	HHIAlertThreshold         float64
+	ElasticityWindow          int
+	RoleSlots                 map[string]int
+	AutoExpandRoleSlots       bool
+	ExpansionTriggerSigmas    float64
+	ExpansionTriggerTicks     int
+	MaxRoleSlots              int
+	ReproductionEnabled       bool
+	ReproductionThreshold     float64
+	PricingRule               ClearingPriceRule
+	MidpointWeight            float64
+	BarterFundsThreshold      float64
+	BarterOfferTicks          int
+	SpoilageHandlers          map[string]SpoilageHandler
+	UtilityWeights            map[string]map[string]float64
+	Oracle                    PriceOracle
+	ProductionCyclesPerTick   int
+	AllowShortSelling         bool
+	ShortCoverCost            float64
+	InitHooks                 map[string][]AgentInitHook
+	ProductionSelector        ProductionSelector
+	Resurrection              ResurrectionStrategy
+	Tracer                    Tracer
+	BeliefInitSpread          float64
+	UninformedShare           float64
+	UninformedLevel           float64
+	InformationNoise          float64
+	MaxConsecutivePenalties   int
+	PriceHistoryCapacity      int
+	ShutdownTimeout           time.Duration
+	BeliefAdjustRates         map[string]BeliefAdjustRates
+	PriceSmoothing            float64
+	DeathThreshold            float64
+	MarketOrderMargin         float64
+	MaxInventory              map[string]int
+	SwitchThreshold           float64
+	ResearchShare             float64
+	TradeHistoryLength        int
+	TransactionFeePercent     float64
+	TradeFutures              map[string]bool
+	FuturesDeliveryTicks      int
+	Speculators               int
+	SpeculatorLookback        int
+	MarketMakers              int
+	MarketMakerSpread         float64
+	MarketMakerQuoteSize      int
+	DirectTradeWeight         float64
+	OpportunityCostBids       bool
+	AdaptiveCyclesToCover     bool
+	SkillMultiplier           float64
+	MinTickInterval           time.Duration
+	MaxTickInterval           time.Duration
+	CSVFlushRows              int
+	KeepLedger                bool
+	MinAge                    int
+	MaxAge                    int
+	Seed                      int64
+	rng                       *randomSource
+}
+
+//An AgentInitHook customizes a freshly made agent - loading what it has learned,
+//say, or setting it up from outside data.
+type AgentInitHook func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig)
+
+//runInitHooks runs every hook registered for an agent's role on it, in order.
+func runInitHooks(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+	for _, hook := range config.InitHooks[agent.role] {
+		hook(agent, commodities, config)
+	}
+}
+
+//The BeliefInitSpread new agents' beliefs start with when none (or an impossible
+//one) is configured.
+const defaultBeliefInitSpread = 0.3
+
+//A ClearingPriceRule decides what price a matched ask and bid trade at (see
+//clearingPrice).
+type ClearingPriceRule int
+
+const (
+	//Midpoint trades halfway between the ask and the bid.
+	Midpoint ClearingPriceRule = iota
+	//AskPrice trades at the ask - the buyer pays what the seller asked.
+	AskPrice
+	//BidPrice trades at the bid - the seller receives what the buyer bid.
+	BidPrice
+	//WeightedMidpoint trades MidpointWeight of the way from the bid to the ask.
+	WeightedMidpoint
+	//Random trades at the ask or the bid, even odds of each, so neither side can
+	//count on the surplus.
+	Random
+)
+
+//defaultSimulationConfig returns the configuration the simulation runs with when
+//nothing else is given.
+func defaultSimulationConfig() SimulationConfig {
+	var config SimulationConfig
+	config.RecalibrationTriggerTicks = 10
+	config.LargeOrderThreshold = 10
+	config.OligopolyAgentThreshold = 3
+	config.OligopolyShareThreshold = 0.5
+	config.MonopolyShareThreshold = 0.5
+	config.MonopolyTriggerTicks = 5
+	config.AntiMonopolySpawnCount = 3
+	config.HHIAlertThreshold = defaultHHIAlertThreshold
+	config.RoleSlots = make(map[string]int)
+	config.AutoExpandRoleSlots = true
+	config.ExpansionTriggerSigmas = 2
+	config.ExpansionTriggerTicks = 5
+	config.MaxRoleSlots = 1000
+	config.ReproductionThreshold = defaultReproductionThreshold
+	config.PricingRule = Midpoint
+	config.MidpointWeight = 0.5
+	config.BarterFundsThreshold = 10
+	config.BarterOfferTicks = 3
+	config.SpoilageHandlers = make(map[string]SpoilageHandler)
+	config.UtilityWeights = make(map[string]map[string]float64)
+	config.ProductionCyclesPerTick = 1
+	config.ShortCoverCost = 10
+	config.InitHooks = make(map[string][]AgentInitHook)
+	config.ProductionSelector = MarketValueSelector{}
+	config.Resurrection = MostExpensiveCommodityStrategy{}
+	config.BeliefInitSpread = defaultBeliefInitSpread
+	config.UninformedLevel = 0.5
+	config.InformationNoise = defaultInformationNoise
+	config.PriceHistoryCapacity = priceHistoryLength
+	config.ShutdownTimeout = 5 * time.Second
+	config.BeliefAdjustRates = make(map[string]BeliefAdjustRates)
+	config.PriceSmoothing = defaultPriceSmoothing
+	config.MaxInventory = make(map[string]int)
+	config.TradeHistoryLength = defaultTradeHistoryLength
+	config.TradeFutures = make(map[string]bool)
+	config.FuturesDeliveryTicks = defaultFuturesDeliveryTicks
+	config.SpeculatorLookback = defaultSpeculatorLookback
+	config.MarketMakerSpread = defaultMarketMakerSpread
+	config.MarketMakerQuoteSize = defaultMarketMakerQuoteSize
+	config.DirectTradeWeight = defaultDirectTradeWeight
+	config.CSVFlushRows = defaultCSVFlushRows
+	config.MinTickInterval = defaultTickInterval
+	config.MaxTickInterval = defaultTickInterval
+	return config
+}
@@ -0,0 +1,258 @@
+// GoEconGo project control.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+//A StateSnapshot is everything the control API reports about a simulation.
+//Tick - the market's tick
+//Paused - whether the simulation is paused
+//TransactionFeePercent - the fee taken on every trade (see chargeFee)
+//FeePool - every fee taken so far
+//Commodities - every commodity's market, by name
+//Population - how many agents of each role are alive, by role
+//Agents - every running agent, lowest id first
+type StateSnapshot struct {
+	Tick                  int                          `json:"tick"`
+	Paused                bool                         `json:"paused"`
+	TransactionFeePercent float64                      `json:"transactionFeePercent"`
+	FeePool               float64                      `json:"feePool"`
+	Commodities           map[string]CommoditySnapshot `json:"commodities"`
+	Population            map[string]int               `json:"population"`
+	Agents                []AgentSnapshot              `json:"agents"`
+}
+
+//A CommoditySnapshot is a commodity's market in a StateSnapshot.
+//AveragePrice - its averagePrice
+//Volume - how many units traded in the last tick
+//Spread - the spread of its books before the last tick's trading
+//SupplyCurve, DemandCurve - the supply and demand curves of its books before the
+//last tick's trading (see EstimateSupplyCurve)
+//EstimatedPrice - the Equilibrium price of those curves (0 is without both), to
+//compare against AveragePrice
+type CommoditySnapshot struct {
+	AveragePrice   float64      `json:"averagePrice"`
+	Volume         int          `json:"volume"`
+	Spread         float64      `json:"spread"`
+	SupplyCurve    []CurvePoint `json:"supplyCurve"`
+	DemandCurve    []CurvePoint `json:"demandCurve"`
+	EstimatedPrice float64      `json:"estimatedPrice"`
+}
+
+//An AgentSnapshot is an agent in a StateSnapshot.
+//ID, Role - the agent's id and role
+//Funds - its funds
+//NetWorth - its NetWorth
+//Age - how many ticks it has lived
+//Inventory - everything it holds, by commodity name
+type AgentSnapshot struct {
+	ID        uint64         `json:"id"`
+	Role      string         `json:"role"`
+	Funds     float64        `json:"funds"`
+	NetWorth  float64        `json:"netWorth"`
+	Age       int            `json:"age"`
+	Inventory map[string]int `json:"inventory"`
+}
+
+//snapshotState takes a StateSnapshot of a simulation between ticks.
+func snapshotState(sim *Simulation) StateSnapshot {
+	m := sim.Market
+	state := StateSnapshot{
+		Tick:                  m.tick,
+		Paused:                sim.paused,
+		TransactionFeePercent: m.config.TransactionFeePercent,
+		FeePool:               m.FeePool,
+		Commodities:           make(map[string]CommoditySnapshot),
+		Population:            make(map[string]int),
+	}
+	for name, com := range m.commodities {
+		saved := CommoditySnapshot{AveragePrice: com.averagePrice, Volume: com.TickVolume,
+			SupplyCurve: com.supplyCurve, DemandCurve: com.demandCurve}
+		saved.EstimatedPrice, _ = Equilibrium(com.supplyCurve, com.demandCurve)
+		if spreads := com.spreadRing.last(1); len(spreads) > 0 {
+			saved.Spread = spreads[0]
+		}
+		state.Commodities[name] = saved
+	}
+	for role, count := range m.population {
+		state.Population[role] = count
+	}
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		saved := AgentSnapshot{ID: agent.id, Role: agent.role, Funds: agent.funds, NetWorth: NetWorth(agent), Age: agent.age, Inventory: make(map[string]int)}
+		for com, num := range agent.inventory {
+			saved.Inventory[com.name] = num
+		}
+		agent.mu.Unlock()
+		state.Agents = append(state.Agents, saved)
+	}
+	return state
+}
+
+//KillAgent kills a running agent outright.  Its goroutine is stopped before it
+//returns, the agent is taken off the market as dead agents are, and its slot is
+//filled by the configured ResurrectionStrategy.  Call it between ticks.
+//id - the agent's id
+func (m *Market) KillAgent(id uint64) error {
+	agent, slot, ok := m.agentByID(id)
+	if !ok {
+		return fmt.Errorf("no running agent with id %v", id)
+	}
+	//Stop it first, so it is remembered as it was when it died.
+	m.stop(slot)
+	agent.mu.Lock()
+	dead := *agent
+	agent.mu.Unlock()
+	m.retire(slot)
+	m.metrics.countDeath()
+	if len(m.roleOrder) == 0 {
+		return nil
+	}
+	m.placeAgent(slot, m.config.resurrection().Resurrect(dead, m.simulation()))
+	return nil
+}
+
+//setTransactionFee changes the fee the market takes on every trade.  Call it
+//between ticks.
+//percent - the new fee, as a percent of each trade's price (0 is none)
+func (m *Market) setTransactionFee(percent float64) error {
+	if percent < 0 {
+		return errors.New("a transaction fee cannot be negative")
+	}
+	m.config.TransactionFeePercent = percent
+	return nil
+}
+
+//A priceShockRequest is the body of POST /shock/price (see InjectPriceShock).
+type priceShockRequest struct {
+	Commodity    string  `json:"commodity"`
+	DeltaPercent float64 `json:"deltaPercent"`
+}
+
+//A supplyShockRequest is the body of POST /shock/supply (see InjectSupplyShock).
+type supplyShockRequest struct {
+	Commodity     string `json:"commodity"`
+	QuantityDelta int    `json:"quantityDelta"`
+}
+
+//A feeRequest is the body of PUT /config/fee.
+type feeRequest struct {
+	Percent float64 `json:"percent"`
+}
+
+//writeJSON answers a request with a value as JSON.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+//decodeJSON reads a request's JSON body into a value, answering with a bad
+//request if it can't.
+//Returns whether it could.
+func decodeJSON(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+//controlHandler builds the control API's routes for a simulation.  Every change
+//is made between ticks (see Simulation.Do).
+func controlHandler(sim *Simulation) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /shock/price", func(w http.ResponseWriter, r *http.Request) {
+		var request priceShockRequest
+		if !decodeJSON(w, r, &request) {
+			return
+		}
+		var err error
+		sim.Do(func() { err = InjectPriceShock(sim, request.Commodity, request.DeltaPercent) })
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /shock/supply", func(w http.ResponseWriter, r *http.Request) {
+		var request supplyShockRequest
+		if !decodeJSON(w, r, &request) {
+			return
+		}
+		var err error
+		sim.Do(func() { err = InjectSupplyShock(sim, request.Commodity, request.QuantityDelta) })
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /state", func(w http.ResponseWriter, r *http.Request) {
+		var state StateSnapshot
+		sim.Do(func() { state = snapshotState(sim) })
+		writeJSON(w, state)
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		sim.Pause()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		sim.Resume()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("PUT /config/fee", func(w http.ResponseWriter, r *http.Request) {
+		var request feeRequest
+		if !decodeJSON(w, r, &request) {
+			return
+		}
+		var err error
+		sim.Do(func() { err = sim.Market.setTransactionFee(request.Percent) })
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE /agent/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "agent ids are numbers", http.StatusBadRequest)
+			return
+		}
+		sim.Do(func() { err = sim.Market.KillAgent(id) })
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+//StartControlAPI serves a REST API for steering a running simulation, in the
+//background:
+//POST /shock/price - {"commodity", "deltaPercent"}, as InjectPriceShock
+//POST /shock/supply - {"commodity", "quantityDelta"}, as InjectSupplyShock
+//GET /state - a StateSnapshot
+//POST /pause, POST /resume - pause and resume ticking
+//PUT /config/fee - {"percent"}, the new TransactionFeePercent
+//DELETE /agent/{id} - kill an agent, as KillAgent
+//Shut the returned server down to stop it.
+//Returns an error if addr can't be listened on.
+//addr - the address to listen on, as in net.Listen ("localhost:8081")
+func StartControlAPI(addr string, sim *Simulation) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: controlHandler(sim)}
+	go server.Serve(listener)
+	return server, nil
+}
@@ -0,0 +1,75 @@
+// GoEconGo project control_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestControlRoutes(t *testing.T) {
+	sim := newTestSimulation(t, testConfig(), 2)
+	RunTicks(1, sim)
+	server := httptest.NewServer(controlHandler(sim))
+	defer server.Close()
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/state", "", http.StatusOK},
+		{"POST", "/state", "", http.StatusMethodNotAllowed},
+		{"POST", "/pause", "", http.StatusNoContent},
+		{"POST", "/resume", "", http.StatusNoContent},
+		{"POST", "/shock/price", `{"commodity": "Food", "deltaPercent": 10}`, http.StatusNoContent},
+		{"POST", "/shock/price", `{"commodity": "Gold", "deltaPercent": 10}`, http.StatusBadRequest},
+		{"PUT", "/config/fee", `{"percent": 1}`, http.StatusNoContent},
+		{"DELETE", "/agent/many", "", http.StatusBadRequest},
+		{"DELETE", "/agent/123456789", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		request, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != test.status {
+			t.Errorf("%v %v: got %v, want %v", test.method, test.path, response.StatusCode, test.status)
+		}
+	}
+}
+
+func TestKillAgentByID(t *testing.T) {
+	sim := newTestSimulation(t, testConfig(), 2)
+	RunTicks(1, sim)
+	m := sim.Market
+	victim := agentsOf(m, "Miner")[0]
+	id := victim.id
+	_, slot, ok := m.agentByID(id)
+	if !ok {
+		t.Fatalf("agent %v isn't running", id)
+	}
+	if err := m.KillAgent(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := m.agentByID(id); ok {
+		t.Errorf("agent %v is still running after being killed", id)
+	}
+	replacement := m.idAt(slot)
+	if replacement == 0 || replacement == id {
+		t.Fatalf("slot %v holds agent %v after killing %v, want a new agent", slot, replacement, id)
+	}
+	if _, at, ok := m.agentByID(replacement); !ok || at != slot {
+		t.Errorf("agent %v not found in slot %v", replacement, slot)
+	}
+	if err := m.KillAgent(id); err == nil {
+		t.Errorf("killing agent %v twice succeeded", id)
+	}
+	RunTicks(1, sim)
+}
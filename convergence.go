@@ -0,0 +1,52 @@
+// GoEconGo project convergence.go
+package main
+
+//beliefWidths adds up how wide every living agent's price belief for each
+//commodity is, along with how many agents hold a belief on it.
+func (m *Market) beliefWidths() (widths map[*commodity]float64, believers map[*commodity]int) {
+	widths = make(map[*commodity]float64)
+	believers = make(map[*commodity]int)
+	for _, agent := range m.agents {
+		agent.mu.Lock()
+		for com, belief := range agent.priceBelief {
+			widths[com] += belief.high - belief.low
+			believers[com]++
+		}
+		agent.mu.Unlock()
+	}
+	return widths, believers
+}
+
+//meanWidth turns the total width of a commodity's price beliefs into a
+//BeliefWidth.
+func meanWidth(com *commodity, width float64, believers int) float64 {
+	if believers == 0 || com.averagePrice <= 0 {
+		return 0
+	}
+	return width / float64(believers) / com.averagePrice
+}
+
+//BeliefWidth measures how far agents are from settling on a commodity's price:
+//the mean width (high less low) of every living agent's price belief for
+//it, as a fraction of its averagePrice.  Near 0, agents' beliefs are tight and
+//have converged; higher, they go on disagreeing.  Where BeliefConvergence asks how
+//far apart agents are, this asks how sure each of them is.  A commodity nobody
+//holds a belief on, or without a price, measures 0.
+func BeliefWidth(sim *Simulation, com *commodity) float64 {
+	widths, believers := sim.Market.beliefWidths()
+	return meanWidth(com, widths[com], believers[com])
+}
+
+//BeliefWidthHistory returns the commodity's BeliefWidth at the end of each of the
+//last n ticks (or as many as there are), oldest first.
+func (com *commodity) BeliefWidthHistory(n int) []float64 {
+	return com.beliefRing.last(n)
+}
+
+//recordBeliefWidth records every commodity's BeliefWidth in its history.
+func (m *Market) recordBeliefWidth() {
+	widths, believers := m.beliefWidths()
+	for _, com := range m.commodities {
+		com.beliefRing.push(meanWidth(com, widths[com], believers[com]))
+	}
+}
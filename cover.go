@@ -0,0 +1,66 @@
+// GoEconGo project cover.go
+package main
+
+//How many production cycles' worth of inputs agents bid to keep in stock, unless
+//their role says otherwise, and the fewest and most they may.  Stocking more
+//cycles guards against starving production when inputs are hard to buy, but ties
+//up funds - and, where holding goods costs (see CarryingCostPerUnit), pays to
+//keep them.  Stocking fewer saves on both, but leaves the agent idle whenever a
+//tick's bids go unfilled.
+const (
+	defaultCyclesToCover = 2
+	minCyclesToCover     = 1
+	maxCyclesToCover     = 10
+)
+
+//An adapting agent whose bids go unfilled at least this often stocks another
+//cycle of inputs.
+const starvedBidShare = 0.5
+
+//clampCycles keeps a number of cycles to cover within [minCyclesToCover,
+//maxCyclesToCover].
+func clampCycles(cycles int) int {
+	if cycles < minCyclesToCover {
+		return minCyclesToCover
+	}
+	if cycles > maxCyclesToCover {
+		return maxCyclesToCover
+	}
+	return cycles
+}
+
+//coverCycles returns how many production cycles' worth of inputs an agent bids to
+//keep in stock.  Agents made without a cyclesToCover stock defaultCyclesToCover.
+func coverCycles(agent *traderAgent) int {
+	if agent.cyclesToCover == 0 {
+		return defaultCyclesToCover
+	}
+	return clampCycles(agent.cyclesToCover)
+}
+
+//adaptCyclesToCover adjusts how many cycles of inputs an agent stocks, after its
+//bids have been settled.  An agent that couldn't buy at least starvedBidShare of
+//the units it bid for risks starving production, so it stocks another cycle; one
+//whose inventory has grown since its last update is buying more than it uses, so
+//it stocks one fewer.
+//bidSlice - the results of the agent's bids
+func adaptCyclesToCover(agent *traderAgent, bidSlice []bids) {
+	offered, accepted := 0, 0
+	for _, bidSet := range bidSlice {
+		if bidSet.offeredBid.deliveryTick > 0 {
+			continue
+		}
+		offered += bidSet.offeredBid.quantity * bidSet.numberOffered
+		accepted += bidSet.offeredBid.quantity * bidSet.numberAccepted
+	}
+	held := inventoryHeld(agent)
+	cycles := coverCycles(agent)
+	switch {
+	case offered > 0 && float64(offered-accepted)/float64(offered) >= starvedBidShare:
+		cycles++
+	case held > agent.heldLastTick:
+		cycles--
+	}
+	agent.cyclesToCover = clampCycles(cycles)
+	agent.heldLastTick = held
+}
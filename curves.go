@@ -0,0 +1,170 @@
+// GoEconGo project curves.go
+package main
+
+import "sort"
+
+//A CurvePoint is one point on a supply or demand curve: how many units in total
+//are on offer (or wanted) at a price.
+type CurvePoint struct {
+	Price              float64 `json:"price"`
+	CumulativeQuantity float64 `json:"cumulativeQuantity"`
+}
+
+//A SupplyCurve is the quantity sellers will part with at each price.  It rises
+//with price.
+type SupplyCurve []CurvePoint
+
+//A DemandCurve is the quantity buyers will take at each price.  It falls with
+//price.
+type DemandCurve []CurvePoint
+
+//quantityAt reads the quantity on a curve at a price, interpolating linearly
+//between points.  Prices off either end of the curve take the quantity at that end.
+//points - the points of the curve, in any order
+//price - the price to read the quantity at
+func quantityAt(points []CurvePoint, price float64) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	sorted := make([]CurvePoint, len(points))
+	copy(sorted, points)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Price < sorted[j].Price })
+	if price <= sorted[0].Price {
+		return sorted[0].CumulativeQuantity
+	}
+	for i := 1; i < len(sorted); i++ {
+		if price <= sorted[i].Price {
+			low := sorted[i-1]
+			high := sorted[i]
+			if high.Price == low.Price {
+				return high.CumulativeQuantity
+			}
+			fraction := (price - low.Price) / (high.Price - low.Price)
+			return low.CumulativeQuantity + fraction*(high.CumulativeQuantity-low.CumulativeQuantity)
+		}
+	}
+	return sorted[len(sorted)-1].CumulativeQuantity
+}
+
+//Equilibrium finds the price at which the quantity supplied meets the quantity
+//demanded, and the quantity traded there.  If the curves never cross within their
+//prices, the price is pinned to whichever end is closest to crossing.
+func Equilibrium(supply SupplyCurve, demand DemandCurve) (price, quantity float64) {
+	if len(supply) == 0 || len(demand) == 0 {
+		return 0, 0
+	}
+	low := supply[0].Price
+	high := supply[0].Price
+	for _, points := range [][]CurvePoint{supply, demand} {
+		for _, point := range points {
+			if point.Price < low {
+				low = point.Price
+			}
+			if point.Price > high {
+				high = point.Price
+			}
+		}
+	}
+	//Excess supply only grows with price, so bisect for where it hits zero.
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if quantityAt(supply, mid) < quantityAt(demand, mid) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	price = (low + high) / 2
+	return price, quantityAt(demand, price)
+}
+
+//ComparativeStaticsAsk predicts how the equilibrium moves when sellers bring more
+//to market, without running a simulation.  The supply curve is shifted right by
+//supplyShiftUnits at every price and the equilibrium found again.
+//supply - the current supply curve
+//demand - the current demand curve
+//supplyShiftUnits - the units added at every price (negative shifts left)
+//deltaPrice - the change in equilibrium price
+//deltaQuantity - the change in equilibrium quantity
+func ComparativeStaticsAsk(supply SupplyCurve, demand DemandCurve, supplyShiftUnits int) (deltaPrice, deltaQuantity float64) {
+	shifted := make(SupplyCurve, len(supply))
+	for i, point := range supply {
+		shifted[i] = CurvePoint{Price: point.Price, CumulativeQuantity: point.CumulativeQuantity + float64(supplyShiftUnits)}
+	}
+	beforePrice, beforeQuantity := Equilibrium(supply, demand)
+	afterPrice, afterQuantity := Equilibrium(shifted, demand)
+	return afterPrice - beforePrice, afterQuantity - beforeQuantity
+}
+
+//ComparativeStaticsDemand predicts how the equilibrium moves when buyers want more,
+//without running a simulation.  The demand curve is shifted right by
+//demandShiftUnits at every price and the equilibrium found again.
+//supply - the current supply curve
+//demand - the current demand curve
+//demandShiftUnits - the units added at every price (negative shifts left)
+//deltaPrice - the change in equilibrium price
+//deltaQuantity - the change in equilibrium quantity
+func ComparativeStaticsDemand(supply SupplyCurve, demand DemandCurve, demandShiftUnits int) (deltaPrice, deltaQuantity float64) {
+	shifted := make(DemandCurve, len(demand))
+	for i, point := range demand {
+		shifted[i] = CurvePoint{Price: point.Price, CumulativeQuantity: point.CumulativeQuantity + float64(demandShiftUnits)}
+	}
+	beforePrice, beforeQuantity := Equilibrium(supply, demand)
+	afterPrice, afterQuantity := Equilibrium(supply, shifted)
+	return afterPrice - beforePrice, afterQuantity - beforeQuantity
+}
+
+//EstimateSupplyCurve fits a step supply curve to a book of asks: a point for
+//every price asked, lowest first, with every unit asked at that price or less.
+//Market orders are counted at the price their agents named.
+//askBook - one commodity's asks, in any order
+func EstimateSupplyCurve(askBook []*asks) []CurvePoint {
+	sorted := append([]*asks(nil), askBook...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].offeredAsk.sellFor < sorted[j].offeredAsk.sellFor
+	})
+	var curve []CurvePoint
+	cumulative := 0.0
+	for _, askSet := range sorted {
+		cumulative += float64(askSet.offeredAsk.quantity * askSet.numberOffered)
+		curve = addStep(curve, askSet.offeredAsk.sellFor, cumulative)
+	}
+	return curve
+}
+
+//EstimateDemandCurve fits a step demand curve to a book of bids: a point for
+//every price bid, highest first, with every unit bid for at that price or more.
+//Market orders are counted at the price their agents named.
+//bidBook - one commodity's bids, in any order
+func EstimateDemandCurve(bidBook []*bids) []CurvePoint {
+	sorted := append([]*bids(nil), bidBook...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].offeredBid.buyFor > sorted[j].offeredBid.buyFor
+	})
+	var curve []CurvePoint
+	cumulative := 0.0
+	for _, bidSet := range sorted {
+		cumulative += float64(bidSet.offeredBid.quantity * bidSet.numberOffered)
+		curve = addStep(curve, bidSet.offeredBid.buyFor, cumulative)
+	}
+	return curve
+}
+
+//addStep adds a step to a curve, or raises the last step if it is at the same
+//price.
+func addStep(curve []CurvePoint, price float64, cumulative float64) []CurvePoint {
+	if len(curve) > 0 && curve[len(curve)-1].Price == price {
+		curve[len(curve)-1].CumulativeQuantity = cumulative
+		return curve
+	}
+	return append(curve, CurvePoint{Price: price, CumulativeQuantity: cumulative})
+}
+
+//estimateCurves fits every commodity's supply and demand curves to its books, as
+//they stand before anything trades.
+func (m *Market) estimateCurves() {
+	for _, com := range m.commodities {
+		com.supplyCurve = EstimateSupplyCurve(m.asksTyped[com])
+		com.demandCurve = EstimateDemandCurve(m.bidsTyped[com])
+	}
+}
@@ -0,0 +1,52 @@
+// GoEconGo project curves_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+//linearCurves returns a supply of 10 units for every 1 of price, and a demand of
+//100 units less 10 for every 1 of price, over prices from 0 to 10.  They cross at
+//a price of 5, where 50 units trade.
+func linearCurves() (SupplyCurve, DemandCurve) {
+	var supply SupplyCurve
+	var demand DemandCurve
+	for price := 0.0; price <= 10; price++ {
+		supply = append(supply, CurvePoint{Price: price, CumulativeQuantity: 10 * price})
+		demand = append(demand, CurvePoint{Price: price, CumulativeQuantity: 100 - 10*price})
+	}
+	return supply, demand
+}
+
+func TestEquilibrium(t *testing.T) {
+	price, quantity := Equilibrium(linearCurves())
+	if math.Abs(price-5) > 1e-6 || math.Abs(quantity-50) > 1e-6 {
+		t.Errorf("got %v units at %v, want 50 at 5", quantity, price)
+	}
+}
+
+func TestComparativeStatics(t *testing.T) {
+	supply, demand := linearCurves()
+	tests := []struct {
+		name          string
+		statics       func(SupplyCurve, DemandCurve, int) (float64, float64)
+		shift         int
+		deltaPrice    float64
+		deltaQuantity float64
+	}{
+		//More supply at every price is cheaper, and more of it trades.
+		{"supply right", ComparativeStaticsAsk, 20, -1, 10},
+		{"supply left", ComparativeStaticsAsk, -20, 1, -10},
+		//More demand at every price is dearer, and more of it trades.
+		{"demand right", ComparativeStaticsDemand, 20, 1, 10},
+		{"demand left", ComparativeStaticsDemand, -20, -1, -10},
+		{"no shift", ComparativeStaticsAsk, 0, 0, 0},
+	}
+	for _, test := range tests {
+		deltaPrice, deltaQuantity := test.statics(supply, demand, test.shift)
+		if math.Abs(deltaPrice-test.deltaPrice) > 1e-6 || math.Abs(deltaQuantity-test.deltaQuantity) > 1e-6 {
+			t.Errorf("%v: got a change of %v in price and %v in quantity, want %v and %v", test.name, deltaPrice, deltaQuantity, test.deltaPrice, test.deltaQuantity)
+		}
+	}
+}
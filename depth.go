@@ -0,0 +1,117 @@
+// GoEconGo project depth.go
+package main
+
+import "container/heap"
+
+//How many price levels on each side of the book the depth-weighted mid price
+//looks at.
+const depthLevels = 5
+
+//A DepthBucket is every unit on one side of the book offered at the same price.
+type DepthBucket struct {
+	Price  float64
+	Volume int
+}
+
+//MarketDepth is an order book aggregated by price.
+//Asks - ask buckets, lowest price first
+//Bids - bid buckets, highest price first
+type MarketDepth struct {
+	Asks []DepthBucket
+	Bids []DepthBucket
+}
+
+//newMarketDepth aggregates sorted ask and bid books into price buckets.
+//asksCom - asks for one commodity, sorted lowest to highest
+//bidsCom - bids for one commodity, sorted highest to lowest
+func newMarketDepth(asksCom []*asks, bidsCom []*bids) MarketDepth {
+	var depth MarketDepth
+	for _, askSet := range asksCom {
+		remaining := askSet.numberOffered - askSet.numberAccepted
+		last := len(depth.Asks) - 1
+		if last >= 0 && depth.Asks[last].Price == askSet.offeredAsk.sellFor {
+			depth.Asks[last].Volume += remaining
+		} else {
+			depth.Asks = append(depth.Asks, DepthBucket{Price: askSet.offeredAsk.sellFor, Volume: remaining})
+		}
+	}
+	for _, bidSet := range bidsCom {
+		remaining := bidSet.numberOffered - bidSet.numberAccepted
+		last := len(depth.Bids) - 1
+		if last >= 0 && depth.Bids[last].Price == bidSet.offeredBid.buyFor {
+			depth.Bids[last].Volume += remaining
+		} else {
+			depth.Bids = append(depth.Bids, DepthBucket{Price: bidSet.offeredBid.buyFor, Volume: remaining})
+		}
+	}
+	return depth
+}
+
+//DepthWeightedMid averages the prices of the best topN ask buckets and the best
+//topN bid buckets, weighted by the volume in each.  Unlike the last traded price it
+//holds steady in thin markets.  An empty book has a mid price of 0.
+//depth - the aggregated order book
+//topN - how many buckets to take from each side of the book
+func DepthWeightedMid(depth MarketDepth, topN int) float64 {
+	var weighted float64
+	var volume int
+	for _, side := range [][]DepthBucket{depth.Asks, depth.Bids} {
+		for i := 0; i < topN && i < len(side); i++ {
+			weighted += side[i].Price * float64(side[i].Volume)
+			volume += side[i].Volume
+		}
+	}
+	if volume == 0 {
+		return 0
+	}
+	return weighted / float64(volume)
+}
+
+//recordDepth takes the measurements of a commodity's order book before it clears.
+func (m *Market) recordDepth(com *commodity, depth MarketDepth) {
+	stats := m.statistics.Commodities[com.name]
+	stats.DepthWeightedMidPrice = DepthWeightedMid(depth, depthLevels)
+	m.statistics.Commodities[com.name] = stats
+}
+
+//topAsks returns the asks in a book's best price levels, lowest first, leaving
+//the book a heap of the same asks.
+//book - one commodity's asks, as a heap (see AsksLowToHigh)
+//levels - how many price levels to return
+func topAsks(book AsksLowToHigh, levels int) []*asks {
+	var top []*asks
+	for distinct := 0; len(book) > 0; {
+		if len(top) == 0 || book[0].offeredAsk.sellFor != top[len(top)-1].offeredAsk.sellFor {
+			if distinct == levels {
+				break
+			}
+			distinct++
+		}
+		top = append(top, heap.Pop(&book).(*asks))
+	}
+	for _, askSet := range top {
+		heap.Push(&book, askSet)
+	}
+	return top
+}
+
+//topBids returns the bids in a book's best price levels, highest first, leaving
+//the book a heap of the same bids.
+//book - one commodity's bids, as a heap (see BidsHighToLow)
+//levels - how many price levels to return
+func topBids(book BidsHighToLow, levels int) []*bids {
+	var top []*bids
+	for distinct := 0; len(book) > 0; {
+		if len(top) == 0 || book[0].offeredBid.buyFor != top[len(top)-1].offeredBid.buyFor {
+			if distinct == levels {
+				break
+			}
+			distinct++
+		}
+		top = append(top, heap.Pop(&book).(*bids))
+	}
+	for _, bidSet := range top {
+		heap.Push(&book, bidSet)
+	}
+	return top
+}
@@ -0,0 +1,41 @@
+// GoEconGo project depth_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDepthWeightedMid(t *testing.T) {
+	food := &commodity{name: "Food", averagePrice: 5}
+	//The 10 units at 5.0 are offered in two asks, which share a bucket.
+	depth := newMarketDepth(
+		[]*asks{
+			{offeredAsk: ask{item: food, sellFor: 5}, numberOffered: 6},
+			{offeredAsk: ask{item: food, sellFor: 5}, numberOffered: 4},
+			{offeredAsk: ask{item: food, sellFor: 6}, numberOffered: 5},
+		},
+		[]*bids{
+			{offeredBid: bid{item: food, buyFor: 4}, numberOffered: 8},
+			{offeredBid: bid{item: food, buyFor: 3}, numberOffered: 4},
+		})
+	if len(depth.Asks) != 2 || depth.Asks[0] != (DepthBucket{Price: 5, Volume: 10}) {
+		t.Fatalf("got ask buckets %v, want 10 units at 5 then 5 at 6", depth.Asks)
+	}
+	tests := []struct {
+		topN int
+		want float64
+	}{
+		{depthLevels, (5*10 + 6*5 + 4*8 + 3*4) / 27.0},
+		{1, (5*10 + 4*8) / 18.0},
+		{0, 0},
+	}
+	for _, test := range tests {
+		if got := DepthWeightedMid(depth, test.topN); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("top %v: got %v, want %v", test.topN, got, test.want)
+		}
+	}
+	if got := DepthWeightedMid(MarketDepth{}, depthLevels); got != 0 {
+		t.Errorf("an empty book gave %v, want 0", got)
+	}
+}
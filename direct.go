@@ -0,0 +1,115 @@
+// GoEconGo project direct.go
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+//How much weight a direct trade's price gets in averagePrice, unless the
+//SimulationConfig says otherwise.  Trades off the exchange say less about the
+//market than trades on it.
+const defaultDirectTradeWeight = 0.05
+
+//A TradeProposal is one agent's offer to trade directly with another, off the
+//exchange.
+//Commodity - what is to change hands
+//Quantity - how many units
+//Price - the price of each unit
+//Selling - whether the proposer is the one selling
+type TradeProposal struct {
+	Commodity *commodity
+	Quantity  int
+	Price     float64
+	Selling   bool
+}
+
+//lockPair locks two different agents, the lower id first so two pairs can't
+//deadlock, and returns a function unlocking them both.
+func lockPair(a, b *traderAgent) func() {
+	if a.id > b.id {
+		a, b = b, a
+	}
+	a.mu.Lock()
+	b.mu.Lock()
+	return func() {
+		b.mu.Unlock()
+		a.mu.Unlock()
+	}
+}
+
+//DirectTrade moves units of a commodity from seller to buyer, and their price
+//from buyer to seller, without going through the books.  The commodity's
+//averagePrice moves towards the price by the seller's DirectTradeWeight.
+func DirectTrade(seller *traderAgent, buyer *traderAgent, com *commodity, quantity int, price float64) error {
+	if seller == buyer {
+		return errors.New("an agent cannot trade with itself")
+	}
+	if quantity <= 0 || price < 0 {
+		return fmt.Errorf("cannot trade %v units at %v", quantity, price)
+	}
+	unlock := lockPair(seller, buyer)
+	defer unlock()
+	if seller.inventory[com] < quantity {
+		return fmt.Errorf("the seller holds only %v of the %v units of %v", seller.inventory[com], quantity, com.name)
+	}
+	cost := price * float64(quantity)
+	if buyer.funds < cost {
+		return fmt.Errorf("the buyer has only %v of the %v the trade costs", buyer.funds, cost)
+	}
+	adjustHolding(seller, com, -quantity)
+	adjustHolding(buyer, com, quantity)
+	seller.funds = seller.funds + cost
+	buyer.funds = buyer.funds - cost
+	buyer.ledger.Record(buyer.id, seller.id, cost, buyer.tick, ledgerDirectTrade)
+	seller.lifetimeAskVolume = seller.lifetimeAskVolume + quantity
+	buyer.lifetimeBidVolume = buyer.lifetimeBidVolume + quantity
+	weight := seller.settings().DirectTradeWeight
+	com.averagePrice = weight*price + (1-weight)*com.averagePrice
+	return nil
+}
+
+//Evaluate weighs a proposal against the agent's belief of the commodity's price.
+//A buyer accepts any price up to the top of its belief, and a seller any price
+//down to the bottom of it.  Otherwise the agent counters, meeting the proposer
+//halfway between the proposed price and the middle of its belief.
+//Returns whether the agent accepts, and its counter if it doesn't.
+func (agent *traderAgent) Evaluate(proposal TradeProposal) (bool, TradeProposal) {
+	belief := agent.priceBelief[proposal.Commodity]
+	if proposal.Selling && proposal.Price <= belief.high {
+		return true, proposal
+	}
+	if !proposal.Selling && proposal.Price >= belief.low {
+		return true, proposal
+	}
+	counter := proposal
+	counter.Selling = !proposal.Selling
+	counter.Price = (proposal.Price + (belief.low+belief.high)/2) / 2
+	return false, counter
+}
+
+//Negotiate has a seller and a buyer haggle over a commodity, the seller opening at
+//the middle of its belief, each evaluating the other's proposal in turn for up to
+//rounds proposals.  If they agree, the trade goes ahead with DirectTrade.
+//Returns the agreed price, or an error if they never agreed or couldn't trade.
+func Negotiate(seller *traderAgent, buyer *traderAgent, com *commodity, quantity int, rounds int) (float64, error) {
+	if seller == buyer {
+		return 0, errors.New("an agent cannot trade with itself")
+	}
+	unlock := lockPair(seller, buyer)
+	belief := seller.priceBelief[com]
+	proposal := TradeProposal{Commodity: com, Quantity: quantity, Price: (belief.low + belief.high) / 2, Selling: true}
+	agreed := false
+	for round := 0; round < rounds && !agreed; round++ {
+		evaluator := buyer
+		if !proposal.Selling {
+			evaluator = seller
+		}
+		agreed, proposal = evaluator.Evaluate(proposal)
+	}
+	unlock()
+	if !agreed {
+		return 0, fmt.Errorf("no agreement on %v after %v rounds", com.name, rounds)
+	}
+	return proposal.Price, DirectTrade(seller, buyer, com, quantity, proposal.Price)
+}
@@ -0,0 +1,94 @@
+// GoEconGo project direct_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+//directTraders returns a seller of food holding 10, and a buyer with 20 to spend,
+//each believing food is worth the given range.
+func directTraders(config *SimulationConfig, food *commodity, sellerBelief, buyerBelief priceRange) (*traderAgent, *traderAgent) {
+	seller := newTestAgent(config, nil, 0, map[*commodity]int{food: 10})
+	buyer := newTestAgent(config, nil, 20, make(map[*commodity]int))
+	seller.id, buyer.id = newAgentID(), newAgentID()
+	seller.priceBelief[food] = sellerBelief
+	buyer.priceBelief[food] = buyerBelief
+	return seller, buyer
+}
+
+func TestDirectTrade(t *testing.T) {
+	config := testConfig()
+	config.DirectTradeWeight = 0.1
+	food := &commodity{name: "Food", averagePrice: 3}
+	seller, buyer := directTraders(&config, food, priceRange{}, priceRange{})
+	if err := DirectTrade(seller, buyer, food, 4, 5); err != nil {
+		t.Fatal(err)
+	}
+	if seller.inventory[food] != 6 || buyer.inventory[food] != 4 || seller.funds != 20 || buyer.funds != 0 {
+		t.Errorf("the seller holds %v food and %v, the buyer %v food and %v, want 6 and 20, 4 and 0", seller.inventory[food], seller.funds, buyer.inventory[food], buyer.funds)
+	}
+	if seller.lifetimeAskVolume != 4 || buyer.lifetimeBidVolume != 4 {
+		t.Errorf("recorded %v sold and %v bought, want 4 of each", seller.lifetimeAskVolume, buyer.lifetimeBidVolume)
+	}
+	//Off the exchange, the trade moves the average a tenth of the way to its price.
+	if math.Abs(food.averagePrice-3.2) > 1e-9 {
+		t.Errorf("food averages %v after trading at 5, want 3.2", food.averagePrice)
+	}
+
+	tests := []struct {
+		name     string
+		quantity int
+		price    float64
+	}{
+		{"more than the seller holds", 7, 1},
+		{"more than the buyer can pay", 1, 21},
+		{"nothing", 0, 1},
+		{"at a negative price", 1, -1},
+	}
+	for _, test := range tests {
+		seller, buyer := directTraders(&config, food, priceRange{}, priceRange{})
+		buyer.funds, seller.inventory[food] = 20, 6
+		average := food.averagePrice
+		if err := DirectTrade(seller, buyer, food, test.quantity, test.price); err == nil {
+			t.Errorf("traded %v", test.name)
+		}
+		if seller.inventory[food] != 6 || buyer.inventory[food] != 0 || seller.funds != 0 || buyer.funds != 20 || food.averagePrice != average {
+			t.Errorf("failing to trade %v changed hands or prices", test.name)
+		}
+	}
+	if err := DirectTrade(seller, seller, food, 1, 1); err == nil {
+		t.Errorf("an agent traded with itself")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	config := testConfig()
+	food := &commodity{name: "Food", averagePrice: 3}
+	tests := []struct {
+		name                      string
+		sellerBelief, buyerBelief priceRange
+		rounds                    int
+		agreed                    bool
+		price                     float64
+		bought                    int
+	}{
+		//The buyer takes the seller's opening price of 3.
+		{"opening accepted", priceRange{low: 2, high: 4}, priceRange{low: 2.5, high: 3.5}, 1, true, 3, 2},
+		//The buyer counters 5 halfway to its 3.25, and the seller takes 4.125.
+		{"counter accepted", priceRange{low: 4, high: 6}, priceRange{low: 2, high: 4.5}, 2, true, 4.125, 2},
+		{"no time to counter", priceRange{low: 4, high: 6}, priceRange{low: 2, high: 4.5}, 1, false, 0, 0},
+		{"never agreed", priceRange{low: 4, high: 6}, priceRange{low: 1, high: 3}, 20, false, 0, 0},
+	}
+	for _, test := range tests {
+		seller, buyer := directTraders(&config, food, test.sellerBelief, test.buyerBelief)
+		price, err := Negotiate(seller, buyer, food, 2, test.rounds)
+		if agreed := err == nil; agreed != test.agreed || math.Abs(price-test.price) > 1e-9 {
+			t.Errorf("%v: agreed %v at %v, want %v at %v", test.name, agreed, price, test.agreed, test.price)
+			continue
+		}
+		if want := 20 - float64(test.bought)*test.price; buyer.inventory[food] != test.bought || math.Abs(buyer.funds-want) > 1e-9 {
+			t.Errorf("%v: the buyer holds %v food and %v, want %v and %v", test.name, buyer.inventory[food], buyer.funds, test.bought, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// GoEconGo project diversity.go
+package main
+
+import "math"
+
+//The narrowest spread a price belief is taken to have, so that beliefs pinned to a
+//single price can still be compared.
+const minBeliefSpread = 1e-9
+
+//beliefDistribution reads a price belief as a normal distribution of prices, with
+//the same mean and standard deviation as a uniform one across the belief.
+func beliefDistribution(belief priceRange) (mean, stdDev float64) {
+	mean = (belief.low + belief.high) / 2
+	stdDev = math.Max((belief.high-belief.low)/math.Sqrt(12), minBeliefSpread)
+	return mean, stdDev
+}
+
+//klDivergence measures how far one agent's price belief is from another's, in
+//nats, as the Kullback-Leibler divergence of their beliefDistributions.  It is 0
+//for the same belief, and not symmetric.
+func klDivergence(p, q priceRange) float64 {
+	pMean, pStdDev := beliefDistribution(p)
+	qMean, qStdDev := beliefDistribution(q)
+	return math.Log(qStdDev/pStdDev) +
+		(pStdDev*pStdDev+(pMean-qMean)*(pMean-qMean))/(2*qStdDev*qStdDev) - 0.5
+}
+
+//roleDiversity returns the mean klDivergence between every ordered pair of the
+//agents' price beliefs, over every commodity both hold a belief on, and whether
+//there were any to compare.
+func roleDiversity(agents []traderAgent) (float64, bool) {
+	total := 0.0
+	compared := 0
+	for i := range agents {
+		for j := range agents {
+			if i == j {
+				continue
+			}
+			for com, belief := range agents[i].priceBelief {
+				if other, ok := agents[j].priceBelief[com]; ok {
+					total += klDivergence(belief, other)
+					compared++
+				}
+			}
+		}
+	}
+	if compared == 0 {
+		return 0, false
+	}
+	return total / float64(compared), true
+}
+
+//BehavioralDiversityIndex measures how differently agents of the same role are
+//behaving: the mean klDivergence between the price beliefs of every pair of
+//agents of a role, averaged across the roles with at least two agents.  Near 0,
+//agents of each role have come to believe the same prices; higher, they go on
+//disagreeing.  Every pair is compared, so the time it takes grows with the square
+//of the population.
+func BehavioralDiversityIndex(sim *Simulation) float64 {
+	byRole := make(map[string][]traderAgent)
+	for _, agent := range sim.Market.snapshotAgents() {
+		byRole[agent.role] = append(byRole[agent.role], agent)
+	}
+	total := 0.0
+	roles := 0
+	for _, role := range sortedKeys(byRole) {
+		if diversity, ok := roleDiversity(byRole[role]); ok {
+			total += diversity
+			roles++
+		}
+	}
+	if roles == 0 {
+		return 0
+	}
+	return total / float64(roles)
+}
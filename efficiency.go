@@ -0,0 +1,111 @@
+// GoEconGo project efficiency.go
+package main
+
+import "sort"
+
+//How much of each new production's efficiency goes into an agent's rolling
+//methodEfficiency.
+const efficiencySmoothing = 0.2
+
+//A productionRecord totals what a method has made and used up for an agent, valued
+//at the prices the agent saw when it ran.
+//outputValue - the value of everything the method has made
+//inputCost - the value of everything the method has used up
+//runs - how many times the agent has run the method
+type productionRecord struct {
+	outputValue float64
+	inputCost   float64
+	runs        int
+}
+
+//recordProduction logs a production the agent just ran and folds its efficiency
+//into the agent's rolling methodEfficiency.
+//agent - pointer to the traderAgent data set
+//method - the method that was run
+//used - the inputs and catalysts the run used up
+func recordProduction(agent *traderAgent, method *productionMethod, used []commoditySet) {
+	var outputValue, inputCost float64
+	for _, output := range skilledOutputs(agent, method) {
+		outputValue = outputValue + float64(output.quantity)*referencePrice(agent, output.item)
+	}
+	for _, input := range used {
+		inputCost = inputCost + float64(input.quantity)*referencePrice(agent, input.item)
+	}
+	if agent.productionLog == nil {
+		agent.productionLog = make(map[*productionMethod]productionRecord)
+	}
+	record := agent.productionLog[method]
+	record.outputValue = record.outputValue + outputValue
+	record.inputCost = record.inputCost + inputCost
+	record.runs++
+	agent.productionLog[method] = record
+	if inputCost <= 0 {
+		return
+	}
+	if agent.methodEfficiency == nil {
+		agent.methodEfficiency = make(map[*productionMethod]float64)
+	}
+	efficiency := outputValue / inputCost
+	if rolling, ok := agent.methodEfficiency[method]; ok {
+		efficiency = rolling + (efficiency-rolling)*efficiencySmoothing
+	}
+	agent.methodEfficiency[method] = efficiency
+}
+
+//MethodEfficiencyRating returns the value a method has made for an agent per unit
+//of value it has used up, over the agent's life.  A method the agent has never run
+//(or that has never cost it anything) rates 0.
+func MethodEfficiencyRating(agent *traderAgent, method *productionMethod) float64 {
+	record, ok := agent.productionLog[method]
+	if !ok || record.inputCost <= 0 {
+		return 0
+	}
+	return record.outputValue / record.inputCost
+}
+
+//A ProductionSelector decides the order an agent tries its production methods in.
+type ProductionSelector interface {
+	Order(agent *traderAgent, methods []*productionMethod) []*productionMethod
+}
+
+//MarketValueSelector orders methods by their value at market prices.
+type MarketValueSelector struct{}
+
+//Order returns the methods sorted by market value.  Agents share their methods, so
+//a copy is sorted rather than the methods themselves.
+func (MarketValueSelector) Order(agent *traderAgent, methods []*productionMethod) []*productionMethod {
+	ordered := make([]*productionMethod, len(methods))
+	copy(ordered, methods)
+	//BUG: This is incorrect.  However, I will test with an incorrect assumption
+	//and fix it going forward.
+	sort.Stable(ByMarketValue(ordered))
+	return ordered
+}
+
+//EfficiencySelector prefers methods that have proven efficient for the agent: each
+//method's market value is weighted by its rolling efficiency (methods the agent
+//hasn't run yet are weighted 1), and the best is tried first.
+type EfficiencySelector struct{}
+
+//Order returns the methods, best weighted value first.
+func (EfficiencySelector) Order(agent *traderAgent, methods []*productionMethod) []*productionMethod {
+	weighted := func(method *productionMethod) float64 {
+		if efficiency, ok := agent.methodEfficiency[method]; ok {
+			return getMarketValue(method) * efficiency
+		}
+		return getMarketValue(method)
+	}
+	ordered := make([]*productionMethod, len(methods))
+	copy(ordered, methods)
+	sort.SliceStable(ordered, func(i, j int) bool { return weighted(ordered[i]) > weighted(ordered[j]) })
+	return ordered
+}
+
+//selector returns the configured ProductionSelector, or a MarketValueSelector if
+//none is.
+func (config *SimulationConfig) selector() ProductionSelector {
+	if config.ProductionSelector == nil {
+		return MarketValueSelector{}
+	}
+	return config.ProductionSelector
+}
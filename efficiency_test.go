@@ -0,0 +1,43 @@
+// GoEconGo project efficiency_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMethodEfficiencyRating(t *testing.T) {
+	ore, metal := &commodity{name: "Ore", averagePrice: 3}, &commodity{name: "Metal", averagePrice: 3}
+	//Both methods are worth 3 a run at market prices, but the lean one makes 2 for
+	//every 1 it uses up, and the bulky one only 5 for every 4.
+	bulky := &productionMethod{inputs: []commoditySet{{item: ore, quantity: 4}}, outputs: []commoditySet{{item: metal, quantity: 5}}}
+	lean := &productionMethod{inputs: []commoditySet{{item: ore, quantity: 1}}, outputs: []commoditySet{{item: metal, quantity: 2}}}
+	methods := []*productionMethod{bulky, lean}
+	config := testConfig()
+	agent := newTestAgent(&config, &productionSet{methods: methods, penalty: 2}, 100, map[*commodity]int{ore: 100})
+	for _, method := range []*productionMethod{lean, bulky} {
+		if rating := MethodEfficiencyRating(agent, method); rating != 0 {
+			t.Errorf("a method never run rates %v, want 0", rating)
+		}
+	}
+	if ordered := (EfficiencySelector{}).Order(agent, methods); ordered[0] != bulky {
+		t.Fatalf("methods of the same market value reordered before either ran")
+	}
+	for i := 0; i < 5; i++ {
+		produce(agent, []*productionMethod{bulky}, 1)
+		produce(agent, []*productionMethod{lean}, 1)
+	}
+	leanRating, bulkyRating := MethodEfficiencyRating(agent, lean), MethodEfficiencyRating(agent, bulky)
+	if math.Abs(leanRating-2) > 1e-9 || math.Abs(bulkyRating-1.25) > 1e-9 {
+		t.Errorf("the lean method rates %v and the bulky one %v, want 2 and 1.25", leanRating, bulkyRating)
+	}
+	if leanRating <= bulkyRating {
+		t.Errorf("the lean method rates %v, want above the bulky one's %v", leanRating, bulkyRating)
+	}
+	if ordered := (EfficiencySelector{}).Order(agent, methods); ordered[0] != lean {
+		t.Errorf("the efficiency selector tries the bulky method first")
+	}
+	if ordered := (MarketValueSelector{}).Order(agent, methods); ordered[0] != bulky {
+		t.Errorf("the market value selector reordered methods of the same value")
+	}
+}
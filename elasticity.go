@@ -0,0 +1,98 @@
+// GoEconGo project elasticity.go
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//A GiffenAlert is raised when a commodity's buyers bid for more of it as its price
+//rises, or its sellers offer less - the wrong way round for a normal good (see
+//CheckElasticity).
+type GiffenAlert struct {
+	CommodityName    string
+	Tick             int
+	DemandElasticity float64
+	SupplyElasticity float64
+}
+
+func (e GiffenAlert) String() string {
+	return fmt.Sprintf("Inverted elasticity on %v at tick %v! Demand: %.2f, Supply: %.2f",
+		e.CommodityName, e.Tick, e.DemandElasticity, e.SupplyElasticity)
+}
+
+//logLogSlope fits log(quantity) to log(price) by least squares, leaving out ticks
+//with nothing at either.
+//Returns the slope, and whether there were at least two different prices to fit.
+func logLogSlope(prices []float64, quantities []int) (float64, bool) {
+	var xs, ys []float64
+	for i := 0; i < len(prices) && i < len(quantities); i++ {
+		if prices[i] > 0 && quantities[i] > 0 {
+			xs = append(xs, math.Log(prices[i]))
+			ys = append(ys, math.Log(float64(quantities[i])))
+		}
+	}
+	if len(xs) < 2 {
+		return 0, false
+	}
+	meanX, _ := meanStdDev(xs)
+	meanY, _ := meanStdDev(ys)
+	var covariance, variance float64
+	for i := range xs {
+		covariance += (xs[i] - meanX) * (ys[i] - meanY)
+		variance += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	return covariance / variance, true
+}
+
+//elasticity fits one of a commodity's quantity histories to its price history
+//over the last windowTicks ticks.  Until there are windowTicks of both, or if the
+//price has not moved, there is nothing to fit.
+func elasticity(com *commodity, quantities []int, windowTicks int) (float64, bool) {
+	prices := com.PriceHistory(windowTicks)
+	if windowTicks < 2 || len(prices) < windowTicks || len(quantities) < windowTicks {
+		return 0, false
+	}
+	return logLogSlope(prices, quantities)
+}
+
+//PriceElasticity estimates how much of a commodity trades as its price moves: the
+//slope of log(volume) against log(price) over the last windowTicks ticks.  As the
+//volume that trades is set by supply and demand together, it reads as the demand
+//elasticity when supply shifts most, and the supply elasticity when demand does.
+//Until there are windowTicks of history, or if the price has not moved, it is 0.
+func PriceElasticity(com *commodity, windowTicks int) float64 {
+	slope, _ := elasticity(com, com.VolumeHistory(windowTicks), windowTicks)
+	return slope
+}
+
+//recordAsked records how many units of a commodity were asked for this tick, from
+//its ask book.
+func (m *Market) recordAsked(com *commodity, asksCom []*asks) {
+	asked := 0
+	for _, askSet := range asksCom {
+		asked += askSet.numberOffered
+	}
+	com.askRing.push(asked)
+}
+
+//CheckElasticity raises a GiffenAlert for every commodity whose demand elasticity
+//(units bid for against price) came out positive, or whose supply elasticity
+//(units asked for against price) came out negative, over the last
+//ElasticityWindow ticks.  It is called once a tick, once the prices are recorded.
+func (m *Market) CheckElasticity() {
+	window := m.config.ElasticityWindow
+	if window <= 0 {
+		return
+	}
+	for _, com := range m.sortedCommodities() {
+		demand, demandOK := elasticity(com, com.bidRing.last(window), window)
+		supply, supplyOK := elasticity(com, com.askRing.last(window), window)
+		if (demandOK && demand > 0) || (supplyOK && supply < 0) {
+			m.Emit(GiffenAlert{CommodityName: com.name, Tick: m.tick, DemandElasticity: demand, SupplyElasticity: supply})
+		}
+	}
+}
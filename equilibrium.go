@@ -0,0 +1,80 @@
+// GoEconGo project equilibrium.go
+package main
+
+//An EquilibriumDetector watches a market's price histories and declares
+//equilibrium once every commodity's price has held steady at the same time - and,
+//if it is asked to, once agents have come to agree on every price, and agents of
+//each role to behave alike.
+//Window - how many of the latest closing prices of each commodity are looked at
+//Epsilon - how small the standard deviation of those prices must be
+//BeliefEpsilon - how small every commodity's latest BeliefWidth must be (0 is
+//any)
+//DiversityEpsilon - how small the BehavioralDiversityIndex must be (0 is any)
+//reached - whether the market was in equilibrium at the last Check
+//hooks - functions called whenever the market reaches equilibrium
+type EquilibriumDetector struct {
+	Window           int
+	Epsilon          float64
+	BeliefEpsilon    float64
+	DiversityEpsilon float64
+	reached          bool
+	hooks            []func(tick int, prices map[string]float64)
+}
+
+//newEquilibriumDetector builds a detector looking at the last window prices of
+//each commodity.
+func newEquilibriumDetector(window int, epsilon float64) *EquilibriumDetector {
+	d := new(EquilibriumDetector)
+	d.Window = window
+	d.Epsilon = epsilon
+	return d
+}
+
+//OnEquilibrium registers a function to be called with the tick and every
+//commodity's averagePrice, by name, whenever the market reaches equilibrium.
+func (d *EquilibriumDetector) OnEquilibrium(fn func(tick int, prices map[string]float64)) {
+	d.hooks = append(d.hooks, fn)
+}
+
+//steady reports whether every commodity's last Window prices have a standard
+//deviation below Epsilon, and its latest BeliefWidth is below BeliefEpsilon,
+//and the BehavioralDiversityIndex is below DiversityEpsilon.  Commodities without
+//Window prices yet are not steady.
+func (d *EquilibriumDetector) steady(m *Market) bool {
+	for _, com := range m.sortedCommodities() {
+		history := com.PriceHistory(d.Window)
+		if len(history) < d.Window || len(history) == 0 {
+			return false
+		}
+		if _, deviation := meanStdDev(history); deviation >= d.Epsilon {
+			return false
+		}
+		if d.BeliefEpsilon > 0 {
+			if latest := com.BeliefWidthHistory(1); len(latest) == 0 || latest[0] >= d.BeliefEpsilon {
+				return false
+			}
+		}
+	}
+	if d.DiversityEpsilon > 0 && BehavioralDiversityIndex(m.simulation()) >= d.DiversityEpsilon {
+		return false
+	}
+	return true
+}
+
+//Check looks at the market as it stands after a tick, calling the OnEquilibrium
+//hooks if it has just reached equilibrium.
+//Returns whether the market is in equilibrium.
+func (d *EquilibriumDetector) Check(m *Market) bool {
+	steady := d.steady(m)
+	if steady && !d.reached {
+		prices := make(map[string]float64)
+		for name, com := range m.commodities {
+			prices[name] = com.averagePrice
+		}
+		for _, hook := range d.hooks {
+			hook(m.tick, prices)
+		}
+	}
+	d.reached = steady
+	return steady
+}
@@ -0,0 +1,151 @@
+// GoEconGo project export.go
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//How many rows a CSV export writes between flushes, unless configured otherwise.
+const defaultCSVFlushRows = 100
+
+//The roles whose populations ExportCSV has a column for.
+var csvRoles = []string{"Farmer", "Miner", "Refiner", "Woodcutter", "Blacksmith"}
+
+//A csvExport writes rows to a CSV file as the market ticks.
+//mu - guards everything below, between the market writing rows and shutting down
+//file - the file being written
+//writer - buffers rows on their way to file
+//rows - how many rows have been written
+//flushRows - how many rows are written between flushes
+//closed - whether the file has been closed
+type csvExport struct {
+	mu        sync.Mutex
+	file      *os.File
+	writer    *csv.Writer
+	rows      int
+	flushRows int
+	closed    bool
+}
+
+//write adds rows to the file, flushing every flushRows rows.
+func (e *csvExport) write(rows [][]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	for _, row := range rows {
+		e.writer.Write(row)
+		e.rows++
+		if e.flushRows <= 0 || e.rows%e.flushRows == 0 {
+			e.writer.Flush()
+		}
+	}
+}
+
+//close flushes whatever rows are left and closes the file.
+func (e *csvExport) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	e.writer.Flush()
+	e.file.Close()
+	e.closed = true
+}
+
+//startCSVExport creates a CSV file with a header, and writes rows to it at the end
+//of every tick from then on until the market shuts down.  It is safe to call while
+//the simulation runs - it waits for the current tick to finish.
+//header - the names of the columns
+//rows - makes the rows for a tick
+func startCSVExport(path string, sim *Simulation, header []string, rows func(m *Market) [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	export := &csvExport{file: file, writer: csv.NewWriter(file)}
+	if err := export.writer.Write(header); err != nil {
+		file.Close()
+		return err
+	}
+	sim.Do(func() {
+		m := sim.Market
+		export.flushRows = m.config.CSVFlushRows
+		m.OnTick(func(m *Market) {
+			export.write(rows(m))
+		})
+		m.OnShutdown(export.close)
+	})
+	return nil
+}
+
+//formatFloat writes a number the way CSV exports do.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+//ExportCSV writes a simulation's time series to a CSV file, from now until the
+//market shuts down: a row per commodity per tick, with the columns
+//tick, commodity_name, average_price, volume, spread - the commodity's market
+//num_farmers, num_miners, num_refiners, num_woodcutters, num_blacksmiths - the
+//population of each role
+//gini_coefficient - the GiniCoefficient of agents' funds
+//total_transactions - how many trades cleared in the whole market that tick
+//Rows are flushed to disk every CSVFlushRows rows, so long runs don't pile up in
+//memory.
+//Returns an error if the file can't be created.
+func ExportCSV(path string, sim *Simulation) error {
+	header := []string{"tick", "commodity_name", "average_price", "volume", "spread"}
+	for _, role := range csvRoles {
+		header = append(header, "num_"+strings.ToLower(role)+"s")
+	}
+	header = append(header, "gini_coefficient", "total_transactions")
+	return startCSVExport(path, sim, header, func(m *Market) [][]string {
+		gini, trades := m.metrics.lastTick()
+		var rows [][]string
+		for _, com := range m.sortedCommodities() {
+			spread := 0.0
+			if spreads := com.spreadRing.last(1); len(spreads) > 0 {
+				spread = spreads[0]
+			}
+			row := []string{strconv.Itoa(m.tick), com.name, formatFloat(com.averagePrice), strconv.Itoa(com.TickVolume), formatFloat(spread)}
+			for _, role := range csvRoles {
+				row = append(row, strconv.Itoa(m.population[role]))
+			}
+			row = append(row, formatFloat(gini), strconv.Itoa(trades))
+			rows = append(rows, row)
+		}
+		return rows
+	})
+}
+
+//ExportAgentCSV writes every agent's wealth at the end of every tick to a CSV
+//file, from now until the market shuts down, with the columns
+//tick, agent_id, role, funds, net_worth
+//revenue, cost, production_penalties, profit, profit_margin - the agent's profit
+//and loss over its life (see Profit)
+//Rows are flushed to disk every CSVFlushRows rows.
+//Returns an error if the file can't be created.
+func ExportAgentCSV(path string, sim *Simulation) error {
+	header := []string{"tick", "agent_id", "role", "funds", "net_worth",
+		"revenue", "cost", "production_penalties", "profit", "profit_margin"}
+	return startCSVExport(path, sim, header, func(m *Market) [][]string {
+		var rows [][]string
+		for _, slot := range m.agentSlots() {
+			agent := m.agents[slot]
+			agent.mu.Lock()
+			row := []string{strconv.Itoa(m.tick), strconv.FormatUint(agent.id, 10), agent.role, formatFloat(agent.funds), formatFloat(NetWorth(agent)),
+				formatFloat(agent.Revenue), formatFloat(agent.Cost), formatFloat(agent.ProductionPenalties),
+				formatFloat(agent.Profit()), formatFloat(agent.ProfitMargin())}
+			agent.mu.Unlock()
+			rows = append(rows, row)
+		}
+		return rows
+	})
+}
@@ -0,0 +1,43 @@
+// GoEconGo project externality.go
+package main
+
+//chargeExternalities makes every agent pay for the externalities held across the
+//whole market.  The level of each externality is the total held by all agents,
+//and every agent pays that level times ExternalityCostPerUnit whether or not they
+//produced any of it.
+func (m *Market) chargeExternalities() {
+	if m.config.ExternalityCostPerUnit == 0 {
+		return
+	}
+	//First, see how much is out there.
+	level := 0
+	for _, agent := range m.agents {
+		agent.mu.Lock()
+		for com, num := range agent.inventory {
+			if com.IsExternality {
+				level += num
+			}
+		}
+		agent.mu.Unlock()
+	}
+	//Then, everybody pays for it.
+	cost := float64(level) * m.config.ExternalityCostPerUnit
+	for _, agent := range m.agents {
+		agent.mu.Lock()
+		agent.funds = agent.funds - cost
+		m.Ledger.Record(agent.id, 0, cost, m.tick, ledgerExternality)
+		agent.mu.Unlock()
+		m.statistics.TotalExternalityCost += cost
+	}
+}
+
+//collectCarbonTax gathers up the carbon tax agents have paid on their production
+//since the last tick.
+func (m *Market) collectCarbonTax() {
+	for _, agent := range m.agents {
+		agent.mu.Lock()
+		m.statistics.CarbonTaxRevenue += agent.carbonTaxPaid
+		agent.carbonTaxPaid = 0
+		agent.mu.Unlock()
+	}
+}
@@ -0,0 +1,45 @@
+// GoEconGo project externality_test.go
+package main
+
+import "testing"
+
+//smeltingMarket returns a market of a smelter holding 10 ore and a farmer who
+//makes nothing dirty, under config.
+func smeltingMarket(config SimulationConfig) (m *Market, smelter *traderAgent, farmer *traderAgent, pollution *commodity) {
+	ore, metal := &commodity{name: "Ore", averagePrice: 3}, &commodity{name: "Metal", averagePrice: 3}
+	pollution = &commodity{name: "Pollution", IsExternality: true}
+	m = newMarket(map[string]*commodity{"Ore": ore, "Metal": metal, "Pollution": pollution}, config)
+	smelter = newTestAgent(&m.config, smeltingJob(ore, metal, pollution), 100, map[*commodity]int{ore: 10})
+	farmer = newTestAgent(&m.config, nil, 100, make(map[*commodity]int))
+	m.agents[0], m.agents[1] = smelter, farmer
+	return m, smelter, farmer, pollution
+}
+
+func TestChargeExternalities(t *testing.T) {
+	//Smelt some number of times, then charge everybody for the pollution.
+	charge := func(smelts int) (total float64, farmerPaid float64) {
+		config := testConfig()
+		config.ExternalityCostPerUnit = 0.5
+		m, smelter, farmer, pollution := smeltingMarket(config)
+		for i := 0; i < smelts; i++ {
+			performProduction(smelter)
+		}
+		if smelter.inventory[pollution] != smelts {
+			t.Fatalf("%v smelts left %v pollution", smelts, smelter.inventory[pollution])
+		}
+		m.chargeExternalities()
+		return m.statistics.TotalExternalityCost, 100 - farmer.funds
+	}
+	if total, farmerPaid := charge(0); total != 0 || farmerPaid != 0 {
+		t.Errorf("no pollution cost %v, and the farmer %v", total, farmerPaid)
+	}
+	lowTotal, lowPaid := charge(2)
+	highTotal, highPaid := charge(6)
+	if lowTotal != 2*2*0.5 || highTotal != 2*6*0.5 {
+		t.Errorf("2 and 6 pollution cost %v and %v, want %v and %v", lowTotal, highTotal, 2*2*0.5, 2*6*0.5)
+	}
+	//The farmer never made any of it, but pays all the same.
+	if lowPaid != 2*0.5 || highPaid != 6*0.5 {
+		t.Errorf("the farmer paid %v and %v for 2 and 6 pollution, want %v and %v", lowPaid, highPaid, 2*0.5, 6*0.5)
+	}
+}
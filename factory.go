@@ -0,0 +1,112 @@
+// GoEconGo project factory.go
+package main
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+//The id last given to an agent (see newAgentID).
+var lastAgentID atomic.Uint64
+
+//newAgentID returns an agent id that has never been given out before.
+func newAgentID() uint64 {
+	return lastAgentID.Add(1)
+}
+
+//reserveAgentID makes sure newAgentID never gives out an id already taken, such
+//as one restored from a checkpoint.
+func reserveAgentID(id uint64) {
+	for {
+		last := lastAgentID.Load()
+		if last >= id || lastAgentID.CompareAndSwap(last, id) {
+			return
+		}
+	}
+}
+
+//An AgentConfig describes how a role's agents start out.
+//FundsMin, FundsMax - the range new agents' funds are drawn from
+//Grants - the range [min, max] of units of each commodity new agents are handed,
+//by commodity name (only while grantGoods is set)
+//RiskAversionRange - the range [min, max] new agents' riskAversion is drawn from
+//CyclesToCover - how many production cycles' worth of inputs new agents bid to
+//keep in stock, from minCyclesToCover to maxCyclesToCover (see coverCycles)
+//ResponseTimeout - how long new agents wait on the market for the results of
+//their offers before giving up and dying (0 is forever).  It must outlast a tick,
+//and any pause between ticks, or healthy agents die waiting.
+type AgentConfig struct {
+	FundsMin          float64
+	FundsMax          float64
+	Grants            map[string][2]int
+	RiskAversionRange [2]int
+	CyclesToCover     int
+	ResponseTimeout   time.Duration
+}
+
+//How an agent of a role without an AgentConfig of its own starts out: with
+//nothing but its funds.
+var defaultAgentConfig = AgentConfig{FundsMin: 50, FundsMax: 100, RiskAversionRange: [2]int{1, 4},
+	CyclesToCover: defaultCyclesToCover}
+
+//How each built in role's agents start out.
+var roleAgentConfigs = map[string]AgentConfig{
+	"Farmer": {FundsMin: 50, FundsMax: 100, RiskAversionRange: [2]int{1, 4}, CyclesToCover: defaultCyclesToCover,
+		Grants: map[string][2]int{"Tools": {0, 1}, "Wood": {2, 5}}},
+	"Miner": {FundsMin: 50, FundsMax: 100, RiskAversionRange: [2]int{1, 4}, CyclesToCover: defaultCyclesToCover,
+		Grants: map[string][2]int{"Tools": {0, 1}, "Food": {2, 5}}},
+	"Refiner": {FundsMin: 50, FundsMax: 100, RiskAversionRange: [2]int{1, 4}, CyclesToCover: defaultCyclesToCover,
+		Grants: map[string][2]int{"Ore": {2, 4}, "Food": {2, 5}, "Tools": {0, 1}}},
+	"Woodcutter": {FundsMin: 50, FundsMax: 100, RiskAversionRange: [2]int{1, 4}, CyclesToCover: defaultCyclesToCover,
+		Grants: map[string][2]int{"Tools": {0, 1}, "Food": {2, 5}}},
+	"Blacksmith": {FundsMin: 50, FundsMax: 100, RiskAversionRange: [2]int{1, 4}, CyclesToCover: defaultCyclesToCover,
+		Grants: map[string][2]int{"Metal": {2, 4}, "Food": {2, 5}}},
+}
+
+//randomBetween draws an integer from [low, high].
+func randomBetween(config SimulationConfig, bounds [2]int) int {
+	if bounds[1] <= bounds[0] {
+		return bounds[0]
+	}
+	return bounds[0] + config.random().Intn(bounds[1]-bounds[0]+1)
+}
+
+//makeAgent makes an agent of a role, starting out as cfg describes.  Grants are
+//drawn in alphabetical order of commodity, and grants of commodities the market
+//doesn't trade are left out.
+//role - the agent's role
+//cfg - how the agent starts out
+//prodSet - the agent's job
+func makeAgent(role string, cfg AgentConfig, commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+	var agentOut traderAgent
+	agentOut.id = newAgentID()
+	agentOut.role = role
+	agentOut.funds = cfg.FundsMin + (config.random().Float64() * (cfg.FundsMax - cfg.FundsMin))
+	agentOut.inventory = make(map[*commodity]int)
+	if grantGoods {
+		names := make([]string, 0, len(cfg.Grants))
+		for name := range cfg.Grants {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			num := randomBetween(config, cfg.Grants[name])
+			if com, ok := commodityList[name]; ok {
+				agentOut.inventory[com] = num
+			}
+		}
+	}
+	agentOut.job = prodSet
+	agentOut.priceBelief = initialPriceBelief(commodityList, config.BeliefInitSpread)
+	informAgent(&agentOut, config)
+	agentOut.riskAversion = randomBetween(config, cfg.RiskAversionRange)
+	agentOut.cyclesToCover = clampCycles(cfg.CyclesToCover)
+	agentOut.responseTimeout = cfg.ResponseTimeout
+	agentOut.beliefAdjustBig, agentOut.beliefAdjustSmall = config.beliefAdjustRates(agentOut.role)
+	agentOut.maxInventory = config.maxInventory(agentOut.role)
+	agentOut.maxAge = config.lifespan()
+	agentOut.tradeFutures = config.TradeFutures[agentOut.role]
+	runInitHooks(&agentOut, commodityList, config)
+	return agentOut
+}
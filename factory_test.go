@@ -0,0 +1,30 @@
+// GoEconGo project factory_test.go
+package main
+
+import "testing"
+
+func TestInitHooks(t *testing.T) {
+	config := testConfig()
+	hooked := 0
+	config.InitHooks["Farmer"] = []AgentInitHook{func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+		hooked++
+		agent.riskAversion = 5
+	}}
+	sim := newTestSimulation(t, config, 3)
+	m := sim.Market
+	for i := 0; i < 2; i++ {
+		m.spawn("Farmer")
+	}
+	RunTicks(10, sim)
+	farmers := agentsOf(m, "Farmer")
+	if len(farmers) < 5 || hooked < len(farmers) {
+		t.Fatalf("the hook ran %v times for %v farmers, want once for each of at least 5", hooked, len(farmers))
+	}
+	for _, agent := range farmers {
+		agent.mu.Lock()
+		if agent.riskAversion != 5 {
+			t.Errorf("farmer %v started with riskAversion %v, want 5", agent.id, agent.riskAversion)
+		}
+		agent.mu.Unlock()
+	}
+}
@@ -0,0 +1,142 @@
+// GoEconGo project fanin.go
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+//A fanned value is one taken by a fan-in, along with which source it came from.
+//index - the index of the source
+//source - the channel it was taken from, which may since have been replaced at
+//index
+//value - what was received
+type fanned[T any] struct {
+	index  int
+	source chan T
+	value  T
+}
+
+//A forwarder passes on what one source of a fanIn sends.
+//resume - lets it take its source's next value
+//quit - stops it
+type forwarder struct {
+	resume chan struct{}
+	quit   chan struct{}
+}
+
+//A fanIn merges many channels into one, so that the receiver does a single
+//blocking receive for each value sent, however many sources sit idle.  Every
+//source has a long-lived forwarder of its own, blocked on it until it sends.  A
+//source may be answered on the channel it sent on (as agents are), so once a
+//forwarder has passed a value on it takes nothing more until it is resumed.
+//C - the merged channel, closed once the fan-in has stopped and every forwarder
+//with it
+//ctx - stops the fan-in once done
+//stop - cancels ctx
+//running - counts the forwarders still running
+//mu - guards stopping and forwarders
+//stopping - whether ctx is done, so that no more forwarders are started
+//forwarders - the forwarder of each source, by index
+type fanIn[T any] struct {
+	C          chan fanned[T]
+	ctx        context.Context
+	stop       context.CancelFunc
+	running    sync.WaitGroup
+	mu         sync.Mutex
+	stopping   bool
+	forwarders map[int]forwarder
+}
+
+//newFanIn starts a forwarder on each of sources (nil sources are skipped).  Once
+//ctx is done, or the fan-in is stopped, the forwarders take nothing more.  Any
+//value a forwarder has already taken is still passed on, so the receiver takes
+//what is left in C until it is closed.
+//sources - the channels to merge, by index
+//size - how many values C holds before forwarders wait on the receiver
+func newFanIn[T any](ctx context.Context, sources []chan T, size int) *fanIn[T] {
+	f := &fanIn[T]{C: make(chan fanned[T], size), forwarders: make(map[int]forwarder)}
+	f.ctx, f.stop = context.WithCancel(ctx)
+	for index, source := range sources {
+		f.add(index, source)
+	}
+	go func() {
+		<-f.ctx.Done()
+		f.mu.Lock()
+		f.stopping = true
+		f.mu.Unlock()
+		f.running.Wait()
+		close(f.C)
+	}()
+	return f
+}
+
+//add starts a forwarder on a source, stopping the one the index had before.
+//index - the index the source's values are tagged with
+//source - the channel to forward from (nil only stops the old forwarder)
+func (f *fanIn[T]) add(index int, source chan T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if old, ok := f.forwarders[index]; ok {
+		close(old.quit)
+		delete(f.forwarders, index)
+	}
+	if source == nil || f.stopping {
+		return
+	}
+	//Room to be resumed before it has finished passing its value on
+	fwd := forwarder{resume: make(chan struct{}, 1), quit: make(chan struct{})}
+	f.forwarders[index] = fwd
+	f.running.Add(1)
+	go f.forward(index, source, fwd)
+}
+
+//remove stops the forwarder of a source.
+//index - the index of the source
+func (f *fanIn[T]) remove(index int) {
+	f.add(index, nil)
+}
+
+//resume lets the forwarder of a source take its next value, once whatever
+//answered its last one is done with the source.
+//index - the index of the source
+func (f *fanIn[T]) resume(index int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if fwd, ok := f.forwarders[index]; ok {
+		select {
+		case fwd.resume <- struct{}{}:
+		default:
+		}
+	}
+}
+
+//forward passes on every value a source sends, one at a time, until it is told
+//to quit or the fan-in stops.
+func (f *fanIn[T]) forward(index int, source chan T, fwd forwarder) {
+	defer f.running.Done()
+	for {
+		var value T
+		select {
+		case value = <-source:
+		case <-fwd.quit:
+			return
+		case <-f.ctx.Done():
+			return
+		}
+		//The source is waiting to be answered, so what it sent is passed on even
+		//if the fan-in stops in the meantime.
+		select {
+		case f.C <- fanned[T]{index, source, value}:
+		case <-fwd.quit:
+			return
+		}
+		select {
+		case <-fwd.resume:
+		case <-fwd.quit:
+			return
+		case <-f.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// GoEconGo project fanin_test.go
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFanInRoutesByIndex(t *testing.T) {
+	sources := []chan int{make(chan int), nil, make(chan int), make(chan int)}
+	f := newFanIn(context.Background(), sources, len(sources))
+	t.Cleanup(f.stop)
+	//Every live source sends its index times ten, then waits to be answered on the
+	//same channel, as agents do.
+	answers := make(chan int, len(sources))
+	for index, source := range sources {
+		if source == nil {
+			continue
+		}
+		go func(index int, source chan int) {
+			source <- index * 10
+			answers <- <-source
+		}(index, source)
+	}
+	for i := 0; i < 3; i++ {
+		in := <-f.C
+		if in.value != in.index*10 {
+			t.Errorf("source %v sent %v, want %v", in.index, in.value, in.index*10)
+		}
+		if in.source != sources[in.index] {
+			t.Errorf("value tagged %v didn't come from source %v", in.index, in.index)
+		}
+		//Nothing more is taken from the source until it is resumed, so the answer
+		//goes to its sender.
+		in.source <- in.index
+		if answer := <-answers; answer != in.index {
+			t.Errorf("source %v was answered with %v, want %v", in.index, answer, in.index)
+		}
+		f.resume(in.index)
+	}
+	select {
+	case in := <-f.C:
+		t.Errorf("got %v from source %v after every source had sent", in.value, in.index)
+	case <-time.After(10 * time.Millisecond):
+	}
+	//A resumed source is forwarded from again.
+	sources[2] <- 7
+	if in := <-f.C; in.index != 2 || in.value != 7 {
+		t.Errorf("got %v from source %v, want 7 from source 2", in.value, in.index)
+	}
+}
+
+func TestFanInStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sources := []chan string{make(chan string), make(chan string)}
+	f := newFanIn(ctx, sources, len(sources))
+	//A source added later is stopped along with the rest.
+	f.add(2, make(chan string))
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		for range f.C {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the fan-in was still open a second after its context was done")
+	}
+	//Nothing is forwarded once stopped.
+	select {
+	case sources[0] <- "late":
+		t.Error("a stopped fan-in took a value")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestRealTimeTicking(t *testing.T) {
+	sim := newTestSimulation(t, testConfig(), 5)
+	m := sim.Market
+	collected := 0
+	for i := 0; i < 20; i++ {
+		//Give the agents time to make their offers, as the real time ticker does.
+		time.Sleep(5 * time.Millisecond)
+		m.Tick()
+		collected += len(m.collected)
+	}
+	if collected == 0 {
+		t.Fatal("20 real time ticks collected no offers")
+	}
+	//Switching to RunTicks carries on from there.
+	if tick := RunTicks(5, sim); tick != 25 {
+		t.Errorf("RunTicks stopped at tick %v, want 25", tick)
+	}
+	if m.offers != nil {
+		t.Error("the fan-in is still running under RunTicks")
+	}
+}
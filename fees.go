@@ -0,0 +1,22 @@
+// GoEconGo project fees.go
+package main
+
+import "math"
+
+//chargeFee takes the TransactionFeePercent of a matched trade's price as a fee,
+//half of it off what the seller receives and half of it on top of what the buyer
+//pays, and adds what was taken to the FeePool.
+//askSet, bidSet - the matched ask and bid, already priced
+//price - the price they matched at
+//matched - how many of each were matched
+func (m *Market) chargeFee(askSet *asks, bidSet *bids, price float64, matched int) {
+	half := price * m.config.TransactionFeePercent / 100 / 2
+	if half <= 0 {
+		return
+	}
+	sellerCut := math.Min(half, askSet.offeredAsk.sellFor)
+	askSet.offeredAsk.sellFor = askSet.offeredAsk.sellFor - sellerCut
+	bidSet.offeredBid.buyFor = bidSet.offeredBid.buyFor + half
+	m.FeePool = m.FeePool + sellerCut*float64(askSet.offeredAsk.quantity*matched) +
+		half*float64(bidSet.offeredBid.quantity*matched)
+}
@@ -0,0 +1,267 @@
+// GoEconGo project futures.go
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+//How many ticks ahead futures are traded for, unless the SimulationConfig says
+//otherwise.
+const defaultFuturesDeliveryTicks = 5
+
+//A FuturesContract binds a seller to deliver a commodity to a buyer on a later
+//tick, at a price agreed now.
+//commodity - what is to be delivered
+//deliveryTick - the tick it is to be delivered on
+//quantity - how many units
+//price - what the buyer pays for each unit
+//buyerID, sellerID - the ids of the agents bound by it
+type FuturesContract struct {
+	commodity    *commodity
+	deliveryTick int
+	quantity     int
+	price        float64
+	buyerID      uint64
+	sellerID     uint64
+}
+
+//A FuturesMarket matches asks and bids for later delivery (those with a
+//deliveryTick) into FuturesContracts, and carries the contracts out when they
+//come due.
+//market - the market whose agents are trading futures
+//asks, bids - this tick's futures offers
+//contracts - the contracts not yet delivered
+type FuturesMarket struct {
+	market    *Market
+	asks      []*asks
+	bids      []*bids
+	contracts []FuturesContract
+}
+
+//A FuturesDeliveryEvent is raised whenever a FuturesContract is carried out.
+//Shortfall is how many units the seller couldn't deliver, and settled in cash.
+type FuturesDeliveryEvent struct {
+	CommodityName string
+	Tick          int
+	SellerID      uint64
+	BuyerID       uint64
+	Delivered     int
+	Shortfall     int
+	Price         float64
+}
+
+func (e FuturesDeliveryEvent) String() string {
+	return fmt.Sprintf("Agent %v delivered %v %v (%v short) to agent %v at %v at tick %v",
+		e.SellerID, e.Delivered, e.CommodityName, e.Shortfall, e.BuyerID, e.Price, e.Tick)
+}
+
+//newFuturesMarket builds an empty futures market for a market's agents.
+func newFuturesMarket(m *Market) *FuturesMarket {
+	fm := new(FuturesMarket)
+	fm.market = m
+	return fm
+}
+
+//A futuresKey is one futures book: a commodity for delivery on a tick.
+type futuresKey struct {
+	com          *commodity
+	deliveryTick int
+}
+
+//MatchFutures matches this tick's futures offers, book by book, lowest ask against
+//highest bid, just as the spot market does.  Every match becomes a contract at
+//the market's PricingRule.  Offers are told how much of them matched, and are
+//never split.
+func (fm *FuturesMarket) MatchFutures() {
+	m := fm.market
+	var keys []futuresKey
+	askBooks := make(map[futuresKey][]*asks)
+	bidBooks := make(map[futuresKey][]*bids)
+	for _, askSet := range fm.asks {
+		key := futuresKey{askSet.offeredAsk.item, askSet.offeredAsk.deliveryTick}
+		if _, ok := askBooks[key]; !ok {
+			keys = append(keys, key)
+		}
+		askBooks[key] = append(askBooks[key], askSet)
+	}
+	for _, bidSet := range fm.bids {
+		key := futuresKey{bidSet.offeredBid.item, bidSet.offeredBid.deliveryTick}
+		bidBooks[key] = append(bidBooks[key], bidSet)
+	}
+	for _, key := range keys {
+		asksBook, bidsBook := askBooks[key], bidBooks[key]
+		sort.Stable(AsksLowToHigh(asksBook))
+		sort.Stable(BidsHighToLow(bidsBook))
+		asksIndex, bidsIndex := 0, 0
+		for asksIndex < len(asksBook) && bidsIndex < len(bidsBook) {
+			askSet, bidSet := asksBook[asksIndex], bidsBook[bidsIndex]
+			if askSet.offeredAsk.sellFor > bidSet.offeredBid.buyFor {
+				break
+			}
+			matched := askSet.numberOffered - askSet.numberAccepted
+			if left := bidSet.numberOffered - bidSet.numberAccepted; left < matched {
+				matched = left
+			}
+			askSet.numberAccepted += matched
+			bidSet.numberAccepted += matched
+			fm.contracts = append(fm.contracts, FuturesContract{
+				commodity:    key.com,
+				deliveryTick: key.deliveryTick,
+				quantity:     matched * askSet.offeredAsk.quantity,
+				price:        m.matchPrice(askSet.offeredAsk.sellFor, bidSet.offeredBid.buyFor),
+				buyerID:      bidSet.offeredBid.id,
+				sellerID:     askSet.offeredAsk.id,
+			})
+			if askSet.numberAccepted >= askSet.numberOffered {
+				asksIndex++
+			}
+			if bidSet.numberAccepted >= bidSet.numberOffered {
+				bidsIndex++
+			}
+		}
+	}
+}
+
+//Deliver carries out every contract due by the current tick.  The seller hands
+//over as many units as it holds, up to the contract's quantity, and the buyer pays
+//the contract price for them.  Any shortfall is settled in cash: the seller pays
+//the buyer the difference between the commodity's averagePrice and the contract
+//price on each missing unit (or is paid it, if the price has fallen).
+func (fm *FuturesMarket) Deliver() {
+	m := fm.market
+	var pending []FuturesContract
+	for _, contract := range fm.contracts {
+		if contract.deliveryTick > m.tick {
+			pending = append(pending, contract)
+			continue
+		}
+		fm.deliver(contract)
+	}
+	fm.contracts = pending
+}
+
+//deliver carries out one contract, if both agents are still running.
+func (fm *FuturesMarket) deliver(contract FuturesContract) {
+	m := fm.market
+	seller, _, ok := m.agentByID(contract.sellerID)
+	if !ok {
+		return
+	}
+	buyer, _, ok := m.agentByID(contract.buyerID)
+	if !ok {
+		return
+	}
+	//Always lock the lower id first, as swaps do.
+	first, second := seller, buyer
+	if contract.sellerID > contract.buyerID {
+		first, second = buyer, seller
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	if second != first {
+		second.mu.Lock()
+		defer second.mu.Unlock()
+	}
+	com := contract.commodity
+	delivered := contract.quantity
+	if held := seller.inventory[com]; held < delivered {
+		delivered = held
+	}
+	if delivered < 0 {
+		delivered = 0
+	}
+	seller.inventory[com] = seller.inventory[com] - delivered
+	buyer.inventory[com] = buyer.inventory[com] + delivered
+	payment := contract.price * float64(delivered)
+	shortfall := contract.quantity - delivered
+	payment = payment - (com.averagePrice-contract.price)*float64(shortfall)
+	buyer.funds = buyer.funds - payment
+	seller.funds = seller.funds + payment
+	m.Ledger.Record(buyer.id, seller.id, payment, m.tick, ledgerFutures)
+	m.Emit(FuturesDeliveryEvent{CommodityName: com.name, Tick: m.tick, SellerID: contract.sellerID,
+		BuyerID: contract.buyerID, Delivered: delivered, Shortfall: shortfall, Price: contract.price})
+}
+
+//cancel drops every contract an agent is bound by, for when it leaves the market.
+func (fm *FuturesMarket) cancel(id uint64) {
+	var kept []FuturesContract
+	for _, contract := range fm.contracts {
+		if contract.buyerID != id && contract.sellerID != id {
+			kept = append(kept, contract)
+		}
+	}
+	fm.contracts = kept
+}
+
+//futuresCommodities returns the commodities an agent's job makes and uses up, each
+//once, in alphabetical order.
+func futuresCommodities(job *productionSet) ([]*commodity, []*commodity) {
+	made := make(map[*commodity]bool)
+	used := make(map[*commodity]bool)
+	for _, method := range job.methods {
+		for _, output := range method.outputs {
+			if !output.item.IsExternality {
+				made[output.item] = true
+			}
+		}
+		for _, input := range method.inputs {
+			if !input.item.IsCommonPool {
+				used[input.item] = true
+			}
+		}
+	}
+	return sortedComs(made), sortedComs(used)
+}
+
+//sortedComs returns the commodities of a set in alphabetical order.
+func sortedComs(set map[*commodity]bool) []*commodity {
+	coms := make([]*commodity, 0, len(set))
+	for com := range set {
+		coms = append(coms, com)
+	}
+	sort.Slice(coms, func(i, j int) bool { return coms[i].name < coms[j].name })
+	return coms
+}
+
+//generateFuturesAsks has an agent that trades futures offer to sell a unit of
+//everything its job makes, for delivery FuturesDeliveryTicks from now, at the
+//middle of its price belief.
+func generateFuturesAsks(agent *traderAgent) []asks {
+	if !agent.tradeFutures || agent.job == nil {
+		return nil
+	}
+	made, _ := futuresCommodities(agent.job)
+	var askSlice []asks
+	for _, com := range made {
+		var askBuild asks
+		askBuild.numberOffered = 1
+		askBuild.offeredAsk.quantity = 1
+		askBuild.offeredAsk.item = com
+		askBuild.offeredAsk.sellFor = (agent.priceBelief[com].high + agent.priceBelief[com].low) / 2
+		askBuild.offeredAsk.deliveryTick = agent.tick + agent.settings().FuturesDeliveryTicks
+		askSlice = append(askSlice, askBuild)
+	}
+	return askSlice
+}
+
+//generateFuturesBids has an agent that trades futures offer to buy a unit of
+//everything its job uses up, for delivery FuturesDeliveryTicks from now, at the
+//middle of its price belief.
+func generateFuturesBids(agent *traderAgent) []bids {
+	if !agent.tradeFutures || agent.job == nil {
+		return nil
+	}
+	_, used := futuresCommodities(agent.job)
+	var bidSlice []bids
+	for _, com := range used {
+		var bidBuild bids
+		bidBuild.numberOffered = 1
+		bidBuild.offeredBid.quantity = 1
+		bidBuild.offeredBid.item = com
+		bidBuild.offeredBid.buyFor = (agent.priceBelief[com].high + agent.priceBelief[com].low) / 2
+		bidBuild.offeredBid.deliveryTick = agent.tick + agent.settings().FuturesDeliveryTicks
+		bidSlice = append(bidSlice, bidBuild)
+	}
+	return bidSlice
+}
@@ -0,0 +1,78 @@
+// GoEconGo project futures_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFuturesDelivery(t *testing.T) {
+	tests := []struct {
+		name                  string
+		held                  int
+		priceAtDelivery       float64
+		sellerFood, buyerFood int
+		sellerFunds           float64
+	}{
+		{"in full", 5, 3, 2, 3, 109},
+		{"one short, price risen", 2, 5, 0, 2, 104},
+		{"all short, price fallen", 0, 1, 0, 0, 106},
+	}
+	for _, test := range tests {
+		food := &commodity{name: "Food", averagePrice: 3}
+		m := newBookMarket(testConfig(), food, nil, nil)
+		seller := newTestAgent(&m.config, nil, 100, map[*commodity]int{food: test.held})
+		buyer := newTestAgent(&m.config, nil, 100, make(map[*commodity]int))
+		placeTestAgent(m, 0, seller)
+		placeTestAgent(m, 1, buyer)
+		deliveries := recordEvents[FuturesDeliveryEvent](m)
+		due := m.tick + 2
+		//3 units sold for 2 and bought for 4 agree on 3, and a bid too low to match.
+		m.futures.asks = []*asks{{offeredAsk: ask{id: seller.id, item: food, quantity: 1, sellFor: 2, deliveryTick: due}, numberOffered: 3}}
+		m.futures.bids = []*bids{
+			{offeredBid: bid{id: buyer.id, item: food, quantity: 1, buyFor: 4, deliveryTick: due}, numberOffered: 3},
+			{offeredBid: bid{id: buyer.id, item: food, quantity: 1, buyFor: 1, deliveryTick: due}, numberOffered: 3},
+		}
+		m.futures.MatchFutures()
+		if len(m.futures.contracts) != 1 {
+			t.Fatalf("%v: matched %v contracts, want 1", test.name, len(m.futures.contracts))
+		}
+		if contract := m.futures.contracts[0]; contract.quantity != 3 || contract.price != 3 || contract.deliveryTick != due {
+			t.Errorf("%v: contracted %v at %v for tick %v, want 3 at 3 for tick %v", test.name, contract.quantity, contract.price, contract.deliveryTick, due)
+		}
+
+		food.averagePrice = test.priceAtDelivery
+		m.tick = due - 1
+		m.futures.Deliver()
+		if len(*deliveries) != 0 || seller.inventory[food] != test.held {
+			t.Errorf("%v: delivered a tick early", test.name)
+		}
+		m.tick = due
+		m.futures.Deliver()
+		if len(*deliveries) != 1 || len(m.futures.contracts) != 0 {
+			t.Fatalf("%v: %v deliveries, with %v contracts left, want 1 and none", test.name, len(*deliveries), len(m.futures.contracts))
+		}
+		if seller.inventory[food] != test.sellerFood || buyer.inventory[food] != test.buyerFood {
+			t.Errorf("%v: the seller holds %v food and the buyer %v, want %v and %v", test.name, seller.inventory[food], buyer.inventory[food], test.sellerFood, test.buyerFood)
+		}
+		//Delivered units are paid for at the contract price, and the seller makes
+		//good the market's price on those it is short.
+		if math.Abs(seller.funds-test.sellerFunds) > 1e-9 || math.Abs(seller.funds+buyer.funds-200) > 1e-9 {
+			t.Errorf("%v: the seller holds %v and the buyer %v, want %v and %v", test.name, seller.funds, buyer.funds, test.sellerFunds, 200-test.sellerFunds)
+		}
+		if delivery := (*deliveries)[0]; delivery.Delivered != test.buyerFood || delivery.Shortfall != 3-test.buyerFood {
+			t.Errorf("%v: recorded %v delivered and %v short", test.name, delivery.Delivered, delivery.Shortfall)
+		}
+	}
+}
+
+func TestTradeFutures(t *testing.T) {
+	config := testConfig()
+	config.TradeFutures = map[string]bool{"Farmer": true, "Woodcutter": true}
+	sim := newTestSimulation(t, config, 10)
+	deliveries := recordEvents[FuturesDeliveryEvent](sim.Market)
+	RunTicks(20, sim)
+	if len(*deliveries) == 0 {
+		t.Errorf("farmers and woodcutters trading futures for 20 ticks delivered none")
+	}
+}
@@ -0,0 +1,72 @@
+// GoEconGo project graph.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+//ExportDependencyGraph writes how commodities are made from one another as a
+//Graphviz DOT directed graph.  Every commodity a production method uses or makes
+//is a node, and every method has an edge from each of its inputs and catalysts to
+//each of its outputs.  Edges are labelled with the role, the units used and the
+//units made; catalyst edges are dashed, and also labelled with the chance of the
+//catalyst being consumed.  Roles are drawn in alphabetical order, so the same
+//economy always draws the same.
+//prodSets - every role's productionSet, by role
+//w - where to write the graph
+//Returns any error writing to w.
+func ExportDependencyGraph(prodSets map[string]*productionSet, w io.Writer) error {
+	out := bufio.NewWriter(w)
+	roles := sortedKeys(prodSets)
+	fmt.Fprintln(out, "digraph economy {")
+	fmt.Fprintln(out, "\trankdir=LR;")
+	fmt.Fprintln(out, "\tnode [shape=box];")
+	//Every commodity once, in alphabetical order
+	nodes := make(map[string]bool)
+	for _, role := range roles {
+		if prodSets[role] == nil {
+			continue
+		}
+		for _, method := range prodSets[role].methods {
+			for _, sets := range [][]commoditySet{method.inputs, method.catalysts, method.outputs} {
+				for _, set := range sets {
+					nodes[set.item.name] = true
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "\t%q;\n", name)
+	}
+	for _, role := range roles {
+		if prodSets[role] == nil {
+			continue
+		}
+		for _, method := range prodSets[role].methods {
+			for _, output := range method.outputs {
+				for _, input := range method.inputs {
+					fmt.Fprintf(out, "\t%q -> %q [label=%q];\n", input.item.name, output.item.name,
+						fmt.Sprintf("%v: %v to %v", role, input.quantity, output.quantity))
+				}
+				for i, catalyst := range method.catalysts {
+					consumption := 0.0
+					if i < len(method.consumption) {
+						consumption = method.consumption[i]
+					}
+					fmt.Fprintf(out, "\t%q -> %q [style=dashed, label=%q];\n", catalyst.item.name, output.item.name,
+						fmt.Sprintf("%v: %v to %v (p=%.2f)", role, catalyst.quantity, output.quantity, consumption))
+				}
+			}
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
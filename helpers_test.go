@@ -0,0 +1,146 @@
+// GoEconGo project helpers_test.go
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+//testEconomy is the economy main sets up, described as a SimConfig.
+const testEconomy = `{
+	"commodities": [
+		{"name": "Wood", "averagePrice": 3},
+		{"name": "Tools", "averagePrice": 3},
+		{"name": "Food", "averagePrice": 3},
+		{"name": "Ore", "averagePrice": 3},
+		{"name": "Metal", "averagePrice": 3}
+	],
+	"roles": [
+		{"name": "Farmer", "product": "Food", "penalty": 2, "methods": [
+			{"inputs": [{"commodity": "Wood", "quantity": 1}], "outputs": [{"commodity": "Food", "quantity": 2}]},
+			{"inputs": [{"commodity": "Wood", "quantity": 1}], "outputs": [{"commodity": "Food", "quantity": 4}],
+				"catalysts": [{"commodity": "Tools", "quantity": 1}], "consumption": [0.1]}
+		]},
+		{"name": "Miner", "product": "Ore", "penalty": 2, "methods": [
+			{"inputs": [{"commodity": "Food", "quantity": 1}], "outputs": [{"commodity": "Ore", "quantity": 2}]},
+			{"inputs": [{"commodity": "Food", "quantity": 1}], "outputs": [{"commodity": "Ore", "quantity": 4}],
+				"catalysts": [{"commodity": "Tools", "quantity": 1}], "consumption": [0.1]}
+		]},
+		{"name": "Refiner", "product": "Metal", "penalty": 2, "methods": [
+			{"inputs": [{"commodity": "Food", "quantity": 1}, {"commodity": "Ore", "quantity": 2}], "outputs": [{"commodity": "Metal", "quantity": 2}]},
+			{"inputs": [{"commodity": "Food", "quantity": 1}, {"commodity": "Ore", "quantity": 4}], "outputs": [{"commodity": "Metal", "quantity": 4}],
+				"catalysts": [{"commodity": "Tools", "quantity": 1}], "consumption": [0.1]}
+		]},
+		{"name": "Woodcutter", "product": "Wood", "penalty": 2, "methods": [
+			{"inputs": [{"commodity": "Food", "quantity": 1}], "outputs": [{"commodity": "Wood", "quantity": 1}]},
+			{"inputs": [{"commodity": "Food", "quantity": 1}], "outputs": [{"commodity": "Wood", "quantity": 2}],
+				"catalysts": [{"commodity": "Tools", "quantity": 1}], "consumption": [0.1]}
+		]},
+		{"name": "Blacksmith", "product": "Tools", "penalty": 2, "methods": [
+			{"inputs": [{"commodity": "Food", "quantity": 1}, {"commodity": "Metal", "quantity": 2}], "outputs": [{"commodity": "Tools", "quantity": 2}]},
+			{"inputs": [{"commodity": "Food", "quantity": 1}, {"commodity": "Metal", "quantity": 4}], "outputs": [{"commodity": "Tools", "quantity": 4}]}
+		]}
+	]
+}`
+
+//testConfig returns the default configuration, seeded so that tests run the same
+//way every time.
+func testConfig() SimulationConfig {
+	config := defaultSimulationConfig()
+	config.Seed = 1
+	return config
+}
+
+//testSimConfig builds testEconomy with cohort agents of every role.
+func testSimConfig(t *testing.T, cohort int) *SimConfig {
+	t.Helper()
+	economy := new(SimConfig)
+	if err := json.Unmarshal([]byte(testEconomy), economy); err != nil {
+		t.Fatal(err)
+	}
+	for i := range economy.Roles {
+		economy.Roles[i].CohortSize = cohort
+	}
+	if err := economy.build(); err != nil {
+		t.Fatal(err)
+	}
+	return economy
+}
+
+//setCohort sets how many agents of a role an economy starts with.
+func setCohort(economy *SimConfig, role string, size int) {
+	for i := range economy.Roles {
+		if economy.Roles[i].Name == role {
+			economy.Roles[i].CohortSize = size
+		}
+	}
+}
+
+//newTestSimulation sets up testEconomy with cohort agents of every role, on a
+//market configured by config (see simulateEconomy).
+func newTestSimulation(t *testing.T, config SimulationConfig, cohort int) *Simulation {
+	t.Helper()
+	return simulateEconomy(t, config, testSimConfig(t, cohort))
+}
+
+//simulateEconomy sets up an economy on a market configured by config, as main
+//would.  The market is shut down when the test ends.
+func simulateEconomy(t *testing.T, config SimulationConfig, economy *SimConfig) *Simulation {
+	t.Helper()
+	addPermits(economy.CommodityList())
+	market := newMarket(economy.CommodityList(), config)
+	economy.Populate(market)
+	market.distributePermits()
+	sim := newSimulation(market)
+	sim.Economy = economy
+	t.Cleanup(func() { market.Shutdown() })
+	return sim
+}
+
+//agentsOf returns the running agents of a role, lowest slot first.
+func agentsOf(m *Market, role string) []*traderAgent {
+	var agents []*traderAgent
+	for _, slot := range m.agentSlots() {
+		if m.agents[slot].role == role {
+			agents = append(agents, m.agents[slot])
+		}
+	}
+	return agents
+}
+
+//newTestAgent returns an agent that isn't running, with a job (nil is none),
+//funds and an inventory, under config.
+func newTestAgent(config *SimulationConfig, job *productionSet, funds float64, inventory map[*commodity]int) *traderAgent {
+	return &traderAgent{mu: new(sync.Mutex), config: config, job: job, funds: funds, inventory: inventory, priceBelief: make(map[*commodity]priceRange)}
+}
+
+//smeltingJob returns a job turning each unit of ore into a unit of metal and a
+//unit of pollution.
+func smeltingJob(ore, metal, pollution *commodity) *productionSet {
+	method := &productionMethod{
+		inputs:  []commoditySet{{item: ore, quantity: 1}},
+		outputs: []commoditySet{{item: metal, quantity: 1}, {item: pollution, quantity: 1}},
+	}
+	return &productionSet{methods: []*productionMethod{method}, penalty: 2}
+}
+
+//placeTestAgent gives an agent that isn't running a new id and puts it in a slot
+//of a market, so that the market can find it by its id.
+func placeTestAgent(m *Market, slot uint64, agent *traderAgent) {
+	agent.id = newAgentID()
+	m.agents[slot] = agent
+	m.slots[agent.id] = slot
+}
+
+//recordEvents subscribes to the events of one kind a market raises, returning
+//where it keeps every one raised from then on.
+func recordEvents[E MarketEvent](m *Market) *[]E {
+	var events []E
+	m.Subscribe(func(event MarketEvent) {
+		if event, ok := event.(E); ok {
+			events = append(events, event)
+		}
+	})
+	return &events
+}
@@ -0,0 +1,95 @@
+// GoEconGo project history.go
+package main
+
+import "sort"
+
+//A Transaction is one trade an agent took part in.
+//Tick - the tick it traded on
+//Commodity - what was traded
+//Quantity - how many units the agent received (negative when it sold)
+//Price - the price of each unit
+type Transaction struct {
+	Tick      int
+	Commodity *commodity
+	Quantity  int
+	Price     float64
+}
+
+//A ProductionEvent is one production an agent ran.
+//Tick - the tick it ran on
+//Consumed - the inputs and catalysts it used up
+//Produced - what it made
+//Cost - any cash it cost (penalties, carbon tax)
+type ProductionEvent struct {
+	Tick     int
+	Consumed []commoditySet
+	Produced []commoditySet
+	Cost     float64
+}
+
+//A TimelineStep is one trade or production in an AgentTimeline, with where the
+//agent stood just after it.  Exactly one of Trade and Production is set.
+//Tick - the tick it happened on
+//Trade - the trade, if it was one
+//Production - the production, if it was one
+//Funds - the agent's running funds
+//NetWorth - the agent's running funds plus its holdings, each valued at the last
+//price it traded at
+type TimelineStep struct {
+	Tick       int
+	Trade      *Transaction
+	Production *ProductionEvent
+	Funds      float64
+	NetWorth   float64
+}
+
+//An AgentTimeline is an agent's trades and productions, in the order they
+//happened.
+type AgentTimeline struct {
+	Steps []TimelineStep
+}
+
+//ReplayAgentHistory rebuilds an agent's timeline from its logged trades and
+//productions.  They are interleaved in tick order - within a tick, productions
+//come first, since agents produce before they trade - and running funds and net
+//worth are worked out from an empty start: no funds, no holdings.
+//log - the agent's trades
+//productionEvents - the agent's productions
+func ReplayAgentHistory(log []Transaction, productionEvents []ProductionEvent) AgentTimeline {
+	var timeline AgentTimeline
+	for i := range productionEvents {
+		timeline.Steps = append(timeline.Steps, TimelineStep{Tick: productionEvents[i].Tick, Production: &productionEvents[i]})
+	}
+	for i := range log {
+		timeline.Steps = append(timeline.Steps, TimelineStep{Tick: log[i].Tick, Trade: &log[i]})
+	}
+	sort.SliceStable(timeline.Steps, func(i, j int) bool {
+		return timeline.Steps[i].Tick < timeline.Steps[j].Tick
+	})
+
+	var funds float64
+	holdings := make(map[*commodity]int)
+	lastPrice := make(map[*commodity]float64)
+	for i := range timeline.Steps {
+		step := &timeline.Steps[i]
+		if step.Trade != nil {
+			funds = funds - float64(step.Trade.Quantity)*step.Trade.Price
+			holdings[step.Trade.Commodity] = holdings[step.Trade.Commodity] + step.Trade.Quantity
+			lastPrice[step.Trade.Commodity] = step.Trade.Price
+		} else {
+			funds = funds - step.Production.Cost
+			for _, set := range step.Production.Consumed {
+				holdings[set.item] = holdings[set.item] - set.quantity
+			}
+			for _, set := range step.Production.Produced {
+				holdings[set.item] = holdings[set.item] + set.quantity
+			}
+		}
+		step.Funds = funds
+		step.NetWorth = funds
+		for com, num := range holdings {
+			step.NetWorth = step.NetWorth + float64(num)*lastPrice[com]
+		}
+	}
+	return timeline
+}
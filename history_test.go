@@ -0,0 +1,53 @@
+// GoEconGo project history_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReplayAgentHistory(t *testing.T) {
+	wood, food := &commodity{name: "Wood"}, &commodity{name: "Food"}
+	//A farmer buys wood, farms it into food and sells the food, twice over.
+	log := []Transaction{
+		{Tick: 3, Commodity: wood, Quantity: 1, Price: 2.5},
+		{Tick: 1, Commodity: wood, Quantity: 2, Price: 3},
+		{Tick: 2, Commodity: food, Quantity: -2, Price: 4},
+	}
+	farm := func(tick int, cost float64) ProductionEvent {
+		return ProductionEvent{Tick: tick, Consumed: []commoditySet{{item: wood, quantity: 1}}, Produced: []commoditySet{{item: food, quantity: 2}}, Cost: cost}
+	}
+	productions := []ProductionEvent{farm(2, 0), farm(3, 2)}
+	want := []struct {
+		tick       int
+		production bool
+		funds      float64
+		netWorth   float64
+	}{
+		//Bought 2 wood at 3.
+		{1, false, -6, 0},
+		//Farmed, with food not yet priced.
+		{2, true, -6, -3},
+		//Sold the food at 4.
+		{2, false, 2, 5},
+		//Farmed again, paying 2 for it: 2 food at 4 and no wood.
+		{3, true, 0, 8},
+		//Bought a wood at 2.5.
+		{3, false, -2.5, 8},
+	}
+	timeline := ReplayAgentHistory(log, productions)
+	if len(timeline.Steps) != len(want) {
+		t.Fatalf("replayed %v steps, want %v", len(timeline.Steps), len(want))
+	}
+	for i, step := range timeline.Steps {
+		if step.Tick != want[i].tick || (step.Production != nil) != want[i].production || (step.Trade != nil) == want[i].production {
+			t.Errorf("step %v: a production (%v) on tick %v, want (%v) on tick %v", i, step.Production != nil, step.Tick, want[i].production, want[i].tick)
+		}
+		if math.Abs(step.Funds-want[i].funds) > 1e-9 || math.Abs(step.NetWorth-want[i].netWorth) > 1e-9 {
+			t.Errorf("step %v: funds %v and net worth %v, want %v and %v", i, step.Funds, step.NetWorth, want[i].funds, want[i].netWorth)
+		}
+	}
+	if empty := ReplayAgentHistory(nil, nil); len(empty.Steps) != 0 {
+		t.Errorf("replayed %v steps of nothing", len(empty.Steps))
+	}
+}
@@ -0,0 +1,60 @@
+// GoEconGo project information.go
+package main
+
+import "math"
+
+//The lowest a widened price belief may go, as a share of its midpoint, so that an
+//uninformed agent still believes everything is worth something.
+const minWidenedBelief = 0.01
+
+//The standard deviation, as a share of the price, of the noise an agent knowing
+//nothing of the market hears prices with, unless configured otherwise.
+const defaultInformationNoise = 0.2
+
+//informationLevel returns how much an agent knows of the market, (0.0,1.0], an
+//agent never given an InformationLevel knowing everything.
+func informationLevel(agent *traderAgent) float64 {
+	if agent.InformationLevel <= 0 || agent.InformationLevel > 1 {
+		return 1
+	}
+	return agent.InformationLevel
+}
+
+//informAgent draws whether a new agent is one of the UninformedShare of agents,
+//and if it is, gives it the UninformedLevel and widens its price beliefs to match
+//(see widenBeliefs).
+func informAgent(agent *traderAgent, config SimulationConfig) {
+	if config.UninformedShare <= 0 || config.random().Float64() >= config.UninformedShare {
+		return
+	}
+	agent.InformationLevel = config.UninformedLevel
+	widenBeliefs(agent)
+}
+
+//widenBeliefs widens each of an agent's price beliefs about its midpoint by one
+//over its informationLevel, as an agent knowing less of the market is less sure
+//of its prices.
+func widenBeliefs(agent *traderAgent) {
+	level := informationLevel(agent)
+	if level == 1 {
+		return
+	}
+	for com, belief := range agent.priceBelief {
+		mid := (belief.low + belief.high) / 2
+		half := (belief.high - belief.low) / 2 / level
+		agent.priceBelief[com] = priceRange{low: math.Max(mid-half, mid*minWidenedBelief), high: mid + half}
+	}
+}
+
+//heardPrice returns a price as an agent hears it.  An agent knowing everything
+//hears it as it is, and the less an agent knows, the more noise it hears it with:
+//price * (1 + N(0, sigma)), where sigma is the InformationNoise scaled by how much
+//the agent doesn't know.  A price is never heard as below nothing.
+func heardPrice(agent *traderAgent, price float64) float64 {
+	level := informationLevel(agent)
+	if level == 1 {
+		return price
+	}
+	sigma := agent.settings().InformationNoise * (1 - level)
+	return math.Max(0, price*(1+agent.random().NormFloat64()*sigma))
+}
@@ -0,0 +1,75 @@
+// GoEconGo project information_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+//uninformedRun runs 100 ticks with the given share of producers knowing next to
+//nothing of the market, returning every agent that died and the books' mean
+//spread.
+func uninformedRun(t *testing.T, share float64) ([]traderAgent, float64) {
+	config := testConfig()
+	recorder := recordDeaths(&config)
+	config.UninformedShare = share
+	config.UninformedLevel = 0.05
+	sim := newTestSimulation(t, config, 10)
+	var spreads []float64
+	for tick := 0; tick < 100; tick++ {
+		RunTicks(1, sim)
+		for _, com := range sim.Market.commodities {
+			spreads = append(spreads, com.spreadRing.last(1)...)
+		}
+	}
+	spread, _ := meanStdDev(spreads)
+	return recorder.dead, spread
+}
+
+func TestWidenBeliefs(t *testing.T) {
+	food := &commodity{name: "Food", averagePrice: 3}
+	tests := []struct {
+		level     float64
+		low, high float64
+	}{
+		{1, 2, 4},
+		{0, 2, 4},
+		{0.5, 1, 5},
+		{0.25, 0.03, 7},
+	}
+	for _, test := range tests {
+		agent := traderAgent{InformationLevel: test.level, priceBelief: map[*commodity]priceRange{food: {low: 2, high: 4}}}
+		widenBeliefs(&agent)
+		if belief := agent.priceBelief[food]; math.Abs(belief.low-test.low) > 1e-9 || math.Abs(belief.high-test.high) > 1e-9 {
+			t.Errorf("knowing %v: widened [2, 4] to [%v, %v], want [%v, %v]", test.level, belief.low, belief.high, test.low, test.high)
+		}
+	}
+}
+
+func TestUninformedAgentsDieYounger(t *testing.T) {
+	dead, _ := uninformedRun(t, 0.5)
+	var informed, uninformed []float64
+	for _, agent := range dead {
+		if informationLevel(&agent) < 1 {
+			uninformed = append(uninformed, float64(agent.age))
+		} else {
+			informed = append(informed, float64(agent.age))
+		}
+	}
+	if len(informed) == 0 || len(uninformed) == 0 {
+		t.Fatalf("%v informed and %v uninformed agents died", len(informed), len(uninformed))
+	}
+	informedAge, _ := meanStdDev(informed)
+	uninformedAge, _ := meanStdDev(uninformed)
+	if uninformedAge >= informedAge {
+		t.Errorf("uninformed agents died %v ticks old on average, want younger than the %v of informed ones", uninformedAge, informedAge)
+	}
+}
+
+func TestUninformedAgentsWidenSpreads(t *testing.T) {
+	_, informed := uninformedRun(t, 0)
+	_, uninformed := uninformedRun(t, 0.5)
+	if uninformed <= informed {
+		t.Errorf("with half the producers uninformed the books spread %v on average, want wider than the %v with none", uninformed, informed)
+	}
+}
@@ -0,0 +1,185 @@
+// GoEconGo project ledger.go
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+//Why money moved, as a LedgerEntry records it.
+const (
+	ledgerEndowment    = "endowment"
+	ledgerTrade        = "trade"
+	ledgerDirectTrade  = "direct trade"
+	ledgerFutures      = "futures delivery"
+	ledgerPenalty      = "production penalty"
+	ledgerCarrying     = "carrying cost"
+	ledgerCarbonTax    = "carbon tax"
+	ledgerExternality  = "externality"
+	ledgerShortCover   = "short cover"
+	ledgerResearch     = "research"
+	ledgerReproduction = "reproduction"
+	ledgerMerger       = "merger"
+	ledgerSlotAuction  = "slot auction"
+	ledgerCentralBank  = "central bank"
+	ledgerRetired      = "retired"
+	ledgerDeath        = "death"
+)
+
+//How far the ledger's accounts may drift from adding up to nothing before Verify
+//complains, from rounding alone.
+const ledgerTolerance = 1e-6
+
+//A LedgerEntry records one movement of money.
+//from - the agent id paying (0 is the system: the market, the central bank and
+//everything else that isn't an agent)
+//to - the agent id paid (0 is the system)
+//amount - how much moved (never negative)
+//tick - the tick it moved on
+//reason - why it moved
+type LedgerEntry struct {
+	from   uint64
+	to     uint64
+	amount float64
+	tick   int
+	reason string
+}
+
+//A Ledger keeps double-entry accounts of every agent's funds, and of the system's.
+//Every entry is paid by one account and paid into another, so while money is only
+//ever moved, never made or lost, the accounts add up to nothing.  An agent's
+//account is opened with its endowment when it joins the market and closed when it
+//dies, so its balance follows its funds.  Trades are paid through the system
+//rather than straight to the other side, as fees and market impact come between
+//what the buyer pays and what the seller gets.  A nil Ledger records nothing.
+//mu - guards everything below, between the market and the agents paying
+//entries - every entry, oldest first
+//balances - every account's balance, by agent id
+type Ledger struct {
+	mu       sync.Mutex
+	entries  []LedgerEntry
+	balances map[uint64]float64
+}
+
+//newLedger returns an empty ledger.
+func newLedger() *Ledger {
+	return &Ledger{balances: make(map[uint64]float64)}
+}
+
+//Record enters money moving from one account to another.  A negative amount moves
+//the other way, and nothing moving is not entered.
+func (l *Ledger) Record(from, to uint64, amount float64, tick int, reason string) {
+	if l == nil || amount == 0 {
+		return
+	}
+	if amount < 0 {
+		from, to, amount = to, from, -amount
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, LedgerEntry{from: from, to: to, amount: amount, tick: tick, reason: reason})
+	l.balances[from] = l.balances[from] - amount
+	l.balances[to] = l.balances[to] + amount
+}
+
+//open opens an agent's account with its endowment, paid by the system, unless it
+//has one already: an agent paid before it joins, like a child or a merged agent,
+//keeps the account it was paid into.  Agents restored from a checkpoint open with
+//what they held.
+func (l *Ledger) open(agent *traderAgent, tick int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	_, ok := l.balances[agent.id]
+	if !ok {
+		l.balances[agent.id] = 0
+	}
+	l.mu.Unlock()
+	if !ok {
+		l.Record(0, agent.id, agent.funds, tick, ledgerEndowment)
+	}
+}
+
+//Balance returns what an agent has been paid, less what it has paid (0 is the
+//system).
+func (l *Ledger) Balance(agentID uint64) float64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.balances[agentID]
+}
+
+//Entries returns a copy of every entry, oldest first.
+func (l *Ledger) Entries() []LedgerEntry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LedgerEntry(nil), l.entries...)
+}
+
+//Verify checks that money has been conserved: that every entry moved a real,
+//non-negative amount, that the balances are what the entries add up to, that all
+//of the accounts together add up to nothing, and that every running agent's
+//balance is what it holds.
+//agents - the running agents (see Market.VerifyLedger)
+//Returns an error describing the first thing that doesn't add up.
+func (l *Ledger) Verify(agents []*traderAgent) error {
+	if l == nil {
+		return nil
+	}
+	if err := l.verifyEntries(); err != nil {
+		return err
+	}
+	//Agents are locked before the ledger, as they are when they pay.
+	for _, agent := range agents {
+		agent.mu.Lock()
+		funds := agent.funds
+		balance := l.Balance(agent.id)
+		agent.mu.Unlock()
+		if math.Abs(funds-balance) > ledgerTolerance*math.Max(1, math.Abs(funds)) {
+			return fmt.Errorf("agent %v holds %v, but its account has a balance of %v", agent.id, funds, balance)
+		}
+	}
+	return nil
+}
+
+//verifyEntries checks the entries and balances of Verify.
+func (l *Ledger) verifyEntries() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	totals := make(map[uint64]float64)
+	for i, entry := range l.entries {
+		if math.IsNaN(entry.amount) || math.IsInf(entry.amount, 0) || entry.amount < 0 {
+			return fmt.Errorf("entry %v (%v on tick %v) moved %v", i, entry.reason, entry.tick, entry.amount)
+		}
+		totals[entry.from] = totals[entry.from] - entry.amount
+		totals[entry.to] = totals[entry.to] + entry.amount
+	}
+	sum := 0.0
+	for id, balance := range l.balances {
+		if math.Abs(totals[id]-balance) > ledgerTolerance*math.Max(1, math.Abs(balance)) {
+			return fmt.Errorf("account %v has a balance of %v, but its entries add up to %v", id, balance, totals[id])
+		}
+		sum += balance
+	}
+	if math.Abs(sum) > ledgerTolerance*math.Max(1, float64(len(l.entries))) {
+		return fmt.Errorf("the accounts add up to %v, not 0", sum)
+	}
+	return nil
+}
+
+//VerifyLedger checks the market's Ledger against its running agents (see
+//Ledger.Verify).  Call it between ticks.
+func (m *Market) VerifyLedger() error {
+	var agents []*traderAgent
+	for _, slot := range m.agentSlots() {
+		agents = append(agents, m.agents[slot])
+	}
+	return m.Ledger.Verify(agents)
+}
@@ -0,0 +1,94 @@
+// GoEconGo project ledger_test.go
+package main
+
+import "testing"
+
+func TestLedgerVerify(t *testing.T) {
+	config := testConfig()
+	config.KeepLedger = true
+	config.ReproductionEnabled = true
+	config.ReproductionThreshold = 150
+	sim := newTestSimulation(t, config, 4)
+	m := sim.Market
+	for i := 0; i < 20; i++ {
+		RunTicks(1, sim)
+		if err := m.VerifyLedger(); err != nil {
+			t.Fatalf("tick %v: %v", m.tick, err)
+		}
+	}
+	farmers, miners := agentsOf(m, "Farmer"), agentsOf(m, "Miner")
+	if _, err := m.MergeAgents(farmers[0].id, miners[0].id); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.VerifyLedger(); err != nil {
+		t.Fatalf("after merging: %v", err)
+	}
+	//Nobody may be trading while the price moves.
+	m.holdOffers()
+	m.products["Blacksmith"].averagePrice = 1000
+	if winner, _ := m.AuctionRoleSlot("Blacksmith"); winner == 0 {
+		t.Fatal("nobody bid for a Blacksmith slot")
+	}
+	if err := m.VerifyLedger(); err != nil {
+		t.Fatalf("after auctioning a slot: %v", err)
+	}
+	RunTicks(5, sim)
+	if err := m.VerifyLedger(); err != nil {
+		t.Fatalf("tick %v: %v", m.tick, err)
+	}
+	//Children and merged agents are paid by the agents they came from.
+	paid := make(map[string]bool)
+	for _, entry := range m.Ledger.Entries() {
+		if entry.from != 0 && entry.to != 0 {
+			paid[entry.reason] = true
+		}
+	}
+	for _, reason := range []string{ledgerReproduction, ledgerMerger} {
+		if !paid[reason] {
+			t.Errorf("no agent paid another for %v", reason)
+		}
+	}
+}
+
+func TestLedgerVerifyMidRun(t *testing.T) {
+	config := testConfig()
+	config.KeepLedger = true
+	sim := newTestSimulation(t, config, 4)
+	m := sim.Market
+	//Merge and kill agents while the rest are still settling up from the last tick.
+	for i := 0; i < 10; i++ {
+		RunTicks(1, sim)
+		farmers, miners := agentsOf(m, "Farmer"), agentsOf(m, "Miner")
+		if len(farmers) > 1 {
+			if _, err := m.MergeAgents(farmers[0].id, farmers[1].id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(miners) > 0 {
+			if err := m.KillAgent(miners[0].id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := m.VerifyLedger(); err != nil {
+			t.Fatalf("tick %v, after merging and killing: %v", m.tick, err)
+		}
+	}
+	RunTicks(5, sim)
+	if err := m.VerifyLedger(); err != nil {
+		t.Fatalf("tick %v: %v", m.tick, err)
+	}
+}
+
+func TestLedgerVerifyUnrecordedFunds(t *testing.T) {
+	config := testConfig()
+	config.KeepLedger = true
+	sim := newTestSimulation(t, config, 2)
+	RunTicks(1, sim)
+	agent := agentsOf(sim.Market, "Farmer")[0]
+	agent.mu.Lock()
+	agent.funds += 10
+	agent.mu.Unlock()
+	if err := sim.Market.VerifyLedger(); err == nil {
+		t.Error("funds paid outside the ledger went unnoticed")
+	}
+}
@@ -2,23 +2,142 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"math"
-	"math/rand"
+	"os"
+	"os/signal"
 	"runtime"
 	"sort"
+	"sync"
 	"time"
 )
 
 //Flags!
 var grantGoods bool
+var anomalyThreshold float64
+var metricsAddr string
+var streamAddr string
+var controlAddr string
 
 //A commodity is traded by traderAgents and used in production sets.
 //name - name of the commodity
 //averagePrice - current average price of the commodity
+//PriceFloor - the lowest price it may trade or average at (0 is no limit)
+//PriceCeiling - the highest price it may trade or average at (0 is no limit)
+//MarketImpactCoeff - how much more large orders pay per unit for moving the market
+//IsExternality - whether this is a by-product nobody trades, but everybody pays for
+//IsCommonPool - whether production draws this from a shared pool instead of inventory
+//PoolSize - how much is left in the common pool
+//RegenerationRate - how much the common pool refills each tick
+//MaxPoolSize - the most the common pool can hold
+//PermitRequired - whether producing or consuming this needs a production permit
+//InitialPermits - how many permits are handed out at the start of the run
+//permit - the commodity permits for this trade as on the market
+//permitFor - if this is a permit, the commodity it permits
+//SpoilageRate - the chance [0.0,1.0] that each unit held spoils each tick
+//poolLock - guards PoolSize, which every producing agent draws on
+//priceRing - the commodity's recent closing prices (see PriceHistory)
+//PriceSmoothing - how much weight (0.0,1.0] each tick's trading gets in
+//averagePrice, against the averagePrice before it (0 is the market's
+//PriceSmoothing)
+//TickVolume - how many units traded in the last tick
+//volumeRing - how many units traded in each recent tick (see VolumeHistory)
+//spreadRing - the spread of the books before each recent tick's trading (see
+//SpreadHistory)
+//bidRing, unfilledRing - how many units were bid for in each recent tick, and how
+//many of those went unbought (see DetectBottleneck)
+//askRing - how many units were asked for in each recent tick (see CheckElasticity)
+//beliefRing - the BeliefWidth at the end of each recent tick (see
+//BeliefWidthHistory)
+//supplyCurve, demandCurve - the supply and demand curves of the books before the
+//last tick's trading (see EstimateSupplyCurve)
+//MaxTickChangePct - the largest change in averagePrice one tick's trading may make,
+//as a fraction of it (0 is no limit, see tripsCircuit)
+//eventLog - everything that has happened to the commodity (see EventLog)
+//Perishable - whether units expire once they have been held for ShelfLife ticks
+//ShelfLife - how many ticks a unit of a Perishable commodity keeps (0 is forever)
+//CarryingCostPerUnit - what holding each unit costs its holder every tick (see
+//chargeCarryingCost)
 type commodity struct {
-	name         string
-	averagePrice float64
+	name                string
+	averagePrice        float64
+	PriceFloor          float64
+	PriceCeiling        float64
+	MarketImpactCoeff   float64
+	IsExternality       bool
+	IsCommonPool        bool
+	PoolSize            int
+	RegenerationRate    int
+	MaxPoolSize         int
+	PermitRequired      bool
+	InitialPermits      int
+	permit              *commodity
+	permitFor           *commodity
+	SpoilageRate        float64
+	poolLock            sync.Mutex
+	priceRing           historyRing[float64]
+	PriceSmoothing      float64
+	TickVolume          int
+	volumeRing          historyRing[int]
+	spreadRing          historyRing[float64]
+	bidRing             historyRing[int]
+	unfilledRing        historyRing[int]
+	askRing             historyRing[int]
+	beliefRing          historyRing[float64]
+	supplyCurve         []CurvePoint
+	demandCurve         []CurvePoint
+	MaxTickChangePct    float64
+	eventLog            []MarketEvent
+	Perishable          bool
+	ShelfLife           int
+	CarryingCostPerUnit float64
+}
+
+//PriceHistory returns the commodity's last n closing prices (or as many as there
+//are), oldest first.
+func (com *commodity) PriceHistory(n int) []float64 {
+	return com.priceRing.last(n)
+}
+
+//VolumeHistory returns how many units of the commodity traded in each of the last
+//n ticks (or as many as there are), oldest first.
+func (com *commodity) VolumeHistory(n int) []int {
+	return com.volumeRing.last(n)
+}
+
+//SpreadHistory returns the commodity's last n spreads (or as many as there are),
+//oldest first.  A spread is the lowest ask less the highest bid, before anything
+//trades; books that overlap have a spread of 0.
+func (com *commodity) SpreadHistory(n int) []float64 {
+	return com.spreadRing.last(n)
+}
+
+//SpreadStats returns the mean and largest of the commodity's last n spreads.
+func (com *commodity) SpreadStats(n int) (mean, max float64) {
+	spreads := com.SpreadHistory(n)
+	if len(spreads) == 0 {
+		return 0, 0
+	}
+	total := 0.0
+	for _, spread := range spreads {
+		total += spread
+		if spread > max {
+			max = spread
+		}
+	}
+	return total / float64(len(spreads)), max
+}
+
+//TotalVolume returns how many units of the commodity traded over all of its
+//volume history.
+func (com *commodity) TotalVolume() int {
+	total := 0
+	for _, volume := range com.volumeRing.all() {
+		total += volume
+	}
+	return total
 }
 
 //A priceRange simply captures the low and high price beliefs of an agent
@@ -42,11 +161,24 @@ type commoditySet struct {
 //outputs - what is produced by this production method (a slice of commoditySets)
 //consumption - the chance of a catalyst being consumed by the production (an slice
 //of probability [0.0,1.0] of it being consumed, aligned with the catalysts slice)
+//discount - the cut in inputs for agents that have produced enough (nil is none)
+//surcharge - the extra inputs for producing too much in one tick (nil is none)
+//SeasonalModifier - scales the outputs by the tick they are made on (nil is no
+//seasons, see seasonalOutputs)
+//ProductionTicks - how many ticks the method takes, its outputs arriving on the
+//last of them (0 or 1 is the tick it is started, see workQueue)
+//UnlockCost - what an agent must invest in a research method before it may use it
+//(see invest)
 type productionMethod struct {
-	inputs      []commoditySet
-	catalysts   []commoditySet
-	outputs     []commoditySet
-	consumption []float64
+	inputs           []commoditySet
+	catalysts        []commoditySet
+	outputs          []commoditySet
+	consumption      []float64
+	discount         *BatchDiscount
+	surcharge        *BatchPenalty
+	SeasonalModifier func(tick int) float64
+	ProductionTicks  int
+	UnlockCost       float64
 }
 
 //A productionSet is a collection of similar productionMethods for producing a
@@ -54,9 +186,12 @@ type productionMethod struct {
 //methods - all of the available productionMethods in this set (slice of
 //productionMethod)
 //penalty - cost of not following this production set (float64)
+//research - advanced productionMethods agents may unlock by investing in them, and
+//then use as well as methods (see invest)
 type productionSet struct {
-	methods []*productionMethod
-	penalty float64
+	methods  []*productionMethod
+	penalty  float64
+	research []*productionMethod
 }
 
 //A traderAgent is an independent agent.  It has a job (productionSet), an inventory,
@@ -68,38 +203,226 @@ type productionSet struct {
 //funds - the amount of cash on hand
 //riskAversion - the level of look ahead in value during bidding in case of failed
 //bids.  Lower is more risky (since you could blow a bid)
+//mu - guards the agent while the market reaches into it from outside agentRun
+//id - the agent's own id, never shared with any other agent (see newAgentID).  The
+//market keeps the agent's channels in a slot, which a replacement takes over, but
+//routes its offers and their results by id.
+//config - the SimulationConfig the agent is running under (see settings)
+//carbonTaxPaid - carbon tax paid since the market last collected it
+//permits - how many production permits the agent holds for each commodity
+//lifetimeAskVolume - how many units the agent has sold over its life
+//lifetimeBidVolume - how many units the agent has bought over its life
+//spoiledAsks - asks for spoiled goods, posted alongside the agent's next asks
+//UtilityWeights - how much more (or less) than its price the agent values each
+//commodity (commodities left out are weighted 1)
+//OracleSubscriber - whether the agent follows the configured PriceOracle's prices
+//instead of the market averages
+//InformationLevel - how much the agent knows of the market, (0.0,1.0]: less widens
+//its first beliefs and makes its prices noisier (0 is everything, see heardPrice)
+//tick - the market tick the agent is trading in
+//tickSpan - carries the span of the tick the agent is trading in, which its own
+//spans open under (nil is none, see Tracer)
+//ProductionCount - how many productions the agent has run since it spawned
+//effectiveInputs - the discounted inputs of each method the agent has earned a
+//BatchDiscount on
+//shortPositions - how many units of each commodity the agent has sold short and
+//still has to buy back
+//productionLog - the value made and spent by each method the agent has run
+//methodEfficiency - a rolling average of the value each method makes per unit of
+//value it uses up
+//CohortID - the tick the agent was spawned on
+//consecutivePenalties - how many ticks in a row the agent has been fined for idling
+//rng - the agent's own source of randomness
+//resume - where an agent restored from a checkpoint picks up (nil for any other)
+//AskBeliefUpdater, BidBeliefUpdater - how the agent rethinks its price beliefs
+//after asking and bidding (nil is the DefaultBeliefUpdater)
+//beliefAdjustBig, beliefAdjustSmall - how far [0.0,1.0] the DefaultBeliefUpdater
+//moves the agent's beliefs when they are on the wrong side of the market, and
+//otherwise
+//outOfBusiness - whether the agent has idled for too long to carry on
+//maxInventory - how many units of everything together the agent can hold (0 is no
+//limit)
+//age - how many ticks the agent has traded
+//maxAge - how many ticks the agent may trade before it retires (0 is forever)
+//startingFunds - the funds the agent was launched with
+//RoleSwitches - every change of role the agent has made, oldest first
+//TradeHistory - the agent's most recent trades, oldest first (see recordTrade)
+//batches - when the agent came by the units of each perishable commodity it
+//holds, oldest first (see trackBatches)
+//tradeFutures - whether the agent trades futures as well as spot
+//lookbackTicks - how many ticks of prices a speculator's moving average spans
+//targetSpreadPct - the spread a market maker quotes, as a fraction of the price
+//quoteSize - how many units a market maker quotes on each side
+//inventoryTarget - how much of each commodity a market maker tries to hold
+//quoteSkew - how far [-1.0,1.0] a market maker leans its quotes on each commodity
+//(see rebalanceQuotes)
+//skill - how practised [0.0,1.0] the agent is at each of its methods (see
+//skilledOutputs)
+//workQueue - production the agent has started but not finished, which keeps it
+//working (see performProduction)
+//cyclesToCover - how many production cycles' worth of inputs the agent bids to
+//keep in stock (see coverCycles)
+//heldLastTick - how many units the agent held after its last update (see
+//adaptCyclesToCover)
+//Revenue - what the agent has made selling, over its life
+//Cost - what the agent has spent buying, and on carrying costs and carbon tax,
+//over its life
+//ProductionPenalties - what the agent has been fined for idling, over its life
+//responseTimeout - how long the agent waits on the market for the results of its
+//offers (0 is forever, see responseDeadline)
+//deathReason - why the agent died, if not for the usual reasons (timeoutDeath)
+//ledger - where the agent's payments are entered (nil is nowhere, see Ledger)
+//research - what the agent has invested in each of its job's research methods so
+//far (see invest)
 type traderAgent struct {
-	role         string
-	id           uint32
-	job          *productionSet
-	inventory    map[*commodity]int
-	priceBelief  map[*commodity]priceRange
-	funds        float64
-	riskAversion int
+	mu                   *sync.Mutex
+	config               *SimulationConfig
+	role                 string
+	id                   uint64
+	job                  *productionSet
+	inventory            map[*commodity]int
+	priceBelief          map[*commodity]priceRange
+	funds                float64
+	riskAversion         int
+	carbonTaxPaid        float64
+	permits              map[*commodity]int
+	lifetimeAskVolume    int
+	lifetimeBidVolume    int
+	spoiledAsks          []asks
+	UtilityWeights       map[*commodity]float64
+	OracleSubscriber     bool
+	InformationLevel     float64
+	tick                 int
+	tickSpan             context.Context
+	ProductionCount      int
+	effectiveInputs      map[*productionMethod][]commoditySet
+	shortPositions       map[*commodity]int
+	productionLog        map[*productionMethod]productionRecord
+	methodEfficiency     map[*productionMethod]float64
+	CohortID             uint32
+	consecutivePenalties int
+	rng                  *randomSource
+	resume               *pendingOffers
+	AskBeliefUpdater     BeliefUpdater
+	BidBeliefUpdater     BeliefUpdater
+	beliefAdjustBig      float64
+	beliefAdjustSmall    float64
+	outOfBusiness        bool
+	maxInventory         int
+	age                  int
+	maxAge               int
+	startingFunds        float64
+	RoleSwitches         []RoleSwitch
+	TradeHistory         []tradeRecord
+	batches              map[*commodity][]stockBatch
+	tradeFutures         bool
+	lookbackTicks        int
+	targetSpreadPct      float64
+	quoteSize            int
+	inventoryTarget      map[*commodity]int
+	quoteSkew            map[*commodity]float64
+	skill                map[*productionMethod]float64
+	workQueue            []pendingWork
+	cyclesToCover        int
+	heldLastTick         int
+	Revenue              float64
+	Cost                 float64
+	ProductionPenalties  float64
+	responseTimeout      time.Duration
+	deathReason          string
+	ledger               *Ledger
+	research             map[*productionMethod]float64
+}
+
+//settings returns the SimulationConfig the agent is running under, falling back
+//to the defaults for an agent the market hasn't launched.
+func (agent *traderAgent) settings() *SimulationConfig {
+	if agent.config == nil {
+		config := defaultSimulationConfig()
+		agent.config = &config
+	}
+	return agent.config
+}
+
+//utilityWeight returns how much the agent weights a commodity's value.
+func (agent *traderAgent) utilityWeight(com *commodity) float64 {
+	if weight, ok := agent.UtilityWeights[com]; ok {
+		return weight
+	}
+	return 1
+}
+
+//utilityWeightedBid works out where in its price belief an agent bids for a
+//commodity, from how much it values what it makes with it.  Weighting the outputs
+//of the methods needing the commodity as the market does (1), it bids the middle
+//of its belief.  Weighting them more, it bids up towards the high end - halfway
+//there at twice the market's value - and weighting them less, down towards the low
+//end, reaching it at half the market's value.
+func utilityWeightedBid(agent *traderAgent, com *commodity) float64 {
+	mid := beliefMidpoint(agent, com)
+	var plain, weighted float64
+	if agent.job != nil {
+		for _, method := range agent.job.methods {
+			if !uses(method, com) {
+				continue
+			}
+			for _, output := range method.outputs {
+				value := float64(output.quantity) * beliefMidpoint(agent, output.item)
+				plain = plain + value
+				weighted = weighted + value*agent.utilityWeight(output.item)
+			}
+		}
+	}
+	if plain <= 0 || weighted == plain {
+		return mid
+	}
+	//How far [-1.0,1.0] from the middle towards either end of the belief to bid
+	lean := math.Max(1-plain/weighted, -1)
+	if weighted <= 0 {
+		lean = -1
+	}
+	if lean > 0 {
+		return mid + (agent.priceBelief[com].high-mid)*lean
+	}
+	return mid + (mid-agent.priceBelief[com].low)*lean
 }
 
 //An ask is a request to the market to sell an item at a given price.
+//id - the id of the agent selling, filled in by the market
 //item - a pointer to a commodity that is being sold
 //quantity - a number of units to sell in this ask
 //sellFor - a price to sell that commodity at
 //accepted - whether or not this ask was successful //a channel to feed back results to the agent
+//deliveryTick - the tick a futures ask delivers on (0 is a spot ask, see
+//FuturesMarket)
+//marketOrder - whether the ask sells at whatever the best bid is, rather than at
+//sellFor (see executionPrice).  Once it trades, sellFor is the price it got.
 type ask struct {
-	id       uint64
-	item     *commodity
-	quantity int
-	sellFor  float64
+	id           uint64
+	item         *commodity
+	quantity     int
+	sellFor      float64
+	deliveryTick int
+	marketOrder  bool
 }
 
 //A bid is a request to the market to buy a commodity at a given price.
+//id - the id of the agent buying, filled in by the market
 //item - a pointer to a commodity that we wish to purchase
 //quantity - the number of units to attempt to buy in this bid
 //buyFor - a price to buy that commodity for
 //accepted - whether or not this bid was successful //a channel to feed back results to the agent
+//deliveryTick - the tick a futures bid delivers on (0 is a spot bid, see
+//FuturesMarket)
+//marketOrder - whether the bid buys at whatever the best ask is, rather than at
+//buyFor (see executionPrice).  Once it trades, buyFor is the price it paid.
 type bid struct {
-	id       uint64
-	item     *commodity
-	quantity int
-	buyFor   float64
+	id           uint64
+	item         *commodity
+	quantity     int
+	buyFor       float64
+	deliveryTick int
+	marketOrder  bool
 }
 
 type asks struct {
@@ -165,47 +488,132 @@ func cQMapConcat(mA map[*commodity]int, mB map[*commodity]int) map[*commodity]in
 //agentRun is the execution part of the traderAgent struct.
 //It performs production, sets up bids and asks, receives data back, updates
 //inventories and cash on hand and updates beliefs.
-//agent - a traderAgent struct
+//agent - a pointer to a traderAgent struct
 //agentAsks - a channel for asks
 //agentBids - a channel for bids
 //deadAgent - a channel for returning a dead traderAgent for examination and ressurection
-func agentRun(agent traderAgent) (chan []asks, chan []bids, chan traderAgent) {
+//Cancelling ctx stops the agent the next time it waits on the market, and closes
+//deadAgent so nothing waits on it.  Between ticks that is always once it has
+//produced and made its offers, so a stopped agent is left in the same state
+//however long it took to notice.  running is told when the agent has stopped.  An agent
+//restored from a checkpoint starts from where it was saved: it sends the offers it
+//had already made, or reports its death.
+func agentRun(ctx context.Context, agent *traderAgent, jobs map[string]*productionSet, cancel chan<- uint64, running *sync.WaitGroup) (chan []asks, chan []bids, chan traderAgent) {
 	var askSlice []asks
 	var bidSlice []bids
 	agentAsks := make(chan []asks)
 	agentBids := make(chan []bids)
 	deadAgent := make(chan traderAgent)
-	alive := true
+	alive := agent.resume == nil || !agent.resume.dead
+	if agent.mu == nil {
+		agent.mu = new(sync.Mutex)
+	}
+	running.Add(1)
 	go func() {
+		defer func() {
+			if ctx.Err() != nil {
+				close(deadAgent)
+			}
+			running.Done()
+		}()
 		//Loop forever, until we quit or die (AKA run out of money)
 		for alive {
-			//First, try and perform production
-			performProduction(&agent)
-			//Then, generate offers
-			askSlice = nil
-			bidSlice = nil
-			askSlice = generateAsks(&agent)
-			bidSlice = generateBids(&agent)
+			agent.mu.Lock()
+			tracer := agent.settings().Tracer
+			if agent.resume != nil {
+				//These offers were made before the checkpoint
+				askSlice, bidSlice = agent.resume.asks, agent.resume.bids
+				agent.resume = nil
+			} else if agent.role == marketMakerRole {
+				//Market makers make nothing - they only quote
+				expirePerishables(agent)
+				askSlice, bidSlice = generateQuotes(agent)
+			} else if agent.job == nil {
+				//Speculators make nothing - they only trade on momentum
+				expirePerishables(agent)
+				askSlice = generateSpeculatorAsks(agent)
+				bidSlice = generateSpeculatorBids(agent)
+			} else {
+				//First, try and perform production
+				_, end := startSpan(tracer, agent.spanContext(), "agent.production")
+				performProduction(agent)
+				end()
+				//Then, generate offers
+				askSlice = nil
+				bidSlice = nil
+				_, end = startSpan(tracer, agent.spanContext(), "agent.generateAsks")
+				askSlice = generateAsks(agent)
+				end()
+				_, end = startSpan(tracer, agent.spanContext(), "agent.generateBids")
+				bidSlice = generateBids(agent)
+				end()
+			}
+			agent.mu.Unlock()
 			//fmt.Println(askSlice)
 			//Send the offers in
-			agentAsks <- askSlice
-			agentBids <- bidSlice
-			//Receive responses
-			askSlice = <-agentAsks
+			select {
+			case agentAsks <- askSlice:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case agentBids <- bidSlice:
+			case <-ctx.Done():
+				return
+			}
+			//Receive responses, unless the market has stopped answering
+			select {
+			case askSlice = <-agentAsks:
+			case <-responseDeadline(agent):
+				timeOut(agent)
+				cancelOrders(agent, cancel)
+				alive = false
+				continue
+			case <-ctx.Done():
+				return
+			}
 			//for len(askSlice) == 0 {
 			//	askSlice = <-agentAsks //get the last one?
 			//}
-			bidSlice = <-agentBids
+			select {
+			case bidSlice = <-agentBids:
+			case <-responseDeadline(agent):
+				timeOut(agent)
+				cancelOrders(agent, cancel)
+				alive = false
+				continue
+			case <-ctx.Done():
+				return
+			}
 			//fmt.Println("Got my responses!")
+			agent.mu.Lock()
 			//Update cash on hand, inventory, and belief
-			agentUpdate(&agent, &askSlice, &bidSlice)
-			//If cash is gone, break the loop
-			if agent.funds <= 0 {
+			_, end := startSpan(tracer, agent.spanContext(), "agent.update")
+			agentUpdate(agent, &askSlice, &bidSlice)
+			end()
+			trackBatches(agent)
+			if agent.role == marketMakerRole {
+				rebalanceQuotes(agent)
+			}
+			agent.age++
+			//If we're running low, try another line of work before it's too late
+			if struggling(agent) {
+				switchRole(agent, jobs)
+			}
+			//If we're worth too little (or out of business, or too old), break the loop
+			if agent.outOfBusiness || NetWorth(agent) < agent.settings().DeathThreshold || retired(agent) {
 				alive = false
 			}
+			agent.mu.Unlock()
+		}
+		//Inform the world that we are dead (out of business) and return
+		agent.mu.Lock()
+		dead := *agent
+		agent.mu.Unlock()
+		select {
+		case deadAgent <- dead:
+		case <-ctx.Done():
 		}
-		//Inform the world that we are dead (out of money) and return
-		deadAgent <- agent
 	}()
 	return agentAsks, agentBids, deadAgent
 }
@@ -248,19 +656,22 @@ func getAverageProductionValue(agent *traderAgent, productionNumber int) float64
 		return -1
 	}
 	method := agent.job.methods[productionNumber]
-	//Get the upside
+	//Get the upside, weighted by how much the agent values each good
 	for _, outputs := range method.outputs {
-		productionValue = productionValue + float64(outputs.quantity)*
+		productionValue = productionValue + float64(outputs.quantity)*agent.utilityWeight(outputs.item)*
 			((agent.priceBelief[outputs.item].high+agent.priceBelief[outputs.item].low)/2)
+		//Whatever we make has to be kept until it sells
+		productionValue = productionValue - float64(outputs.quantity)*outputs.item.CarryingCostPerUnit
 	}
 	//Calculate the cost of inputs and subtract
 	for _, inputs := range method.inputs {
-		productionValue = productionValue - float64(inputs.quantity)*
+		productionValue = productionValue - float64(inputs.quantity)*agent.utilityWeight(inputs.item)*
 			((agent.priceBelief[inputs.item].high+agent.priceBelief[inputs.item].low)/2)
 	}
 	//Calculate the catalyst costs and subtract
 	for index, catalysts := range method.catalysts {
 		productionValue = productionValue - float64(catalysts.quantity)*method.consumption[index]*
+			agent.utilityWeight(catalysts.item)*
 			((agent.priceBelief[catalysts.item].high+agent.priceBelief[catalysts.item].low)/2)
 	}
 
@@ -282,21 +693,65 @@ func getAllAverageProductionValues(agent *traderAgent) map[*productionMethod]flo
 //Given a production set, which contains a set of production methods, the agent
 //solves for the most expected value, given their internal belief of the commodity
 //price.  If they cannot execute the activity with the most expected value, they
-//execute the next highest value activity.  Idle agents are fined the idle penalty
-//of their productionSet.
+//execute the next highest value activity.  Methods whose output wouldn't fit in
+//the agent's maxInventory are skipped.  Idle agents are fined the idle penalty
+//of their productionSet, and agents idle for MaxConsecutivePenalties ticks in a row
+//are put out of business.  Agents produce up to ProductionCyclesPerTick times a
+//tick, stopping at the first cycle they can't run.  An agent busy with a method
+//that takes more than one tick (see advanceWork) starts nothing else until it is
+//done, and isn't idle meanwhile.
 //agent - pointer to the traderAgent data set
 func performProduction(agent *traderAgent) {
-	//This is a sorting of methods by market value.
-	//BUG: This is incorrect.  However, I will test with an incorrect assumption
-	//and fix it going forward.
-	sort.Sort(ByMarketValue(agent.job.methods))
-	//Attempt to execute methods in order of expected value.  If failing to execute,
-	//apply penalty.
+	//Pay to keep what we hold, throw out anything past its shelf life, and note when
+	//we made whatever we make.
+	chargeCarryingCost(agent)
+	expirePerishables(agent)
+	defer trackBatches(agent)
+	if advanceWork(agent) {
+		agent.consecutivePenalties = 0
+		return
+	}
+	//Let the selector decide what order to try our methods in.
+	methods := agent.settings().selector().Order(agent, agent.job.methods)
+	for cycle := 1; cycle <= agent.settings().ProductionCyclesPerTick; cycle++ {
+		if working(agent) {
+			break
+		}
+		if !produce(agent, methods, cycle) {
+			if cycle == 1 {
+				//Penalty!
+				agent.funds = agent.funds - agent.job.penalty
+				agent.ledger.Record(agent.id, 0, agent.job.penalty, agent.tick, ledgerPenalty)
+				agent.ProductionPenalties = agent.ProductionPenalties + agent.job.penalty
+				agent.consecutivePenalties++
+				if limit := agent.settings().MaxConsecutivePenalties; limit > 0 && agent.consecutivePenalties >= limit {
+					//Nothing we can make - time for someone else to take this spot.
+					agent.outOfBusiness = true
+				}
+				return
+			}
+			break
+		}
+	}
+	agent.consecutivePenalties = 0
+}
+
+//produce runs one production cycle of the agent, returning false if it couldn't
+//run any of its methods.
+//agent - pointer to the traderAgent data set
+//methods - the agent's production methods, in the order to try them
+//cycle - which production of the tick this is, counting from 1
+func produce(agent *traderAgent, methods []*productionMethod, cycle int) bool {
+	//Attempt to execute methods in order of expected value.
 	accepted := false
 	executedIndex := -1
-	for methodIndex, method := range agent.job.methods {
+	for methodIndex, method := range methods {
 		accepted = true
-		for _, input := range method.inputs {
+		for _, input := range cycleInputs(agent, method, cycle) {
+			//Common pool inputs come out of the pool, not our inventory.
+			if input.item.IsCommonPool {
+				continue
+			}
 			//Make sure we have all the inputs in quantity necessary.
 			//NOTE: The following construct says "accepted is equal to the current
 			//truth of accepted ANDed with the truth of whether required input
@@ -307,37 +762,55 @@ func performProduction(agent *traderAgent) {
 			//Make sure we have all the catalysts in quantity necessary.
 			accepted = accepted && catalyst.quantity <= agent.inventory[catalyst.item]
 		}
-		if accepted {
+		//Make sure we're allowed to do it, and have room for what it makes.
+		accepted = accepted && hasPermits(agent, method)
+		accepted = accepted && fitsInventory(agent, method, cycleInputs(agent, method, cycle))
+		//Last, grab what we need from any common pools.
+		if accepted && drawPoolInputs(cycleInputs(agent, method, cycle)) {
 			executedIndex = methodIndex
 			break
 		}
 	}
 	if executedIndex == -1 {
-		//Penalty!
-		agent.funds = agent.funds - agent.job.penalty
+		return false
 	} else {
 		//SUCCESS!  Work it!
 		//Remove inputs!
-		for _, input := range agent.job.methods[executedIndex].inputs {
+		inputs := cycleInputs(agent, methods[executedIndex], cycle)
+		for _, input := range inputs {
+			//Pool inputs were already drawn.
+			if input.item.IsCommonPool {
+				continue
+			}
 			//Remove these automatically!
 			agent.inventory[input.item] = agent.inventory[input.item] - input.quantity
 		}
 		//Try and remove catalysts!
-		for catalystIndex, catalyst := range agent.job.methods[executedIndex].catalysts {
+		var consumed []commoditySet
+		for catalystIndex, catalyst := range methods[executedIndex].catalysts {
 			//Test seperately for each catalyst
 			for i := 0; i < catalyst.quantity; i++ {
 				//Remove these on probablility given in consumption
-				if agent.job.methods[executedIndex].consumption[catalystIndex] > rand.Float64() {
+				if methods[executedIndex].consumption[catalystIndex] > agent.random().Float64() {
 					//OK, you were unlucky!
 					agent.inventory[catalyst.item] = agent.inventory[catalyst.item] - 1
+					consumed = append(consumed, commoditySet{item: catalyst.item, quantity: 1})
 				}
 			}
 		}
-		//Provide output!
-		for _, output := range agent.job.methods[executedIndex].outputs {
-			agent.inventory[output.item] = agent.inventory[output.item] + output.quantity
+		//Provide output!  Slow methods provide it later.
+		if ticks := methods[executedIndex].ProductionTicks; ticks > 1 {
+			agent.workQueue = append(agent.workQueue, pendingWork{method: methods[executedIndex],
+				remaining: ticks - 1, outputs: skilledOutputs(agent, methods[executedIndex])})
+		} else {
+			deliverOutputs(agent, skilledOutputs(agent, methods[executedIndex]))
 		}
+		agent.ProductionCount++
+		used := append([]commoditySet{}, inputs...)
+		recordProduction(agent, methods[executedIndex], append(used, consumed...))
+		practise(agent, methods[executedIndex])
 	}
+	return true
 }
 
 //gatherAllRequirements takes an agent's job list and returns a set of requirements
@@ -366,6 +839,10 @@ func gatherRequirements(pm *productionMethod) map[*commodity]int {
 	pmn := make(map[*commodity]int)
 
 	for _, inputs := range pm.inputs {
+		//Nobody can buy from a common pool - it comes straight out of the ground.
+		if inputs.item.IsCommonPool {
+			continue
+		}
 		pmn[inputs.item] = pmn[inputs.item] + inputs.quantity
 	}
 	for _, catalysts := range pm.catalysts {
@@ -377,7 +854,8 @@ func gatherRequirements(pm *productionMethod) map[*commodity]int {
 
 //generateAsks creates asks for the agent to place in the marketplace and sell its
 //goods.  These asks are based on the agent's current belief of the price modulated
-//by the current price average.
+//by the current price average.  An agent near its maxInventory hurries to sell
+//whatever it holds most of.
 //agent - a pointer to a traderAgent dataset
 //askSlice - a return slice of asks.  This contains all of the asks the trader will
 //make in this round of trading.
@@ -385,16 +863,31 @@ func generateAsks(agent *traderAgent) []asks {
 	var askSlice []asks
 	//gather any possible requirements for production
 	cnm := gatherAllRequirements(agent)
+	//spoiled goods go up for sale on their own - as many as are still left after
+	//production, anyway
+	spoiled := make(map[*commodity]int)
+	var spoiledAsks []asks
+	for _, askSet := range agent.spoiledAsks {
+		com := askSet.offeredAsk.item
+		if left := agent.inventory[com] - spoiled[com]; askSet.numberOffered > left {
+			askSet.numberOffered = left
+		}
+		if askSet.numberOffered > 0 {
+			spoiled[com] += askSet.numberOffered
+			spoiledAsks = append(spoiledAsks, askSet)
+		}
+	}
+	agent.spoiledAsks = nil
 
 	//sell everything else in inventory
 	for com, num := range agent.inventory {
 		_, ok := cnm[com]
 		//ok is false if this inventory item is not in required items.
-		//That means we should try and sell it.
-		if !ok {
+		//That means we should try and sell it - unless nobody would ever buy it.
+		if !ok && !com.IsExternality && num > spoiled[com] {
 			var askBuild asks
 			askBuild.numberAccepted = 0
-			askBuild.numberOffered = num
+			askBuild.numberOffered = num - spoiled[com]
 			askBuild.offeredAsk.quantity = 1
 			askBuild.offeredAsk.item = com
 			//So, given the average price on the exchange, what should we sell for?
@@ -405,6 +898,19 @@ func generateAsks(agent *traderAgent) []asks {
 			askSlice = append(askSlice, askBuild)
 		}
 	}
+	if nearCapacity(agent) {
+		prioritizeAsks(agent, askSlice)
+	}
+	//Close to death, we'll take whatever we can get.
+	if urgent(agent) {
+		for i := range askSlice {
+			askSlice[i].offeredAsk.marketOrder = true
+		}
+	}
+	askSlice = append(askSlice, generatePermitAsks(agent)...)
+	askSlice = append(askSlice, spoiledAsks...)
+	askSlice = append(askSlice, generateShortAsks(agent)...)
+	askSlice = append(askSlice, generateFuturesAsks(agent)...)
 
 	return askSlice
 }
@@ -423,8 +929,8 @@ func generateBids(agent *traderAgent) []bids {
 	spv := sortedPVKeys(pvm)
 
 	//Take the top "riskAversion" number of possible production methods and make
-	//sure we can cover at least two cycles with them.
-	cyclesToCover := 2
+	//sure we can cover our cycles with them.
+	cyclesToCover := coverCycles(agent)
 	invReqs := make(map[*commodity]int)
 	for i := 0; i < agent.riskAversion; i++ {
 		for j := 0; j < cyclesToCover; j++ {
@@ -435,6 +941,11 @@ func generateBids(agent *traderAgent) []bids {
 		}
 	}
 
+	//Buffer less of whatever costs to keep.
+	for com, num := range invReqs {
+		invReqs[com] = bufferedUnits(agent, com, num, cyclesToCover)
+	}
+
 	//Now that we know what we need, let's see remove what we've already got.
 	for com, num := range agent.inventory {
 		_, ok := invReqs[com]
@@ -450,12 +961,15 @@ func generateBids(agent *traderAgent) []bids {
 		bidBuild.offeredBid.quantity = 1
 		bidBuild.offeredBid.item = com
 		//So, given the average price on the exchange, what should we buy at?
-		//This instantiation buys at the average of my price belief and the
-		//exchange average.
-		bidBuild.offeredBid.buyFor = (agent.priceBelief[com].high + agent.priceBelief[com].low) / 2
+		//This instantiation buys at the middle of my price belief, less what the
+		//cash could make elsewhere.
+		bidBuild.offeredBid.buyFor = opportunityCostAdjustedBid(agent, com)
 		//(agent.priceBelief[com].high + agent.priceBelief[com].low + com.averagePrice) / 3
 		bidSlice = append(bidSlice, bidBuild)
 	}
+	bidSlice = append(bidSlice, generatePermitBids(agent)...)
+	bidSlice = append(bidSlice, generateCoverBids(agent, invReqs)...)
+	bidSlice = append(bidSlice, generateFuturesBids(agent)...)
 
 	return bidSlice
 }
@@ -466,151 +980,103 @@ func generateBids(agent *traderAgent) []bids {
 //askSlice - pointer to the post market ask slice (carrying sold data)
 //bidSlice - pointer to the post market bid slice (carrying buy data)
 func agentUpdate(agent *traderAgent, askSlice *[]asks, bidSlice *[]bids) {
+	//Short positions open now must be bought back by the end of this update.
+	due := make(map[*commodity]int)
+	for com, num := range agent.shortPositions {
+		due[com] = num
+	}
 	//Go through all the asks and tally up the sales/remove items from inventory.
-	//If not accepted, lower sales price internal estimate
-	bigPercent := 0.2
-	littlePercent := 0.01
+	//Then let the agent rethink its price - if not accepted, it was too high.
 	for _, askSet := range *askSlice {
-		agentHigh := agent.priceBelief[askSet.offeredAsk.item].high
-		agentLow := agent.priceBelief[askSet.offeredAsk.item].low
-		agentAvg := (agentHigh + agentLow) / 2
-		itemAvg := askSet.offeredAsk.item.averagePrice
+		//Futures are settled on delivery, not now.
+		if askSet.offeredAsk.deliveryTick > 0 {
+			continue
+		}
+		itemAvg := heardPrice(agent, referencePrice(agent, askSet.offeredAsk.item))
 		if askSet.numberAccepted > 0 {
 			//AskSet was accepted!  Take out that much inventory and add cash.
 			fmt.Printf("Ask Accepted! %v units of %v for %v\n", askSet.numberAccepted, askSet.offeredAsk.item.name, askSet.offeredAsk.sellFor)
-			agent.funds = agent.funds + (float64(askSet.offeredAsk.quantity) * float64(askSet.numberAccepted) * askSet.offeredAsk.sellFor)
-			agent.inventory[askSet.offeredAsk.item] = agent.inventory[askSet.offeredAsk.item] - (askSet.offeredAsk.quantity * askSet.numberAccepted)
-			//Consider raising our prices - a lot if we're under the average, a little if we're over.
-			if agentAvg <= itemAvg {
-				//Agent Average under Average - Raise a lot!
-				agentHigh = agentHigh + math.Abs(agentHigh-itemAvg)*bigPercent
-				agentLow = agentLow + math.Abs(agentLow-itemAvg)*bigPercent
-				//Bring it back down if too big.
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*bigPercent
-					fmt.Println("INVERT1")
-				}
-			} else {
-				//Overaverage!  Raise just a bit.
-				agentHigh = agentHigh + math.Abs(agentHigh-itemAvg)*littlePercent
-				agentLow = agentLow + math.Abs(agentLow-itemAvg)*littlePercent
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*littlePercent
-					fmt.Println("INVERT2")
-				}
-			}
-
-		} else {
-			//None were accepted!  This means our price was too high. =(
-			//Consider, are we larger than the average?  Lower it down towards the average by a lot.
-			//Are we lower than the average?  Lower it down a little bit.
-			if agentAvg >= itemAvg {
-				//Agent Average over Average - Lower a lot!
-				agentHigh = agentHigh - math.Abs(agentHigh-itemAvg)*bigPercent
-				agentLow = agentLow - math.Abs(agentLow-itemAvg)*bigPercent
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*bigPercent
-					fmt.Println("INVERT3")
-				}
-			} else {
-				//Under Average
-				agentHigh = agentHigh - math.Abs(agentHigh-itemAvg)*littlePercent
-				agentLow = agentLow - math.Abs(agentLow-itemAvg)*littlePercent
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*littlePercent
-					//fmt.Println("INVERT4")
-				}
-			}
-		}
-		//if agentHigh < askSet.offeredAsk.item.averagePrice {
-		//	agentHigh = askSet.offeredAsk.item.averagePrice
-		//}
-		if agentLow < 0 {
-			agentLow = 0.5 //Totally arbitrary
+			revenue := float64(askSet.offeredAsk.quantity) * float64(askSet.numberAccepted) * askSet.offeredAsk.sellFor
+			agent.funds = agent.funds + revenue
+			agent.ledger.Record(0, agent.id, revenue, agent.tick, ledgerTrade)
+			agent.Revenue = agent.Revenue + revenue
+			adjustHolding(agent, askSet.offeredAsk.item, -(askSet.offeredAsk.quantity * askSet.numberAccepted))
+			agent.lifetimeAskVolume = agent.lifetimeAskVolume + askSet.offeredAsk.quantity*askSet.numberAccepted
 		}
-		var agentPriceBelief = agent.priceBelief[askSet.offeredAsk.item]
-		agentPriceBelief.high = agentHigh
-		agentPriceBelief.low = agentLow
 		//fmt.Printf("Price on %v: Low: %v, High: %v, Current Average: %v\n", askSet.offeredAsk.item.name, agentLow, agentHigh, askSet.offeredAsk.item.averagePrice)
-		agent.priceBelief[askSet.offeredAsk.item] = agentPriceBelief
+		agent.priceBelief[askSet.offeredAsk.item] = agent.askUpdater().Update(agent.priceBelief[askSet.offeredAsk.item],
+			askSet.numberAccepted, askSet.numberOffered, itemAvg)
 	}
 
 	//Go through all the bids.
 	//Clear buys, remove money, add inventory, alter prices
 	for _, bidSet := range *bidSlice {
-		agentHigh := agent.priceBelief[bidSet.offeredBid.item].high
-		agentLow := agent.priceBelief[bidSet.offeredBid.item].low
-		agentAvg := (agentHigh + agentLow) / 2
-		itemAvg := bidSet.offeredBid.item.averagePrice
+		if bidSet.offeredBid.deliveryTick > 0 {
+			continue
+		}
+		itemAvg := heardPrice(agent, referencePrice(agent, bidSet.offeredBid.item))
 		if bidSet.numberAccepted > 0 {
 			//bidSet was accepted!  Give inventory and remove cash
-			agent.funds = agent.funds - (float64(bidSet.offeredBid.quantity) * float64(bidSet.numberAccepted) * bidSet.offeredBid.buyFor)
-			agent.inventory[bidSet.offeredBid.item] = agent.inventory[bidSet.offeredBid.item] + (bidSet.offeredBid.quantity * bidSet.numberAccepted)
-			//Consider lowering our prices - a lot if we're over the average, a little if we're under.
-			if agentAvg >= itemAvg {
-				//Agent Average over Average - Lower a lot!
-				agentHigh = agentHigh - math.Abs(agentHigh-itemAvg)*bigPercent
-				agentLow = agentLow - math.Abs(agentLow-itemAvg)*bigPercent
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*bigPercent
-					fmt.Println("INVERT5")
-				}
-			} else {
-				//Under Average
-				agentHigh = agentHigh - math.Abs(agentHigh-itemAvg)*littlePercent
-				agentLow = agentLow - math.Abs(agentLow-itemAvg)*littlePercent
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*littlePercent
-					fmt.Println("INVERT6")
-				}
-			}
-
-		} else {
-			//None were accepted!  This means our price was too low. =(
-			//Consider, are we larger than the average?  Raise it down towards the average by a little.
-			//Are we lower than the average?  Raise it a lot
-			if agentAvg <= itemAvg {
-				//Agent Average under Average - Raise a lot!
-				agentHigh = agentHigh + math.Abs(agentHigh-itemAvg)*bigPercent
-				agentLow = agentLow + math.Abs(agentLow-itemAvg)*bigPercent
-				//Bring it back down if too big.
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*bigPercent
-					fmt.Println("INVERT7")
-				}
-			} else {
-				//Overaverage!  Raise just a bit.
-				agentHigh = agentHigh + math.Abs(agentHigh-itemAvg)*littlePercent
-				agentLow = agentLow + math.Abs(agentLow-itemAvg)*littlePercent
-				for agentLow >= agentHigh {
-					agentLow = agentLow - math.Abs(agentLow-itemAvg)*littlePercent
-					fmt.Println("INVERT8")
-				}
-			}
+			cost := float64(bidSet.offeredBid.quantity) * float64(bidSet.numberAccepted) * bidSet.offeredBid.buyFor
+			agent.funds = agent.funds - cost
+			agent.ledger.Record(agent.id, 0, cost, agent.tick, ledgerTrade)
+			agent.Cost = agent.Cost + cost
+			adjustHolding(agent, bidSet.offeredBid.item, bidSet.offeredBid.quantity*bidSet.numberAccepted)
+			agent.lifetimeBidVolume = agent.lifetimeBidVolume + bidSet.offeredBid.quantity*bidSet.numberAccepted
 		}
-		//if agentHigh < bidSet.offeredBid.item.averagePrice {
-		//	agentHigh = bidSet.offeredBid.item.averagePrice
-		//}
-		if agentLow < 0 {
-			agentLow = 0.5 //Totally arbitrary
+		agent.priceBelief[bidSet.offeredBid.item] = agent.bidUpdater().Update(agent.priceBelief[bidSet.offeredBid.item],
+			bidSet.numberAccepted, bidSet.numberOffered, itemAvg)
+	}
+	settleShorts(agent, due)
+	if agent.settings().AdaptiveCyclesToCover {
+		adaptCyclesToCover(agent, *bidSlice)
+	}
+	doResearch(agent)
+}
+
+//clampPriceRange makes sure a price belief's low sits below its high.  An inverted
+//range first has its low pulled away from the average by adjustPct, once; if that
+//doesn't do it (the low sat right on the average, say), the low is set adjustPct
+//below the high.  A high at or below zero falls back to the average, or to 1 if
+//the average is no better.
+//low, high - the belief's range
+//itemAvg - the average price being steered towards
+//adjustPct - how far [0.0,1.0] to pull the low
+func clampPriceRange(low, high, itemAvg, adjustPct float64) (float64, float64) {
+	if low < high {
+		return low, high
+	}
+	low = low - math.Abs(low-itemAvg)*adjustPct
+	if low < high {
+		return low, high
+	}
+	if high <= 0 {
+		high = itemAvg
+		if high <= 0 {
+			high = 1
 		}
-		var agentPriceBelief = agent.priceBelief[bidSet.offeredBid.item]
-		agentPriceBelief.high = agentHigh
-		agentPriceBelief.low = agentLow
-		agent.priceBelief[bidSet.offeredBid.item] = agentPriceBelief
 	}
+	if adjustPct <= 0 || adjustPct >= 1 {
+		adjustPct = 0.5
+	}
+	return high * (1 - adjustPct), high
 }
 
-//Generates an initial random price belief for an agent.  It is set to high >
-//averagePrice and low > 0
+//Generates an initial price belief for an agent.  It is set spread either side of
+//averagePrice, so high > averagePrice > low for any positive averagePrice.
 //commoditySlice - a slice of commodity pointers
+//spread - how far either side of averagePrice, as a fraction (0.0,1.0) of it (any
+//other spread uses defaultBeliefInitSpread)
 //Returns a map of commodity pointers to price range
-func randomPriceBelief(commodityList map[string]*commodity) map[*commodity]priceRange {
+func initialPriceBelief(commodityList map[string]*commodity, spread float64) map[*commodity]priceRange {
+	if spread <= 0 || spread >= 1 {
+		spread = defaultBeliefInitSpread
+	}
 	prMap := make(map[*commodity]priceRange)
 	for _, aCommodity := range commodityList {
 		var pr priceRange
-		pr.high = aCommodity.averagePrice + (rand.Float64() * aCommodity.averagePrice)
-		pr.low = aCommodity.averagePrice - (rand.Float64() * aCommodity.averagePrice)
+		pr.high = aCommodity.averagePrice * (1 + spread)
+		pr.low = aCommodity.averagePrice * (1 - spread)
 		prMap[aCommodity] = pr
 	}
 	return prMap
@@ -618,6 +1084,26 @@ func randomPriceBelief(commodityList map[string]*commodity) map[*commodity]price
 
 func main() {
 	fmt.Println("Economic Simulation")
+	seed := flag.Int64("seed", 0, "seed for the simulation's randomness (0 is a different run every time)")
+	ticks := flag.Int("ticks", 0, "run this many ticks as fast as possible, then stop (0 is real time, until interrupted)")
+	resurrection := flag.String("resurrection", "expensive", "what replaces dead agents: expensive, balance, copy or bottleneck")
+	flag.StringVar(&metricsAddr, "metrics", "", "serve Prometheus metrics at /metrics on this address (empty is none)")
+	flag.StringVar(&controlAddr, "control", "", "serve the control API on this address (empty is none)")
+	flag.StringVar(&streamAddr, "stream", "", "stream every tick over a WebSocket at /stream on this address (empty is none)")
+	flag.Parse()
+	config := defaultSimulationConfig()
+	config.Seed = *seed
+	if strategy, ok := resurrectionStrategies[*resurrection]; ok {
+		config.Resurrection = strategy
+	} else {
+		fmt.Println("Unknown resurrection strategy", *resurrection)
+		return
+	}
+	//An economy described in a file replaces the one below.
+	if flag.NArg() > 0 {
+		runFromFile(flag.Arg(0), config, *ticks)
+		return
+	}
 	fmt.Println("Set up our commodities")
 	var wood commodity
 	wood.name = "Wood"
@@ -628,12 +1114,16 @@ func main() {
 	var food commodity
 	food.name = "Food"
 	food.averagePrice = 3
+	food.SpoilageRate = 0.02
 	var ore commodity
 	ore.name = "Ore"
 	ore.averagePrice = 3
 	var metal commodity
 	metal.name = "Metal"
 	metal.averagePrice = 3
+	var pollution commodity
+	pollution.name = "Pollution"
+	pollution.IsExternality = true
 
 	allCommodities := make(map[string]*commodity)
 	allCommodities["Wood"] = &wood
@@ -641,6 +1131,8 @@ func main() {
 	allCommodities["Food"] = &food
 	allCommodities["Ore"] = &ore
 	allCommodities["Metal"] = &metal
+	allCommodities["Pollution"] = &pollution
+	addPermits(allCommodities)
 
 	//Commodity Sets
 	//Food
@@ -687,6 +1179,13 @@ func main() {
 	var fourTools commoditySet
 	fourTools.item = &tools
 	fourTools.quantity = 4
+	//Pollution
+	var singlePollution commoditySet
+	singlePollution.item = &pollution
+	singlePollution.quantity = 1
+	var twoPollution commoditySet
+	twoPollution.item = &pollution
+	twoPollution.quantity = 2
 
 	fmt.Println("Set up our production rules")
 	//Farmer
@@ -723,13 +1222,21 @@ func main() {
 	refinerProd.inputs[0] = singleFood
 	refinerProd.inputs[1] = twoOre
 	refinerProd.outputs = append(refinerProd.outputs, twoMetal)
+	refinerProd.outputs = append(refinerProd.outputs, singlePollution)
 	var refinerToolsProd productionMethod
 	refinerToolsProd.inputs = make([]commoditySet, 2)
 	refinerToolsProd.inputs[0] = singleFood
 	refinerToolsProd.inputs[1] = fourOre
 	refinerToolsProd.outputs = append(refinerToolsProd.outputs, fourMetal)
+	refinerToolsProd.outputs = append(refinerToolsProd.outputs, twoPollution)
 	refinerToolsProd.catalysts = append(refinerToolsProd.catalysts, singleTools)
 	refinerToolsProd.consumption = append(refinerToolsProd.consumption, 0.1)
+	//Seasoned refiners get by on a tenth less ore.
+	var refinerDiscount BatchDiscount
+	refinerDiscount.Threshold = 50
+	refinerDiscount.DiscountRate = 0.1
+	refinerProd.discount = &refinerDiscount
+	refinerToolsProd.discount = &refinerDiscount
 	var refinerProdSet productionSet
 	refinerProdSet.methods = make([]*productionMethod, 2)
 	refinerProdSet.methods[0] = &refinerProd
@@ -767,16 +1274,20 @@ func main() {
 	blacksmithProdSet.penalty = 2
 
 	fmt.Println("Set up our traders!")
+	market := newMarket(allCommodities, config)
+	market.Subscribe(func(event MarketEvent) {
+		fmt.Println(event)
+	})
 	////makeFarmer Example
-	//farmer := makeFarmer(allCommodities, &farmerProdSet)
+	//farmer := makeFarmer(allCommodities, &farmerProdSet, defaultSimulationConfig())
 	////makeMiner Example
-	//miner := makeMiner(allCommodities, &minerProdSet)
+	//miner := makeMiner(allCommodities, &minerProdSet, defaultSimulationConfig())
 	////makeRefiner Example
-	//refiner := makeRefiner(allCommodities, &refinerProdSet)
+	//refiner := makeRefiner(allCommodities, &refinerProdSet, defaultSimulationConfig())
 	////makeWoodcutter Example
-	//woodcutter := makeWoodcutter(allCommodities, &woodcutterProdSet)
+	//woodcutter := makeWoodcutter(allCommodities, &woodcutterProdSet, defaultSimulationConfig())
 	////makeBlacksmith Example
-	//blacksmith := makeBlacksmith(allCommodities, &blacksmithProdSet)
+	//blacksmith := makeBlacksmith(allCommodities, &blacksmithProdSet, defaultSimulationConfig())
 
 	//Set the cohort sizes
 	numFarmers := 500
@@ -784,392 +1295,236 @@ func main() {
 	numRefiners := 500
 	numWoodcutters := 500
 	numBlacksmiths := 500
-	totalTraders := numFarmers + numMiners + numRefiners + numWoodcutters + numBlacksmiths
-	askChannels := make([]chan []asks, totalTraders)
-	bidChannels := make([]chan []bids, totalTraders)
-	deadChannels := make([]chan traderAgent, totalTraders)
-	tempAskChannel := make(chan []asks)
-	tempBidChannel := make(chan []bids)
-	tempDeadChannel := make(chan traderAgent)
+	market.addRole("Farmer", &food, &farmerProdSet, func() traderAgent { return makeFarmer(allCommodities, &farmerProdSet, market.config) })
+	market.addRole("Miner", &ore, &minerProdSet, func() traderAgent { return makeMiner(allCommodities, &minerProdSet, market.config) })
+	market.addRole("Refiner", &metal, &refinerProdSet, func() traderAgent { return makeRefiner(allCommodities, &refinerProdSet, market.config) })
+	market.addRole("Woodcutter", &wood, &woodcutterProdSet, func() traderAgent { return makeWoodcutter(allCommodities, &woodcutterProdSet, market.config) })
+	market.addRole("Blacksmith", &tools, &blacksmithProdSet, func() traderAgent { return makeBlacksmith(allCommodities, &blacksmithProdSet, market.config) })
 	for i := 0; i < numFarmers; i++ {
-		tempAskChannel, tempBidChannel, tempDeadChannel = agentRun(makeFarmer(allCommodities, &farmerProdSet))
-		askChannels = append(askChannels, tempAskChannel)
-		bidChannels = append(bidChannels, tempBidChannel)
-		deadChannels = append(deadChannels, tempDeadChannel)
+		market.spawn("Farmer")
 	}
 	for i := 0; i < numMiners; i++ {
-		tempAskChannel, tempBidChannel, tempDeadChannel = agentRun(makeMiner(allCommodities, &minerProdSet))
-		askChannels = append(askChannels, tempAskChannel)
-		bidChannels = append(bidChannels, tempBidChannel)
-		deadChannels = append(deadChannels, tempDeadChannel)
+		market.spawn("Miner")
 	}
 	for i := 0; i < numRefiners; i++ {
-		tempAskChannel, tempBidChannel, tempDeadChannel = agentRun(makeRefiner(allCommodities, &refinerProdSet))
-		askChannels = append(askChannels, tempAskChannel)
-		bidChannels = append(bidChannels, tempBidChannel)
-		deadChannels = append(deadChannels, tempDeadChannel)
+		market.spawn("Refiner")
 	}
 	for i := 0; i < numWoodcutters; i++ {
-		tempAskChannel, tempBidChannel, tempDeadChannel = agentRun(makeWoodcutter(allCommodities, &woodcutterProdSet))
-		askChannels = append(askChannels, tempAskChannel)
-		bidChannels = append(bidChannels, tempBidChannel)
-		deadChannels = append(deadChannels, tempDeadChannel)
+		market.spawn("Woodcutter")
 	}
 	for i := 0; i < numBlacksmiths; i++ {
-		tempAskChannel, tempBidChannel, tempDeadChannel = agentRun(makeBlacksmith(allCommodities, &blacksmithProdSet))
-		askChannels = append(askChannels, tempAskChannel)
-		bidChannels = append(bidChannels, tempBidChannel)
-		deadChannels = append(deadChannels, tempDeadChannel)
+		market.spawn("Blacksmith")
+	}
+	market.addSpeculators(market.config.Speculators, market.config.SpeculatorLookback)
+	market.addMarketMakers(market.config.MarketMakers, market.config.MarketMakerSpread)
+
+	market.distributePermits()
+
+	run(newSimulation(market), *ticks)
+}
+
+//runFromFile sets up the economy described in a SimConfig file and runs it.  A
+//seed in the file is used unless config already has one.
+//ticks - how many ticks to run for (0 is forever, in real time)
+func runFromFile(path string, config SimulationConfig, ticks int) {
+	simConfig, err := LoadSimConfig(path)
+	if err != nil {
+		fmt.Println("Couldn't load", path, "-", err)
+		return
 	}
+	if config.Seed == 0 {
+		config.Seed = simConfig.Seed
+	}
+	addPermits(simConfig.CommodityList())
+	fmt.Println("Set up our traders!")
+	market := newMarket(simConfig.CommodityList(), config)
+	market.Subscribe(func(event MarketEvent) {
+		fmt.Println(event)
+	})
+	simConfig.Populate(market)
+	market.distributePermits()
+	sim := newSimulation(market)
+	sim.Economy = simConfig
+	run(sim, ticks)
+}
 
+//run ticks a market along in real time until interrupted or, given a number of
+//ticks, runs exactly that many as fast as it can and prints every commodity's
+//price history.
+func run(sim *Simulation, ticks int) {
+	market := sim.Market
 	fmt.Println("Set up a market!")
-	//Make the ask and bid books
-	//Break them by type
-	asksTyped := make(map[*commodity][]*asks)
-	bidsTyped := make(map[*commodity][]*bids)
-	for _, com := range allCommodities {
-		var asksBlank []*asks
-		var bidsBlank []*bids
-		asksTyped[com] = asksBlank
-		bidsTyped[com] = bidsBlank
+	if metricsAddr != "" {
+		if _, err := StartMetricsServer(metricsAddr, sim); err != nil {
+			fmt.Println("Couldn't serve metrics -", err)
+		}
+	}
+	if streamAddr != "" {
+		if _, err := StartStreamingServer(streamAddr, sim); err != nil {
+			fmt.Println("Couldn't stream ticks -", err)
+		}
+	}
+	if controlAddr != "" {
+		if _, err := StartControlAPI(controlAddr, sim); err != nil {
+			fmt.Println("Couldn't serve the control API -", err)
+		}
+	}
+	if ticks > 0 {
+		RunTicks(ticks, sim)
+		report(market)
+		fmt.Println("\nPrice Histories!")
+		for _, name := range market.commodityNames() {
+			fmt.Println(name+": ", market.commodities[name].PriceHistory(ticks))
+		}
+		if err := market.Shutdown(); err != nil {
+			fmt.Println(err)
+		}
+		return
 	}
 	//totalTimeMillis := 300
-	ticker := time.NewTicker(time.Millisecond * 500)
-	go func() {
-		for t := range ticker.C {
+	ticker := NewAdaptiveTicker(market.config.MinTickInterval, market.config.MaxTickInterval)
+	defer ticker.Stop()
+	market.metrics.setTickInterval(ticker.Interval)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	//Tick until interrupted
+	for {
+		select {
+		case t := <-ticker.C:
 			fmt.Println("tick at", t)
-			//RECEIVE ALL THE ASKS AND BIDS
-
-			//Check all the ask channels
-			var tempAsksStorage []asks
-			for com, _ := range asksTyped {
-				asksTyped[com] = nil
-			}
-			for chindex, channel := range askChannels {
-				select {
-				case tempAsksStorage = <-channel:
-					//fmt.Println("Got an *[]asks on ", chindex)
-					for _, asksIn := range tempAsksStorage {
-						//Add them to the ask book
-						asksIn.offeredAsk.id = uint64(chindex)
-						asksTyped[asksIn.offeredAsk.item] = append(asksTyped[asksIn.offeredAsk.item], &asksIn)
-					}
-				default:
-					//fmt.Println("No Asks on %v", chindex)
-				}
+			if !sim.Step() {
+				continue
 			}
-			var tempBidsStorage []bids
-			for com, _ := range bidsTyped {
-				bidsTyped[com] = nil
-			}
-			for chindex, channel := range bidChannels {
-				select {
-				case tempBidsStorage = <-channel:
-					//fmt.Println("Got a *[]bids on %v", chindex)
-					for _, bidsIn := range tempBidsStorage {
-						//Add them to the bids book
-						bidsIn.offeredBid.id = uint64(chindex)
-						bidsTyped[bidsIn.offeredBid.item] = append(bidsTyped[bidsIn.offeredBid.item], &bidsIn)
-					}
-				default:
-					//fmt.Println("No Bid on %v", chindex)
-				}
-			}
-
-			fmt.Println("Total Asks Types: ", len(asksTyped))
-			fmt.Println("Total Bids Types: ", len(bidsTyped))
-
-			//Sort the Asks and Bids within each type
-			for com, asksCom := range asksTyped {
-				fmt.Printf("Asks for %v: %v\n", com.name, len(asksCom))
-				sort.Sort(AsksLowToHigh(asksCom))
-			}
-			for com, bidsCom := range bidsTyped {
-				fmt.Printf("Bids for %v: %v\n", com.name, len(bidsCom))
-				sort.Sort(BidsHighToLow(bidsCom))
+			ticker.Adapt(market.activeShare())
+			report(market)
+		case <-interrupt:
+			fmt.Println("Shutting down")
+			if err := market.Shutdown(); err != nil {
+				fmt.Println(err)
 			}
+			return
+		}
+	}
+}
 
-			for com, asksCom := range asksTyped {
-				//Comparison: Lowest Ask to Highest Bid
-				bidsCom := bidsTyped[com]
-				//continue to match them, executing clearing trades as we go.
-				asksIndex := 0
-				bidsIndex := 0
-				totalTransactions := 0
-				var runningTotal float64
-				runningTotal = 0.0
-				if len(asksCom) > 0 && len(bidsCom) > 0 {
-					for {
-						asksQuantityRemaining := asksCom[asksIndex].numberOffered - asksCom[asksIndex].numberAccepted
-						bidsQuantityRemaining := bidsCom[bidsIndex].numberOffered - bidsCom[bidsIndex].numberAccepted
-						//Make sure prices are still acceptable - are there bids greater than asks in existance?
-						if asksCom[asksIndex].offeredAsk.sellFor > bidsCom[bidsIndex].offeredBid.buyFor {
-							break
-						}
-						//We're in business then - keep rollin'.
-						if asksQuantityRemaining >= bidsQuantityRemaining {
-							asksCom[asksIndex].numberAccepted += bidsQuantityRemaining
-							bidsCom[bidsIndex].numberAccepted = bidsCom[bidsIndex].numberOffered
-							totalTransactions += bidsCom[bidsIndex].numberAccepted
-							if asksQuantityRemaining != bidsQuantityRemaining {
-								//Split to add a new ask with the remaining bit (since we need to communicate back our price)
-								tempAsksComPre := asksCom[:asksIndex+1]  //Get everything before including our current index
-								tempAsksComPost := asksCom[asksIndex+1:] //Get everything after our current index
-								newAsk := asksCom[asksIndex].offeredAsk
-								newAsks := asksCom[asksIndex]
-								newAsks.numberAccepted = 0
-								newAsks.numberOffered = asksCom[asksIndex].numberOffered - asksCom[asksIndex].numberAccepted
-								newAsks.offeredAsk = newAsk
-								asksCom = append(tempAsksComPre, newAsks)
-								asksCom = append(asksCom, tempAsksComPost...)
-							}
-							//OK! New one added, let's clear the rest of it.
-							asksCom[asksIndex].numberOffered = asksCom[asksIndex].numberAccepted
-							asksCom[asksIndex].offeredAsk.sellFor = (asksCom[asksIndex].offeredAsk.sellFor + bidsCom[bidsIndex].offeredBid.buyFor) / 2.0
-							bidsCom[bidsIndex].offeredBid.buyFor = asksCom[asksIndex].offeredAsk.sellFor
-							runningTotal += bidsCom[bidsIndex].offeredBid.buyFor * float64(bidsCom[bidsIndex].numberAccepted)
-						} else {
-							//OK, more bids than asks instead.
-							bidsCom[bidsIndex].numberAccepted += asksQuantityRemaining
-							asksCom[asksIndex].numberAccepted = asksCom[asksIndex].numberOffered
-							totalTransactions += asksCom[asksIndex].numberAccepted
-							//Split to add a new bid with the remaining bit (since we need to communicate back our price)
-							tempBidsComPre := bidsCom[:bidsIndex+1]  //Get everything before including our current index
-							tempBidsComPost := bidsCom[bidsIndex+1:] //Get everything after our current index
-							newBid := bidsCom[bidsIndex].offeredBid
-							newBids := bidsCom[bidsIndex]
-							newBids.numberAccepted = 0
-							newBids.numberOffered = bidsCom[bidsIndex].numberOffered - bidsCom[bidsIndex].numberAccepted
-							newBids.offeredBid = newBid
-							bidsCom = append(tempBidsComPre, newBids)
-							bidsCom = append(bidsCom, tempBidsComPost...)
-							//OK! new one added, let's clear the rest of it.
-							bidsCom[bidsIndex].numberOffered = bidsCom[bidsIndex].numberAccepted
-							asksCom[asksIndex].offeredAsk.sellFor = (asksCom[asksIndex].offeredAsk.sellFor + bidsCom[bidsIndex].offeredBid.buyFor) / 2.0
-							bidsCom[bidsIndex].offeredBid.buyFor = asksCom[asksIndex].offeredAsk.sellFor
-							runningTotal += asksCom[asksIndex].offeredAsk.sellFor * float64(asksCom[asksIndex].numberAccepted)
-						}
-						//increase the indexes
-						bidsIndex++
-						asksIndex++
-						//fmt.Printf("AskIndex: %v , BidIndex: %v\n", asksIndex, bidsIndex)
-
-						//while both bids and asks have remaining individuals
-						if bidsIndex >= len(bidsCom) || asksIndex >= len(asksCom) {
-							break
-						}
-					}
-				}
-				if totalTransactions != 0 {
-					com.averagePrice = runningTotal / float64(totalTransactions)
-				} else {
-					fmt.Printf("No transactions of %v!\n", com.name)
-				}
-			}
+//report prints how many agents each role has, a chart of what each product has
+//cost and how wealth is spread, with a histogram of agents' net worth.
+func report(market *Market) {
+	//Output our live counts!
+	fmt.Println("\nAgent Count!")
+	for _, role := range market.roleOrder {
+		fmt.Println(role+"s: ", market.population[role])
+	}
+	for _, role := range []string{speculatorRole, marketMakerRole} {
+		if count := market.population[role]; count > 0 {
+			fmt.Println(role+"s: ", count)
+		}
+	}
 
-			//OK! Market Cleared.  Communicate results
-			fmt.Println("Market Cleared!")
-			for index, askChannel := range askChannels {
-				var asksOut []asks
-				//Search the results for matching results to send on the channel
-				for _, asksCom := range asksTyped {
-					for _, asksTest := range asksCom {
-						if asksTest.offeredAsk.id == uint64(index) {
-							asksOut = append(asksOut, *asksTest)
-						}
-					}
-				}
-				select {
-				case askChannel <- asksOut:
-					//fmt.Println("Sent a message!")
-				default:
-				}
-			}
-			fmt.Println("Done sending over askChannels")
-
-			for index, bidChannel := range bidChannels {
-				var bidsOut []bids
-				//Search the results for matching results to send on the channel
-				for _, bidsCom := range bidsTyped {
-					for _, bidsTest := range bidsCom {
-						if bidsTest.offeredBid.id == uint64(index) {
-							bidsOut = append(bidsOut, *bidsTest)
-						}
-					}
-				}
-				select {
-				case bidChannel <- bidsOut:
-					//fmt.Println("Sent a Bid Message")
-				default:
-				}
-			}
+	fmt.Println("\nPrices!")
+	for _, role := range market.roleOrder {
+		PrintPriceChart(market.products[role], reportChartWidth, reportChartHeight, os.Stdout)
+	}
 
-			//Check for Deads and Regen
-			for chindex, channel := range deadChannels {
-				var deadAgent traderAgent
-				select {
-				case deadAgent = <-channel:
-					fmt.Println("Got a dead on ", chindex)
-					switch deadAgent.role {
-					case "Farmer":
-						numFarmers--
-					case "Miner":
-						numMiners--
-					case "Refiner":
-						numRefiners--
-					case "Woodcutter":
-						numWoodcutters--
-					case "Blacksmith":
-						numBlacksmiths--
-					}
-
-					//Which Commodity is the most expensive?
-					maxCom := allCommodities["Food"]
-					for _, com := range allCommodities {
-						if com.averagePrice > maxCom.averagePrice {
-							maxCom = com
-						}
-					}
-
-					//Make that one!
-					switch maxCom.name {
-					case "Food":
-						askChannels[chindex], bidChannels[chindex], deadChannels[chindex] = agentRun(makeFarmer(allCommodities, &farmerProdSet))
-						numFarmers++
-					case "Ore":
-						askChannels[chindex], bidChannels[chindex], deadChannels[chindex] = agentRun(makeMiner(allCommodities, &minerProdSet))
-						numMiners++
-					case "Metal":
-						askChannels[chindex], bidChannels[chindex], deadChannels[chindex] = agentRun(makeRefiner(allCommodities, &refinerProdSet))
-						numRefiners++
-					case "Wood":
-						askChannels[chindex], bidChannels[chindex], deadChannels[chindex] = agentRun(makeWoodcutter(allCommodities, &woodcutterProdSet))
-						numWoodcutters++
-					case "Tools":
-						askChannels[chindex], bidChannels[chindex], deadChannels[chindex] = agentRun(makeBlacksmith(allCommodities, &blacksmithProdSet))
-						numBlacksmiths++
-					}
-
-				default:
-					//fmt.Println("No Deads on %v", chindex)
-				}
-			}
-			//Output our live counts!
-			fmt.Println("\nAgent Count!")
-			fmt.Println("Farmers: ", numFarmers)
-			fmt.Println("Miners: ", numMiners)
-			fmt.Println("Refiners: ", numRefiners)
-			fmt.Println("Woodcutters: ", numWoodcutters)
-			fmt.Println("Blacksmiths: ", numBlacksmiths)
-
-			fmt.Println("\nPrices!")
-			fmt.Println("Food: ", allCommodities["Food"].averagePrice)
-			fmt.Println("Ore: ", allCommodities["Ore"].averagePrice)
-			fmt.Println("Metal: ", allCommodities["Metal"].averagePrice)
-			fmt.Println("Wood: ", allCommodities["Wood"].averagePrice)
-			fmt.Println("Tools: ", allCommodities["Tools"].averagePrice)
+	fmt.Println("\nBottlenecks!")
+	bottlenecks := DetectBottleneck(market.simulation(), bottleneckWindow)
+	causes := BottleneckCauses(market.simulation(), bottleneckWindow)
+	for _, name := range market.commodityNames() {
+		if cause, ok := causes[name]; ok {
+			fmt.Println(name+": ", bottlenecks[name], "("+cause+")")
+		} else {
+			fmt.Println(name+": ", bottlenecks[name])
 		}
-	}()
+	}
+
+	fmt.Println("\nVolatility!")
+	for _, name := range market.commodityNames() {
+		_, deviation := meanStdDev(market.commodities[name].PriceHistory(market.config.PriceHistoryCapacity))
+		fmt.Println(name+": ", deviation)
+	}
 
-	//Block forever
-	select {}
+	fmt.Println("\nWealth!")
+	agents := market.snapshotAgents()
+	fmt.Println("Gini: ", GiniCoefficient(agents))
+	fmt.Println("Median: ", WealthPercentile(agents, 0.5))
+	fmt.Println("90th Percentile: ", WealthPercentile(agents, 0.9))
+	PrintHistogram(WealthHistogram(agents, reportHistogramBuckets), reportChartWidth, os.Stdout)
 }
 
-//This is the definition of the sort asks lowest to highest
+//This is the definition of the sort asks lowest to highest, market orders first,
+//which also makes a min-heap of asks (see container/heap)
 type AsksLowToHigh []*asks
 
-func (a AsksLowToHigh) Len() int           { return len(a) }
-func (a AsksLowToHigh) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a AsksLowToHigh) Less(i, j int) bool { return a[i].offeredAsk.sellFor < a[j].offeredAsk.sellFor }
+func (a AsksLowToHigh) Len() int      { return len(a) }
+func (a AsksLowToHigh) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a AsksLowToHigh) Less(i, j int) bool {
+	if a[i].offeredAsk.marketOrder != a[j].offeredAsk.marketOrder {
+		return a[i].offeredAsk.marketOrder
+	}
+	return a[i].offeredAsk.sellFor < a[j].offeredAsk.sellFor
+}
+func (a *AsksLowToHigh) Push(x interface{}) { *a = append(*a, x.(*asks)) }
+func (a *AsksLowToHigh) Pop() interface{} {
+	last := (*a)[len(*a)-1]
+	*a = (*a)[:len(*a)-1]
+	return last
+}
 
-//This is the definition of the sort bids from highest to lowest
+//This is the definition of the sort bids from highest to lowest, market orders
+//first, which also makes a max-heap of bids (see container/heap)
 type BidsHighToLow []*bids
 
-func (a BidsHighToLow) Len() int           { return len(a) }
-func (a BidsHighToLow) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a BidsHighToLow) Less(i, j int) bool { return a[i].offeredBid.buyFor > a[j].offeredBid.buyFor } //THIS MAY NOT WORK
-
-func makeFarmer(commodityList map[string]*commodity, prodSet *productionSet) traderAgent {
-	var farmerOut traderAgent
-	farmerOut.role = "Farmer"
-	farmerOut.funds = 50 + (rand.Float64() * 50)
-	farmerOut.inventory = make(map[*commodity]int)
-	if grantGoods {
-		farmerOut.inventory[commodityList["Tools"]] = rand.Intn(2)
-		farmerOut.inventory[commodityList["Wood"]] = rand.Intn(4) + 2
-	}
-	farmerOut.job = prodSet
-	farmerOut.priceBelief = randomPriceBelief(commodityList)
-	farmerOut.riskAversion = rand.Intn(4) + 1
-	return farmerOut
+func (a BidsHighToLow) Len() int      { return len(a) }
+func (a BidsHighToLow) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a BidsHighToLow) Less(i, j int) bool {
+	if a[i].offeredBid.marketOrder != a[j].offeredBid.marketOrder {
+		return a[i].offeredBid.marketOrder
+	}
+	return a[i].offeredBid.buyFor > a[j].offeredBid.buyFor //THIS MAY NOT WORK
+}
+func (a *BidsHighToLow) Push(x interface{}) { *a = append(*a, x.(*bids)) }
+func (a *BidsHighToLow) Pop() interface{} {
+	last := (*a)[len(*a)-1]
+	*a = (*a)[:len(*a)-1]
+	return last
+}
+
+//makeFarmer makes a Farmer, who starts out with a few tools and some wood.
+func makeFarmer(commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+	return makeAgent("Farmer", roleAgentConfigs["Farmer"], commodityList, prodSet, config)
+}
+
+//makeMiner makes a Miner, who starts out with a few tools and some food.
+func makeMiner(commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+	return makeAgent("Miner", roleAgentConfigs["Miner"], commodityList, prodSet, config)
 }
 
-func makeMiner(commodityList map[string]*commodity, prodSet *productionSet) traderAgent {
-	var minerOut traderAgent
-	minerOut.role = "Miner"
-	minerOut.funds = 50 + (rand.Float64() * 50)
-	minerOut.inventory = make(map[*commodity]int)
-	if grantGoods {
-		minerOut.inventory[commodityList["Tools"]] = rand.Intn(2)
-		minerOut.inventory[commodityList["Food"]] = rand.Intn(4) + 2
-	}
-	minerOut.job = prodSet
-	minerOut.priceBelief = randomPriceBelief(commodityList)
-	minerOut.riskAversion = rand.Intn(4) + 1
-	return minerOut
+//makeRefiner makes a Refiner, who starts out with some ore, food and a few tools.
+func makeRefiner(commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+	return makeAgent("Refiner", roleAgentConfigs["Refiner"], commodityList, prodSet, config)
 }
 
-func makeRefiner(commodityList map[string]*commodity, prodSet *productionSet) traderAgent {
-	var refinerOut traderAgent
-	refinerOut.role = "Refiner"
-	refinerOut.funds = 50 + (rand.Float64() * 50)
-	refinerOut.inventory = make(map[*commodity]int)
-	if grantGoods {
-		refinerOut.inventory[commodityList["Ore"]] = 2 + rand.Intn(3)
-		refinerOut.inventory[commodityList["Food"]] = rand.Intn(4) + 2
-		refinerOut.inventory[commodityList["Tools"]] = rand.Intn(2)
-	}
-	refinerOut.job = prodSet
-	refinerOut.priceBelief = randomPriceBelief(commodityList)
-	refinerOut.riskAversion = rand.Intn(4) + 1
-	return refinerOut
+//makeWoodcutter makes a Woodcutter, who starts out with a few tools and some food.
+func makeWoodcutter(commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+	return makeAgent("Woodcutter", roleAgentConfigs["Woodcutter"], commodityList, prodSet, config)
 }
 
-func makeWoodcutter(commodityList map[string]*commodity, prodSet *productionSet) traderAgent {
-	var woodcutterOut traderAgent
-	woodcutterOut.role = "Woodcutter"
-	woodcutterOut.funds = 50 + (rand.Float64() * 50)
-	woodcutterOut.inventory = make(map[*commodity]int)
-	if grantGoods {
-		woodcutterOut.inventory[commodityList["Tools"]] = rand.Intn(2)
-		woodcutterOut.inventory[commodityList["Food"]] = rand.Intn(4) + 2
-	}
-	woodcutterOut.job = prodSet
-	woodcutterOut.priceBelief = randomPriceBelief(commodityList)
-	woodcutterOut.riskAversion = rand.Intn(4) + 1
-	return woodcutterOut
+//makeBlacksmith makes a Blacksmith, who starts out with some metal and food.
+func makeBlacksmith(commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+	return makeAgent("Blacksmith", roleAgentConfigs["Blacksmith"], commodityList, prodSet, config)
 }
 
-func makeBlacksmith(commodityList map[string]*commodity, prodSet *productionSet) traderAgent {
-	var blacksmithOut traderAgent
-	blacksmithOut.role = "Blacksmith"
-	blacksmithOut.funds = 50 + (rand.Float64() * 50)
-	blacksmithOut.inventory = make(map[*commodity]int)
-	if grantGoods {
-		blacksmithOut.inventory[commodityList["Metal"]] = 2 + rand.Intn(3)
-		blacksmithOut.inventory[commodityList["Food"]] = rand.Intn(4) + 2
-	}
-	blacksmithOut.job = prodSet
-	blacksmithOut.priceBelief = randomPriceBelief(commodityList)
-	blacksmithOut.riskAversion = rand.Intn(4) + 1
-	return blacksmithOut
+//makeTrader makes an agent of any role, starting with nothing but its funds.
+func makeTrader(role string, commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+	return makeAgent(role, defaultAgentConfig, commodityList, prodSet, config)
 }
 
 //Set up our agent system/world state in here.
 func init() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	fmt.Printf("Number of CPUS: %d\n", runtime.NumCPU())
-	rand.Seed(time.Now().UTC().UnixNano())
 	//Flags!
 	grantGoods = true
+	anomalyThreshold = 3.0
 }
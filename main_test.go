@@ -0,0 +1,78 @@
+// GoEconGo project main_test.go
+package main
+
+import "testing"
+
+//A deathRecorder remembers every agent that died before resurrecting it as next
+//would.
+type deathRecorder struct {
+	dead []traderAgent
+	next ResurrectionStrategy
+}
+
+func (r *deathRecorder) Resurrect(dead traderAgent, sim *Simulation) traderAgent {
+	r.dead = append(r.dead, dead)
+	return r.next.Resurrect(dead, sim)
+}
+
+//recordDeaths has the market a config sets up remember every agent that dies.
+func recordDeaths(config *SimulationConfig) *deathRecorder {
+	recorder := &deathRecorder{next: MostExpensiveCommodityStrategy{}}
+	config.Resurrection = recorder
+	return recorder
+}
+
+//onlyRole strips an economy down to one role.
+func onlyRole(economy *SimConfig, role string) {
+	var roles []RoleSpec
+	for _, spec := range economy.Roles {
+		if spec.Name == role {
+			roles = append(roles, spec)
+		}
+	}
+	economy.Roles = roles
+}
+
+func TestMaxConsecutivePenalties(t *testing.T) {
+	for _, funds := range []float64{100, 1000000} {
+		config := testConfig()
+		recorder := recordDeaths(&config)
+		config.MaxConsecutivePenalties = 5
+		//A farmer with no wood, and nobody to buy any from.
+		config.InitHooks["Farmer"] = []AgentInitHook{func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+			agent.funds = funds
+			agent.inventory = make(map[*commodity]int)
+		}}
+		economy := testSimConfig(t, 1)
+		onlyRole(economy, "Farmer")
+		sim := simulateEconomy(t, config, economy)
+		for tick := 0; tick < 10 && len(recorder.dead) == 0; tick++ {
+			RunTicks(1, sim)
+		}
+		if len(recorder.dead) == 0 {
+			t.Fatalf("starting with %v, the farmer never went out of business", funds)
+		}
+		dead := recorder.dead[0]
+		if !dead.outOfBusiness {
+			t.Errorf("starting with %v, the farmer died with %v but not out of business", funds, dead.funds)
+		}
+		if rounds := dead.ProductionPenalties / dead.job.penalty; rounds != 5 {
+			t.Errorf("starting with %v, the farmer died after %v penalty rounds, want 5", funds, rounds)
+		}
+	}
+}
+
+func TestMaxConsecutivePenaltiesOffByDefault(t *testing.T) {
+	config := testConfig()
+	recorder := recordDeaths(&config)
+	config.InitHooks["Farmer"] = []AgentInitHook{func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+		agent.funds = 1000000
+		agent.inventory = make(map[*commodity]int)
+	}}
+	economy := testSimConfig(t, 1)
+	onlyRole(economy, "Farmer")
+	RunTicks(30, simulateEconomy(t, config, economy))
+	if len(recorder.dead) > 0 {
+		t.Errorf("a rich idle farmer died after %v penalties", recorder.dead[0].consecutivePenalties)
+	}
+}
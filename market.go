@@ -0,0 +1,677 @@
+// GoEconGo project market.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+//How much weight a tick's trading gets in a commodity's averagePrice, unless
+//configured otherwise.
+const defaultPriceSmoothing = 0.3
+
+//How many ticks of price history the market keeps for each commodity, unless
+//configured otherwise.
+const priceHistoryLength = 100
+
+//How many previous price changes are used as the rolling window when looking for
+//price anomalies.
+const anomalyWindow = 20
+
+//How many ticks of spreads the market statistics measure.
+const spreadWindow = 20
+
+//A MarketEvent is anything notable that the market reports during a tick.
+type MarketEvent interface {
+	String() string
+}
+
+//A Market is the central exchange.  It keeps track of the commodities being
+//traded, the agents trading them, the current tick, the recent price history of
+//every commodity and the events raised during the current tick.
+//config - the SimulationConfig the market runs under
+//commodities - a map of commodity names to commodity pointers
+//agents - the running agents, by slot: the index of their channels
+//slots - the slot of every running agent, by its id
+//askChannels, bidChannels, deadChannels - every agent's channels, by index
+//roles - a factory for a fresh agent of each role
+//roleOrder - every role, in the order it was added
+//products - the commodity each role makes
+//jobs - each role's productionSet, for agents switching roles
+//population - how many agents of each role are alive
+//countedAs - the role each agent is counted under in population
+//populationHistory - population at the end of each recent tick
+//metrics - the measurements a metrics server exposes (see StartMetricsServer)
+//barter - the market for swapping goods directly
+//futures - the market for goods delivered later
+//asksTyped - this tick's ask book, by commodity: a heap (see AsksLowToHigh) until
+//it clears, then every ask in the order it came off the heap, followed by the rest
+//bidsTyped - this tick's bid book, by commodity: a heap (see BidsHighToLow) until
+//it clears, then every bid in the order it came off the heap, followed by the rest
+//tick - the number of ticks the market has run
+//pinnedTicks - how many consecutive ticks each commodity has sat on a price limit
+//monopolists - the agent controlling each monopolized commodity's supply
+//monopolyTicks - how many consecutive ticks that agent has held its monopoly
+//highPriceTicks - how many consecutive ticks each commodity's price has run high
+//statistics - the measurements taken at the end of the last tick
+//cohorts - what is remembered of each cohort, by the tick it was spawned on
+//synchronous - whether each tick waits for every agent (see RunTicks), rather than
+//trading with whoever is ready
+//collected - the agents whose offers were taken this tick, by slot
+//held - offers taken ahead of the tick that trades them, by slot (see holdOffers)
+//offers - brings in the asks of a market ticking in real time (see
+//collectFannedIn)
+//ctx - cancelled when the market shuts down, stopping its agents
+//cancel - cancels ctx
+//stops - cancels each agent's own context, by slot, stopping just that agent (see
+//stop)
+//running - counts the agent goroutines still running
+//FeePool - every transaction fee the market has taken (see chargeFee)
+//PricingRule - how the price of a matched ask and bid is settled (see matchPrice),
+//starting from the configured PricingRule
+//centralBank - the bank managing the money supply (nil is none)
+//sim - the Simulation running the market (see simulation)
+//events - the events raised during the current tick
+//listeners - functions called with every event as it is raised
+//tickHooks - functions called with the market at the end of every tick
+//shutdownHooks - functions called when the market shuts down
+//CancelChannel - where agents send their ids to cancel their open orders (see
+//cancelOrders)
+//cancelled - the ids of the agents whose orders have been cancelled this tick
+//Ledger - every movement of money in the market, if KeepLedger is set (nil
+//otherwise)
+//valueRing, moneyRing - what traded in each recent tick, and the money supply at
+//the end of it (see VelocityOfMoney)
+type Market struct {
+	config            SimulationConfig
+	commodities       map[string]*commodity
+	agents            map[uint64]*traderAgent
+	slots             map[uint64]uint64
+	askChannels       []chan []asks
+	bidChannels       []chan []bids
+	deadChannels      []chan traderAgent
+	roles             map[string]func() traderAgent
+	roleOrder         []string
+	products          map[string]*commodity
+	jobs              map[string]*productionSet
+	population        map[string]int
+	countedAs         map[uint64]string
+	populationHistory *PopulationHistory
+	metrics           *SimulationMetrics
+	barter            *BarterMarket
+	futures           *FuturesMarket
+	asksTyped         map[*commodity]AsksLowToHigh
+	bidsTyped         map[*commodity]BidsHighToLow
+	tick              int
+	pinnedTicks       map[*commodity]int
+	monopolists       map[*commodity]uint64
+	monopolyTicks     map[*commodity]int
+	highPriceTicks    map[*commodity]int
+	statistics        MarketStatistics
+	cohorts           map[uint32]*cohortRecord
+	synchronous       bool
+	collected         map[uint64]*traderAgent
+	held              map[uint64]heldOffers
+	offers            *fanIn[[]asks]
+	ctx               context.Context
+	cancel            context.CancelFunc
+	stops             map[uint64]context.CancelFunc
+	running           sync.WaitGroup
+	FeePool           float64
+	PricingRule       ClearingPriceRule
+	centralBank       *centralBankAgent
+	sim               *Simulation
+	events            []MarketEvent
+	listeners         []func(MarketEvent)
+	tickHooks         []func(*Market)
+	shutdownHooks     []func()
+	CancelChannel     chan uint64
+	cancelled         map[uint64]bool
+	Ledger            *Ledger
+	valueRing         historyRing[float64]
+	moneyRing         historyRing[float64]
+}
+
+//newMarket builds an empty market trading the given commodities.
+func newMarket(commodityList map[string]*commodity, config SimulationConfig) *Market {
+	m := new(Market)
+	m.config = config
+	m.config.rng = newRandom(config.Seed)
+	m.PricingRule = config.PricingRule
+	//The market opens role slots and adds hooks as it runs - leave the caller's as
+	//they were.
+	m.config.RoleSlots = make(map[string]int, len(config.RoleSlots))
+	for role, slots := range config.RoleSlots {
+		m.config.RoleSlots[role] = slots
+	}
+	m.config.InitHooks = make(map[string][]AgentInitHook, len(config.InitHooks))
+	for role, hooks := range config.InitHooks {
+		m.config.InitHooks[role] = append([]AgentInitHook(nil), hooks...)
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.stops = make(map[uint64]context.CancelFunc)
+	m.commodities = commodityList
+	m.agents = make(map[uint64]*traderAgent)
+	m.slots = make(map[uint64]uint64)
+	m.roles = make(map[string]func() traderAgent)
+	m.products = make(map[string]*commodity)
+	m.jobs = make(map[string]*productionSet)
+	m.barter = newBarterMarket(m)
+	m.futures = newFuturesMarket(m)
+	m.population = make(map[string]int)
+	m.countedAs = make(map[uint64]string)
+	m.populationHistory = newPopulationHistory(config.PriceHistoryCapacity)
+	m.valueRing.init(config.PriceHistoryCapacity)
+	m.moneyRing.init(config.PriceHistoryCapacity)
+	m.metrics = newSimulationMetrics()
+	m.pinnedTicks = make(map[*commodity]int)
+	m.monopolists = make(map[*commodity]uint64)
+	m.monopolyTicks = make(map[*commodity]int)
+	m.highPriceTicks = make(map[*commodity]int)
+	m.statistics.Commodities = make(map[string]CommodityStats)
+	m.cohorts = make(map[uint32]*cohortRecord)
+	m.CancelChannel = make(chan uint64, cancelBuffer)
+	m.cancelled = make(map[uint64]bool)
+	if config.KeepLedger {
+		m.Ledger = newLedger()
+	}
+	for _, com := range commodityList {
+		com.priceRing.init(config.PriceHistoryCapacity)
+		com.volumeRing.init(config.PriceHistoryCapacity)
+		com.spreadRing.init(config.PriceHistoryCapacity)
+		com.bidRing.init(config.PriceHistoryCapacity)
+		com.unfilledRing.init(config.PriceHistoryCapacity)
+		com.askRing.init(config.PriceHistoryCapacity)
+		com.beliefRing.init(config.PriceHistoryCapacity)
+	}
+	//Make the ask and bid books
+	//Break them by type
+	m.asksTyped = make(map[*commodity]AsksLowToHigh)
+	m.bidsTyped = make(map[*commodity]BidsHighToLow)
+	for _, com := range commodityList {
+		m.asksTyped[com] = nil
+		m.bidsTyped[com] = nil
+	}
+	return m
+}
+
+//launch starts an agent running and registers it with the market.  An agent
+//without utility weights of its own is given its role's, and one without an id is
+//given a new one.
+//slot - the index of the agent's channels
+func (m *Market) launch(slot uint64, agent traderAgent) (chan []asks, chan []bids, chan traderAgent) {
+	running := &agent
+	running.config = &m.config
+	if running.id == 0 {
+		running.id = newAgentID()
+	}
+	running.rng = newRandom(m.config.random().Int63())
+	running.ledger = m.Ledger
+	m.Ledger.open(running, m.tick)
+	if running.startingFunds == 0 {
+		running.startingFunds = running.funds
+	}
+	m.joinCohort(running)
+	if running.UtilityWeights == nil {
+		running.UtilityWeights = make(map[*commodity]float64)
+		for name, weight := range m.config.UtilityWeights[running.role] {
+			if com, ok := m.commodities[name]; ok {
+				running.UtilityWeights[com] = weight
+			}
+		}
+	}
+	m.agents[slot] = running
+	m.slots[running.id] = slot
+	m.metrics.countBirth()
+	//The agent that ran in the slot before is gone, so let go of its context.
+	if stop, ok := m.stops[slot]; ok {
+		stop()
+	}
+	ctx, stop := context.WithCancel(m.ctx)
+	m.stops[slot] = stop
+	askChannel, bidChannel, deadChannel := agentRun(ctx, running, m.jobs, m.CancelChannel, &m.running)
+	if m.offers != nil {
+		m.offers.add(int(slot), askChannel)
+	}
+	return askChannel, bidChannel, deadChannel
+}
+
+//simulation returns the Simulation running the market, wrapping it in one if
+//nothing has.
+func (m *Market) simulation() *Simulation {
+	if m.sim == nil {
+		return newSimulation(m)
+	}
+	return m.sim
+}
+
+//Shutdown stops every agent and waits up to the configured ShutdownTimeout for
+//their goroutines to exit.  The market does not tick again afterwards, and anything
+//registered with OnShutdown is run.
+//Returns an error if any agent was still running when the time ran out.
+func (m *Market) Shutdown() error {
+	m.cancel()
+	for _, hook := range m.shutdownHooks {
+		hook()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-time.After(m.config.ShutdownTimeout):
+		return errors.New("timed out waiting for agents to stop")
+	}
+}
+
+//agentSlots returns the slot of every agent in the market, lowest first, for
+//walking the agents in the same order every time.
+func (m *Market) agentSlots() []uint64 {
+	slots := make([]uint64, 0, len(m.agents))
+	for slot := range m.agents {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots
+}
+
+//idAt returns the id of the agent in a slot (0 is an empty slot, as no agent has
+//id 0).
+func (m *Market) idAt(slot uint64) uint64 {
+	if agent, ok := m.agents[slot]; ok {
+		return agent.id
+	}
+	return 0
+}
+
+//agentByID returns the running agent with an id, and its slot.
+func (m *Market) agentByID(id uint64) (*traderAgent, uint64, bool) {
+	slot, ok := m.slots[id]
+	if !ok {
+		return nil, 0, false
+	}
+	return m.agents[slot], slot, true
+}
+
+//commodityNames returns the name of every commodity the market trades, in
+//alphabetical order.
+func (m *Market) commodityNames() []string {
+	names := make([]string, 0, len(m.commodities))
+	for name := range m.commodities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//priceSmoothing returns the weight a tick's trading of a commodity gets in its
+//averagePrice: the commodity's own PriceSmoothing, or else the market's.
+func (m *Market) priceSmoothing(com *commodity) float64 {
+	if com.PriceSmoothing > 0 && com.PriceSmoothing <= 1 {
+		return com.PriceSmoothing
+	}
+	if m.config.PriceSmoothing > 0 && m.config.PriceSmoothing <= 1 {
+		return m.config.PriceSmoothing
+	}
+	return defaultPriceSmoothing
+}
+
+//sortedCommodities returns every commodity the market trades, in the order of
+//commodityNames.
+func (m *Market) sortedCommodities() []*commodity {
+	names := m.commodityNames()
+	coms := make([]*commodity, len(names))
+	for i, name := range names {
+		coms[i] = m.commodities[name]
+	}
+	return coms
+}
+
+//addRole teaches the market how to make a fresh agent of a role.
+//role - the name of the role
+//product - a pointer to the commodity the role makes
+//factory - makes a fresh agent of the role
+func (m *Market) addRole(role string, product *commodity, job *productionSet, factory func() traderAgent) {
+	if _, ok := m.roles[role]; !ok {
+		m.roleOrder = append(m.roleOrder, role)
+	}
+	m.roles[role] = factory
+	m.products[role] = product
+	m.jobs[role] = job
+}
+
+//spawn launches a fresh agent of a role on a new set of channels and returns its
+//id.
+func (m *Market) spawn(role string) uint64 {
+	return m.add(m.roles[role]())
+}
+
+//add launches an agent on a new set of channels and returns its id.
+func (m *Market) add(agent traderAgent) uint64 {
+	slot := uint64(len(m.askChannels))
+	askChannel, bidChannel, deadChannel := m.launch(slot, agent)
+	m.askChannels = append(m.askChannels, askChannel)
+	m.bidChannels = append(m.bidChannels, bidChannel)
+	m.deadChannels = append(m.deadChannels, deadChannel)
+	m.population[agent.role]++
+	m.countedAs[slot] = agent.role
+	return m.agents[slot].id
+}
+
+//respawn launches a fresh agent of a role on the channels of a dead agent.
+func (m *Market) respawn(slot uint64, role string) {
+	m.respawnAgent(slot, m.roles[role]())
+}
+
+//respawnAgent launches an agent on the channels of a dead agent.
+func (m *Market) respawnAgent(slot uint64, agent traderAgent) {
+	m.askChannels[slot], m.bidChannels[slot], m.deadChannels[slot] = m.launch(slot, agent)
+	m.population[agent.role]++
+	m.countedAs[slot] = agent.role
+}
+
+//deregister forgets about the dead agent in a slot.
+func (m *Market) deregister(slot uint64) {
+	if agent, ok := m.agents[slot]; ok {
+		m.population[m.countedAs[slot]]--
+		m.leaveCohort(agent)
+		m.futures.cancel(agent.id)
+		delete(m.slots, agent.id)
+	}
+	delete(m.agents, slot)
+	delete(m.countedAs, slot)
+}
+
+//Subscribe registers a function to be called with every event the market raises.
+func (m *Market) Subscribe(fn func(MarketEvent)) {
+	m.listeners = append(m.listeners, fn)
+}
+
+//OnTick registers a function to be called with the market at the end of every
+//tick, once everything in it is settled.
+func (m *Market) OnTick(fn func(*Market)) {
+	m.tickHooks = append(m.tickHooks, fn)
+}
+
+//OnShutdown registers a function to be called when the market shuts down, once it
+//has stopped ticking.
+func (m *Market) OnShutdown(fn func()) {
+	m.shutdownHooks = append(m.shutdownHooks, fn)
+}
+
+//Emit records an event for the current tick and hands it to every listener.
+func (m *Market) Emit(event MarketEvent) {
+	m.events = append(m.events, event)
+	for _, fn := range m.listeners {
+		fn(event)
+	}
+}
+
+//Events returns the events raised during the current tick.
+func (m *Market) Events() []MarketEvent {
+	return m.events
+}
+
+//beginTick advances the tick counter, clears out the last tick's events and
+//surpluses, refills the common pools ahead of production and tells every agent
+//the new tick, and the span it runs in.
+func (m *Market) beginTick(ctx context.Context) {
+	m.tick++
+	m.events = nil
+	m.metrics.resetTick()
+	for name, com := range m.commodities {
+		com.regeneratePool()
+		stats := m.statistics.Commodities[name]
+		stats.ProducerSurplus = 0
+		stats.ConsumerSurplus = 0
+		m.statistics.Commodities[name] = stats
+	}
+	for _, agent := range m.agents {
+		agent.mu.Lock()
+		agent.tick = m.tick
+		agent.tickSpan = ctx
+		agent.mu.Unlock()
+	}
+}
+
+//recordPrices records the closing average price of every commodity in its price
+//history.
+func (m *Market) recordPrices() {
+	for _, com := range m.commodities {
+		com.priceRing.push(com.averagePrice)
+	}
+}
+
+//recordVolume records the number of units of a commodity traded this tick in its
+//volume history.
+func (m *Market) recordVolume(com *commodity, volume int) {
+	com.TickVolume = volume
+	com.volumeRing.push(volume)
+}
+
+//averageVolume returns the mean number of units of a commodity traded per tick
+//over its volume history.
+func (m *Market) averageVolume(com *commodity) float64 {
+	history := com.volumeRing.all()
+	if len(history) == 0 {
+		return 0
+	}
+	return float64(com.TotalVolume()) / float64(len(history))
+}
+
+//marketImpact returns the extra price per unit an order pays for moving the
+//market: price * MarketImpactCoeff * (quantity / averageVolume).  Orders of no more
+//than LargeOrderThreshold units, and commodities that have never traded, have no
+//impact.
+//com - a pointer to the commodity being traded
+//price - the price per unit the order matched at
+//quantity - the number of units in the order
+func (m *Market) marketImpact(com *commodity, price float64, quantity int) float64 {
+	averageVolume := m.averageVolume(com)
+	if quantity <= m.config.LargeOrderThreshold || averageVolume == 0 {
+		return 0
+	}
+	return price * com.MarketImpactCoeff * (float64(quantity) / averageVolume)
+}
+
+//applyMarketImpact charges a matched ask and bid for their market impact once
+//they have been given their match price.  A large buyer pays more per unit and a
+//large seller receives less, which comes straight out of their funds when they
+//settle up in agentUpdate.
+//askSize - the number of units the ask had on offer when it was matched
+//bidSize - the number of units the bid had on offer when it was matched
+func (m *Market) applyMarketImpact(com *commodity, askSet *asks, bidSet *bids, askSize int, bidSize int) {
+	askSet.offeredAsk.sellFor = math.Max(0, askSet.offeredAsk.sellFor-m.marketImpact(com, askSet.offeredAsk.sellFor, askSize))
+	bidSet.offeredBid.buyFor = bidSet.offeredBid.buyFor + m.marketImpact(com, bidSet.offeredBid.buyFor, bidSize)
+}
+
+//matchPrice settles the price a matched ask and bid trade at under the market's
+//PricingRule.  Random trades draw from the market's own source of randomness, so
+//seeded runs repeat.
+//askPrice - what the seller asked
+//bidPrice - what the buyer bid
+func (m *Market) matchPrice(askPrice, bidPrice float64) float64 {
+	if m.PricingRule == WeightedMidpoint {
+		return bidPrice + m.config.MidpointWeight*(askPrice-bidPrice)
+	}
+	return clearingPrice(askPrice, bidPrice, m.PricingRule, m.config.random())
+}
+
+//clearingPrice settles the price a matched ask and bid trade at under a rule.
+//Trading at the ask hands the whole surplus to the buyer, trading at the bid
+//hands it to the seller, and the midpoint splits it between them.  Random draws
+//from rng, and WeightedMidpoint, which has no weight here, trades at the
+//midpoint.
+//askPrice - what the seller asked
+//bidPrice - what the buyer bid
+//rule - where between the two the price is settled
+//rng - the source of randomness for the Random rule
+func clearingPrice(askPrice, bidPrice float64, rule ClearingPriceRule, rng *randomSource) float64 {
+	switch rule {
+	case AskPrice:
+		return askPrice
+	case BidPrice:
+		return bidPrice
+	case Random:
+		if rng.Intn(2) == 0 {
+			return askPrice
+		}
+		return bidPrice
+	default:
+		return (askPrice + bidPrice) / 2.0
+	}
+}
+
+//enforcePriceLimits clamps the average price of every commodity to its PriceFloor
+//and PriceCeiling.
+func (m *Market) enforcePriceLimits() {
+	for _, com := range m.commodities {
+		if com.PriceFloor > 0 && com.averagePrice < com.PriceFloor {
+			com.averagePrice = com.PriceFloor
+		}
+		if com.PriceCeiling > 0 && com.averagePrice > com.PriceCeiling {
+			com.averagePrice = com.PriceCeiling
+		}
+	}
+}
+
+//A PriceAnomaly is an unusual price movement of a commodity.
+//CommodityName - the name of the commodity that moved
+//Tick - the tick the movement happened on
+//ZScore - how many standard deviations the move was from the rolling mean move
+type PriceAnomaly struct {
+	CommodityName string
+	Tick          int
+	ZScore        float64
+}
+
+//A PriceAnomalyEvent is raised for every anomaly DetectPriceAnomalies finds.
+type PriceAnomalyEvent struct {
+	PriceAnomaly
+}
+
+func (e PriceAnomalyEvent) String() string {
+	return fmt.Sprintf("Price anomaly on %v at tick %v! Z-Score: %.2f", e.CommodityName, e.Tick, e.ZScore)
+}
+
+//DetectPriceAnomalies compares this tick's price change of each commodity against
+//the mean and standard deviation of the previous anomalyWindow changes in its
+//price history.  Every commodity whose Z-Score is beyond zThreshold is returned
+//and raised as a PriceAnomalyEvent, in order of name.  A commodity whose price
+//changed by the same amount every tick of the window (sat flat, most often, with
+//nothing traded) is an anomaly as soon as it changes by any other amount, with a
+//Z-Score of +Inf or -Inf.
+//zThreshold - the absolute Z-Score above which a price change is an anomaly
+func (m *Market) DetectPriceAnomalies(zThreshold float64) []PriceAnomaly {
+	var anomalies []PriceAnomaly
+	for _, com := range m.sortedCommodities() {
+		history := com.PriceHistory(anomalyWindow + 2)
+		//We need the current change and at least two before it to say anything.
+		if len(history) < 4 {
+			continue
+		}
+		changes := make([]float64, len(history)-1)
+		for i := 1; i < len(history); i++ {
+			changes[i-1] = history[i] - history[i-1]
+		}
+		current := changes[len(changes)-1]
+		window := changes[:len(changes)-1]
+		if len(window) > anomalyWindow {
+			window = window[len(window)-anomalyWindow:]
+		}
+		mean, stdDev := meanStdDev(window)
+		var zScore float64
+		switch {
+		case stdDev != 0:
+			zScore = (current - mean) / stdDev
+		//Against a perfectly flat window any other change is infinitely unusual.
+		case current > mean:
+			zScore = math.Inf(1)
+		case current < mean:
+			zScore = math.Inf(-1)
+		}
+		if math.Abs(zScore) > zThreshold {
+			anomaly := PriceAnomaly{CommodityName: com.name, Tick: m.tick, ZScore: zScore}
+			anomalies = append(anomalies, anomaly)
+			m.Emit(PriceAnomalyEvent{anomaly})
+		}
+	}
+	return anomalies
+}
+
+//meanStdDev returns the mean and population standard deviation of values.
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+//A RecalibrationEvent is raised whenever the market resets agents' beliefs of a
+//commodity stuck on one of its price limits.
+type RecalibrationEvent struct {
+	CommodityName string
+	Tick          int
+	Price         float64
+}
+
+func (e RecalibrationEvent) String() string {
+	return fmt.Sprintf("Recalibrated beliefs on %v at tick %v around %v", e.CommodityName, e.Tick, e.Price)
+}
+
+//checkRecalibration counts how long each commodity has been pinned to its
+//PriceFloor or PriceCeiling, and recalibrates the beliefs of any that have been
+//stuck there for more than RecalibrationTriggerTicks.
+func (m *Market) checkRecalibration() {
+	if m.config.RecalibrationTriggerTicks <= 0 {
+		return
+	}
+	//Recalibrating draws on the seeded random source, so go in the same order every
+	//time.
+	for _, com := range m.sortedCommodities() {
+		pinned := (com.PriceFloor > 0 && com.averagePrice == com.PriceFloor) ||
+			(com.PriceCeiling > 0 && com.averagePrice == com.PriceCeiling)
+		if !pinned {
+			m.pinnedTicks[com] = 0
+			continue
+		}
+		m.pinnedTicks[com]++
+		if m.pinnedTicks[com] > m.config.RecalibrationTriggerTicks {
+			m.RecalibrateBeliefs(com)
+			m.pinnedTicks[com] = 0
+		}
+	}
+}
+
+//RecalibrateBeliefs throws away every agent's belief of a commodity's price and
+//replaces it with a random range centered on the commodity's current (clamped)
+//average price.
+//com - the commodity to recalibrate
+func (m *Market) RecalibrateBeliefs(com *commodity) {
+	price := com.averagePrice
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		width := m.config.random().Float64() * price
+		agent.mu.Lock()
+		agent.priceBelief[com] = priceRange{low: price - width/2, high: price + width/2}
+		agent.mu.Unlock()
+	}
+	m.Emit(RecalibrationEvent{CommodityName: com.name, Tick: m.tick, Price: price})
+}
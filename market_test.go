@@ -0,0 +1,331 @@
+// GoEconGo project market_test.go
+package main
+
+import (
+	"container/heap"
+	"math"
+	"testing"
+)
+
+//newAnomalyMarket returns a market trading Food and Wood, each with anomalyWindow+1
+//ticks of prices wobbling about 3 behind it.
+func newAnomalyMarket() (*Market, *commodity, *commodity) {
+	food := &commodity{name: "Food", averagePrice: 3}
+	wood := &commodity{name: "Wood", averagePrice: 3}
+	m := newMarket(map[string]*commodity{"Food": food, "Wood": wood}, testConfig())
+	for i := 0; i <= anomalyWindow; i++ {
+		wobble := 0.1 * math.Sin(float64(i))
+		food.priceRing.push(3 + wobble)
+		wood.priceRing.push(3 - wobble)
+	}
+	return m, food, wood
+}
+
+func TestDetectPriceAnomaliesSteadyState(t *testing.T) {
+	m, food, wood := newAnomalyMarket()
+	for i := anomalyWindow + 1; i < 3*anomalyWindow; i++ {
+		wobble := 0.1 * math.Sin(float64(i))
+		food.priceRing.push(3 + wobble)
+		wood.priceRing.push(3 - wobble)
+		if anomalies := m.DetectPriceAnomalies(anomalyThreshold); len(anomalies) > 0 {
+			t.Fatalf("tick %v: normal variation flagged as %v", i, anomalies)
+		}
+	}
+}
+
+func TestDetectPriceAnomaliesDemandShock(t *testing.T) {
+	sim := newTestSimulation(t, testConfig(), 10)
+	RunTicks(40, sim)
+	anomalies := recordEvents[PriceAnomalyEvent](sim.Market)
+	//Ten times the demand for food sends its price up tenfold.
+	if err := InjectPriceShock(sim, "Food", 900); err != nil {
+		t.Fatal(err)
+	}
+	RunTicks(1, sim)
+	var food *PriceAnomalyEvent
+	for i := range *anomalies {
+		if (*anomalies)[i].CommodityName == "Food" {
+			food = &(*anomalies)[i]
+		}
+	}
+	if food == nil {
+		t.Fatalf("got anomalies %v, want one on Food", *anomalies)
+	}
+	if food.ZScore <= 3 {
+		t.Errorf("Z-Score of a tenfold jump is %v, want above 3", food.ZScore)
+	}
+}
+
+func TestDetectPriceAnomaliesFlatWindow(t *testing.T) {
+	food := &commodity{name: "Food", averagePrice: 3}
+	m := newMarket(map[string]*commodity{"Food": food}, testConfig())
+	for i := 0; i <= anomalyWindow; i++ {
+		food.priceRing.push(3)
+	}
+	if anomalies := m.DetectPriceAnomalies(anomalyThreshold); len(anomalies) > 0 {
+		t.Errorf("flat prices gave anomalies %v", anomalies)
+	}
+	food.priceRing.push(30)
+	anomalies := m.DetectPriceAnomalies(anomalyThreshold)
+	if len(anomalies) != 1 || !math.IsInf(anomalies[0].ZScore, 1) {
+		t.Errorf("a tenfold jump from flat prices gave anomalies %v, want Food at +Inf", anomalies)
+	}
+}
+
+func TestDetectPriceAnomaliesOrder(t *testing.T) {
+	m, food, wood := newAnomalyMarket()
+	food.priceRing.push(30)
+	wood.priceRing.push(30)
+	for i := 0; i < 10; i++ {
+		anomalies := m.DetectPriceAnomalies(anomalyThreshold)
+		if len(anomalies) != 2 || anomalies[0].CommodityName != "Food" || anomalies[1].CommodityName != "Wood" {
+			t.Fatalf("got anomalies %v, want Food then Wood", anomalies)
+		}
+	}
+}
+
+func TestClearingPrice(t *testing.T) {
+	tests := []struct {
+		rule ClearingPriceRule
+		want float64
+	}{
+		{Midpoint, 3},
+		{AskPrice, 2},
+		{BidPrice, 4},
+		{WeightedMidpoint, 3},
+	}
+	for _, test := range tests {
+		if got := clearingPrice(2, 4, test.rule, newRandom(1)); got != test.want {
+			t.Errorf("rule %v: got %v, want %v", test.rule, got, test.want)
+		}
+	}
+	rng := newRandom(1)
+	for i := 0; i < 10; i++ {
+		if got := clearingPrice(2, 4, Random, rng); got != 2 && got != 4 {
+			t.Fatalf("Random cleared at %v, want the ask or the bid", got)
+		}
+	}
+}
+
+//newBookMarket returns a market trading just food, configured by config, with the
+//given offers on its books.
+func newBookMarket(config SimulationConfig, food *commodity, offeredAsks []*asks, offeredBids []*bids) *Market {
+	m := newMarket(map[string]*commodity{"Food": food}, config)
+	asksBook, bidsBook := m.asksTyped[food], m.bidsTyped[food]
+	for _, askSet := range offeredAsks {
+		heap.Push(&asksBook, askSet)
+	}
+	for _, bidSet := range offeredBids {
+		heap.Push(&bidsBook, bidSet)
+	}
+	m.asksTyped[food], m.bidsTyped[food] = asksBook, bidsBook
+	return m
+}
+
+//clearRents clears one ask for 5 units of food at 2 against one bid for 5 at 4
+//under a rule, and returns the producer and consumer surplus of the trade.
+func clearRents(rule ClearingPriceRule) (float64, float64) {
+	food := &commodity{name: "Food", averagePrice: 3}
+	m := newBookMarket(testConfig(), food,
+		[]*asks{{offeredAsk: ask{id: 1, item: food, quantity: 5, sellFor: 2}, numberOffered: 5}},
+		[]*bids{{offeredBid: bid{id: 2, item: food, quantity: 5, buyFor: 4}, numberOffered: 5}})
+	m.PricingRule = rule
+	m.clear(food)
+	stats := m.statistics.Commodities["Food"]
+	return stats.ProducerSurplus, stats.ConsumerSurplus
+}
+
+func TestClearingPriceRuleRents(t *testing.T) {
+	//Of the 10 the trade makes, the side whose price it clears at gets none.
+	tests := []struct {
+		rule     ClearingPriceRule
+		producer float64
+		consumer float64
+	}{
+		{Midpoint, 5, 5},
+		{AskPrice, 0, 10},
+		{BidPrice, 10, 0},
+	}
+	for _, test := range tests {
+		producer, consumer := clearRents(test.rule)
+		if producer != test.producer || consumer != test.consumer {
+			t.Errorf("rule %v: producer surplus %v and consumer surplus %v, want %v and %v", test.rule, producer, consumer, test.producer, test.consumer)
+		}
+	}
+	producer, consumer := clearRents(Random)
+	if producer+consumer != 10 || (producer != 0 && consumer != 0) {
+		t.Errorf("Random gave producer surplus %v and consumer surplus %v, want all 10 to one side", producer, consumer)
+	}
+	//The same seed settles the same way every time.
+	for i := 0; i < 10; i++ {
+		if again, _ := clearRents(Random); again != producer {
+			t.Fatalf("Random gave producer surplus %v, then %v under the same seed", producer, again)
+		}
+	}
+}
+
+func TestPricingRuleShiftsWelfare(t *testing.T) {
+	//settle clears the trade of clearRents under a rule, and returns what the buyer
+	//paid for its 5 units and the surplus of each side.
+	settle := func(rule ClearingPriceRule, weight float64) (paid, producer, consumer float64) {
+		food := &commodity{name: "Food", averagePrice: 3}
+		config := testConfig()
+		config.PricingRule = rule
+		config.MidpointWeight = weight
+		m := newBookMarket(config, food,
+			[]*asks{{offeredAsk: ask{id: 1, item: food, quantity: 5, sellFor: 2}, numberOffered: 5}},
+			[]*bids{{offeredBid: bid{id: 2, item: food, quantity: 5, buyFor: 4}, numberOffered: 5}})
+		m.clear(food)
+		stats := m.statistics.Commodities["Food"]
+		return 5 * m.bidsTyped[food][0].offeredBid.buyFor, stats.ProducerSurplus, stats.ConsumerSurplus
+	}
+	midPaid, midProducer, midConsumer := settle(Midpoint, 0)
+	askPaid, askProducer, askConsumer := settle(AskPrice, 0)
+	bidPaid, bidProducer, bidConsumer := settle(BidPrice, 0)
+	//Buyers pay the ask, the lower of the two prices, so they pay less and keep more
+	//of the surplus than at the midpoint; paying the bid is the other way about.
+	if askPaid >= midPaid || askProducer >= midProducer || askConsumer <= midConsumer {
+		t.Errorf("at the ask the buyer paid %v for surpluses of %v and %v, at the midpoint %v for %v and %v",
+			askPaid, askProducer, askConsumer, midPaid, midProducer, midConsumer)
+	}
+	if bidPaid <= midPaid || bidProducer <= midProducer || bidConsumer >= midConsumer {
+		t.Errorf("at the bid the buyer paid %v for surpluses of %v and %v, at the midpoint %v for %v and %v",
+			bidPaid, bidProducer, bidConsumer, midPaid, midProducer, midConsumer)
+	}
+	//Weighting the midpoint towards the ask moves the price, and the surplus, by
+	//the same steps in between.
+	previous := bidPaid
+	for _, weight := range []float64{0.25, 0.5, 0.75, 1} {
+		paid, producer, consumer := settle(WeightedMidpoint, weight)
+		if paid >= previous {
+			t.Errorf("weight %v: the buyer paid %v, want less than the %v of a lighter weight", weight, paid, previous)
+		}
+		if producer+consumer != 10 {
+			t.Errorf("weight %v: surpluses of %v and %v, want 10 between them", weight, producer, consumer)
+		}
+		previous = paid
+	}
+	if previous != askPaid {
+		t.Errorf("a weight of 1 paid %v, want the %v paid at the ask", previous, askPaid)
+	}
+	for _, welfare := range []float64{midProducer + midConsumer, askProducer + askConsumer, bidProducer + bidConsumer} {
+		if welfare != 10 {
+			t.Errorf("the trade made %v in all, want 10 whatever the rule", welfare)
+		}
+	}
+}
+
+func TestRecalibrateBeliefsAtFloor(t *testing.T) {
+	config := testConfig()
+	//Refiners think ore is worth next to nothing, and there are no miners to sell
+	//them any, so its price sits on the floor.
+	config.InitHooks["Refiner"] = []AgentInitHook{func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+		agent.priceBelief[commodities["Ore"]] = priceRange{low: 0.2, high: 0.5}
+	}}
+	economy := testSimConfig(t, 2)
+	setCohort(economy, "Miner", 0)
+	ore := economy.CommodityList()["Ore"]
+	ore.PriceFloor = 1
+	ore.averagePrice = 0.5
+	sim := simulateEconomy(t, config, economy)
+	m := sim.Market
+	recalibrations := recordEvents[RecalibrationEvent](m)
+	RunTicks(config.RecalibrationTriggerTicks+1, sim)
+	if len(*recalibrations) == 0 || (*recalibrations)[0].CommodityName != "Ore" {
+		t.Fatalf("ore pinned to its floor for %v ticks wasn't recalibrated", m.tick)
+	}
+	if ore.averagePrice != ore.PriceFloor {
+		t.Fatalf("ore's price moved off its floor to %v", ore.averagePrice)
+	}
+	RunTicks(1, sim)
+	above := 0
+	for _, bidSet := range m.bidsTyped[ore] {
+		if bidSet.offeredBid.buyFor >= ore.PriceFloor {
+			above++
+		}
+	}
+	if above == 0 {
+		t.Errorf("all %v ore bids are still below the floor after recalibrating", len(m.bidsTyped[ore]))
+	}
+}
+
+//marketOrderCost clears a market order for some units of food against an ask for
+//200 at 2, where 10 units trade in a usual tick, and returns what the order paid
+//per unit.
+func marketOrderCost(impact float64, units int) float64 {
+	food := &commodity{name: "Food", averagePrice: 2, MarketImpactCoeff: impact}
+	m := newBookMarket(testConfig(), food,
+		[]*asks{{offeredAsk: ask{id: 1, item: food, quantity: 200, sellFor: 2}, numberOffered: 200}},
+		[]*bids{{offeredBid: bid{id: 2, item: food, quantity: units, marketOrder: true}, numberOffered: units}})
+	m.recordVolume(food, 10)
+	m.clear(food)
+	return m.bidsTyped[food][0].offeredBid.buyFor
+}
+
+func TestMarketImpact(t *testing.T) {
+	small, large := marketOrderCost(0.1, 1), marketOrderCost(0.1, 100)
+	if small != 2 {
+		t.Errorf("1 unit cost %v, want the ask of 2", small)
+	}
+	if large <= small {
+		t.Errorf("100 units cost %v each, want more than the %v of 1 unit", large, small)
+	}
+	if got, want := large, 2*(1+0.1*100/10.0); math.Abs(got-want) > 1e-9 {
+		t.Errorf("100 units cost %v each, want %v", got, want)
+	}
+	for _, units := range []int{1, 100} {
+		if got := marketOrderCost(0, units); got != 2 {
+			t.Errorf("with no impact, %v units cost %v each, want the ask of 2", units, got)
+		}
+	}
+}
+
+//controlledFill clears a book of 5 unit offers at the given prices under price
+//controls, and returns how many units asked for and bid for went unfilled.
+func controlledFill(floor, ceiling float64, askPrices, bidPrices []float64) (int, int) {
+	food := &commodity{name: "Food", averagePrice: 3, PriceFloor: floor, PriceCeiling: ceiling}
+	var offeredAsks []*asks
+	var offeredBids []*bids
+	for i, price := range askPrices {
+		offeredAsks = append(offeredAsks, &asks{offeredAsk: ask{id: uint64(i + 1), item: food, quantity: 5, sellFor: price}, numberOffered: 5})
+	}
+	for i, price := range bidPrices {
+		offeredBids = append(offeredBids, &bids{offeredBid: bid{id: uint64(len(askPrices) + i + 1), item: food, quantity: 5, buyFor: price}, numberOffered: 5})
+	}
+	m := newBookMarket(testConfig(), food, offeredAsks, offeredBids)
+	m.clear(food)
+	unsold, unbought := 0, 0
+	for _, askSet := range m.asksTyped[food] {
+		unsold += askSet.numberOffered - askSet.numberAccepted
+	}
+	for _, bidSet := range m.bidsTyped[food] {
+		unbought += bidSet.numberOffered - bidSet.numberAccepted
+	}
+	return unsold, unbought
+}
+
+func TestPriceControls(t *testing.T) {
+	//A ceiling under what buyers would pay leaves them short.
+	if _, unbought := controlledFill(0, 0, []float64{3}, []float64{5, 2}); unbought != 5 {
+		t.Errorf("without a ceiling %v units went unbought, want 5", unbought)
+	}
+	if _, unbought := controlledFill(0, 4, []float64{3}, []float64{5, 2}); unbought != 10 {
+		t.Errorf("under a ceiling of 4 %v units went unbought, want a shortage of all 10", unbought)
+	}
+	//A floor over what sellers would take leaves them with a surplus.
+	if unsold, _ := controlledFill(0, 0, []float64{1, 4}, []float64{3}); unsold != 5 {
+		t.Errorf("without a floor %v units went unsold, want 5", unsold)
+	}
+	if unsold, _ := controlledFill(2, 0, []float64{1, 4}, []float64{3}); unsold != 10 {
+		t.Errorf("over a floor of 2 %v units went unsold, want a surplus of all 10", unsold)
+	}
+
+	food := &commodity{name: "Food", averagePrice: 1, PriceFloor: 2, PriceCeiling: 4}
+	wood := &commodity{name: "Wood", averagePrice: 9, PriceFloor: 2, PriceCeiling: 4}
+	ore := &commodity{name: "Ore", averagePrice: 9}
+	m := newMarket(map[string]*commodity{"Food": food, "Wood": wood, "Ore": ore}, testConfig())
+	m.enforcePriceLimits()
+	if food.averagePrice != 2 || wood.averagePrice != 4 || ore.averagePrice != 9 {
+		t.Errorf("limits held food, wood and ore at %v, %v and %v, want 2, 4 and 9", food.averagePrice, wood.averagePrice, ore.averagePrice)
+	}
+}
@@ -0,0 +1,118 @@
+// GoEconGo project marketmaker.go
+package main
+
+//The role market makers go by.
+const marketMakerRole = "MarketMaker"
+
+//The spread market makers quote, and how many units they quote on each side,
+//unless the SimulationConfig says otherwise.
+const (
+	defaultMarketMakerSpread    = 0.1
+	defaultMarketMakerQuoteSize = 5
+)
+
+//makeMarketMaker makes an agent that never produces - it has no job - but quotes
+//both sides of every commodity around its averagePrice, living off the spread.
+//It starts holding twice its quote size of everything, which is the inventory it
+//tries to keep.
+//targetSpreadPct - the spread it quotes, as a fraction of the price (0.1 asks 5%
+//over and bids 5% under)
+func makeMarketMaker(commodityList map[string]*commodity, targetSpreadPct float64, config SimulationConfig) traderAgent {
+	var makerOut traderAgent
+	makerOut.role = marketMakerRole
+	makerOut.funds = 50 + (config.random().Float64() * 50)
+	makerOut.inventory = make(map[*commodity]int)
+	makerOut.priceBelief = initialPriceBelief(commodityList, config.BeliefInitSpread)
+	makerOut.riskAversion = config.random().Intn(4) + 1
+	makerOut.beliefAdjustBig, makerOut.beliefAdjustSmall = config.beliefAdjustRates(makerOut.role)
+	makerOut.maxInventory = config.maxInventory(makerOut.role)
+	makerOut.maxAge = config.lifespan()
+	makerOut.targetSpreadPct = targetSpreadPct
+	makerOut.quoteSize = config.MarketMakerQuoteSize
+	makerOut.inventoryTarget = make(map[*commodity]int)
+	for _, com := range commodityList {
+		if speculates(com) {
+			makerOut.inventory[com] = 2 * makerOut.quoteSize
+			makerOut.inventoryTarget[com] = 2 * makerOut.quoteSize
+		}
+	}
+	runInitHooks(&makerOut, commodityList, config)
+	return makerOut
+}
+
+//addMarketMakers launches n market makers on new channels.  Like speculators,
+//they are not a role the market refills.
+func (m *Market) addMarketMakers(n int, targetSpreadPct float64) {
+	for i := 0; i < n; i++ {
+		m.add(makeMarketMaker(m.commodities, targetSpreadPct, m.config))
+	}
+}
+
+//rebalanceQuotes works out how far a market maker should lean its quotes on each
+//commodity to steer its inventory back to target: from -1 (it holds nothing, so
+//it quotes higher to buy more and sell less) to 1 (it holds twice its target, so
+//it quotes lower).  Called after every update.
+func rebalanceQuotes(agent *traderAgent) {
+	if agent.quoteSkew == nil {
+		agent.quoteSkew = make(map[*commodity]float64)
+	}
+	for com, target := range agent.inventoryTarget {
+		if target <= 0 {
+			continue
+		}
+		skew := float64(agent.inventory[com]-target) / float64(target)
+		if skew > 1 {
+			skew = 1
+		}
+		if skew < -1 {
+			skew = -1
+		}
+		agent.quoteSkew[com] = skew
+	}
+}
+
+//generateQuotes has a market maker ask over and bid under every commodity's
+//averagePrice by half its spread, both leaned by its quoteSkew.  It asks no more
+//than it holds and bids no more than its funds cover.
+func generateQuotes(agent *traderAgent) ([]asks, []bids) {
+	var askSlice []asks
+	var bidSlice []bids
+	funds := agent.funds
+	half := agent.targetSpreadPct / 2
+	for _, com := range sortedBeliefs(agent) {
+		if _, ok := agent.inventoryTarget[com]; !ok || com.averagePrice <= 0 {
+			continue
+		}
+		lean := agent.quoteSkew[com] * half
+		if size := minInt(agent.quoteSize, agent.inventory[com]); size > 0 {
+			var askBuild asks
+			askBuild.numberOffered = size
+			askBuild.offeredAsk.quantity = 1
+			askBuild.offeredAsk.item = com
+			askBuild.offeredAsk.sellFor = com.averagePrice * (1 + half - lean)
+			askSlice = append(askSlice, askBuild)
+		}
+		price := com.averagePrice * (1 - half - lean)
+		if price <= 0 {
+			continue
+		}
+		if size := minInt(agent.quoteSize, int(funds/price)); size > 0 {
+			funds = funds - price*float64(size)
+			var bidBuild bids
+			bidBuild.numberOffered = size
+			bidBuild.offeredBid.quantity = 1
+			bidBuild.offeredBid.item = com
+			bidBuild.offeredBid.buyFor = price
+			bidSlice = append(bidSlice, bidBuild)
+		}
+	}
+	return askSlice, bidSlice
+}
+
+//minInt returns the smaller of two ints.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
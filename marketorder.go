@@ -0,0 +1,26 @@
+// GoEconGo project marketorder.go
+package main
+
+//urgent reports whether an agent is close enough to death to sell with market
+//orders: worth less than MarketOrderMargin above the DeathThreshold.  The agent
+//must be locked, or not running.
+func urgent(agent *traderAgent) bool {
+	margin := agent.settings().MarketOrderMargin
+	return margin > 0 && NetWorth(agent) < agent.settings().DeathThreshold+margin
+}
+
+//executionPrice settles the price a matched ask and bid trade at.  A market order
+//takes the price of the limit order it meets, and two market orders meeting trade
+//at the commodity's averagePrice; limit orders meeting trade under the
+//PricingRule (see matchPrice).
+func (m *Market) executionPrice(com *commodity, askSet *asks, bidSet *bids) float64 {
+	switch {
+	case askSet.offeredAsk.marketOrder && bidSet.offeredBid.marketOrder:
+		return com.averagePrice
+	case askSet.offeredAsk.marketOrder:
+		return bidSet.offeredBid.buyFor
+	case bidSet.offeredBid.marketOrder:
+		return askSet.offeredAsk.sellFor
+	}
+	return m.matchPrice(askSet.offeredAsk.sellFor, bidSet.offeredBid.buyFor)
+}
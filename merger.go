@@ -0,0 +1,110 @@
+// GoEconGo project merger.go
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+//stop halts a running agent's goroutine and waits for it to exit, so that nothing
+//it does afterwards can touch the market.  Its channels are dropped so the market
+//no longer trades with it or resurrects it.  Stopping a stopped agent does nothing.
+//slot - the agent's channels
+func (m *Market) stop(slot uint64) {
+	deadChannel := m.deadChannels[slot]
+	if deadChannel == nil {
+		return
+	}
+	m.askChannels[slot], m.bidChannels[slot], m.deadChannels[slot] = nil, nil, nil
+	delete(m.held, slot)
+	if m.offers != nil {
+		m.offers.remove(int(slot))
+	}
+	m.stops[slot]()
+	//It either reports its death, or hangs up without one.
+	<-deadChannel
+}
+
+//retire takes a running agent off the market for good.  It is stopped and left
+//with nothing, and it pays nothing into the Ledger.
+//slot - the agent's channels
+func (m *Market) retire(slot uint64) {
+	agent := m.agents[slot]
+	m.stop(slot)
+	m.deregister(slot)
+
+	agent.mu.Lock()
+	m.Ledger.Record(agent.id, 0, agent.funds, m.tick, ledgerRetired)
+	agent.funds = 0
+	agent.ledger = nil
+	agent.inventory = make(map[*commodity]int)
+	agent.mu.Unlock()
+}
+
+//MergeAgents combines two running agents into one new agent, which takes the
+//first agent's role and job.  The merged agent has:
+//funds - the sum of both agents' funds
+//inventory - both inventories added together
+//priceBelief - the geometric mean of both agents' beliefs of each commodity
+//riskAversion - the larger of the two
+//lifetimeAskVolume, lifetimeBidVolume - the sum of both agents'
+//beliefAdjustBig, beliefAdjustSmall, maxInventory, age, maxAge - the first agent's
+//Both old agents are retired and the merged agent is launched on new channels.
+//id1, id2 - the ids of the agents to merge
+//Returns the merged agent's id.
+func (m *Market) MergeAgents(id1, id2 uint64) (uint64, error) {
+	if id1 == id2 {
+		return 0, errors.New("cannot merge an agent with itself")
+	}
+	first, slot1, ok := m.agentByID(id1)
+	if !ok {
+		return 0, errors.New("no running agent to merge with that first id")
+	}
+	second, slot2, ok := m.agentByID(id2)
+	if !ok {
+		return 0, errors.New("no running agent to merge with that second id")
+	}
+	//Neither may produce or trade while they are being combined.
+	m.stop(slot1)
+	m.stop(slot2)
+
+	first.mu.Lock()
+	second.mu.Lock()
+	var merged traderAgent
+	merged.id = newAgentID()
+	merged.role = first.role
+	merged.job = first.job
+	merged.funds = first.funds + second.funds
+	merged.beliefAdjustBig, merged.beliefAdjustSmall = first.beliefAdjustBig, first.beliefAdjustSmall
+	merged.maxInventory = first.maxInventory
+	merged.age, merged.maxAge = first.age, first.maxAge
+	merged.riskAversion = first.riskAversion
+	if second.riskAversion > merged.riskAversion {
+		merged.riskAversion = second.riskAversion
+	}
+	merged.lifetimeAskVolume = first.lifetimeAskVolume + second.lifetimeAskVolume
+	merged.lifetimeBidVolume = first.lifetimeBidVolume + second.lifetimeBidVolume
+	merged.inventory = cQMapConcat(cQMapConcat(make(map[*commodity]int), first.inventory), second.inventory)
+	merged.permits = cQMapConcat(cQMapConcat(make(map[*commodity]int), first.permits), second.permits)
+	merged.priceBelief = make(map[*commodity]priceRange)
+	for com, pr := range first.priceBelief {
+		merged.priceBelief[com] = pr
+	}
+	for com, pr := range second.priceBelief {
+		if mine, ok := merged.priceBelief[com]; ok {
+			pr = priceRange{low: math.Sqrt(mine.low * pr.low), high: math.Sqrt(mine.high * pr.high)}
+		}
+		merged.priceBelief[com] = pr
+	}
+	//Both agents pay everything they hold to the merged agent, so there is nothing
+	//left for them to retire with.
+	m.Ledger.Record(first.id, merged.id, first.funds, m.tick, ledgerMerger)
+	m.Ledger.Record(second.id, merged.id, second.funds, m.tick, ledgerMerger)
+	first.funds, second.funds = 0, 0
+	second.mu.Unlock()
+	first.mu.Unlock()
+
+	m.retire(slot1)
+	m.retire(slot2)
+	return m.add(merged), nil
+}
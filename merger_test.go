@@ -0,0 +1,61 @@
+// GoEconGo project merger_test.go
+package main
+
+import "testing"
+
+func TestMergeAgents(t *testing.T) {
+	sim := newTestSimulation(t, testConfig(), 2)
+	RunTicks(1, sim)
+	m := sim.Market
+	miners := agentsOf(m, "Miner")
+	first, second := miners[0], miners[1]
+	_, slot1, _ := m.agentByID(first.id)
+	_, slot2, _ := m.agentByID(second.id)
+	//Stop them first, so that neither produces again before they are merged.
+	m.stop(slot1)
+	m.stop(slot2)
+	first.mu.Lock()
+	second.mu.Lock()
+	funds := first.funds + second.funds
+	inventory := cQMapConcat(cQMapConcat(make(map[*commodity]int), first.inventory), second.inventory)
+	second.mu.Unlock()
+	first.mu.Unlock()
+
+	id, err := m.MergeAgents(first.id, second.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, _, ok := m.agentByID(id)
+	if !ok {
+		t.Fatalf("merged agent %v isn't running", id)
+	}
+	merged.mu.Lock()
+	if merged.funds != funds {
+		t.Errorf("merged agent holds %v, want the %v both held", merged.funds, funds)
+	}
+	for com, num := range inventory {
+		if merged.inventory[com] != num {
+			t.Errorf("merged agent holds %v %v, want the %v both held", merged.inventory[com], com.name, num)
+		}
+	}
+	merged.mu.Unlock()
+	for _, parent := range []uint64{first.id, second.id} {
+		if _, ok := m.slots[parent]; ok {
+			t.Errorf("agent %v still has a slot after merging", parent)
+		}
+		for slot, agent := range m.agents {
+			if agent.id == parent {
+				t.Errorf("agent %v still runs in slot %v after merging", parent, slot)
+			}
+		}
+	}
+	for _, slot := range []uint64{slot1, slot2} {
+		if m.askChannels[slot] != nil || m.bidChannels[slot] != nil || m.deadChannels[slot] != nil {
+			t.Errorf("slot %v still has channels after merging", slot)
+		}
+	}
+	if _, err := m.MergeAgents(first.id, id); err == nil {
+		t.Errorf("merged a retired agent")
+	}
+	RunTicks(1, sim)
+}
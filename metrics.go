@@ -0,0 +1,266 @@
+// GoEconGo project metrics.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//How many points of the Lorenz curve the metrics keep.
+const lorenzPoints = 101
+
+//SimulationMetrics are the measurements a metrics server exposes, taken at the
+//end of every tick so that a scrape never has to touch the market itself.
+//mu - guards everything below, which the market writes as a scraper reads
+//prices - each commodity's averagePrice, by name
+//volumes - how many units of each commodity traded in the last tick, by name
+//population - how many agents of each role are alive, by role
+//gini - the GiniCoefficient of every agent's funds
+//turnover - the mean InventoryTurnover of each role's agents, by role
+//lorenz - the LorenzCurve of every agent's net worth, thinned out to lorenzPoints
+//points
+//transactionValue - what everything traded in the last tick was worth
+//velocity - the VelocityOfMoney over the last velocityWindow ticks
+//tickValue - what has traded so far this tick
+//tickTrades - how many trades cleared in the last tick
+//pendingTrades - how many trades have cleared so far this tick
+//circuitBreaks - how many circuit breakers tripped in the last tick
+//trades - every trade cleared so far
+//deaths - every agent that has died so far
+//births - every agent launched so far, the starting population included
+//tickInterval - how long the market waits between real-time ticks (nil is not
+//ticking in real time)
+type SimulationMetrics struct {
+	mu               sync.RWMutex
+	prices           map[string]float64
+	volumes          map[string]int
+	population       map[string]int
+	gini             float64
+	turnover         map[string]float64
+	lorenz           [][2]float64
+	transactionValue float64
+	velocity         float64
+	tickValue        float64
+	tickTrades       int
+	pendingTrades    int
+	circuitBreaks    int
+	trades           uint64
+	deaths           uint64
+	births           uint64
+	tickInterval     func() time.Duration
+}
+
+//newSimulationMetrics builds an empty set of metrics.
+func newSimulationMetrics() *SimulationMetrics {
+	metrics := new(SimulationMetrics)
+	metrics.prices = make(map[string]float64)
+	metrics.volumes = make(map[string]int)
+	metrics.population = make(map[string]int)
+	metrics.turnover = make(map[string]float64)
+	return metrics
+}
+
+//countTrades adds a commodity's cleared trades, and what they were worth, to the
+//tick's totals.
+//trades - how many trades cleared
+//value - what they were worth between them
+func (s *SimulationMetrics) countTrades(trades int, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trades += uint64(trades)
+	s.pendingTrades += trades
+	s.tickValue += value
+}
+
+//countDeath counts an agent dying.
+func (s *SimulationMetrics) countDeath() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deaths++
+}
+
+//setTickInterval has the metrics report how long the market waits between ticks.
+func (s *SimulationMetrics) setTickInterval(interval func() time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickInterval = interval
+}
+
+//countBirth counts an agent being launched.
+func (s *SimulationMetrics) countBirth() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.births++
+}
+
+//tradedValue returns what has traded so far this tick.
+func (s *SimulationMetrics) tradedValue() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tickValue
+}
+
+//resetTick starts a new tick's totals.
+func (s *SimulationMetrics) resetTick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickValue = 0
+	s.pendingTrades = 0
+}
+
+//update takes the end of tick measurements of a market.
+func (s *SimulationMetrics) update(m *Market) {
+	agents := m.snapshotAgents()
+	gini := GiniCoefficient(agents)
+	lorenz := sampleCurve(LorenzCurve(agents), lorenzPoints)
+	turnover := m.roleTurnover(turnoverWindow)
+	velocity := m.velocityOfMoney(velocityWindow)
+	breaks := 0
+	for _, event := range m.events {
+		if _, ok := event.(CircuitBreakEvent); ok {
+			breaks++
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, com := range m.commodities {
+		s.prices[name] = com.averagePrice
+		s.volumes[name] = com.TickVolume
+	}
+	s.population = make(map[string]int, len(m.population))
+	for role, count := range m.population {
+		s.population[role] = count
+	}
+	s.gini = gini
+	s.turnover = turnover
+	s.lorenz = lorenz
+	s.transactionValue = s.tickValue
+	s.velocity = velocity
+	s.tickTrades = s.pendingTrades
+	s.circuitBreaks = breaks
+}
+
+//lastTick returns the Gini coefficient and the number of trades cleared at the
+//last tick.
+func (s *SimulationMetrics) lastTick() (float64, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.gini, s.tickTrades
+}
+
+//sortedKeys returns a map's keys in alphabetical order.
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//writeMetric writes a metric's HELP and TYPE lines.
+func writeMetric(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+//Expose writes the metrics out in the Prometheus text exposition format.
+func (s *SimulationMetrics) Expose(w io.Writer) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	writeMetric(w, "goecongo_commodity_price", "gauge", "Average price of each commodity.")
+	for _, name := range sortedKeys(s.prices) {
+		fmt.Fprintf(w, "goecongo_commodity_price{commodity=%q} %v\n", name, s.prices[name])
+	}
+	writeMetric(w, "goecongo_commodity_volume", "gauge", "Units of each commodity traded in the last tick.")
+	for _, name := range sortedKeys(s.volumes) {
+		fmt.Fprintf(w, "goecongo_commodity_volume{commodity=%q} %v\n", name, s.volumes[name])
+	}
+	writeMetric(w, "goecongo_role_population", "gauge", "Agents of each role alive.")
+	for _, role := range sortedKeys(s.population) {
+		fmt.Fprintf(w, "goecongo_role_population{role=%q} %v\n", role, s.population[role])
+	}
+	writeMetric(w, "goecongo_role_inventory_turnover", "gauge", "Mean inventory turnover of each role's agents.")
+	for _, role := range sortedKeys(s.turnover) {
+		fmt.Fprintf(w, "goecongo_role_inventory_turnover{role=%q} %v\n", role, s.turnover[role])
+	}
+	writeMetric(w, "goecongo_gini", "gauge", "Gini coefficient of agents' funds.")
+	fmt.Fprintf(w, "goecongo_gini %v\n", s.gini)
+	writeMetric(w, "goecongo_tick_transaction_value", "gauge", "Value of everything traded in the last tick.")
+	fmt.Fprintf(w, "goecongo_tick_transaction_value %v\n", s.transactionValue)
+	writeMetric(w, "goecongo_velocity_of_money", "gauge", "Value traded over the money supply, over recent ticks.")
+	fmt.Fprintf(w, "goecongo_velocity_of_money %v\n", s.velocity)
+	writeMetric(w, "goecongo_circuit_breaks", "gauge", "Circuit breakers tripped in the last tick.")
+	fmt.Fprintf(w, "goecongo_circuit_breaks %v\n", s.circuitBreaks)
+	writeMetric(w, "goecongo_trades_total", "counter", "Trades cleared.")
+	fmt.Fprintf(w, "goecongo_trades_total %v\n", s.trades)
+	writeMetric(w, "goecongo_deaths_total", "counter", "Agents that have died.")
+	fmt.Fprintf(w, "goecongo_deaths_total %v\n", s.deaths)
+	writeMetric(w, "goecongo_births_total", "counter", "Agents launched.")
+	fmt.Fprintf(w, "goecongo_births_total %v\n", s.births)
+	if s.tickInterval != nil {
+		writeMetric(w, "goecongo_tick_interval_seconds", "gauge", "Time between real-time ticks.")
+		fmt.Fprintf(w, "goecongo_tick_interval_seconds %v\n", s.tickInterval().Seconds())
+	}
+}
+
+//ServeHTTP answers a scrape with the latest metrics, as JSON (see ExposeJSON) if
+//the scraper accepts it, and otherwise in the Prometheus text format.
+func (s *SimulationMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		s.ExposeJSON(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.Expose(w)
+}
+
+//ExposeJSON writes the latest metrics as a JSON object, along with the Lorenz
+//curve of agents' net worth as [population share, wealth share] points.
+//Returns any error writing to w.
+func (s *SimulationMetrics) ExposeJSON(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"prices":           s.prices,
+		"volumes":          s.volumes,
+		"population":       s.population,
+		"gini":             s.gini,
+		"turnover":         s.turnover,
+		"lorenz":           s.lorenz,
+		"transactionValue": s.transactionValue,
+		"velocity":         s.velocity,
+		"circuitBreaks":    s.circuitBreaks,
+		"trades":           s.trades,
+		"deaths":           s.deaths,
+		"births":           s.births,
+	})
+}
+
+//Metrics returns the market's metrics, as a metrics server exposes them.
+func (m *Market) Metrics() *SimulationMetrics {
+	return m.metrics
+}
+
+//StartMetricsServer serves a simulation's metrics at /metrics, in the Prometheus
+//text format or as JSON (see ServeHTTP), in the background.  Shut the returned server down to stop it.
+//Returns an error if addr can't be listened on.
+//addr - the address to listen on, as in net.Listen ("localhost:9090")
+func StartMetricsServer(addr string, sim *Simulation) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", sim.Market.Metrics())
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	return server, nil
+}
@@ -0,0 +1,69 @@
+// GoEconGo project opportunity.go
+package main
+
+//uses reports whether a production method needs a commodity, as an input or a
+//catalyst.
+func uses(method *productionMethod, com *commodity) bool {
+	for _, sets := range [][]commoditySet{method.inputs, method.catalysts} {
+		for _, set := range sets {
+			if set.item == com {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//bestAlternativeReturn works out the most an agent expects to make per unit of
+//cash put into any of its production methods that don't need a commodity: each
+//method's getAverageProductionValue over what its inputs, and the catalysts it
+//expects to use up, cost at the agent's price beliefs.  Methods that cost nothing
+//to run, or would lose money, aren't an alternative use of cash.
+//Returns 0 if there is no alternative.
+func bestAlternativeReturn(agent *traderAgent, com *commodity) float64 {
+	best := 0.0
+	for index, method := range agent.job.methods {
+		if uses(method, com) {
+			continue
+		}
+		cost := 0.0
+		for _, input := range method.inputs {
+			cost = cost + float64(input.quantity)*agent.utilityWeight(input.item)*beliefMidpoint(agent, input.item)
+		}
+		for i, catalyst := range method.catalysts {
+			cost = cost + float64(catalyst.quantity)*method.consumption[i]*
+				agent.utilityWeight(catalyst.item)*beliefMidpoint(agent, catalyst.item)
+		}
+		if cost <= 0 {
+			continue
+		}
+		if perCash := getAverageProductionValue(agent, index) / cost; perCash > best {
+			best = perCash
+		}
+	}
+	return best
+}
+
+//beliefMidpoint returns the middle of an agent's price belief for a commodity.
+func beliefMidpoint(agent *traderAgent, com *commodity) float64 {
+	return (agent.priceBelief[com].high + agent.priceBelief[com].low) / 2
+}
+
+//opportunityCostAdjustedBid works out what an agent bids for a unit of a
+//commodity.  The bid starts where the agent's utility weights put it in its price
+//belief (see utilityWeightedBid - the middle, for an agent without any); with
+//OpportunityCostBids on, whatever that cash would have made in the agent's best
+//other production method (see bestAlternativeReturn) is taken off, as spending it
+//here means forgoing that.  Bids never go below the low end of the agent's price
+//belief.
+func opportunityCostAdjustedBid(agent *traderAgent, com *commodity) float64 {
+	bid := utilityWeightedBid(agent, com)
+	if !agent.settings().OpportunityCostBids {
+		return bid
+	}
+	bid = bid - bid*bestAlternativeReturn(agent, com)
+	if low := agent.priceBelief[com].low; bid < low {
+		return low
+	}
+	return bid
+}
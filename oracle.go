@@ -0,0 +1,72 @@
+// GoEconGo project oracle.go
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+//A PriceOracle gives out reference prices from outside the market.  Agents that
+//subscribe to it update their beliefs towards its prices instead of the
+//market's averages.
+type PriceOracle interface {
+	//QueryPrice returns the reference price of a commodity at a tick, and whether
+	//the oracle has one.
+	QueryPrice(commodityName string, tick int) (float64, bool)
+}
+
+//A StaticOracle quotes the same price for a commodity at every tick.
+//Prices - the price of each commodity, by name
+type StaticOracle struct {
+	Prices map[string]float64
+}
+
+//QueryPrice returns the commodity's fixed price.
+func (o StaticOracle) QueryPrice(commodityName string, tick int) (float64, bool) {
+	price, ok := o.Prices[commodityName]
+	return price, ok
+}
+
+//A JSONFileOracle replays prices recorded in a JSON file.  The file maps each
+//commodity name to its list of prices, the first being tick 1's, e.g.
+//{"Food": [3.0, 3.1, 2.9]}
+//prices - the recorded prices of each commodity, by name
+type JSONFileOracle struct {
+	prices map[string][]float64
+}
+
+//newJSONFileOracle reads the recorded prices out of a JSON file.
+func newJSONFileOracle(path string) (*JSONFileOracle, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	o := new(JSONFileOracle)
+	if err := json.Unmarshal(data, &o.prices); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+//QueryPrice returns the price recorded for the commodity at the tick, or
+//(0, false) if none was recorded.
+func (o *JSONFileOracle) QueryPrice(commodityName string, tick int) (float64, bool) {
+	recorded := o.prices[commodityName]
+	if tick < 1 || tick > len(recorded) {
+		return 0, false
+	}
+	return recorded[tick-1], true
+}
+
+//referencePrice returns the price an agent updates its belief of a commodity
+//towards - the oracle's, if it subscribes and the oracle has one, and the market
+//average otherwise.
+func referencePrice(agent *traderAgent, com *commodity) float64 {
+	oracle := agent.settings().Oracle
+	if agent.OracleSubscriber && oracle != nil {
+		if price, ok := oracle.QueryPrice(com.name, agent.tick); ok {
+			return price
+		}
+	}
+	return com.averagePrice
+}
@@ -0,0 +1,76 @@
+// GoEconGo project oracle_test.go
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestOracleSubscribersConverge(t *testing.T) {
+	config := testConfig()
+	config.Oracle = StaticOracle{Prices: map[string]float64{"Food": 6}}
+	//Every other farmer follows the oracle, which reckons food is worth twice what
+	//the market starts out thinking.
+	farmers := 0
+	config.InitHooks["Farmer"] = []AgentInitHook{func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+		farmers++
+		agent.OracleSubscriber = farmers%2 == 0
+	}}
+	sim := newTestSimulation(t, config, 10)
+	food := sim.Market.commodities["Food"]
+	RunTicks(20, sim)
+	var subscribers, others []float64
+	for _, agent := range agentsOf(sim.Market, "Farmer") {
+		agent.mu.Lock()
+		off := math.Abs(beliefMidpoint(agent, food) - 6)
+		if agent.OracleSubscriber {
+			subscribers = append(subscribers, off)
+		} else {
+			others = append(others, off)
+		}
+		agent.mu.Unlock()
+	}
+	if len(subscribers) == 0 || len(others) == 0 {
+		t.Fatalf("%v subscribing farmers and %v others", len(subscribers), len(others))
+	}
+	subscribed, _ := meanStdDev(subscribers)
+	unsubscribed, _ := meanStdDev(others)
+	if subscribed >= unsubscribed {
+		t.Errorf("after 20 ticks subscribers believe food is %v off the oracle's price, want closer than the %v of the others", subscribed, unsubscribed)
+	}
+}
+
+func TestJSONFileOracle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	if err := ioutil.WriteFile(path, []byte(`{"Food": [3.0, 3.5, 2.5]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oracle, err := newJSONFileOracle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tick, want := range []float64{3.0, 3.5, 2.5} {
+		if price, ok := oracle.QueryPrice("Food", tick+1); !ok || price != want {
+			t.Errorf("tick %v: got %v, %v, want %v, true", tick+1, price, ok, want)
+		}
+	}
+	tests := []struct {
+		name string
+		tick int
+	}{
+		{"Food", 0},
+		{"Food", 4},
+		{"Food", 100},
+		{"Wood", 1},
+	}
+	for _, test := range tests {
+		if price, ok := oracle.QueryPrice(test.name, test.tick); ok || price != 0 {
+			t.Errorf("%v at tick %v: got %v, %v, want 0, false", test.name, test.tick, price, ok)
+		}
+	}
+	if _, err := newJSONFileOracle(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("read prices from a missing file")
+	}
+}
@@ -0,0 +1,52 @@
+// GoEconGo project otel.go
+//go:build otel
+
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+//An InstrumentedMarket is a Market whose ticks, and whose agents' turns, are
+//traced with OpenTelemetry.  Build with the otel tag to use it.
+type InstrumentedMarket struct {
+	*Market
+	tracer trace.Tracer
+}
+
+//otelTracer opens Tracer spans as OpenTelemetry spans.
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+//StartSpan opens an OpenTelemetry span under whatever span ctx carries.
+func (t otelTracer) StartSpan(ctx context.Context, name string) (context.Context, func()) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, func() { span.End() }
+}
+
+//InstrumentMarket has a market trace its work with an OpenTelemetry tracer.  Each
+//Tick, the InstrumentedMarket's or the Market's own, is a "market.tick" span with
+//"market.collect", "market.clear.{commodity}" and "market.dispatch" spans under
+//it, and every agent's turn is traced in "agent.production",
+//"agent.generateAsks", "agent.generateBids" and "agent.update" spans under the
+//span of the tick it is trading in.  A nil tracer traces to a no-op provider,
+//which costs next to nothing.  Instrument the market before spawning any agents.
+func InstrumentMarket(m *Market, tracer trace.Tracer) *InstrumentedMarket {
+	if tracer == nil {
+		tracer = noop.NewTracerProvider().Tracer("GoEconGo")
+	}
+	m.config.Tracer = otelTracer{tracer: tracer}
+	return &InstrumentedMarket{Market: m, tracer: tracer}
+}
+
+//Tick runs the market through a Tick inside a "market.tick" span, which the
+//market's spans and its agents' spans open under.
+func (im *InstrumentedMarket) Tick() {
+	ctx, span := im.tracer.Start(context.Background(), "market.tick")
+	defer span.End()
+	im.Market.tickIn(ctx)
+}
@@ -0,0 +1,72 @@
+// GoEconGo project otel_test.go
+//go:build otel
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInstrumentMarketSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	economy := testSimConfig(t, 2)
+	addPermits(economy.CommodityList())
+	market := newMarket(economy.CommodityList(), testConfig())
+	market.synchronous = true
+	instrumented := InstrumentMarket(market, provider.Tracer("GoEconGo"))
+	economy.Populate(market)
+	market.distributePermits()
+	for i := 0; i < 3; i++ {
+		instrumented.Tick()
+	}
+	if err := market.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	ticks := make(map[trace.SpanID]bool)
+	for _, span := range spans {
+		if span.Name() == "market.tick" {
+			if span.Parent().IsValid() {
+				t.Errorf("market.tick span has a parent")
+			}
+			ticks[span.SpanContext().SpanID()] = true
+		}
+	}
+	if len(ticks) != 3 {
+		t.Fatalf("got %v market.tick spans, want 3", len(ticks))
+	}
+	counts := make(map[string]int)
+	for _, span := range spans {
+		name := span.Name()
+		parent := span.Parent()
+		switch {
+		case name == "market.tick":
+			continue
+		case strings.HasPrefix(name, "market."):
+			if !ticks[parent.SpanID()] {
+				t.Errorf("%v span isn't under a market.tick span", name)
+			}
+		case strings.HasPrefix(name, "agent."):
+			//Agents make their first offers before the first tick.
+			if name != "agent.update" && !parent.IsValid() {
+				continue
+			}
+			if !ticks[parent.SpanID()] {
+				t.Errorf("%v span isn't under a market.tick span", name)
+			}
+		}
+		counts[name]++
+	}
+	for _, name := range []string{"market.collect", "market.clear.Food", "market.dispatch", "agent.production", "agent.generateAsks", "agent.generateBids", "agent.update"} {
+		if counts[name] == 0 {
+			t.Errorf("no %v spans under a market.tick span", name)
+		}
+	}
+}
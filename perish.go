@@ -0,0 +1,110 @@
+// GoEconGo project perish.go
+package main
+
+import "sync"
+
+//spoilageStatsLock guards SpoilageStats, which every agent writes to.
+var spoilageStatsLock sync.Mutex
+
+//SpoilageStats counts the units of each perishable commodity that have expired,
+//by name.  Read it with ExpiredUnits while the simulation is running.
+var SpoilageStats = make(map[string]int)
+
+//ExpiredUnits returns how many units of a commodity have expired so far.
+func ExpiredUnits(name string) int {
+	spoilageStatsLock.Lock()
+	defer spoilageStatsLock.Unlock()
+	return SpoilageStats[name]
+}
+
+//recordExpiry adds expired units of a commodity to the SpoilageStats.
+func recordExpiry(com *commodity, quantity int) {
+	spoilageStatsLock.Lock()
+	defer spoilageStatsLock.Unlock()
+	SpoilageStats[com.name] = SpoilageStats[com.name] + quantity
+}
+
+//A stockBatch is a number of units of a perishable commodity an agent came by on
+//the same tick.
+//tick - the tick the agent came by them
+//quantity - how many of them the agent still holds
+type stockBatch struct {
+	tick     int
+	quantity int
+}
+
+//trackBatches brings an agent's batches of every perishable commodity in line
+//with its inventory.  Units the agent has come by since the last look are
+//stamped with the agent's tick, and units it has parted with (sold, used up,
+//spoiled) are taken from its oldest batches first.
+func trackBatches(agent *traderAgent) {
+	for com := range agent.inventory {
+		if com.Perishable {
+			trackBatch(agent, com)
+		}
+	}
+	for com := range agent.batches {
+		if _, ok := agent.inventory[com]; !ok {
+			trackBatch(agent, com)
+		}
+	}
+}
+
+//trackBatch brings an agent's batches of one perishable commodity in line with
+//its inventory.
+func trackBatch(agent *traderAgent, com *commodity) {
+	if agent.batches == nil {
+		agent.batches = make(map[*commodity][]stockBatch)
+	}
+	batches := agent.batches[com]
+	tracked := 0
+	for _, batch := range batches {
+		tracked = tracked + batch.quantity
+	}
+	held := agent.inventory[com]
+	if held < 0 {
+		held = 0
+	}
+	if held > tracked {
+		batches = append(batches, stockBatch{tick: agent.tick, quantity: held - tracked})
+	}
+	for gone := tracked - held; gone > 0; {
+		if batches[0].quantity > gone {
+			batches[0].quantity = batches[0].quantity - gone
+			break
+		}
+		gone = gone - batches[0].quantity
+		batches = batches[1:]
+	}
+	if len(batches) == 0 {
+		delete(agent.batches, com)
+		return
+	}
+	agent.batches[com] = batches
+}
+
+//expirePerishables throws out every unit of a perishable commodity the agent has
+//held for its commodity's ShelfLife or longer, logging them in the SpoilageStats.
+func expirePerishables(agent *traderAgent) {
+	trackBatches(agent)
+	for com, batches := range agent.batches {
+		if com.ShelfLife <= 0 {
+			continue
+		}
+		expired := 0
+		for len(batches) > 0 && agent.tick-batches[0].tick >= com.ShelfLife {
+			expired = expired + batches[0].quantity
+			batches = batches[1:]
+		}
+		if expired == 0 {
+			continue
+		}
+		agent.inventory[com] = agent.inventory[com] - expired
+		recordExpiry(com, expired)
+		if len(batches) == 0 {
+			delete(agent.batches, com)
+			continue
+		}
+		agent.batches[com] = batches
+	}
+}
@@ -0,0 +1,158 @@
+// GoEconGo project permits.go
+package main
+
+//addPermits creates the permit commodity for every commodity that needs permits,
+//and adds it to the commodity list so that permits trade on the market alongside
+//everything else.  A permit starts out priced the same as what it permits.
+func addPermits(commodityList map[string]*commodity) {
+	var permitted []*commodity
+	for _, com := range commodityList {
+		if com.PermitRequired && com.permit == nil {
+			permitted = append(permitted, com)
+		}
+	}
+	for _, com := range permitted {
+		permit := new(commodity)
+		permit.name = com.name + " Permit"
+		permit.averagePrice = com.averagePrice
+		permit.permitFor = com
+		com.permit = permit
+		commodityList[permit.name] = permit
+	}
+}
+
+//methodUses reports whether a production method consumes, needs or produces a
+//commodity.
+func methodUses(method *productionMethod, com *commodity) bool {
+	for _, sets := range [][]commoditySet{method.inputs, method.catalysts, method.outputs} {
+		for _, set := range sets {
+			if set.item == com {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//jobUses reports whether any method of a production set consumes, needs or
+//produces a commodity.
+func jobUses(job *productionSet, com *commodity) bool {
+	if job == nil {
+		return false
+	}
+	for _, method := range job.methods {
+		if methodUses(method, com) {
+			return true
+		}
+	}
+	return false
+}
+
+//hasPermits reports whether an agent holds a permit for every commodity a
+//production method uses that needs one.
+func hasPermits(agent *traderAgent, method *productionMethod) bool {
+	for _, sets := range [][]commoditySet{method.inputs, method.catalysts, method.outputs} {
+		for _, set := range sets {
+			if set.item.PermitRequired && agent.permits[set.item] < 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//adjustHolding changes how much of a commodity an agent holds after a trade.
+//Permits go into the agent's permits, everything else into its inventory.
+func adjustHolding(agent *traderAgent, item *commodity, delta int) {
+	if item.permitFor != nil {
+		if agent.permits == nil {
+			agent.permits = make(map[*commodity]int)
+		}
+		agent.permits[item.permitFor] = agent.permits[item.permitFor] + delta
+		return
+	}
+	agent.inventory[item] = agent.inventory[item] + delta
+}
+
+//generatePermitAsks offers up any permits the agent holds beyond the one its job
+//needs.  These are the agent's PermitAsks.
+func generatePermitAsks(agent *traderAgent) []asks {
+	var askSlice []asks
+	for com, num := range agent.permits {
+		if jobUses(agent.job, com) {
+			num = num - 1
+		}
+		if num <= 0 || com.permit == nil {
+			continue
+		}
+		var askBuild asks
+		askBuild.numberOffered = num
+		askBuild.offeredAsk.quantity = 1
+		askBuild.offeredAsk.item = com.permit
+		askBuild.offeredAsk.sellFor = (agent.priceBelief[com.permit].high + agent.priceBelief[com.permit].low) / 2
+		askSlice = append(askSlice, askBuild)
+	}
+	return askSlice
+}
+
+//generatePermitBids bids for a permit for every commodity the agent's job needs
+//one for but it doesn't hold.  These are the agent's PermitBids.
+func generatePermitBids(agent *traderAgent) []bids {
+	var bidSlice []bids
+	if agent.job == nil {
+		return bidSlice
+	}
+	needed := make(map[*commodity]bool)
+	for _, method := range agent.job.methods {
+		for _, sets := range [][]commoditySet{method.inputs, method.catalysts, method.outputs} {
+			for _, set := range sets {
+				if set.item.PermitRequired && set.item.permit != nil && agent.permits[set.item] < 1 {
+					needed[set.item] = true
+				}
+			}
+		}
+	}
+	for com := range needed {
+		var bidBuild bids
+		bidBuild.numberOffered = 1
+		bidBuild.offeredBid.quantity = 1
+		bidBuild.offeredBid.item = com.permit
+		bidBuild.offeredBid.buyFor = (agent.priceBelief[com.permit].high + agent.priceBelief[com.permit].low) / 2
+		bidSlice = append(bidSlice, bidBuild)
+	}
+	return bidSlice
+}
+
+//distributePermits hands out each permitted commodity's InitialPermits evenly
+//among the agents whose jobs use it.  Any that don't divide evenly go one each to
+//the agents in the lowest slots.
+func (m *Market) distributePermits() {
+	for _, com := range m.sortedCommodities() {
+		if !com.PermitRequired {
+			continue
+		}
+		var holders []*traderAgent
+		for _, slot := range m.agentSlots() {
+			if agent := m.agents[slot]; jobUses(agent.job, com) {
+				holders = append(holders, agent)
+			}
+		}
+		if len(holders) == 0 {
+			continue
+		}
+		share := com.InitialPermits / len(holders)
+		extra := com.InitialPermits % len(holders)
+		for index, agent := range holders {
+			grant := share
+			if index < extra {
+				grant++
+			}
+			agent.mu.Lock()
+			if agent.permits == nil {
+				agent.permits = make(map[*commodity]int)
+			}
+			agent.permits[com] = agent.permits[com] + grant
+			agent.mu.Unlock()
+		}
+	}
+}
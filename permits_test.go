@@ -0,0 +1,90 @@
+// GoEconGo project permits_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPermitsGateProduction(t *testing.T) {
+	ore, metal := &commodity{name: "Ore", averagePrice: 3}, &commodity{name: "Metal", averagePrice: 3}
+	pollution := &commodity{name: "Pollution", IsExternality: true}
+	metal.PermitRequired = true
+	config := testConfig()
+	smelt := func(permits int) *traderAgent {
+		smelter := newTestAgent(&config, smeltingJob(ore, metal, pollution), 100, map[*commodity]int{ore: 10})
+		smelter.permits = map[*commodity]int{metal: permits}
+		performProduction(smelter)
+		return smelter
+	}
+	without := smelt(0)
+	if without.inventory[metal] != 0 || without.inventory[ore] != 10 {
+		t.Errorf("a smelter without a permit made %v metal from %v ore", without.inventory[metal], 10-without.inventory[ore])
+	}
+	if without.funds != 100-without.job.penalty {
+		t.Errorf("a smelter without a permit has %v, want %v after its penalty", without.funds, 100-without.job.penalty)
+	}
+	with := smelt(1)
+	if with.inventory[metal] != 1 || with.inventory[ore] != 9 {
+		t.Errorf("a smelter with a permit made %v metal from %v ore, want 1 from 1", with.inventory[metal], 10-with.inventory[ore])
+	}
+	if with.funds != 100 {
+		t.Errorf("a smelter with a permit has %v, want all of its 100", with.funds)
+	}
+}
+
+//permitPriceAt runs the test economy, with ore mined only under permit, starting
+//out with some number of permits, and launches two new miners without any every
+//tick for 30 ticks.  It returns the price ore permits end up at.
+func permitPriceAt(t *testing.T, permits int) float64 {
+	economy := testSimConfig(t, 10)
+	ore := economy.CommodityList()["Ore"]
+	ore.PermitRequired = true
+	ore.InitialPermits = permits
+	sim := simulateEconomy(t, testConfig(), economy)
+	for i := 0; i < 30; i++ {
+		sim.Market.spawn("Miner")
+		sim.Market.spawn("Miner")
+		RunTicks(1, sim)
+	}
+	if ore.permit.TotalVolume() == 0 {
+		t.Fatalf("no ore permits traded out of %v", permits)
+	}
+	return sim.Market.statistics.Commodities["Ore"].PermitPrice
+}
+
+func TestPermitPriceRisesWithScarcity(t *testing.T) {
+	previous := 0.0
+	for _, permits := range []int{100, 60, 40, 30, 24} {
+		price := permitPriceAt(t, permits)
+		if price <= previous {
+			t.Errorf("%v permits traded at %v, want more than the %v of more permits", permits, price, previous)
+		}
+		previous = price
+	}
+}
+
+func TestPermitsDeterministic(t *testing.T) {
+	//permittedRun runs the test economy under seed 1 for 30 ticks, with ore mined
+	//only under permit and fewer permits than there are agents using ore, and
+	//returns every commodity's price history.
+	permittedRun := func() map[string][]float64 {
+		economy := testSimConfig(t, 10)
+		ore := economy.CommodityList()["Ore"]
+		ore.PermitRequired = true
+		ore.InitialPermits = 15
+		sim := simulateEconomy(t, testConfig(), economy)
+		RunTicks(30, sim)
+		prices := make(map[string][]float64)
+		for name, com := range sim.Market.commodities {
+			prices[name] = com.PriceHistory(30)
+		}
+		return prices
+	}
+	prices := permittedRun()
+	for run := 0; run < 5; run++ {
+		if again := permittedRun(); !reflect.DeepEqual(prices, again) {
+			t.Fatalf("run %v of seed 1 priced %v, want %v", run+2, again, prices)
+		}
+	}
+}
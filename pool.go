@@ -0,0 +1,60 @@
+// GoEconGo project pool.go
+package main
+
+//regeneratePool refills a common pool by its RegenerationRate, up to its
+//MaxPoolSize.
+func (com *commodity) regeneratePool() {
+	if !com.IsCommonPool {
+		return
+	}
+	com.poolLock.Lock()
+	defer com.poolLock.Unlock()
+	room := com.MaxPoolSize - com.PoolSize
+	if room <= 0 {
+		return
+	}
+	if com.RegenerationRate < room {
+		com.PoolSize = com.PoolSize + com.RegenerationRate
+	} else {
+		com.PoolSize = com.PoolSize + room
+	}
+}
+
+//drawFromPool takes quantity units out of a common pool.  If there aren't that
+//many left, nothing is taken and it returns false.
+func (com *commodity) drawFromPool(quantity int) bool {
+	com.poolLock.Lock()
+	defer com.poolLock.Unlock()
+	if com.PoolSize < quantity || com.PoolSize == 0 {
+		return false
+	}
+	com.PoolSize = com.PoolSize - quantity
+	return true
+}
+
+//returnToPool puts back units drawn from a common pool that went unused.
+func (com *commodity) returnToPool(quantity int) {
+	com.poolLock.Lock()
+	defer com.poolLock.Unlock()
+	com.PoolSize = com.PoolSize + quantity
+}
+
+//drawPoolInputs draws every common pool input among a production's inputs.  It is
+//all or nothing: if any pool runs dry, whatever was already drawn goes back and it
+//returns false.
+func drawPoolInputs(inputs []commoditySet) bool {
+	var drawn []commoditySet
+	for _, input := range inputs {
+		if !input.item.IsCommonPool {
+			continue
+		}
+		if !input.item.drawFromPool(input.quantity) {
+			for _, back := range drawn {
+				back.item.returnToPool(back.quantity)
+			}
+			return false
+		}
+		drawn = append(drawn, input)
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+// GoEconGo project pool_test.go
+package main
+
+import "testing"
+
+//newMiners returns an ore vein, a common pool of size units refilling by
+//regeneration a tick, and ten miners each digging 2 ore out of it at a time.
+func newMiners(size, regeneration int) (*commodity, *commodity, []*traderAgent) {
+	vein := &commodity{name: "Vein", IsCommonPool: true, PoolSize: size, MaxPoolSize: size, RegenerationRate: regeneration}
+	ore := &commodity{name: "Ore", averagePrice: 3}
+	mining := &productionMethod{inputs: []commoditySet{{item: vein, quantity: 2}}, outputs: []commoditySet{{item: ore, quantity: 2}}}
+	job := &productionSet{methods: []*productionMethod{mining}, penalty: 2}
+	config := testConfig()
+	var miners []*traderAgent
+	for i := 0; i < 10; i++ {
+		miners = append(miners, newTestAgent(&config, job, 100, make(map[*commodity]int)))
+	}
+	return vein, ore, miners
+}
+
+//mine runs one tick of the miners' production, after refilling the vein, and
+//returns how much ore they dug and how many of them failed to dig any.
+func mine(vein, ore *commodity, miners []*traderAgent) (dug int, failed int) {
+	vein.regeneratePool()
+	for _, miner := range miners {
+		before := miner.inventory[ore]
+		performProduction(miner)
+		if miner.consecutivePenalties > 0 {
+			failed++
+		}
+		dug += miner.inventory[ore] - before
+	}
+	return dug, failed
+}
+
+func TestCommonPoolExhaustion(t *testing.T) {
+	vein, ore, miners := newMiners(1000, 0)
+	//Twenty ore a tick empties the vein in 50 ticks.
+	for tick := 1; tick <= 50; tick++ {
+		if _, failed := mine(vein, ore, miners); failed > 0 {
+			t.Fatalf("tick %v: %v miners failed with %v left in the vein", tick, failed, vein.PoolSize)
+		}
+	}
+	if vein.PoolSize != 0 {
+		t.Fatalf("the vein has %v left after 50 ticks, want 0", vein.PoolSize)
+	}
+	if dug, failed := mine(vein, ore, miners); dug != 0 || failed != len(miners) {
+		t.Errorf("an empty vein gave %v ore, and %v miners failed, want none and all of them", dug, failed)
+	}
+}
+
+func TestCommonPoolRenewable(t *testing.T) {
+	//The miners would dig 20 ore a tick, but the vein only grows back 15.
+	vein, ore, miners := newMiners(1000, 15)
+	for tick := 0; tick < 300; tick++ {
+		mine(vein, ore, miners)
+		if vein.PoolSize < 0 || vein.PoolSize > vein.MaxPoolSize {
+			t.Fatalf("tick %v: the vein holds %v", tick, vein.PoolSize)
+		}
+	}
+	total := 0
+	for tick := 0; tick < 100; tick++ {
+		dug, _ := mine(vein, ore, miners)
+		total += dug
+	}
+	if total < 15*100-2 || total > 15*100+2 {
+		t.Errorf("the miners dug %v in 100 ticks, want the 1500 the vein grows back", total)
+	}
+}
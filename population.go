@@ -0,0 +1,70 @@
+// GoEconGo project population.go
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+//A PopulationHistory remembers how many agents each role had at the end of each
+//recent tick.
+//mu - guards roles, which a reader may look at while the market ticks
+//capacity - how many ticks each role's history holds
+//roles - each role's counts, by role
+type PopulationHistory struct {
+	mu       sync.RWMutex
+	capacity int
+	roles    map[string]*historyRing[int]
+}
+
+//newPopulationHistory builds an empty history holding capacity ticks of each role.
+func newPopulationHistory(capacity int) *PopulationHistory {
+	h := new(PopulationHistory)
+	h.capacity = capacity
+	h.roles = make(map[string]*historyRing[int])
+	return h
+}
+
+//record adds a tick's counts, by role.
+func (h *PopulationHistory) record(population map[string]int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for role, count := range population {
+		ring, ok := h.roles[role]
+		if !ok {
+			ring = new(historyRing[int])
+			ring.init(h.capacity)
+			h.roles[role] = ring
+		}
+		ring.push(count)
+	}
+}
+
+//RoleCount returns how many agents a role had at the end of each of the last
+//ticksBack ticks (or as many as are remembered), oldest first.
+func (h *PopulationHistory) RoleCount(role string, ticksBack int) []int {
+	h.mu.RLock()
+	ring, ok := h.roles[role]
+	h.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return ring.last(ticksBack)
+}
+
+//Roles returns every role the history has counted, in alphabetical order.
+func (h *PopulationHistory) Roles() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	roles := make([]string, 0, len(h.roles))
+	for role := range h.roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+//PopulationHistory returns the market's history of role populations.
+func (m *Market) PopulationHistory() *PopulationHistory {
+	return m.populationHistory
+}
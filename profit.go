@@ -0,0 +1,18 @@
+// GoEconGo project profit.go
+package main
+
+//Profit returns what an agent has made over its life: its Revenue, less its Cost
+//and ProductionPenalties.  Goods the agent was granted at launch, or still holds,
+//don't count, so an agent living off its grants shows as losing money.
+func (agent *traderAgent) Profit() float64 {
+	return agent.Revenue - agent.Cost - agent.ProductionPenalties
+}
+
+//ProfitMargin returns an agent's Profit as a fraction of its Revenue.
+//Returns 0 for an agent that has never sold anything.
+func (agent *traderAgent) ProfitMargin() float64 {
+	if agent.Revenue == 0 {
+		return 0
+	}
+	return agent.Profit() / agent.Revenue
+}
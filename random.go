@@ -0,0 +1,84 @@
+// GoEconGo project random.go
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+//A countingSource is a seeded source of randomness that counts its draws.
+//seed - the seed it was made from
+//draws - how many draws have been taken from it
+type countingSource struct {
+	rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.Source64.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.Source64.Uint64()
+}
+
+//A randomSource is a source of randomness that knows where it has got to, so it
+//can be saved and picked up again (see state and restoreRandom).
+type randomSource struct {
+	*rand.Rand
+	source *countingSource
+}
+
+//A randomState is how far a randomSource has got: its seed, and how many draws
+//have been taken from it.
+type randomState struct {
+	Seed  int64  `json:"seed"`
+	Draws uint64 `json:"draws"`
+}
+
+//newRandom makes a source of randomness.  A seed of 0 picks one from the clock,
+//so every run differs; any other seed always gives the same draws.
+func newRandom(seed int64) *randomSource {
+	if seed == 0 {
+		seed = time.Now().UTC().UnixNano()
+	}
+	source := &countingSource{Source64: rand.NewSource(seed).(rand.Source64), seed: seed}
+	return &randomSource{Rand: rand.New(source), source: source}
+}
+
+//state returns how far the source has got.
+func (r *randomSource) state() randomState {
+	return randomState{Seed: r.source.seed, Draws: r.source.draws}
+}
+
+//restoreRandom makes a source of randomness that carries on from a saved state,
+//giving the same draws the saved source would have.
+func restoreRandom(state randomState) *randomSource {
+	r := newRandom(state.Seed)
+	for r.source.draws < state.Draws {
+		r.source.Int63()
+	}
+	return r
+}
+
+//random returns the source of randomness the market and the agent factories draw
+//from, making it from Seed the first time it is asked for.  It is not safe to use
+//from the agents' goroutines - each agent has its own.
+func (config *SimulationConfig) random() *randomSource {
+	if config.rng == nil {
+		config.rng = newRandom(config.Seed)
+	}
+	return config.rng
+}
+
+//random returns the agent's own source of randomness, which the market seeds from
+//its own when it launches the agent.
+func (agent *traderAgent) random() *randomSource {
+	if agent.rng == nil {
+		agent.rng = newRandom(agent.settings().random().Int63())
+	}
+	return agent.rng
+}
@@ -0,0 +1,72 @@
+// GoEconGo project reproduction.go
+package main
+
+import "fmt"
+
+//The funds above which producers split in two, unless configured otherwise.
+const defaultReproductionThreshold = 500
+
+//A ReproductionEvent records a producer splitting in two.
+type ReproductionEvent struct {
+	ParentID uint64
+	ChildID  uint64
+	Role     string
+	Tick     int
+	Funds    float64
+}
+
+func (e ReproductionEvent) String() string {
+	return fmt.Sprintf("%v %v split in two at tick %v, making agent %v with %v", e.Role, e.ParentID, e.Tick, e.ChildID, e.Funds)
+}
+
+//child makes a new agent of a parent's role to split off from it: it takes half
+//of the parent's funds and a copy of its price beliefs, and starts out holding
+//nothing, with its own riskAversion from the role's factory.  The parent must be
+//locked, and loses what the child takes.
+func (m *Market) child(parent *traderAgent) traderAgent {
+	offspring := m.roles[parent.role]()
+	offspring.id = newAgentID()
+	share := parent.funds / 2
+	parent.funds = parent.funds - share
+	m.Ledger.Record(parent.id, offspring.id, share, m.tick, ledgerReproduction)
+	offspring.funds = share
+	offspring.inventory = make(map[*commodity]int)
+	offspring.priceBelief = make(map[*commodity]priceRange)
+	for com, belief := range parent.priceBelief {
+		offspring.priceBelief[com] = belief
+	}
+	return offspring
+}
+
+//reproduce splits every producer whose funds are above the ReproductionThreshold
+//in two, launching a child of its role on new channels (see child), so that roles
+//doing well grow on their own.  Parents split in slot order, and only while their
+//role has room under its RoleSlots.  Children don't split until the next tick.
+//Every split raises a ReproductionEvent.
+func (m *Market) reproduce() {
+	if !m.config.ReproductionEnabled {
+		return
+	}
+	var parents []uint64
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		rich := agent.funds > m.config.ReproductionThreshold
+		_, producer := m.roles[agent.role]
+		agent.mu.Unlock()
+		if rich && producer {
+			parents = append(parents, slot)
+		}
+	}
+	for _, slot := range parents {
+		parent := m.agents[slot]
+		parent.mu.Lock()
+		if !m.hasRoom(parent.role) {
+			parent.mu.Unlock()
+			continue
+		}
+		offspring := m.child(parent)
+		parent.mu.Unlock()
+		m.Emit(ReproductionEvent{ParentID: parent.id, ChildID: m.add(offspring), Role: parent.role, Tick: m.tick, Funds: offspring.funds})
+	}
+}
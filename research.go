@@ -0,0 +1,97 @@
+// GoEconGo project research.go
+package main
+
+import "math"
+
+//invest puts an agent's funds towards researching one of its job's research
+//methods.  Once the agent has put in the method's UnlockCost, the method is
+//unlocked: the agent is given a job of its own, with the method added to it (see
+//unlockResearched).  The agent must be locked, or not running.
+//method - the method to research, which must be one of the job's research
+//amount - the funds to put in (nothing is put in past the UnlockCost)
+//Returns whether the method was unlocked.
+func invest(agent *traderAgent, method *productionMethod, amount float64) bool {
+	if agent.job == nil || unlocked(agent, method) {
+		return false
+	}
+	if agent.research == nil {
+		agent.research = make(map[*productionMethod]float64)
+	}
+	amount = math.Min(amount, method.UnlockCost-agent.research[method])
+	if amount > 0 {
+		agent.funds = agent.funds - amount
+		agent.Cost = agent.Cost + amount
+		agent.ledger.Record(agent.id, 0, amount, agent.tick, ledgerResearch)
+		agent.research[method] = agent.research[method] + amount
+	}
+	if agent.research[method] < method.UnlockCost {
+		return false
+	}
+	unlockResearched(agent)
+	return true
+}
+
+//unlocked reports whether an agent's job already has a method.
+func unlocked(agent *traderAgent, method *productionMethod) bool {
+	for _, have := range agent.job.methods {
+		if have == method {
+			return true
+		}
+	}
+	return false
+}
+
+//unlockResearched gives an agent a job of its own, with every research method it
+//has invested the UnlockCost of added to its methods, in the order of the job's
+//research - so that the methods come in the same order however they were
+//unlocked.  The job the agent had is left alone, as the rest of its role share it.
+func unlockResearched(agent *traderAgent) {
+	researched := make(map[*productionMethod]bool)
+	for _, method := range agent.job.research {
+		researched[method] = true
+	}
+	job := *agent.job
+	job.methods = nil
+	for _, method := range agent.job.methods {
+		if !researched[method] {
+			job.methods = append(job.methods, method)
+		}
+	}
+	for _, method := range agent.job.research {
+		if agent.research[method] >= method.UnlockCost {
+			job.methods = append(job.methods, method)
+		}
+	}
+	agent.job = &job
+}
+
+//researchTarget returns the cheapest method of an agent's job's research it has
+//not yet unlocked (nil is none).
+func researchTarget(agent *traderAgent) *productionMethod {
+	var target *productionMethod
+	for _, method := range agent.job.research {
+		if unlocked(agent, method) {
+			continue
+		}
+		if target == nil || method.UnlockCost < target.UnlockCost {
+			target = method
+		}
+	}
+	return target
+}
+
+//doResearch has an agent invest the ResearchShare of what it has made, beyond the
+//funds it was launched with, in the cheapest method it has yet to unlock.
+func doResearch(agent *traderAgent) {
+	share := agent.settings().ResearchShare
+	if share <= 0 || agent.job == nil {
+		return
+	}
+	surplus := agent.funds - agent.startingFunds
+	if surplus <= 0 {
+		return
+	}
+	if target := researchTarget(agent); target != nil {
+		invest(agent, target, share*surplus)
+	}
+}
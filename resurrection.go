@@ -0,0 +1,93 @@
+// GoEconGo project resurrection.go
+package main
+
+//A ResurrectionStrategy decides what takes a dead agent's place.
+type ResurrectionStrategy interface {
+	Resurrect(dead traderAgent, sim *Simulation) traderAgent
+}
+
+//The ResurrectionStrategies that can be picked by name on the command line.
+var resurrectionStrategies = map[string]ResurrectionStrategy{
+	"expensive":  MostExpensiveCommodityStrategy{},
+	"balance":    BalancePopulationStrategy{},
+	"copy":       CopySuccessfulAgentStrategy{},
+	"bottleneck": BottleneckStrategy{Window: bottleneckWindow},
+}
+
+//MostExpensiveCommodityStrategy replaces the dead with an agent of the role making
+//whatever commodity is most expensive.
+type MostExpensiveCommodityStrategy struct{}
+
+//Resurrect makes an agent of the role with the most expensive product.
+func (MostExpensiveCommodityStrategy) Resurrect(dead traderAgent, sim *Simulation) traderAgent {
+	m := sim.Market
+	best := ""
+	for _, role := range m.roleOrder {
+		if best == "" || m.products[role].averagePrice > m.products[best].averagePrice {
+			best = role
+		}
+	}
+	return m.roles[best]()
+}
+
+//BalancePopulationStrategy replaces the dead with an agent of whichever role has
+//the fewest agents.
+type BalancePopulationStrategy struct{}
+
+//Resurrect makes an agent of the least populated role.
+func (BalancePopulationStrategy) Resurrect(dead traderAgent, sim *Simulation) traderAgent {
+	m := sim.Market
+	best := ""
+	for _, role := range m.roleOrder {
+		if best == "" || m.population[role] < m.population[best] {
+			best = role
+		}
+	}
+	return m.roles[best]()
+}
+
+//CopySuccessfulAgentStrategy replaces the dead with an agent of the role of the
+//living agent with the highest NetWorth.
+type CopySuccessfulAgentStrategy struct{}
+
+//Resurrect makes an agent of the richest agent's role.  Agents of roles the market
+//can't make (speculators, market makers) are passed over, and with nobody to copy
+//it falls back to the MostExpensiveCommodityStrategy.
+func (CopySuccessfulAgentStrategy) Resurrect(dead traderAgent, sim *Simulation) traderAgent {
+	m := sim.Market
+	best := ""
+	var bestWorth float64
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		role, worth := agent.role, NetWorth(agent)
+		agent.mu.Unlock()
+		if _, ok := m.roles[role]; ok && (best == "" || worth > bestWorth) {
+			best, bestWorth = role, worth
+		}
+	}
+	if best == "" {
+		return MostExpensiveCommodityStrategy{}.Resurrect(dead, sim)
+	}
+	return m.roles[best]()
+}
+
+//resurrection returns the configured ResurrectionStrategy, or the
+//MostExpensiveCommodityStrategy if there is none.
+func (config *SimulationConfig) resurrection() ResurrectionStrategy {
+	if config.Resurrection == nil {
+		return MostExpensiveCommodityStrategy{}
+	}
+	return config.Resurrection
+}
+
+//placeAgent puts a replacement agent on a dead agent's channels.  An agent of a
+//capped role goes through fillSlot, which may make another role instead.
+//slot - the channels of the dead agent
+func (m *Market) placeAgent(slot uint64, agent traderAgent) {
+	if _, capped := m.config.RoleSlots[agent.role]; capped {
+		m.fillSlot(slot, agent.role)
+		return
+	}
+	m.respawnAgent(slot, agent)
+}
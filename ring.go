@@ -0,0 +1,71 @@
+// GoEconGo project ring.go
+package main
+
+import "sync"
+
+//A historyRing keeps the last few values of something in a fixed amount of
+//space, overwriting the oldest as new ones come in.  It is safe to read while
+//another goroutine writes it.
+//mu - guards everything below
+//values - the ring itself; its length is the ring's capacity
+//next - where the next value goes
+//count - how many values the ring holds
+type historyRing[T any] struct {
+	mu     sync.RWMutex
+	values []T
+	next   int
+	count  int
+}
+
+//init empties the ring and sizes it to hold capacity values (at least one).
+func (r *historyRing[T]) init(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = make([]T, capacity)
+	r.next = 0
+	r.count = 0
+}
+
+//push adds a value, overwriting the oldest if the ring is full.  A ring that was
+//never sized holds priceHistoryLength values.
+func (r *historyRing[T]) push(value T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.values) == 0 {
+		r.values = make([]T, priceHistoryLength)
+	}
+	r.values[r.next] = value
+	r.next = (r.next + 1) % len(r.values)
+	if r.count < len(r.values) {
+		r.count++
+	}
+}
+
+//all returns a copy of every value in the ring, oldest first.
+func (r *historyRing[T]) all() []T {
+	r.mu.RLock()
+	count := r.count
+	r.mu.RUnlock()
+	return r.last(count)
+}
+
+//last returns a copy of the newest n values (or all of them, if there are fewer),
+//oldest first.
+func (r *historyRing[T]) last(n int) []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if n > r.count {
+		n = r.count
+	}
+	if n < 0 {
+		n = 0
+	}
+	out := make([]T, n)
+	for i := 0; i < n; i++ {
+		out[i] = r.values[(r.next-n+i+len(r.values))%len(r.values)]
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+// GoEconGo project scale.go
+package main
+
+import "math"
+
+//A BatchDiscount models economies of scale: an agent that has run enough
+//productions gets by on fewer inputs.
+//Threshold - how many productions the agent must have run since it spawned (the
+//next one is discounted)
+//DiscountRate - the fraction [0.0,1.0] cut from every input quantity (never below
+//one unit)
+type BatchDiscount struct {
+	Threshold    int
+	DiscountRate float64
+}
+
+//A BatchPenalty models diseconomies of scale: past a number of productions in a
+//single tick, congestion and fatigue make every further production take more
+//inputs.
+//Threshold - how many productions a tick run at the normal inputs
+//PenaltyRate - how much more of every input each production past Threshold takes
+type BatchPenalty struct {
+	Threshold   int
+	PenaltyRate float64
+}
+
+//inputsFor returns the inputs a production method takes from an agent, after any
+//batch discount the agent has earned.  Once earned, the discounted inputs are kept
+//in the agent's effectiveInputs.
+func inputsFor(agent *traderAgent, method *productionMethod) []commoditySet {
+	if inputs, ok := agent.effectiveInputs[method]; ok {
+		return inputs
+	}
+	if method.discount == nil || agent.ProductionCount < method.discount.Threshold {
+		return method.inputs
+	}
+	inputs := make([]commoditySet, len(method.inputs))
+	for index, input := range method.inputs {
+		inputs[index] = input
+		discounted := int(math.Floor(float64(input.quantity) * (1 - method.discount.DiscountRate)))
+		if discounted < 1 {
+			discounted = 1
+		}
+		if discounted < input.quantity {
+			inputs[index].quantity = discounted
+		}
+	}
+	if agent.effectiveInputs == nil {
+		agent.effectiveInputs = make(map[*productionMethod][]commoditySet)
+	}
+	agent.effectiveInputs[method] = inputs
+	return inputs
+}
+
+//cycleInputs returns the inputs a production method takes from an agent on a
+//given cycle of the tick - its inputsFor, raised by the method's BatchPenalty once
+//the cycle is past the penalty's Threshold.
+func cycleInputs(agent *traderAgent, method *productionMethod, cycle int) []commoditySet {
+	inputs := inputsFor(agent, method)
+	if method.surcharge == nil || cycle <= method.surcharge.Threshold {
+		return inputs
+	}
+	penalized := make([]commoditySet, len(inputs))
+	for index, input := range inputs {
+		penalized[index] = input
+		penalized[index].quantity = int(math.Floor(float64(input.quantity) * (1 + method.surcharge.PenaltyRate)))
+	}
+	return penalized
+}
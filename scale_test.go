@@ -0,0 +1,80 @@
+// GoEconGo project scale_test.go
+package main
+
+import "testing"
+
+//refiningJob returns a job refining some units of ore into a unit of metal, under
+//a batch discount and a batch penalty (nil is none).
+func refiningJob(ore, metal *commodity, units int, discount *BatchDiscount, surcharge *BatchPenalty) *productionSet {
+	method := &productionMethod{
+		inputs:    []commoditySet{{item: ore, quantity: units}},
+		outputs:   []commoditySet{{item: metal, quantity: 1}},
+		discount:  discount,
+		surcharge: surcharge,
+	}
+	return &productionSet{methods: []*productionMethod{method}, penalty: 2}
+}
+
+//refine has an agent produce for a tick, and returns how much of an input it used
+//up.
+func refine(agent *traderAgent, input *commodity) int {
+	before := agent.inventory[input]
+	performProduction(agent)
+	return before - agent.inventory[input]
+}
+
+func TestBatchDiscount(t *testing.T) {
+	ore, metal := &commodity{name: "Ore"}, &commodity{name: "Metal"}
+	config := testConfig()
+	job := refiningJob(ore, metal, 10, &BatchDiscount{Threshold: 50, DiscountRate: 0.1}, nil)
+	seasoned := newTestAgent(&config, job, 100, map[*commodity]int{ore: 1000})
+	for production := 1; production <= 60; production++ {
+		want := 10
+		if production > 50 {
+			//The discount starts with the first production after 50 have run.
+			want = 9
+		}
+		if used := refine(seasoned, ore); used != want {
+			t.Fatalf("production %v used %v ore, want %v", production, used, want)
+		}
+	}
+	fresh := newTestAgent(&config, job, 100, map[*commodity]int{ore: 1000})
+	if used := refine(fresh, ore); used != 10 {
+		t.Errorf("a new refiner used %v ore, want all 10", used)
+	}
+	//However deep the discount, a production takes at least a unit.
+	job = refiningJob(ore, metal, 1, &BatchDiscount{Threshold: 0, DiscountRate: 0.9}, nil)
+	seasoned = newTestAgent(&config, job, 100, map[*commodity]int{ore: 10})
+	refine(seasoned, ore)
+	if used := refine(seasoned, ore); used != 1 {
+		t.Errorf("a 90%% discount on a unit of ore used %v, want 1", used)
+	}
+}
+
+func TestBatchPenalty(t *testing.T) {
+	food, ore := &commodity{name: "Food"}, &commodity{name: "Ore"}
+	config := testConfig()
+	//A miner digs a unit of ore from 5 food, and tires after 3 digs a tick.
+	job := refiningJob(food, ore, 5, nil, &BatchPenalty{Threshold: 3, PenaltyRate: 0.2})
+	miner := newTestAgent(&config, job, 100, map[*commodity]int{food: 100})
+	for cycle, want := range []int{5, 5, 5, 6, 6} {
+		before := miner.inventory[food]
+		if !produce(miner, job.methods, cycle+1) {
+			t.Fatalf("cycle %v didn't produce", cycle+1)
+		}
+		if used := before - miner.inventory[food]; used != want {
+			t.Errorf("cycle %v used %v food, want %v", cycle+1, used, want)
+		}
+	}
+	//All the cycles of a tick add up.
+	config.ProductionCyclesPerTick = 4
+	miner = newTestAgent(&config, job, 100, map[*commodity]int{food: 100})
+	if used := refine(miner, food); used != 3*5+6 {
+		t.Errorf("4 cycles in a tick used %v food, want %v", used, 3*5+6)
+	}
+	config.ProductionCyclesPerTick = 3
+	miner = newTestAgent(&config, job, 100, map[*commodity]int{food: 100})
+	if used := refine(miner, food); used != 3*5 {
+		t.Errorf("3 cycles in a tick used %v food, want %v", used, 3*5)
+	}
+}
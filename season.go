@@ -0,0 +1,34 @@
+// GoEconGo project season.go
+package main
+
+import "math"
+
+//seasonalOutputs returns what a production method makes on a given tick - its
+//outputs, multiplied by its SeasonalModifier rounded to a whole number (at least
+//1).  A method without a SeasonalModifier always makes its outputs.
+func seasonalOutputs(method *productionMethod, tick int) []commoditySet {
+	if method.SeasonalModifier == nil {
+		return method.outputs
+	}
+	factor := int(math.Round(method.SeasonalModifier(tick)))
+	if factor < 1 {
+		factor = 1
+	}
+	outputs := make([]commoditySet, len(method.outputs))
+	for index, output := range method.outputs {
+		outputs[index] = output
+		outputs[index].quantity = output.quantity * factor
+	}
+	return outputs
+}
+
+//SinusoidalSeason builds a SeasonalModifier that swings between 1-amplitude and
+//1+amplitude and back over every period ticks, starting at 1 on tick 0.
+func SinusoidalSeason(period int, amplitude float64) func(int) float64 {
+	return func(tick int) float64 {
+		if period <= 0 {
+			return 1
+		}
+		return 1 + amplitude*math.Sin(2*math.Pi*float64(tick)/float64(period))
+	}
+}
@@ -0,0 +1,48 @@
+// GoEconGo project season_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSinusoidalSeason(t *testing.T) {
+	season := SinusoidalSeason(8, 2)
+	//Up to 3 times the harvest a quarter of the way through, and never less than
+	//the usual once it rounds below 1.
+	factors := []int{1, 2, 3, 2, 1, 1, 1, 1}
+	for tick := 0; tick < 16; tick++ {
+		want := 1 + 2*math.Sin(2*math.Pi*float64(tick)/8)
+		if got := season(tick); math.Abs(got-want) > 1e-9 {
+			t.Errorf("tick %v: modifier %v, want %v", tick, got, want)
+		}
+	}
+	food, wood := &commodity{name: "Food"}, &commodity{name: "Wood"}
+	method := &productionMethod{
+		inputs:           []commoditySet{{item: wood, quantity: 1}},
+		outputs:          []commoditySet{{item: food, quantity: 2}},
+		SeasonalModifier: season,
+	}
+	config := testConfig()
+	farmer := newTestAgent(&config, &productionSet{methods: []*productionMethod{method}, penalty: 2}, 100, map[*commodity]int{wood: 100})
+	//Over two full periods, the harvest follows the seasons.
+	for tick := 0; tick < 16; tick++ {
+		want := 2 * factors[tick%8]
+		if got := seasonalOutputs(method, tick)[0].quantity; got != want {
+			t.Errorf("tick %v: method makes %v food, want %v", tick, got, want)
+		}
+		farmer.tick = tick
+		before := farmer.inventory[food]
+		performProduction(farmer)
+		if got := farmer.inventory[food] - before; got != want {
+			t.Errorf("tick %v: farmer harvested %v food, want %v", tick, got, want)
+		}
+	}
+	if flat := SinusoidalSeason(0, 2); flat(3) != 1 {
+		t.Errorf("a season with no period modifies by %v, want 1", flat(3))
+	}
+	method.SeasonalModifier = nil
+	if got := seasonalOutputs(method, 2)[0].quantity; got != 2 {
+		t.Errorf("a method without seasons makes %v food, want 2", got)
+	}
+}
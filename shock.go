@@ -0,0 +1,108 @@
+// GoEconGo project shock.go
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+//A PriceShockEvent records a commodity's price being pushed from outside the
+//market.
+type PriceShockEvent struct {
+	CommodityName string
+	Tick          int
+	DeltaPercent  float64
+	Price         float64
+}
+
+func (e PriceShockEvent) String() string {
+	return fmt.Sprintf("Shocked %v by %v%% at tick %v, now %v", e.CommodityName, e.DeltaPercent, e.Tick, e.Price)
+}
+
+//A SupplyShockEvent records units of a commodity appearing in (or vanishing from)
+//its producers' inventories from outside the market.
+type SupplyShockEvent struct {
+	CommodityName string
+	Tick          int
+	QuantityDelta int
+}
+
+func (e SupplyShockEvent) String() string {
+	return fmt.Sprintf("Shocked the supply of %v by %v units at tick %v", e.CommodityName, e.QuantityDelta, e.Tick)
+}
+
+//InjectPriceShock moves a commodity's averagePrice, and every living agent's
+//belief of its price, by deltaPercent (-10 is a 10% drop).  Call it between ticks.
+func InjectPriceShock(sim *Simulation, commodityName string, deltaPercent float64) error {
+	m := sim.Market
+	com, ok := m.commodities[commodityName]
+	if !ok {
+		return fmt.Errorf("no commodity named %v", commodityName)
+	}
+	factor := 1 + deltaPercent/100
+	if factor <= 0 {
+		return errors.New("a price shock cannot take a price to zero or below")
+	}
+	com.averagePrice = com.averagePrice * factor
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		if belief, ok := agent.priceBelief[com]; ok {
+			agent.priceBelief[com] = priceRange{low: belief.low * factor, high: belief.high * factor}
+		}
+		agent.mu.Unlock()
+	}
+	m.Emit(PriceShockEvent{CommodityName: commodityName, Tick: m.tick, DeltaPercent: deltaPercent, Price: com.averagePrice})
+	return nil
+}
+
+//InjectSupplyShock adds units of a commodity to (or, for a negative quantityDelta,
+//takes them from) the inventories of the agents whose role produces it, one unit
+//at a time to a randomly chosen producer.  Removal stops early once no producer
+//holds any.  Call it between ticks.
+func InjectSupplyShock(sim *Simulation, commodityName string, quantityDelta int) error {
+	m := sim.Market
+	com, ok := m.commodities[commodityName]
+	if !ok {
+		return fmt.Errorf("no commodity named %v", commodityName)
+	}
+	var producers []*traderAgent
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		if m.products[agent.role] == com {
+			producers = append(producers, agent)
+		}
+		agent.mu.Unlock()
+	}
+	if len(producers) == 0 {
+		return fmt.Errorf("no living agent produces %v", commodityName)
+	}
+	rng := m.config.random()
+	moved := 0
+	for ; moved < quantityDelta; moved++ {
+		agent := producers[rng.Intn(len(producers))]
+		agent.mu.Lock()
+		adjustHolding(agent, com, 1)
+		agent.mu.Unlock()
+	}
+	for ; moved > quantityDelta; moved-- {
+		var holders []*traderAgent
+		for _, agent := range producers {
+			agent.mu.Lock()
+			if agent.inventory[com] > 0 {
+				holders = append(holders, agent)
+			}
+			agent.mu.Unlock()
+		}
+		if len(holders) == 0 {
+			break
+		}
+		agent := holders[rng.Intn(len(holders))]
+		agent.mu.Lock()
+		adjustHolding(agent, com, -1)
+		agent.mu.Unlock()
+	}
+	m.Emit(SupplyShockEvent{CommodityName: commodityName, Tick: m.tick, QuantityDelta: moved})
+	return nil
+}
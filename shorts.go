@@ -0,0 +1,79 @@
+// GoEconGo project shorts.go
+package main
+
+//generateShortAsks has the agent sell short a unit of anything it holds none of,
+//doesn't need and believes is overpriced - so expects to fall - when the market
+//allows short selling.  Nothing already sold short is sold short again until the
+//position is settled.
+func generateShortAsks(agent *traderAgent) []asks {
+	var askSlice []asks
+	if !agent.settings().AllowShortSelling {
+		return askSlice
+	}
+	needs := gatherAllRequirements(agent)
+	for com, belief := range agent.priceBelief {
+		if _, needed := needs[com]; needed || agent.inventory[com] != 0 || agent.shortPositions[com] > 0 {
+			continue
+		}
+		if com.IsExternality || com.IsCommonPool || com.permitFor != nil {
+			continue
+		}
+		expected := (belief.high + belief.low) / 2
+		if expected >= referencePrice(agent, com) {
+			continue
+		}
+		var askBuild asks
+		askBuild.numberOffered = 1
+		askBuild.offeredAsk.quantity = 1
+		askBuild.offeredAsk.item = com
+		askBuild.offeredAsk.sellFor = expected
+		askSlice = append(askSlice, askBuild)
+	}
+	return askSlice
+}
+
+//generateCoverBids has the agent bid at the top of its belief to buy back whatever
+//it is short, leaving out anything its ordinary bids already cover.
+//agent - a pointer to a traderAgent dataset
+//covered - the commodities the agent is already bidding for
+func generateCoverBids(agent *traderAgent, covered map[*commodity]int) []bids {
+	var bidSlice []bids
+	for com := range agent.shortPositions {
+		if _, ok := covered[com]; ok || agent.inventory[com] >= 0 {
+			continue
+		}
+		var bidBuild bids
+		bidBuild.numberOffered = -agent.inventory[com]
+		bidBuild.offeredBid.quantity = 1
+		bidBuild.offeredBid.item = com
+		bidBuild.offeredBid.buyFor = agent.priceBelief[com].high
+		bidSlice = append(bidSlice, bidBuild)
+	}
+	return bidSlice
+}
+
+//settleShorts settles the agent's short positions after its trades are tallied.
+//Positions that were already open going into this round had their chance to buy
+//back - whatever is still owed is charged ShortCoverCost a unit and written off.
+//Anything newly sold short opens a new position.
+//agent - a pointer to a traderAgent dataset
+//due - the short positions open going into this round
+func settleShorts(agent *traderAgent, due map[*commodity]int) {
+	for com := range due {
+		if owed := -agent.inventory[com]; owed > 0 {
+			cover := agent.settings().ShortCoverCost * float64(owed)
+			agent.funds = agent.funds - cover
+			agent.ledger.Record(agent.id, 0, cover, agent.tick, ledgerShortCover)
+			agent.inventory[com] = 0
+		}
+		delete(agent.shortPositions, com)
+	}
+	for com, num := range agent.inventory {
+		if _, ok := due[com]; num < 0 && !ok {
+			if agent.shortPositions == nil {
+				agent.shortPositions = make(map[*commodity]int)
+			}
+			agent.shortPositions[com] = -num
+		}
+	}
+}
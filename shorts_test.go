@@ -0,0 +1,60 @@
+// GoEconGo project shorts_test.go
+package main
+
+import "testing"
+
+//shortTools returns a smelter, allowed to sell short, that believes tools are
+//worth about 2 while the market has them at 5, and has just sold a unit it never
+//had for 4.
+func shortTools(t *testing.T) (*traderAgent, *commodity) {
+	t.Helper()
+	config := testConfig()
+	config.AllowShortSelling = true
+	ore, metal, tools := &commodity{name: "Ore"}, &commodity{name: "Metal"}, &commodity{name: "Tools", averagePrice: 5}
+	smelter := newTestAgent(&config, smeltingJob(ore, metal, &commodity{name: "Pollution", IsExternality: true}), 100, make(map[*commodity]int))
+	smelter.priceBelief[tools] = priceRange{low: 1, high: 3}
+	asked := generateShortAsks(smelter)
+	if len(asked) != 1 || asked[0].offeredAsk.item != tools || asked[0].numberOffered != 1 {
+		t.Fatalf("sold short %v, want a unit of tools", asked)
+	}
+	asked[0].numberAccepted = 1
+	asked[0].offeredAsk.sellFor = 4
+	agentUpdate(smelter, &asked, new([]bids))
+	if smelter.inventory[tools] != -1 || smelter.shortPositions[tools] != 1 || smelter.funds != 104 {
+		t.Fatalf("after selling short, holds %v tools short %v with %v", smelter.inventory[tools], smelter.shortPositions[tools], smelter.funds)
+	}
+	if again := generateShortAsks(smelter); len(again) != 0 {
+		t.Fatalf("sold tools short again before settling: %v", again)
+	}
+	return smelter, tools
+}
+
+func TestShortSellingProfits(t *testing.T) {
+	smelter, tools := shortTools(t)
+	//The price drops, and the smelter buys back at 1.5.
+	tools.averagePrice = 1
+	covers := generateCoverBids(smelter, nil)
+	if len(covers) != 1 || covers[0].offeredBid.item != tools || covers[0].numberOffered != 1 {
+		t.Fatalf("bid %v to cover, want a unit of tools", covers)
+	}
+	covers[0].numberAccepted = 1
+	covers[0].offeredBid.buyFor = 1.5
+	agentUpdate(smelter, new([]asks), &covers)
+	if smelter.inventory[tools] != 0 || len(smelter.shortPositions) != 0 {
+		t.Errorf("after covering, holds %v tools with positions %v", smelter.inventory[tools], smelter.shortPositions)
+	}
+	if smelter.funds != 100+4-1.5 {
+		t.Errorf("the short seller ended up with %v, want the %v of selling at 4 and buying back at 1.5", smelter.funds, 100+4-1.5)
+	}
+}
+
+func TestShortSellingUncovered(t *testing.T) {
+	smelter, tools := shortTools(t)
+	//Nobody sells it tools to cover with.
+	covers := generateCoverBids(smelter, nil)
+	agentUpdate(smelter, new([]asks), &covers)
+	cost := smelter.settings().ShortCoverCost
+	if smelter.funds != 104-cost || smelter.inventory[tools] != 0 || len(smelter.shortPositions) != 0 {
+		t.Errorf("left short, holds %v tools with %v and positions %v, want none with %v", smelter.inventory[tools], smelter.funds, smelter.shortPositions, 104-cost)
+	}
+}
@@ -0,0 +1,251 @@
+// GoEconGo project simconfig.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+//A SimConfig describes an economy - its commodities, and the roles that make them
+//- so that it can be set up from a file instead of in code.
+//Commodities - every commodity traded
+//Roles - every role agents can take
+//Seed - seeds the simulation's randomness (0, or left out, is a different run
+//every time)
+//commodityList - the commodities, built and ready to trade, by name
+type SimConfig struct {
+	Commodities   []CommoditySpec `json:"commodities"`
+	Roles         []RoleSpec      `json:"roles"`
+	Seed          int64           `json:"seed"`
+	commodityList map[string]*commodity
+}
+
+//A CommoditySpec describes a commodity.
+//Name - the commodity's name, which must be unique
+//AveragePrice - the price it starts at, which can't be negative
+//PriceSmoothing - as in commodity, between 0 and 1 (left out is the market's)
+//CarryingCostPerUnit - as in commodity, which can't be negative (left out is none)
+type CommoditySpec struct {
+	Name                string  `json:"name"`
+	AveragePrice        float64 `json:"averagePrice"`
+	PriceSmoothing      float64 `json:"priceSmoothing"`
+	CarryingCostPerUnit float64 `json:"carryingCostPerUnit"`
+}
+
+//A QuantitySpec describes a commoditySet.
+//Commodity - the name of the commodity
+//Quantity - how many units
+type QuantitySpec struct {
+	Commodity string `json:"commodity"`
+	Quantity  int    `json:"quantity"`
+}
+
+//A MethodSpec describes a productionMethod.
+//Inputs, Catalysts, Outputs, Consumption - as in productionMethod; there must be
+//one Consumption for every catalyst
+//ProductionTicks - as in productionMethod, which can't be negative (left out is
+//one tick)
+//UnlockCost - as in productionMethod, which can't be negative; a method costing
+//anything must be researched before it is used (left out is free)
+type MethodSpec struct {
+	Inputs          []QuantitySpec `json:"inputs"`
+	Catalysts       []QuantitySpec `json:"catalysts"`
+	Outputs         []QuantitySpec `json:"outputs"`
+	Consumption     []float64      `json:"consumption"`
+	ProductionTicks int            `json:"productionTicks"`
+	UnlockCost      float64        `json:"unlockCost"`
+}
+
+//A RoleSpec describes a role.
+//Name - the role's name
+//Product - the name of the commodity the role makes
+//Methods - the role's production methods
+//Penalty - what agents of the role are fined for idling
+//CohortSize - how many agents of the role to start with
+//MinFunds, MaxFunds - the range new agents' funds are drawn from (leave both 0 for
+//the role's usual funds)
+//prodSet - the role's productionSet, built from Methods and Penalty
+type RoleSpec struct {
+	Name       string       `json:"name"`
+	Product    string       `json:"product"`
+	Methods    []MethodSpec `json:"methods"`
+	Penalty    float64      `json:"penalty"`
+	CohortSize int          `json:"cohortSize"`
+	MinFunds   float64      `json:"minFunds"`
+	MaxFunds   float64      `json:"maxFunds"`
+	prodSet    *productionSet
+}
+
+//The factory for each built in role.  Any other role gets makeTrader.
+var roleFactories = map[string]func(map[string]*commodity, *productionSet, SimulationConfig) traderAgent{
+	"Farmer":     makeFarmer,
+	"Miner":      makeMiner,
+	"Refiner":    makeRefiner,
+	"Woodcutter": makeWoodcutter,
+	"Blacksmith": makeBlacksmith,
+}
+
+//LoadSimConfig reads an economy from a JSON file, checks it and builds its
+//commodities and production sets.
+func LoadSimConfig(path string) (*SimConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := new(SimConfig)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if err := config.build(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+//build checks the economy and builds its commodities and production sets.
+func (c *SimConfig) build() error {
+	c.commodityList = make(map[string]*commodity)
+	for _, spec := range c.Commodities {
+		if spec.Name == "" {
+			return errors.New("a commodity has no name")
+		}
+		if _, ok := c.commodityList[spec.Name]; ok {
+			return fmt.Errorf("commodity %v is listed more than once", spec.Name)
+		}
+		if spec.AveragePrice < 0 {
+			return fmt.Errorf("commodity %v has a negative averagePrice", spec.Name)
+		}
+		if spec.PriceSmoothing < 0 || spec.PriceSmoothing > 1 {
+			return fmt.Errorf("commodity %v has a priceSmoothing outside [0,1]", spec.Name)
+		}
+		if spec.CarryingCostPerUnit < 0 {
+			return fmt.Errorf("commodity %v has a negative carryingCostPerUnit", spec.Name)
+		}
+		com := new(commodity)
+		com.name = spec.Name
+		com.averagePrice = spec.AveragePrice
+		com.PriceSmoothing = spec.PriceSmoothing
+		com.CarryingCostPerUnit = spec.CarryingCostPerUnit
+		c.commodityList[spec.Name] = com
+	}
+	for index := range c.Roles {
+		role := &c.Roles[index]
+		if _, ok := c.commodityList[role.Product]; !ok {
+			return fmt.Errorf("role %v makes unknown commodity %v", role.Name, role.Product)
+		}
+		if role.MinFunds > role.MaxFunds {
+			return fmt.Errorf("role %v has minFunds above maxFunds", role.Name)
+		}
+		role.prodSet = new(productionSet)
+		role.prodSet.penalty = role.Penalty
+		for _, spec := range role.Methods {
+			method, err := c.buildMethod(spec)
+			if err != nil {
+				return fmt.Errorf("role %v: %v", role.Name, err)
+			}
+			if method.UnlockCost > 0 {
+				role.prodSet.research = append(role.prodSet.research, method)
+			} else {
+				role.prodSet.methods = append(role.prodSet.methods, method)
+			}
+		}
+	}
+	return nil
+}
+
+//buildMethod builds a productionMethod from its description.
+func (c *SimConfig) buildMethod(spec MethodSpec) (*productionMethod, error) {
+	if len(spec.Consumption) != len(spec.Catalysts) {
+		return nil, errors.New("a method needs exactly one consumption for each catalyst")
+	}
+	if spec.ProductionTicks < 0 {
+		return nil, errors.New("a method can't take a negative number of ticks")
+	}
+	if spec.UnlockCost < 0 {
+		return nil, errors.New("a method can't have a negative unlock cost")
+	}
+	method := new(productionMethod)
+	var err error
+	if method.inputs, err = c.buildSets(spec.Inputs); err != nil {
+		return nil, err
+	}
+	if method.catalysts, err = c.buildSets(spec.Catalysts); err != nil {
+		return nil, err
+	}
+	if method.outputs, err = c.buildSets(spec.Outputs); err != nil {
+		return nil, err
+	}
+	method.consumption = spec.Consumption
+	method.ProductionTicks = spec.ProductionTicks
+	method.UnlockCost = spec.UnlockCost
+	return method, nil
+}
+
+//buildSets builds commoditySets from their descriptions.
+func (c *SimConfig) buildSets(specs []QuantitySpec) ([]commoditySet, error) {
+	var sets []commoditySet
+	for _, spec := range specs {
+		com, ok := c.commodityList[spec.Commodity]
+		if !ok {
+			return nil, fmt.Errorf("unknown commodity %v", spec.Commodity)
+		}
+		sets = append(sets, commoditySet{item: com, quantity: spec.Quantity})
+	}
+	return sets, nil
+}
+
+//CommodityList returns the economy's commodities, by name.
+func (c *SimConfig) CommodityList() map[string]*commodity {
+	return c.commodityList
+}
+
+//ProductionSet returns a role's productionSet, or nil if there is no such role.
+func (c *SimConfig) ProductionSet(role string) *productionSet {
+	for _, spec := range c.Roles {
+		if spec.Name == role {
+			return spec.prodSet
+		}
+	}
+	return nil
+}
+
+//Populate teaches a market every role of the economy and spawns each role's
+//cohort, and any speculators and market makers configured.  The market must trade the economy's CommodityList.
+func (c *SimConfig) Populate(m *Market) {
+	c.addRoles(m)
+	for _, spec := range c.Roles {
+		for i := 0; i < spec.CohortSize; i++ {
+			m.spawn(spec.Name)
+		}
+	}
+	m.addSpeculators(m.config.Speculators, m.config.SpeculatorLookback)
+	m.addMarketMakers(m.config.MarketMakers, m.config.MarketMakerSpread)
+}
+
+//addRoles teaches a market every role of the economy.
+func (c *SimConfig) addRoles(m *Market) {
+	for _, spec := range c.Roles {
+		spec := spec
+		factory, ok := roleFactories[spec.Name]
+		if !ok {
+			factory = func(commodityList map[string]*commodity, prodSet *productionSet, config SimulationConfig) traderAgent {
+				return makeTrader(spec.Name, commodityList, prodSet, config)
+			}
+		}
+		if spec.MaxFunds > 0 {
+			m.config.InitHooks[spec.Name] = append(m.config.InitHooks[spec.Name],
+				func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+					agent.funds = spec.MinFunds + config.random().Float64()*(spec.MaxFunds-spec.MinFunds)
+				})
+		}
+		m.addRole(spec.Name, c.commodityList[spec.Product], spec.prodSet, func() traderAgent {
+			agent := factory(c.commodityList, spec.prodSet, m.config)
+			//Built in roles are granted goods by name - drop any this economy
+			//doesn't have.
+			delete(agent.inventory, nil)
+			return agent
+		})
+	}
+}
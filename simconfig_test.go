@@ -0,0 +1,55 @@
+// GoEconGo project simconfig_test.go
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSimConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		economy string
+		valid   bool
+	}{
+		{"the test economy", testEconomy, true},
+		{"catalysts without consumption", `{
+			"commodities": [{"name": "Food", "averagePrice": 3}, {"name": "Tools", "averagePrice": 3}],
+			"roles": [{"name": "Farmer", "product": "Food", "methods": [
+				{"outputs": [{"commodity": "Food", "quantity": 2}], "catalysts": [{"commodity": "Tools", "quantity": 1}]}
+			]}]
+		}`, false},
+		{"a duplicate commodity", `{
+			"commodities": [{"name": "Food", "averagePrice": 3}, {"name": "Food", "averagePrice": 4}],
+			"roles": [{"name": "Farmer", "product": "Food"}]
+		}`, false},
+		{"a negative averagePrice", `{
+			"commodities": [{"name": "Food", "averagePrice": -3}],
+			"roles": [{"name": "Farmer", "product": "Food"}]
+		}`, false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "economy.json")
+		if err := ioutil.WriteFile(path, []byte(test.economy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadSimConfig(path)
+		if test.valid && err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v loaded without an error", test.name)
+		}
+	}
+}
+
+func TestPopulateLeavesConfigHooks(t *testing.T) {
+	config := testConfig()
+	economy := testSimConfig(t, 1)
+	economy.Roles[0].MinFunds, economy.Roles[0].MaxFunds = 50, 100
+	simulateEconomy(t, config, economy)
+	if hooks := len(config.InitHooks[economy.Roles[0].Name]); hooks != 0 {
+		t.Errorf("populating a market added %v hooks to its config", hooks)
+	}
+}
@@ -0,0 +1,105 @@
+// GoEconGo project simulation.go
+package main
+
+import "sync"
+
+//A Simulation is a market together with everything running on it.
+//Market - the market being simulated
+//Economy - the economy the market was set up from, if it was set up from one
+//Equilibrium - checked after every tick RunTicks runs (nil is never)
+//StopAtEquilibrium - whether RunTicks stops as soon as Equilibrium is reached
+//mu - held while the market ticks, so that it can be changed safely in between
+//(see Do)
+//paused - whether ticking is held off (see Pause)
+//resumed - signalled when the simulation is resumed
+type Simulation struct {
+	Market            *Market
+	Economy           *SimConfig
+	Equilibrium       *EquilibriumDetector
+	StopAtEquilibrium bool
+	mu                sync.Mutex
+	paused            bool
+	resumed           *sync.Cond
+}
+
+//newSimulation wraps a set up market in a Simulation.
+func newSimulation(market *Market) *Simulation {
+	sim := new(Simulation)
+	sim.Market = market
+	sim.resumed = sync.NewCond(&sim.mu)
+	market.sim = sim
+	return sim
+}
+
+//Do runs a function between ticks, holding off the next tick until it is done.
+//Anything changing a running simulation from outside should go through it.
+func (sim *Simulation) Do(fn func()) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	fn()
+}
+
+//Pause holds off ticking until Resume is called.  RunTicks waits; the real time
+//ticker skips its ticks.
+func (sim *Simulation) Pause() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.paused = true
+}
+
+//Resume lets a paused simulation tick again.
+func (sim *Simulation) Resume() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.paused = false
+	sim.resumed.Broadcast()
+}
+
+//Paused reports whether the simulation is paused.
+func (sim *Simulation) Paused() bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	return sim.paused
+}
+
+//Step ticks the market once, unless the simulation is paused.
+//Returns whether it ticked.
+func (sim *Simulation) Step() bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.paused {
+		return false
+	}
+	sim.Market.Tick()
+	return true
+}
+
+//stepWhenResumed ticks the market once, waiting first for a paused simulation to
+//be resumed.
+func (sim *Simulation) stepWhenResumed() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	for sim.paused {
+		sim.resumed.Wait()
+	}
+	sim.Market.Tick()
+}
+
+//RunTicks runs a simulation for exactly n ticks, as fast as it can.  Unlike the
+//real time ticker, every tick waits for every agent to send its offers and get
+//its results, so no agent ever misses a tick and no clock is involved - and with a
+//Seed configured, the same market runs the same way every time.  Once a market has
+//been run this way it stays synchronous.
+//A paused simulation waits to be resumed before carrying on.
+//Returns the market's tick when it stopped - the tick equilibrium was reached on,
+//if it stopped early.
+func RunTicks(n int, sim *Simulation) int {
+	sim.Market.synchronize()
+	for i := 0; i < n; i++ {
+		sim.stepWhenResumed()
+		if sim.Equilibrium != nil && sim.Equilibrium.Check(sim.Market) && sim.StopAtEquilibrium {
+			break
+		}
+	}
+	return sim.Market.tick
+}
@@ -0,0 +1,43 @@
+// GoEconGo project simulation_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+//seededRun runs the test economy for 50 ticks under a seed, and returns every
+//commodity's price history and the population it ended with.
+func seededRun(t *testing.T, seed int64) (map[string][]float64, map[string]int) {
+	config := testConfig()
+	config.Seed = seed
+	sim := newTestSimulation(t, config, 5)
+	if tick := RunTicks(50, sim); tick != 50 {
+		t.Fatalf("ran to tick %v, want 50", tick)
+	}
+	prices := make(map[string][]float64)
+	for name, com := range sim.Market.commodities {
+		prices[name] = com.PriceHistory(50)
+	}
+	population := make(map[string]int)
+	for role, count := range sim.Market.population {
+		population[role] = count
+	}
+	return prices, population
+}
+
+func TestRunTicksDeterministic(t *testing.T) {
+	prices, population := seededRun(t, 3)
+	for run := 0; run < 3; run++ {
+		again, againPopulation := seededRun(t, 3)
+		if !reflect.DeepEqual(prices, again) {
+			t.Fatalf("run %v of seed 3 priced %v, want %v", run+2, again, prices)
+		}
+		if !reflect.DeepEqual(population, againPopulation) {
+			t.Fatalf("run %v of seed 3 ended with %v, want %v", run+2, againPopulation, population)
+		}
+	}
+	if other, _ := seededRun(t, 4); reflect.DeepEqual(prices, other) {
+		t.Errorf("seeds 3 and 4 ran exactly the same")
+	}
+}
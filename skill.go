@@ -0,0 +1,36 @@
+// GoEconGo project skill.go
+package main
+
+import "math"
+
+//How much more skilled an agent gets at a method each time it runs it.
+const skillGain = 0.01
+
+//The most skilled an agent can get at a method.
+const maxSkill = 1.0
+
+//skilledOutputs returns what a production method makes for an agent - its
+//seasonalOutputs, multiplied by 1 + the agent's skill at the method times the
+//configured SkillMultiplier, rounded to whole units.
+func skilledOutputs(agent *traderAgent, method *productionMethod) []commoditySet {
+	outputs := seasonalOutputs(method, agent.tick)
+	bonus := agent.skill[method] * agent.settings().SkillMultiplier
+	if bonus == 0 {
+		return outputs
+	}
+	skilled := make([]commoditySet, len(outputs))
+	for index, output := range outputs {
+		skilled[index] = output
+		skilled[index].quantity = int(math.Round(float64(output.quantity) * (1 + bonus)))
+	}
+	return skilled
+}
+
+//practise makes an agent a little more skilled at a method it has just run, up to
+//maxSkill.
+func practise(agent *traderAgent, method *productionMethod) {
+	if agent.skill == nil {
+		agent.skill = make(map[*productionMethod]float64)
+	}
+	agent.skill[method] = math.Min(maxSkill, agent.skill[method]+skillGain)
+}
@@ -0,0 +1,191 @@
+// GoEconGo project slots.go
+package main
+
+import "fmt"
+
+//hasRoom reports whether a role can take on another agent under its RoleSlots.
+func (m *Market) hasRoom(role string) bool {
+	slots, capped := m.config.RoleSlots[role]
+	return !capped || m.population[role] < slots
+}
+
+//fillSlot replaces the dead agent on a set of channels with an agent of the
+//preferred role.  A capped role's open slot goes to auction among agents of less
+//valuable roles, and the winner's old role is what fills the dead agent's
+//channels.  If the preferred role is full, the most valuable role with room is
+//made instead.
+//slot - the channels of the dead agent
+//role - the role the market would like to make
+func (m *Market) fillSlot(slot uint64, role string) {
+	if !m.hasRoom(role) {
+		role = m.nextRoleWithRoom()
+		if role == "" {
+			return
+		}
+	}
+	if _, capped := m.config.RoleSlots[role]; capped {
+		if _, price, oldRole := m.auctionRoleSlot(role); price >= 0 {
+			//The winner moved up and left its old role one short.
+			role = oldRole
+		}
+	}
+	m.respawn(slot, role)
+}
+
+//nextRoleWithRoom returns the role with room to spare whose product is worth the
+//most, or "" if every role is full.
+func (m *Market) nextRoleWithRoom() string {
+	best := ""
+	for _, role := range m.roleOrder {
+		product := m.products[role]
+		if !m.hasRoom(role) {
+			continue
+		}
+		if best == "" || product.averagePrice > m.products[best].averagePrice {
+			best = role
+		}
+	}
+	return best
+}
+
+//slotBid is an agent's sealed bid for a slot in a more valuable role: what it
+//believes one unit of the new role's product is worth over its own, for each
+//production cycle it looks ahead (riskAversion).  Nobody bids more than half of
+//what they have.
+func slotBid(agent *traderAgent, target *commodity, current *commodity) float64 {
+	targetBelief := agent.priceBelief[target]
+	currentBelief := agent.priceBelief[current]
+	bid := ((targetBelief.high+targetBelief.low)/2 - (currentBelief.high+currentBelief.low)/2) * float64(agent.riskAversion)
+	if bid > agent.funds/2 {
+		bid = agent.funds / 2
+	}
+	return bid
+}
+
+//AuctionRoleSlot auctions a slot in a capped role.  Every agent in a role whose
+//product is worth less than the slot's role puts in a sealed bid, and the highest
+//bidder pays its bid once to switch roles.  The winner keeps its channels, id,
+//inventory and beliefs, and respawns in the new role with its funds less the bid,
+//raising a SlotAuctionEvent.  If nobody bids, there is no winner and the price is
+//-1.
+//role - the role whose slot is up for auction
+func (m *Market) AuctionRoleSlot(role string) (winnerID uint64, price float64) {
+	winnerID, price, _ = m.auctionRoleSlot(role)
+	return winnerID, price
+}
+
+//auctionRoleSlot runs AuctionRoleSlot, and also returns the role the winner left.
+func (m *Market) auctionRoleSlot(role string) (winnerID uint64, price float64, oldRole string) {
+	target := m.products[role]
+	price = -1
+	var winnerSlot uint64
+	//Walk the agents in order so that ties always go the same way.
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		current, ok := m.products[agent.role]
+		if !ok || current.averagePrice >= target.averagePrice {
+			continue
+		}
+		agent.mu.Lock()
+		bid := slotBid(agent, target, current)
+		agent.mu.Unlock()
+		if bid > 0 && bid > price {
+			winnerSlot, price = slot, bid
+		}
+	}
+	if price < 0 {
+		return 0, -1, ""
+	}
+
+	//Stop the winner, then pack it up and move it into its new role.
+	winner := m.agents[winnerSlot]
+	m.stop(winnerSlot)
+	winner.mu.Lock()
+	promoted := m.roles[role]()
+	promoted.id = winner.id
+	promoted.funds = winner.funds - price
+	promoted.inventory = cQMapConcat(make(map[*commodity]int), winner.inventory)
+	promoted.permits = cQMapConcat(make(map[*commodity]int), winner.permits)
+	promoted.priceBelief = make(map[*commodity]priceRange)
+	for com, pr := range winner.priceBelief {
+		promoted.priceBelief[com] = pr
+	}
+	oldRole = winner.role
+	//The winner pays for the slot and takes the rest of its funds with it, so its
+	//account stays open and there is nothing left for it to retire with.
+	m.Ledger.Record(winner.id, 0, price, m.tick, ledgerSlotAuction)
+	winner.funds = 0
+	winner.mu.Unlock()
+	m.retire(winnerSlot)
+	m.respawnAgent(winnerSlot, promoted)
+	m.Emit(SlotAuctionEvent{Role: role, OldRole: oldRole, WinnerID: winner.id, Tick: m.tick, Price: price})
+	return winner.id, price, oldRole
+}
+
+//A SlotAuctionEvent records an agent winning a slot in a capped role at auction.
+type SlotAuctionEvent struct {
+	Role     string
+	OldRole  string
+	WinnerID uint64
+	Tick     int
+	Price    float64
+}
+
+func (e SlotAuctionEvent) String() string {
+	return fmt.Sprintf("%v %v won a %v slot at tick %v for %v", e.OldRole, e.WinnerID, e.Role, e.Tick, e.Price)
+}
+
+//A RoleSlotEvent records a role being given another slot because the price of
+//its product ran high.
+type RoleSlotEvent struct {
+	Role          string
+	CommodityName string
+	Tick          int
+	Slots         int
+}
+
+func (e RoleSlotEvent) String() string {
+	return fmt.Sprintf("Opened a %v slot at tick %v on high %v prices, now %v", e.Role, e.Tick, e.CommodityName, e.Slots)
+}
+
+//expandRoleSlots looks for commodities whose price has been more than
+//ExpansionTriggerSigmas standard deviations above its rolling mean for
+//ExpansionTriggerTicks ticks in a row, and opens another slot (up to MaxRoleSlots)
+//in each capped role making them, in order of commodity name.
+func (m *Market) expandRoleSlots() {
+	if !m.config.AutoExpandRoleSlots {
+		return
+	}
+	for _, com := range m.sortedCommodities() {
+		history := com.PriceHistory(anomalyWindow + 2)
+		if len(history) < 3 {
+			continue
+		}
+		//Judge this tick's price against the window before it.
+		window := history[:len(history)-1]
+		if len(window) > anomalyWindow {
+			window = window[len(window)-anomalyWindow:]
+		}
+		mean, stdDev := meanStdDev(window)
+		if com.averagePrice <= mean+m.config.ExpansionTriggerSigmas*stdDev {
+			m.highPriceTicks[com] = 0
+			continue
+		}
+		m.highPriceTicks[com]++
+		if m.highPriceTicks[com] < m.config.ExpansionTriggerTicks {
+			continue
+		}
+		m.highPriceTicks[com] = 0
+		for _, role := range m.roleOrder {
+			product := m.products[role]
+			slots, capped := m.config.RoleSlots[role]
+			if product != com || !capped || slots >= m.config.MaxRoleSlots {
+				continue
+			}
+			m.config.RoleSlots[role] = slots + 1
+			event := RoleSlotEvent{Role: role, CommodityName: com.name, Tick: m.tick, Slots: slots + 1}
+			m.statistics.RoleSlotExpansionEvents = append(m.statistics.RoleSlotExpansionEvents, event)
+			m.Emit(event)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// GoEconGo project slots_test.go
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRoleSlotAuction(t *testing.T) {
+	config := testConfig()
+	config.RoleSlots = map[string]int{"Blacksmith": 3}
+	economy := testSimConfig(t, 4)
+	setCohort(economy, "Blacksmith", 3)
+	sim := simulateEconomy(t, config, economy)
+	RunTicks(1, sim)
+	m := sim.Market
+	//Let every agent make its next offers, so none is trading while prices move.
+	m.holdOffers()
+	tools := m.products["Blacksmith"]
+	//Tools are worth the most, so a dead agent is replaced with a blacksmith, and
+	//one farmer reckons a blacksmith's slot is worth far more than anyone else does.
+	tools.averagePrice = 100
+	favourite := agentsOf(m, "Farmer")[1]
+	favourite.mu.Lock()
+	favourite.funds = 1000
+	favourite.riskAversion = 5
+	favourite.priceBelief[tools] = priceRange{low: 50, high: 60}
+	want := slotBid(favourite, tools, m.products["Farmer"])
+	favouriteFunds := favourite.funds
+	favourite.mu.Unlock()
+	auctions := recordEvents[SlotAuctionEvent](m)
+
+	if err := m.KillAgent(agentsOf(m, "Blacksmith")[0].id); err != nil {
+		t.Fatal(err)
+	}
+	if len(*auctions) != 1 {
+		t.Fatalf("killing a blacksmith ran %v auctions, want 1", len(*auctions))
+	}
+	auction := (*auctions)[0]
+	if auction.WinnerID != favourite.id || auction.Price != want || auction.OldRole != "Farmer" {
+		t.Errorf("%v won at %v, want farmer %v at %v", auction.WinnerID, auction.Price, favourite.id, want)
+	}
+	winner, _, ok := m.agentByID(auction.WinnerID)
+	if !ok {
+		t.Fatalf("winner %v isn't running", auction.WinnerID)
+	}
+	winner.mu.Lock()
+	if winner.role != "Blacksmith" || winner.funds != favouriteFunds-want {
+		t.Errorf("winner is a %v with %v, want a Blacksmith with %v", winner.role, winner.funds, favouriteFunds-want)
+	}
+	winner.mu.Unlock()
+	if blacksmiths := len(agentsOf(m, "Blacksmith")); blacksmiths != 3 {
+		t.Errorf("%v blacksmiths after the auction, want all 3 slots filled", blacksmiths)
+	}
+	RunTicks(1, sim)
+}
+
+func TestExpandRoleSlots(t *testing.T) {
+	config := testConfig()
+	config.RoleSlots = map[string]int{"Farmer": 10}
+	sim := newTestSimulation(t, config, 10)
+	m := sim.Market
+	//Let every agent make its first offers, so none is trading while prices move.
+	m.holdOffers()
+	food := m.products["Farmer"]
+	//price sets food's price for a tick, and closes it.
+	price := func(p float64) {
+		food.averagePrice = p
+		m.recordPrices()
+		m.expandRoleSlots()
+	}
+	for i := 0; i < anomalyWindow; i++ {
+		price(3 + 0.1*math.Sin(float64(i)))
+	}
+	//Food runs high for one tick too few, then comes back down.
+	for i := 0; i < config.ExpansionTriggerTicks-1; i++ {
+		price(30 * math.Pow(2, float64(i)))
+	}
+	price(3)
+	if slots := m.config.RoleSlots["Farmer"]; slots != 10 {
+		t.Fatalf("%v ticks of high food prices opened farmer slots up to %v", config.ExpansionTriggerTicks-1, slots)
+	}
+	for i := 0; i < anomalyWindow; i++ {
+		price(3 + 0.1*math.Sin(float64(i)))
+	}
+	for i := 0; i < config.ExpansionTriggerTicks; i++ {
+		price(30 * math.Pow(2, float64(i)))
+	}
+	if slots := m.config.RoleSlots["Farmer"]; slots != 11 {
+		t.Errorf("%v ticks of high food prices left %v farmer slots, want 11", config.ExpansionTriggerTicks, slots)
+	}
+	if slots := config.RoleSlots["Farmer"]; slots != 10 {
+		t.Errorf("expanding the market's farmer slots left the config with %v, want 10", slots)
+	}
+	events := m.statistics.RoleSlotExpansionEvents
+	if len(events) != 1 || events[0].Role != "Farmer" || events[0].CommodityName != "Food" || events[0].Slots != 11 {
+		t.Errorf("recorded expansions %v, want one of Farmer to 11 slots", events)
+	}
+}
+
+func TestExpandRoleSlotsInNameOrder(t *testing.T) {
+	config := testConfig()
+	config.RoleSlots = map[string]int{"Woodcutter": 10, "Farmer": 10, "Miner": 10}
+	sim := newTestSimulation(t, config, 10)
+	m := sim.Market
+	m.holdOffers()
+	products := []*commodity{m.products["Woodcutter"], m.products["Farmer"], m.products["Miner"]}
+	//price sets the price of every product for a tick, and closes it.
+	price := func(p float64) {
+		for _, com := range products {
+			com.averagePrice = p
+		}
+		m.recordPrices()
+		m.expandRoleSlots()
+	}
+	for round := 1; round <= 5; round++ {
+		for i := 0; i < anomalyWindow; i++ {
+			price(3 + 0.1*math.Sin(float64(i)))
+		}
+		for i := 0; i < config.ExpansionTriggerTicks; i++ {
+			price(30 * math.Pow(2, float64(i)))
+		}
+	}
+	var names []string
+	for _, event := range m.statistics.RoleSlotExpansionEvents {
+		names = append(names, event.CommodityName)
+	}
+	want := strings.Repeat("Food Ore Wood ", 5)
+	if got := strings.Join(names, " ") + " "; got != want {
+		t.Errorf("expanded slots for %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+// GoEconGo project speculator.go
+package main
+
+//The role speculators go by.
+const speculatorRole = "Speculator"
+
+//How many ticks of prices speculators look back over, unless the SimulationConfig
+//says otherwise.
+const defaultSpeculatorLookback = 5
+
+//makeSpeculator makes an agent that never produces - it has no job - but buys
+//whatever is rising and sells whatever is falling.
+//lookbackTicks - how many ticks the moving average it follows spans
+func makeSpeculator(commodityList map[string]*commodity, lookbackTicks int, config SimulationConfig) traderAgent {
+	var speculatorOut traderAgent
+	speculatorOut.role = speculatorRole
+	speculatorOut.funds = 50 + (config.random().Float64() * 50)
+	speculatorOut.inventory = make(map[*commodity]int)
+	speculatorOut.priceBelief = initialPriceBelief(commodityList, config.BeliefInitSpread)
+	speculatorOut.riskAversion = config.random().Intn(4) + 1
+	speculatorOut.beliefAdjustBig, speculatorOut.beliefAdjustSmall = config.beliefAdjustRates(speculatorOut.role)
+	speculatorOut.maxInventory = config.maxInventory(speculatorOut.role)
+	speculatorOut.maxAge = config.lifespan()
+	speculatorOut.lookbackTicks = lookbackTicks
+	runInitHooks(&speculatorOut, commodityList, config)
+	return speculatorOut
+}
+
+//addSpeculators launches n speculators on new channels.  They are not a role the
+//market refills: a dead speculator's slot goes to a producer.
+func (m *Market) addSpeculators(n int, lookbackTicks int) {
+	for i := 0; i < n; i++ {
+		m.add(makeSpeculator(m.commodities, lookbackTicks, m.config))
+	}
+}
+
+//momentum compares a commodity's moving average over the last lookbackTicks
+//closing prices with the same average a tick earlier.  It is positive when the
+//price is rising, negative when it is falling, and 0 without enough history.
+func momentum(com *commodity, lookbackTicks int) float64 {
+	if lookbackTicks < 1 {
+		return 0
+	}
+	history := com.PriceHistory(lookbackTicks + 1)
+	if len(history) < lookbackTicks+1 {
+		return 0
+	}
+	now, _ := meanStdDev(history[1:])
+	before, _ := meanStdDev(history[:lookbackTicks])
+	return now - before
+}
+
+//speculates reports whether speculators trade a commodity at all - they leave
+//alone whatever can't be bought and sold like goods.
+func speculates(com *commodity) bool {
+	return !com.IsExternality && !com.IsCommonPool && com.permitFor == nil
+}
+
+//generateSpeculatorAsks has a speculator sell everything it holds of each
+//commodity whose price is falling, at the middle of its price belief.
+func generateSpeculatorAsks(agent *traderAgent) []asks {
+	var askSlice []asks
+	for _, com := range sortedHoldings(agent) {
+		if !speculates(com) || momentum(com, agent.lookbackTicks) >= 0 {
+			continue
+		}
+		var askBuild asks
+		askBuild.numberOffered = agent.inventory[com]
+		askBuild.offeredAsk.quantity = 1
+		askBuild.offeredAsk.item = com
+		askBuild.offeredAsk.sellFor = (agent.priceBelief[com].high + agent.priceBelief[com].low) / 2
+		askSlice = append(askSlice, askBuild)
+	}
+	return askSlice
+}
+
+//generateSpeculatorBids has a speculator buy a unit of each commodity whose price
+//is rising, at the middle of its price belief, for as long as its funds cover it.
+func generateSpeculatorBids(agent *traderAgent) []bids {
+	var bidSlice []bids
+	funds := agent.funds
+	for _, com := range sortedBeliefs(agent) {
+		if !speculates(com) || momentum(com, agent.lookbackTicks) <= 0 {
+			continue
+		}
+		price := (agent.priceBelief[com].high + agent.priceBelief[com].low) / 2
+		if price <= 0 || price > funds {
+			continue
+		}
+		funds = funds - price
+		var bidBuild bids
+		bidBuild.numberOffered = 1
+		bidBuild.offeredBid.quantity = 1
+		bidBuild.offeredBid.item = com
+		bidBuild.offeredBid.buyFor = price
+		bidSlice = append(bidSlice, bidBuild)
+	}
+	return bidSlice
+}
+
+//sortedHoldings returns every commodity an agent holds any of, in alphabetical
+//order.
+func sortedHoldings(agent *traderAgent) []*commodity {
+	held := make(map[*commodity]bool)
+	for com, num := range agent.inventory {
+		if num > 0 {
+			held[com] = true
+		}
+	}
+	return sortedComs(held)
+}
+
+//sortedBeliefs returns every commodity an agent has a price belief of, in
+//alphabetical order.
+func sortedBeliefs(agent *traderAgent) []*commodity {
+	known := make(map[*commodity]bool)
+	for com := range agent.priceBelief {
+		known[com] = true
+	}
+	return sortedComs(known)
+}
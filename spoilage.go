@@ -0,0 +1,84 @@
+// GoEconGo project spoilage.go
+package main
+
+//A SpoilageHandler decides what becomes of an agent's spoiled goods.  It is
+//called with the agent locked.
+type SpoilageHandler interface {
+	Handle(agent *traderAgent, com *commodity, quantity int, m *Market)
+}
+
+//DiscardSpoilage throws spoiled units away.
+type DiscardSpoilage struct{}
+
+//Handle removes the spoiled units from the agent's inventory.
+func (DiscardSpoilage) Handle(agent *traderAgent, com *commodity, quantity int, m *Market) {
+	agent.inventory[com] = agent.inventory[com] - quantity
+}
+
+//EmergencySellSpoilage dumps spoiled units on the market at a tenth of the lowest
+//price the agent believes they fetch.  They go up with the agent's next asks, and
+//whatever doesn't sell stays in stock, where it may spoil again.
+type EmergencySellSpoilage struct{}
+
+//Handle queues a deeply discounted ask for the spoiled units.
+func (EmergencySellSpoilage) Handle(agent *traderAgent, com *commodity, quantity int, m *Market) {
+	var askBuild asks
+	askBuild.numberOffered = quantity
+	askBuild.offeredAsk.quantity = 1
+	askBuild.offeredAsk.item = com
+	askBuild.offeredAsk.sellFor = agent.priceBelief[com].low * 0.1
+	agent.spoiledAsks = append(agent.spoiledAsks, askBuild)
+}
+
+//CompostSpoilage turns spoiled units into another commodity.
+//Target - the name of the commodity spoiled units become
+//Rate - how many units of Target each spoiled unit becomes (rounded down)
+type CompostSpoilage struct {
+	Target string
+	Rate   float64
+}
+
+//Handle swaps the spoiled units for their compost.
+func (c CompostSpoilage) Handle(agent *traderAgent, com *commodity, quantity int, m *Market) {
+	agent.inventory[com] = agent.inventory[com] - quantity
+	target, ok := m.commodities[c.Target]
+	if !ok {
+		return
+	}
+	agent.inventory[target] = agent.inventory[target] + int(float64(quantity)*c.Rate)
+}
+
+//spoilageHandler returns the handler for a commodity's spoiled units.
+func (m *Market) spoilageHandler(com *commodity) SpoilageHandler {
+	if handler, ok := m.config.SpoilageHandlers[com.name]; ok {
+		return handler
+	}
+	return DiscardSpoilage{}
+}
+
+//spoil rolls every unit every agent holds against its commodity's SpoilageRate
+//and hands whatever spoils to that commodity's SpoilageHandler.
+func (m *Market) spoil() {
+	coms := m.sortedCommodities()
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		spoiled := make(map[*commodity]int)
+		for _, com := range coms {
+			if com.SpoilageRate <= 0 {
+				continue
+			}
+			for i := 0; i < agent.inventory[com]; i++ {
+				if m.config.random().Float64() < com.SpoilageRate {
+					spoiled[com]++
+				}
+			}
+		}
+		for com, num := range spoiled {
+			if num > 0 {
+				m.spoilageHandler(com).Handle(agent, com, num, m)
+			}
+		}
+		agent.mu.Unlock()
+	}
+}
@@ -0,0 +1,44 @@
+// GoEconGo project spoilage_test.go
+package main
+
+import "testing"
+
+func TestEmergencySellSpoilage(t *testing.T) {
+	config := testConfig()
+	config.SpoilageHandlers["Ore"] = EmergencySellSpoilage{}
+	m, smelter, _, _ := smeltingMarket(config)
+	ore := m.commodities["Ore"]
+	//Everything spoils.  The smelter needs its ore, so it only sells what spoiled.
+	ore.SpoilageRate = 1
+	smelter.id = newAgentID()
+	smelter.priceBelief[ore] = priceRange{low: 2, high: 4}
+	m.spoil()
+	if smelter.inventory[ore] != 10 {
+		t.Fatalf("selling off spoiled ore left %v, want all 10 until it sells", smelter.inventory[ore])
+	}
+	m.collected = make(map[uint64]*traderAgent)
+	m.fileAsks(0, generateAsks(smelter))
+	book := m.asksTyped[ore]
+	if len(book) != 1 {
+		t.Fatalf("%v ore asks in the next book, want 1", len(book))
+	}
+	if got := book[0].offeredAsk; got.id != smelter.id || got.sellFor != 0.2 || book[0].numberOffered != 10 {
+		t.Errorf("next book asks %v for %v ore from agent %v, want 0.2 for 10 from %v", got.sellFor, book[0].numberOffered, got.id, smelter.id)
+	}
+	if len(generateAsks(smelter)) != 0 {
+		t.Errorf("spoiled ore went up for sale twice")
+	}
+}
+
+func TestDiscardSpoilage(t *testing.T) {
+	m, smelter, _, _ := smeltingMarket(testConfig())
+	ore := m.commodities["Ore"]
+	ore.SpoilageRate = 1
+	m.spoil()
+	if smelter.inventory[ore] != 0 {
+		t.Errorf("%v ore left after it all spoiled", smelter.inventory[ore])
+	}
+	if asks := generateAsks(smelter); len(asks) != 0 {
+		t.Errorf("discarded ore went up for sale as %v", asks)
+	}
+}
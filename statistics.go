@@ -0,0 +1,115 @@
+// GoEconGo project statistics.go
+package main
+
+//MarketStatistics gathers the measurements the market takes of itself each tick.
+//BeliefConvergence - the spread of agents' price beliefs, by commodity name (see
+//BeliefConvergence)
+//BeliefWidth - how wide agents' price beliefs are, by commodity name (see
+//BeliefWidth)
+//Commodities - the measurements of each commodity's market, by commodity name
+//TotalExternalityCost - everything agents have paid for externalities so far
+//CarbonTaxRevenue - all the carbon tax producers have paid so far
+//AntiMonopolyInterventions - how many times competitors have been spawned to break
+//up a monopoly
+//RoleSlotExpansionEvents - every time high prices opened up another role slot
+type MarketStatistics struct {
+	BeliefConvergence         map[string]float64
+	BeliefWidth               map[string]float64
+	Commodities               map[string]CommodityStats
+	TotalExternalityCost      float64
+	CarbonTaxRevenue          float64
+	AntiMonopolyInterventions int
+	RoleSlotExpansionEvents   []RoleSlotEvent
+}
+
+//CommodityStats gathers the measurements taken of a single commodity's market.
+//DepthWeightedMidPrice - the DepthWeightedMid of the order book before clearing
+//PermitPrice - the average price of the commodity's production permits
+//ProducerSurplus - how much more sellers got this tick than they asked for
+//ConsumerSurplus - how much less buyers paid this tick than they bid
+//MeanSpread, MaxSpread - the mean and largest of the last spreadWindow spreads
+//(see SpreadHistory)
+type CommodityStats struct {
+	DepthWeightedMidPrice float64
+	PermitPrice           float64
+	ProducerSurplus       float64
+	ConsumerSurplus       float64
+	MeanSpread            float64
+	MaxSpread             float64
+}
+
+//Statistics returns the measurements taken at the end of the last tick.
+func (m *Market) Statistics() MarketStatistics {
+	return m.statistics
+}
+
+//updateStatistics takes this tick's measurements of the market.
+func (m *Market) updateStatistics() {
+	agents := m.snapshotAgents()
+	m.statistics.BeliefConvergence = make(map[string]float64)
+	m.statistics.BeliefWidth = make(map[string]float64)
+	for name, com := range m.commodities {
+		m.statistics.BeliefConvergence[name] = BeliefConvergence(agents, com)
+		if latest := com.BeliefWidthHistory(1); len(latest) > 0 {
+			m.statistics.BeliefWidth[name] = latest[0]
+		}
+		stats := m.statistics.Commodities[name]
+		if com.permit != nil {
+			stats.PermitPrice = com.permit.averagePrice
+		}
+		stats.MeanSpread, stats.MaxSpread = com.SpreadStats(spreadWindow)
+		m.statistics.Commodities[name] = stats
+	}
+}
+
+//snapshotAgents returns a copy of every running agent, taken under each agent's
+//lock so that it can be examined while the agents carry on trading.
+func (m *Market) snapshotAgents() []traderAgent {
+	agents := make([]traderAgent, 0, len(m.agents))
+	for _, agent := range m.agents {
+		agent.mu.Lock()
+		snapshot := *agent
+		snapshot.inventory = make(map[*commodity]int)
+		for com, num := range agent.inventory {
+			snapshot.inventory[com] = num
+		}
+		snapshot.priceBelief = make(map[*commodity]priceRange)
+		for com, pr := range agent.priceBelief {
+			snapshot.priceBelief[com] = pr
+		}
+		agent.mu.Unlock()
+		agents = append(agents, snapshot)
+	}
+	return agents
+}
+
+//BeliefConvergence measures how much agents agree on the price of a commodity.
+//It is the standard deviation of the midpoint of every agent's price belief.
+//Agents with no belief of the commodity are left out.  Lower is more agreement.
+//agents - the agents to measure
+//com - a pointer to the commodity to measure
+func BeliefConvergence(agents []traderAgent, com *commodity) float64 {
+	var midpoints []float64
+	for _, agent := range agents {
+		pr, ok := agent.priceBelief[com]
+		if !ok {
+			continue
+		}
+		midpoints = append(midpoints, (pr.high+pr.low)/2)
+	}
+	_, stdDev := meanStdDev(midpoints)
+	return stdDev
+}
+
+//recordSurplus adds a matched trade's gains to the sellers and buyers of a
+//commodity to this tick's producer and consumer surplus.
+//askPrice - what the seller asked
+//bidPrice - what the buyer bid
+//price - what they traded at
+//quantity - how many units traded
+func (m *Market) recordSurplus(com *commodity, askPrice, bidPrice, price float64, quantity int) {
+	stats := m.statistics.Commodities[com.name]
+	stats.ProducerSurplus += (price - askPrice) * float64(quantity)
+	stats.ConsumerSurplus += (bidPrice - price) * float64(quantity)
+	m.statistics.Commodities[com.name] = stats
+}
@@ -0,0 +1,60 @@
+// GoEconGo project statistics_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBeliefConvergence(t *testing.T) {
+	food := &commodity{name: "Food", averagePrice: 3}
+	wood := &commodity{name: "Wood", averagePrice: 3}
+	believer := func(low, high float64) traderAgent {
+		return traderAgent{priceBelief: map[*commodity]priceRange{food: {low: low, high: high}}}
+	}
+	//Midpoints of 1, 3 and 5, and a woodcutter who has never thought about food.
+	agents := []traderAgent{believer(0, 2), believer(2, 4), believer(4, 6), {priceBelief: map[*commodity]priceRange{wood: {low: 2, high: 4}}}}
+	if got, want := BeliefConvergence(agents, food), math.Sqrt(8.0/3); math.Abs(got-want) > 1e-9 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := BeliefConvergence(agents, wood); got != 0 {
+		t.Errorf("one belief of wood gave %v, want 0", got)
+	}
+	if got := BeliefConvergence([]traderAgent{believer(2, 4), believer(1, 5)}, food); got != 0 {
+		t.Errorf("beliefs sharing a midpoint gave %v, want 0", got)
+	}
+}
+
+func TestBeliefConvergenceTrading(t *testing.T) {
+	config := testConfig()
+	//Every agent the market starts with has its own idea of every price, anywhere
+	//from 0.5 to 10.5.  Agents joining later start out believing the market.
+	scatter := newRandom(12)
+	scattering := true
+	for _, spec := range testSimConfig(t, 0).Roles {
+		config.InitHooks[spec.Name] = []AgentInitHook{func(agent *traderAgent, commodities map[string]*commodity, config SimulationConfig) {
+			if !scattering {
+				return
+			}
+			for _, name := range sortedKeys(commodities) {
+				com := commodities[name]
+				if _, ok := agent.priceBelief[com]; !ok {
+					continue
+				}
+				mid := 0.5 + 10*scatter.Float64()
+				agent.priceBelief[com] = priceRange{low: mid * 0.85, high: mid * 1.15}
+			}
+		}}
+	}
+	sim := newTestSimulation(t, config, 10)
+	scattering = false
+	food := sim.Market.commodities["Food"]
+	fresh := BeliefConvergence(sim.Market.snapshotAgents(), food)
+	if fresh <= 2 {
+		t.Fatalf("scattered beliefs of food measure %v, want above 2", fresh)
+	}
+	RunTicks(100, sim)
+	if traded := BeliefConvergence(sim.Market.snapshotAgents(), food); traded >= 0.5 {
+		t.Errorf("100 ticks of trading took beliefs of food from %v to %v, want them below 0.5", fresh, traded)
+	}
+}
@@ -0,0 +1,299 @@
+// GoEconGo project stream.go
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//How many ticks of updates a streaming client may fall behind before it is
+//dropped.
+const streamClientBuffer = 16
+
+//How many of the wealthiest agents each tick's update lists.
+const streamTopAgents = 10
+
+//The GUID every WebSocket handshake hashes the client's key with (RFC 6455).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+//A TickUpdate is what a streaming client is sent at the end of every tick.
+//Tick - the tick that just ended
+//Prices - each commodity's averagePrice, by name
+//Volumes - how many units of each commodity traded, by name
+//Population - how many agents of each role are alive, by role
+//TopAgents - the wealthiest agents, richest first
+type TickUpdate struct {
+	Tick       int                `json:"tick"`
+	Prices     map[string]float64 `json:"prices"`
+	Volumes    map[string]int     `json:"volumes"`
+	Population map[string]int     `json:"population"`
+	TopAgents  []AgentWealth      `json:"topAgents"`
+}
+
+//An AgentWealth is an agent's standing in a TickUpdate.
+//ID - the agent's own id (see newAgentID)
+//Role - the agent's role
+//NetWorth - the agent's NetWorth
+type AgentWealth struct {
+	ID       uint64  `json:"id"`
+	Role     string  `json:"role"`
+	NetWorth float64 `json:"netWorth"`
+}
+
+//newTickUpdate takes a market's measurements at the end of a tick.
+func newTickUpdate(m *Market) TickUpdate {
+	update := TickUpdate{Tick: m.tick}
+	update.Prices = make(map[string]float64)
+	update.Volumes = make(map[string]int)
+	for name, com := range m.commodities {
+		update.Prices[name] = com.averagePrice
+		update.Volumes[name] = com.TickVolume
+	}
+	update.Population = make(map[string]int)
+	for role, count := range m.population {
+		update.Population[role] = count
+	}
+	agents := m.snapshotAgents()
+	for i := range agents {
+		update.TopAgents = append(update.TopAgents, AgentWealth{ID: agents[i].id, Role: agents[i].role, NetWorth: NetWorth(&agents[i])})
+	}
+	sort.Slice(update.TopAgents, func(i, j int) bool {
+		if update.TopAgents[i].NetWorth != update.TopAgents[j].NetWorth {
+			return update.TopAgents[i].NetWorth > update.TopAgents[j].NetWorth
+		}
+		return update.TopAgents[i].ID < update.TopAgents[j].ID
+	})
+	if len(update.TopAgents) > streamTopAgents {
+		update.TopAgents = update.TopAgents[:streamTopAgents]
+	}
+	return update
+}
+
+//A streamClient is one WebSocket connection to a StreamHub.
+//conn - the connection
+//send - the updates waiting to be written to it
+type streamClient struct {
+	conn net.Conn
+	send chan []byte
+}
+
+//A StreamHub hands every tick's update to every connected streaming client.  A
+//client too slow to keep up with its buffer is dropped rather than holding up the
+//market.
+//mu - guards clients
+//clients - every connected client
+type StreamHub struct {
+	mu      sync.Mutex
+	clients map[*streamClient]bool
+}
+
+//newStreamHub builds a hub with nobody connected.
+func newStreamHub() *StreamHub {
+	hub := new(StreamHub)
+	hub.clients = make(map[*streamClient]bool)
+	return hub
+}
+
+//Clients returns how many clients are connected.
+func (h *StreamHub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+//add connects a client.
+func (h *StreamHub) add(client *streamClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[client] = true
+}
+
+//remove disconnects a client, if it is still connected.  Its writer finishes up
+//and closes the connection.
+func (h *StreamHub) remove(client *streamClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+//Broadcast queues a message for every client, dropping any whose buffer is full.
+func (h *StreamHub) Broadcast(message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client.send <- message:
+		default:
+			delete(h.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+//publish sends every client a market's update for the tick just ended.
+func (h *StreamHub) publish(m *Market) {
+	if h.Clients() == 0 {
+		return
+	}
+	message, err := json.Marshal(newTickUpdate(m))
+	if err != nil {
+		return
+	}
+	h.Broadcast(message)
+}
+
+//ServeHTTP upgrades a request to a WebSocket and streams updates down it until
+//the client goes away or falls behind.
+func (h *StreamHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebsocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client := &streamClient{conn: conn, send: make(chan []byte, streamClientBuffer)}
+	h.add(client)
+	go client.writeUpdates()
+	go func() {
+		client.discardFrames()
+		h.remove(client)
+	}()
+}
+
+//writeUpdates writes each queued update to the client as a text frame, closing the
+//connection once the client has been removed or can't be written to.
+func (c *streamClient) writeUpdates() {
+	defer c.conn.Close()
+	for message := range c.send {
+		if err := writeFrame(c.conn, 0x1, message); err != nil {
+			return
+		}
+	}
+	writeFrame(c.conn, 0x8, nil)
+}
+
+//discardFrames reads, and ignores, whatever the client sends until it closes the
+//connection.
+func (c *streamClient) discardFrames() {
+	reader := bufio.NewReader(c.conn)
+	for {
+		opcode, err := skipFrame(reader)
+		if err != nil || opcode == 0x8 {
+			return
+		}
+	}
+}
+
+//upgradeWebsocket answers a WebSocket opening handshake and takes over the
+//request's connection.
+//Returns an error, having written nothing, if the request isn't a WebSocket
+//handshake.
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		return nil, errNotWebsocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errNotWebsocket
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buffered.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n")
+	if err := buffered.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+//errNotWebsocket is returned for requests that aren't WebSocket handshakes.
+var errNotWebsocket = errors.New("expected a WebSocket handshake")
+
+//writeFrame writes a single, final, unmasked frame, as servers send them.
+//opcode - what the frame holds (0x1 is text, 0x8 is close)
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+//skipFrame reads past a frame a client sent.
+//Returns the frame's opcode.
+func skipFrame(r *bufio.Reader) (byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return 0, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	//Clients mask everything they send.
+	if header[1]&0x80 != 0 {
+		length += 4
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+		return 0, err
+	}
+	return header[0] & 0x0F, nil
+}
+
+//StartStreamingServer streams every tick of a simulation to WebSocket clients
+//connected to /stream, in the background, as a JSON TickUpdate.  Shut the returned
+//server down to stop it.
+//Returns an error if addr can't be listened on.
+//addr - the address to listen on, as in net.Listen ("localhost:8080")
+func StartStreamingServer(addr string, sim *Simulation) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	hub := newStreamHub()
+	sim.Market.OnTick(hub.publish)
+	mux := http.NewServeMux()
+	mux.Handle("/stream", hub)
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	return server, nil
+}
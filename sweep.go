@@ -0,0 +1,165 @@
+// GoEconGo project sweep.go
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+//How many ticks of prices, and how steady, a sweep's runs look for to declare
+//equilibrium (see EquilibriumDetector).
+const (
+	sweepEquilibriumWindow  = 10
+	sweepEquilibriumEpsilon = 0.01
+)
+
+//A SweepResult is how one run of a ParameterSweep turned out.
+//Params - the parameters the run was given, by SimulationConfig field name
+//Prices - every commodity's final averagePrice, by name
+//Gini - the GiniCoefficient of agents' funds at the end
+//EquilibriumTick - the tick the market first reached equilibrium on (0 is never)
+//Err - why the run couldn't be made, if it couldn't
+type SweepResult struct {
+	Params          map[string]interface{}
+	Prices          map[string]float64
+	Gini            float64
+	EquilibriumTick int
+	Err             error
+}
+
+//ParameterSweep runs an economy once for every combination of parameters, each
+//for ticks ticks with RunTicks, spread across every CPU.  Every run starts from
+//the default SimulationConfig, with the economy's seed unless Seed is swept.
+//baseConfig - the economy to run
+//sweepParams - the values to try, by the name of the SimulationConfig field they
+//set; numbers are converted to the field's type
+//Returns a result for every combination, in order of the parameters' names with
+//the last name's values changing fastest.
+func ParameterSweep(baseConfig SimConfig, sweepParams map[string][]interface{}, ticks int) []SweepResult {
+	combinations := sweepCombinations(sweepParams)
+	results := make([]SweepResult, len(combinations))
+	runs := make(chan int)
+	var workers sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range runs {
+				results[i] = runSweep(baseConfig, combinations[i], ticks)
+			}
+		}()
+	}
+	for i := range combinations {
+		runs <- i
+	}
+	close(runs)
+	workers.Wait()
+	return results
+}
+
+//sweepCombinations returns the Cartesian product of every parameter's values.
+//A parameter with no values to try leaves nothing to run.
+func sweepCombinations(sweepParams map[string][]interface{}) []map[string]interface{} {
+	names := make([]string, 0, len(sweepParams))
+	for name := range sweepParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	combinations := []map[string]interface{}{{}}
+	for _, name := range names {
+		var extended []map[string]interface{}
+		for _, combination := range combinations {
+			for _, value := range sweepParams[name] {
+				params := make(map[string]interface{}, len(combination)+1)
+				for k, v := range combination {
+					params[k] = v
+				}
+				params[name] = value
+				extended = append(extended, params)
+			}
+		}
+		combinations = extended
+	}
+	return combinations
+}
+
+//setConfigField sets a SimulationConfig field by name.
+//Returns an error if there is no such field, or the value doesn't suit it.
+func setConfigField(config *SimulationConfig, name string, value interface{}) error {
+	field := reflect.ValueOf(config).Elem().FieldByName(name)
+	if !field.IsValid() || !field.CanSet() {
+		return fmt.Errorf("no SimulationConfig field named %v", name)
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		return fmt.Errorf("no value given for %v", name)
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case isNumber(v.Kind()) && isNumber(field.Kind()):
+		field.Set(v.Convert(field.Type()))
+	default:
+		return fmt.Errorf("%v can't be set to a %v", name, v.Type())
+	}
+	return nil
+}
+
+//isNumber reports whether a kind of value is an integer or a float.
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+//runSweep sets up a fresh copy of an economy with the given parameters, runs it
+//and measures how it turned out.
+func runSweep(baseConfig SimConfig, params map[string]interface{}, ticks int) SweepResult {
+	result := SweepResult{Params: params}
+	//Every run trades its own commodities and production sets.
+	economy := baseConfig
+	economy.Roles = append([]RoleSpec(nil), baseConfig.Roles...)
+	if err := economy.build(); err != nil {
+		result.Err = err
+		return result
+	}
+	config := defaultSimulationConfig()
+	for _, name := range sortedKeys(params) {
+		if err := setConfigField(&config, name, params[name]); err != nil {
+			result.Err = err
+			return result
+		}
+	}
+	if config.Seed == 0 {
+		config.Seed = economy.Seed
+	}
+	addPermits(economy.CommodityList())
+	market := newMarket(economy.CommodityList(), config)
+	economy.Populate(market)
+	market.distributePermits()
+	sim := newSimulation(market)
+	sim.Economy = &economy
+	sim.Equilibrium = newEquilibriumDetector(sweepEquilibriumWindow, sweepEquilibriumEpsilon)
+	sim.Equilibrium.OnEquilibrium(func(tick int, prices map[string]float64) {
+		if result.EquilibriumTick == 0 {
+			result.EquilibriumTick = tick
+		}
+	})
+	RunTicks(ticks, sim)
+
+	result.Prices = make(map[string]float64)
+	for name, com := range market.commodities {
+		result.Prices[name] = com.averagePrice
+	}
+	result.Gini = GiniCoefficient(market.snapshotAgents())
+	if err := market.Shutdown(); err != nil {
+		result.Err = err
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+// GoEconGo project switching.go
+package main
+
+import "sort"
+
+//A RoleSwitch is one change of trade an agent made.
+//Tick - the tick it switched on
+//From - the role it gave up
+//To - the role it took on
+type RoleSwitch struct {
+	Tick int
+	From string
+	To   string
+}
+
+//bestMarketValue is the getMarketValue of the most valuable method in a
+//productionSet.
+func bestMarketValue(job *productionSet) float64 {
+	best := 0.0
+	for index, method := range job.methods {
+		if value := getMarketValue(method); index == 0 || value > best {
+			best = value
+		}
+	}
+	return best
+}
+
+//switchRole moves a struggling agent into whichever role's productionSet is worth
+//the most at market prices, if that is worth more than its own.  The switch is
+//recorded in the agent's RoleSwitches.
+//available - every role's productionSet, by role
+//Returns whether the agent switched.
+func switchRole(agent *traderAgent, available map[string]*productionSet) bool {
+	//Speculators have no trade to give up.
+	if agent.job == nil {
+		return false
+	}
+	roles := make([]string, 0, len(available))
+	for role := range available {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	bestRole := agent.role
+	bestValue := bestMarketValue(agent.job)
+	for _, role := range roles {
+		if value := bestMarketValue(available[role]); value > bestValue {
+			bestRole, bestValue = role, value
+		}
+	}
+	if bestRole == agent.role {
+		return false
+	}
+	agent.RoleSwitches = append(agent.RoleSwitches, RoleSwitch{Tick: agent.tick, From: agent.role, To: bestRole})
+	agent.role = bestRole
+	agent.job = available[bestRole]
+	//A new trade is learnt from scratch
+	agent.skill = nil
+	return true
+}
+
+//struggling reports whether an agent's funds have fallen below the configured
+//SwitchThreshold share of what it started with, without running out.
+func struggling(agent *traderAgent) bool {
+	return agent.funds > 0 && agent.funds < agent.settings().SwitchThreshold*agent.startingFunds
+}
+
+//recountRoles moves agents that have switched roles to their new role's
+//population.
+func (m *Market) recountRoles() {
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		role := agent.role
+		agent.mu.Unlock()
+		if counted := m.countedAs[slot]; counted != role {
+			m.population[counted]--
+			m.population[role]++
+			m.countedAs[slot] = role
+		}
+	}
+}
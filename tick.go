@@ -0,0 +1,533 @@
+// GoEconGo project tick.go
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+)
+
+//Tick runs the market through one full round of trading: it collects every
+//agent's offers, clears each commodity's books, does the end of tick bookkeeping,
+//sends every agent its results and replaces any agents that died.  A market that
+//has been shut down no longer ticks.
+func (m *Market) Tick() {
+	ctx, end := m.span(context.Background(), "market.tick")
+	defer end()
+	m.tickIn(ctx)
+}
+
+//tickIn runs a Tick under the span ctx carries, which the market's own spans and
+//its agents' spans open under.
+func (m *Market) tickIn(ctx context.Context) {
+	if m.ctx.Err() != nil {
+		return
+	}
+	m.beginTick(ctx)
+
+	_, endCollect := m.span(ctx, "market.collect")
+	m.collect()
+	endCollect()
+
+	for _, com := range m.sortedCommodities() {
+		_, endClear := m.span(ctx, "market.clear."+com.name)
+		m.clear(com)
+		endClear()
+	}
+	m.futures.MatchFutures()
+	m.runMonetaryPolicy()
+	m.bookkeeping()
+
+	_, endDispatch := m.span(ctx, "market.dispatch")
+	m.dispatch()
+	endDispatch()
+	//A synchronous market replaces the dead as it collects, in slot order, so that
+	//runs repeat exactly.
+	if !m.synchronous {
+		m.reapDead()
+	}
+	m.populationHistory.record(m.population)
+	m.metrics.update(m)
+	for _, hook := range m.tickHooks {
+		hook(m)
+	}
+}
+
+//collect receives the offers of every agent that has sent them, builds and sorts
+//the books, and takes a look at them before anything trades.
+func (m *Market) collect() {
+	//RECEIVE ALL THE ASKS AND BIDS
+
+	//Check all the ask channels
+	for com, _ := range m.asksTyped {
+		m.asksTyped[com] = nil
+	}
+	for com, _ := range m.bidsTyped {
+		m.bidsTyped[com] = nil
+	}
+	m.futures.asks = nil
+	m.futures.bids = nil
+	m.collected = make(map[uint64]*traderAgent)
+	if m.synchronous {
+		for chindex := range m.askChannels {
+			if offers, ok := m.awaitOffers(chindex); ok {
+				m.fileAsks(chindex, offers.asks)
+				m.fileBids(chindex, offers.bids)
+			}
+		}
+		m.held = nil
+	} else {
+		m.collectFannedIn()
+	}
+
+	m.recountRoles()
+	m.dropCancelled()
+
+	fmt.Println("Total Asks Types: ", len(m.asksTyped))
+	fmt.Println("Total Bids Types: ", len(m.bidsTyped))
+
+	//The books were kept in order as they filled.
+	for com, asksCom := range m.asksTyped {
+		fmt.Printf("Asks for %v: %v\n", com.name, len(asksCom))
+	}
+	for com, bidsCom := range m.bidsTyped {
+		fmt.Printf("Bids for %v: %v\n", com.name, len(bidsCom))
+	}
+	//Take a look at the books before anything trades, in order of name so alerts
+	//are raised in the same order every time
+	for _, com := range m.sortedCommodities() {
+		if _, ok := m.asksTyped[com]; !ok {
+			continue
+		}
+		m.recordDepth(com, newMarketDepth(topAsks(m.asksTyped[com], depthLevels), topBids(m.bidsTyped[com], depthLevels)))
+		m.DetectOligopoly(com, m.config.OligopolyAgentThreshold, m.config.OligopolyShareThreshold)
+	}
+	m.estimateCurves()
+	m.CheckMonopoly()
+}
+
+//clear matches a commodity's asks against its bids, executing clearing trades,
+//and moves the commodity's average price towards what traded.  A tick nothing
+//trades in leaves the average where it was.  Asks below the commodity's PriceFloor
+//and bids above its PriceCeiling are passed over.  The best ask and bid are taken
+//off the books' heaps as they match, so only what trades pays for being put in
+//order.
+func (m *Market) clear(com *commodity) {
+	//Comparison: Lowest Ask to Highest Bid
+	asksBook := m.asksTyped[com]
+	bidsBook := m.bidsTyped[com]
+	//Everything taken off the books, in the order it came off.
+	var asksCom []*asks
+	var bidsCom []*bids
+	//continue to match them, executing clearing trades as we go.
+	totalTransactions := 0
+	trades := 0
+	var runningTotal float64
+	runningTotal = 0.0
+	if len(asksBook) > 0 && len(bidsBook) > 0 {
+		//How far apart are the books?  Overlapping books will trade.
+		com.spreadRing.push(math.Max(asksBook[0].offeredAsk.sellFor-bidsBook[0].offeredBid.buyFor, 0))
+	}
+	//while both bids and asks have remaining individuals
+	for len(asksBook) > 0 && len(bidsBook) > 0 {
+		//Price controls: nobody may sell below the floor or buy above the ceiling.
+		//Market orders take the other side's price, which is held to them already.
+		if com.PriceFloor > 0 && !asksBook[0].offeredAsk.marketOrder && asksBook[0].offeredAsk.sellFor < com.PriceFloor {
+			asksCom = append(asksCom, heap.Pop(&asksBook).(*asks))
+			continue
+		}
+		if com.PriceCeiling > 0 && !bidsBook[0].offeredBid.marketOrder && bidsBook[0].offeredBid.buyFor > com.PriceCeiling {
+			bidsCom = append(bidsCom, heap.Pop(&bidsBook).(*bids))
+			continue
+		}
+		//Make sure prices are still acceptable - are there bids greater than asks in existance?
+		//Market orders come off the books first, and take any price.
+		if !asksBook[0].offeredAsk.marketOrder && !bidsBook[0].offeredBid.marketOrder &&
+			asksBook[0].offeredAsk.sellFor > bidsBook[0].offeredBid.buyFor {
+			break
+		}
+		ask := heap.Pop(&asksBook).(*asks)
+		bid := heap.Pop(&bidsBook).(*bids)
+		asksQuantityRemaining := ask.numberOffered - ask.numberAccepted
+		bidsQuantityRemaining := bid.numberOffered - bid.numberAccepted
+		//We're in business then - keep rollin'.
+		var filled int
+		if asksQuantityRemaining >= bidsQuantityRemaining {
+			ask.numberAccepted += bidsQuantityRemaining
+			bid.numberAccepted = bid.numberOffered
+			filled = bidsQuantityRemaining
+			totalTransactions += bid.numberAccepted
+			//Split off a new ask with the remaining bit (since we need to communicate
+			//back our price), and put it back on the book.
+			if split := splitAsk([]*asks{ask}, 0); len(split) > 1 {
+				ask = split[0]
+				heap.Push(&asksBook, split[1])
+			}
+		} else {
+			//OK, more bids than asks instead.
+			bid.numberAccepted += asksQuantityRemaining
+			ask.numberAccepted = ask.numberOffered
+			filled = asksQuantityRemaining
+			totalTransactions += ask.numberAccepted
+			//Split off a new bid with the remaining bit (since we need to communicate
+			//back our price), and put it back on the book.
+			if split := splitBid([]*bids{bid}, 0); len(split) > 1 {
+				bid = split[0]
+				heap.Push(&bidsBook, split[1])
+			}
+		}
+		price := m.executionPrice(com, ask, bid)
+		//A market order gives up no surplus - it asked for whatever it got.
+		askPrice, bidPrice := ask.offeredAsk.sellFor, bid.offeredBid.buyFor
+		if ask.offeredAsk.marketOrder {
+			askPrice = price
+		}
+		if bid.offeredBid.marketOrder {
+			bidPrice = price
+		}
+		ask.offeredAsk.sellFor = price
+		bid.offeredBid.buyFor = price
+		runningTotal += price * float64(filled)
+		m.recordSurplus(com, askPrice, bidPrice, price, filled)
+		m.applyMarketImpact(com, ask, bid, asksQuantityRemaining, bidsQuantityRemaining)
+		m.chargeFee(ask, bid, price, filled)
+		asksCom = append(asksCom, ask)
+		bidsCom = append(bidsCom, bid)
+		trades++
+	}
+	//Keep the split books, so every part hears its result.
+	asksCom = append(asksCom, asksBook...)
+	bidsCom = append(bidsCom, bidsBook...)
+	m.asksTyped[com] = asksCom
+	m.bidsTyped[com] = bidsCom
+	m.recordVolume(com, totalTransactions)
+	m.recordBidFill(com, bidsCom)
+	m.recordAsked(com, asksCom)
+	m.metrics.countTrades(trades, runningTotal)
+	if totalTransactions != 0 {
+		alpha := m.priceSmoothing(com)
+		newPrice := alpha*(runningTotal/float64(totalTransactions)) + (1-alpha)*com.averagePrice
+		//The trades stand either way, but a runaway price is not made public.
+		if !m.tripsCircuit(com, newPrice) {
+			com.averagePrice = newPrice
+		}
+	} else {
+		fmt.Printf("No transactions of %v!\n", com.name)
+	}
+}
+
+//bookkeeping keeps track of where prices are going, watches for odd movements and
+//settles everything else that happens at the end of a tick.
+func (m *Market) bookkeeping() {
+	m.enforcePriceLimits()
+	m.recordPrices()
+	m.recordBeliefWidth()
+	m.recordVelocity()
+	m.DetectPriceAnomalies(anomalyThreshold)
+	m.CheckConcentration()
+	m.CheckElasticity()
+	m.checkRecalibration()
+	m.chargeExternalities()
+	m.collectCarbonTax()
+	m.expandRoleSlots()
+	m.reproduce()
+	m.updateStatistics()
+	m.postBarterOffers()
+	m.barter.MatchBarters()
+	m.futures.Deliver()
+	m.spoil()
+}
+
+//dispatch sends every agent the results of its offers.  Agents that cancelled
+//their orders have stopped listening, so whatever of theirs traded is settled for
+//them (see settleCancelled).
+func (m *Market) dispatch() {
+	//OK! Market Cleared.  Communicate results
+	fmt.Println("Market Cleared!")
+	m.takeCancels()
+	for index, askChannel := range m.askChannels {
+		owner := m.idAt(uint64(index))
+		var asksOut []asks
+		//Search the results for matching results to send on the channel
+		for _, com := range m.sortedCommodities() {
+			for _, asksTest := range m.asksTyped[com] {
+				if asksTest.offeredAsk.id == owner {
+					asksOut = append(asksOut, *asksTest)
+				}
+			}
+		}
+		for _, asksTest := range m.futures.asks {
+			if asksTest.offeredAsk.id == owner {
+				asksOut = append(asksOut, *asksTest)
+			}
+		}
+		if m.cancelledAt(uint64(index)) {
+			m.settleCancelled(uint64(index), asksOut, nil)
+			continue
+		}
+		//Only the agents whose offers were taken are waiting on results.
+		if m.heardFrom(index) {
+			select {
+			case askChannel <- asksOut:
+				m.recordDelivery(uint64(index), asksOut, nil)
+			case <-m.deadChannels[index]:
+				//It gave up waiting on us.
+				m.settleCancelled(uint64(index), asksOut, nil)
+				m.replaceDead(index)
+			}
+		}
+	}
+	fmt.Println("Done sending over askChannels")
+	m.takeCancels()
+
+	for index, bidChannel := range m.bidChannels {
+		owner := m.idAt(uint64(index))
+		var bidsOut []bids
+		//Search the results for matching results to send on the channel
+		for _, com := range m.sortedCommodities() {
+			for _, bidsTest := range m.bidsTyped[com] {
+				if bidsTest.offeredBid.id == owner {
+					bidsOut = append(bidsOut, *bidsTest)
+				}
+			}
+		}
+		for _, bidsTest := range m.futures.bids {
+			if bidsTest.offeredBid.id == owner {
+				bidsOut = append(bidsOut, *bidsTest)
+			}
+		}
+		if m.cancelledAt(uint64(index)) {
+			m.settleCancelled(uint64(index), nil, bidsOut)
+			continue
+		}
+		if m.heardFrom(index) {
+			select {
+			case bidChannel <- bidsOut:
+				m.recordDelivery(uint64(index), nil, bidsOut)
+				//It has its results, so its next offers can come in.
+				if m.offers != nil {
+					m.offers.resume(index)
+				}
+			case <-m.deadChannels[index]:
+				//It gave up waiting on us.
+				m.settleCancelled(uint64(index), nil, bidsOut)
+				m.replaceDead(index)
+			}
+		}
+	}
+	m.cancelled = make(map[uint64]bool)
+}
+
+//reapDead deregisters every agent that has run out of money and fills its slot
+//with an agent making whatever commodity is most expensive.
+func (m *Market) reapDead() {
+	for chindex, channel := range m.deadChannels {
+		select {
+		case <-channel:
+			m.replaceDead(chindex)
+		default:
+			//fmt.Println("No Deads on %v", chindex)
+		}
+	}
+}
+
+//A DeathEvent records an agent dying.
+type DeathEvent struct {
+	AgentID  uint64
+	Role     string
+	Tick     int
+	NetWorth float64
+	Reason   string
+}
+
+func (e DeathEvent) String() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%v %v died at tick %v worth %v", e.Role, e.AgentID, e.Tick, e.NetWorth)
+	}
+	return fmt.Sprintf("%v %v died at tick %v worth %v (%v)", e.Role, e.AgentID, e.Tick, e.NetWorth, e.Reason)
+}
+
+//replaceDead deregisters a dead agent, raising a DeathEvent, and fills its slot
+//with whatever the configured ResurrectionStrategy makes.
+func (m *Market) replaceDead(chindex int) {
+	var dead traderAgent
+	if agent, ok := m.agents[uint64(chindex)]; ok {
+		agent.mu.Lock()
+		dead = *agent
+		worth := NetWorth(agent)
+		agent.mu.Unlock()
+		m.Ledger.Record(dead.id, 0, dead.funds, m.tick, ledgerDeath)
+		m.Emit(DeathEvent{AgentID: dead.id, Role: dead.role, Tick: m.tick, NetWorth: worth, Reason: dead.deathReason})
+	}
+	m.deregister(uint64(chindex))
+	m.metrics.countDeath()
+	if len(m.roleOrder) == 0 {
+		return
+	}
+	m.placeAgent(uint64(chindex), m.config.resurrection().Resurrect(dead, m.simulation()))
+}
+
+//fileAsks adds the asks an agent sent to the ask books.
+func (m *Market) fileAsks(chindex int, offered []asks) {
+	//fmt.Println("Got an *[]asks on ", chindex)
+	agent := m.agents[uint64(chindex)]
+	m.collected[uint64(chindex)] = agent
+	for _, asksIn := range offered {
+		//Add them to the ask book
+		asksIn.offeredAsk.id = agent.id
+		if asksIn.offeredAsk.deliveryTick > 0 {
+			m.futures.asks = append(m.futures.asks, &asksIn)
+			continue
+		}
+		book := m.asksTyped[asksIn.offeredAsk.item]
+		heap.Push(&book, &asksIn)
+		m.asksTyped[asksIn.offeredAsk.item] = book
+	}
+}
+
+//fileBids adds the bids an agent sent to the bid books.
+func (m *Market) fileBids(chindex int, offered []bids) {
+	//fmt.Println("Got a *[]bids on %v", chindex)
+	agent := m.agents[uint64(chindex)]
+	for _, bidsIn := range offered {
+		//Add them to the bids book
+		bidsIn.offeredBid.id = agent.id
+		if bidsIn.offeredBid.deliveryTick > 0 {
+			m.futures.bids = append(m.futures.bids, &bidsIn)
+			continue
+		}
+		book := m.bidsTyped[bidsIn.offeredBid.item]
+		heap.Push(&book, &bidsIn)
+		m.bidsTyped[bidsIn.offeredBid.item] = book
+	}
+}
+
+//pendingOffers are an agent's offers for a tick, made but not yet traded.
+//asks, bids - the offers
+//dead - whether the agent died instead of making any
+type pendingOffers struct {
+	asks []asks
+	bids []bids
+	dead bool
+}
+
+//heldOffers are offers the market has taken ahead of their tick.
+//agent - the agent that made them
+//offers - the offers
+type heldOffers struct {
+	agent  *traderAgent
+	offers pendingOffers
+}
+
+//awaitOffers waits for an agent's offers, replacing the agent if it dies instead.
+//Offers already held for the agent (see holdOffers) are taken first.  Returns
+//false if the slot has been emptied.
+func (m *Market) awaitOffers(chindex int) (pendingOffers, bool) {
+	id := uint64(chindex)
+	if held, ok := m.held[id]; ok && held.agent == m.agents[id] {
+		delete(m.held, id)
+		if !held.offers.dead {
+			return held.offers, true
+		}
+		m.replaceDead(chindex)
+	}
+	for m.askChannels[chindex] != nil {
+		offers := m.takeOffers(chindex)
+		if !offers.dead {
+			return offers, true
+		}
+		m.replaceDead(chindex)
+	}
+	return pendingOffers{}, false
+}
+
+//collectFannedIn takes the offers of every agent that has sent them since they
+//were last taken, without waiting for the rest.  They come in through a fan-in,
+//started the first time, so agents that haven't sent any cost nothing.
+func (m *Market) collectFannedIn() {
+	if m.offers == nil {
+		offers := newFanIn(m.ctx, m.askChannels, len(m.askChannels)+1)
+		m.OnShutdown(func() {
+			//Let any forwarder still passing offers on finish
+			go func() {
+				for range offers.C {
+				}
+			}()
+		})
+		m.offers = offers
+	}
+	for waiting := len(m.offers.C); waiting > 0; waiting-- {
+		in := <-m.offers.C
+		if in.source != m.askChannels[in.index] {
+			//The agent that sent these is gone
+			continue
+		}
+		//Bids follow straight after
+		select {
+		case offered := <-m.bidChannels[in.index]:
+			m.fileAsks(in.index, in.value)
+			m.fileBids(in.index, offered)
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+//synchronize has every tick wait for every agent from now on (see RunTicks).  A
+//market that has been ticking in real time stops its fan-in, holding the offers
+//it had taken until the next tick collects them.
+func (m *Market) synchronize() {
+	m.synchronous = true
+	if m.offers == nil {
+		return
+	}
+	m.offers.stop()
+	if m.held == nil {
+		m.held = make(map[uint64]heldOffers)
+	}
+	for in := range m.offers.C {
+		if in.source != m.askChannels[in.index] {
+			continue
+		}
+		slot := uint64(in.index)
+		offers := pendingOffers{asks: in.value, bids: <-m.bidChannels[in.index]}
+		m.held[slot] = heldOffers{agent: m.agents[slot], offers: offers}
+	}
+	m.offers = nil
+}
+
+//takeOffers waits for the agent in a slot to send its offers, or to die.
+func (m *Market) takeOffers(chindex int) pendingOffers {
+	var offers pendingOffers
+	select {
+	case offers.asks = <-m.askChannels[chindex]:
+		//Bids follow straight after
+		offers.bids = <-m.bidChannels[chindex]
+	case <-m.deadChannels[chindex]:
+		offers.dead = true
+	}
+	return offers
+}
+
+//holdOffers waits for every agent to make its offers for the next tick, and holds
+//them until that tick collects them.  Until then every agent sits still.
+func (m *Market) holdOffers() {
+	if m.held == nil {
+		m.held = make(map[uint64]heldOffers)
+	}
+	for chindex := range m.askChannels {
+		id := uint64(chindex)
+		if _, ok := m.held[id]; ok || m.askChannels[chindex] == nil {
+			continue
+		}
+		m.held[id] = heldOffers{agent: m.agents[id], offers: m.takeOffers(chindex)}
+	}
+}
+
+//heardFrom reports whether the market took this tick's offers from the agent now
+//in a slot - and so whether that agent is waiting on its results.
+func (m *Market) heardFrom(chindex int) bool {
+	agent, ok := m.collected[uint64(chindex)]
+	return ok && agent != nil && agent == m.agents[uint64(chindex)]
+}
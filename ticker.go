@@ -0,0 +1,92 @@
+// GoEconGo project ticker.go
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+//How often the market ticks in real time, unless configured otherwise.
+const defaultTickInterval = 500 * time.Millisecond
+
+//The share of agents sending offers under which an AdaptiveTicker slows down, and
+//over which it speeds up.
+const (
+	idleActiveShare = 0.1
+	busyActiveShare = 0.9
+)
+
+//An AdaptiveTicker ticks like a time.Ticker, but slows down while the market is
+//quiet and speeds up while it is busy, so that idle agents (waiting for inventory,
+//say) don't burn CPU and busy ones aren't held back.
+//C - delivers the ticks
+//ticker - the time.Ticker underneath
+//mu - guards interval, which the metrics server reads as the ticker adapts
+//interval - how long the ticker waits between ticks
+//minInterval, maxInterval - the band interval is kept within
+type AdaptiveTicker struct {
+	C           <-chan time.Time
+	ticker      *time.Ticker
+	mu          sync.RWMutex
+	interval    time.Duration
+	minInterval time.Duration
+	maxInterval time.Duration
+}
+
+//NewAdaptiveTicker starts a ticker at minInterval.  A band with maxInterval below
+//minInterval is taken to be minInterval alone.
+func NewAdaptiveTicker(minInterval time.Duration, maxInterval time.Duration) *AdaptiveTicker {
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	ticker := time.NewTicker(minInterval)
+	return &AdaptiveTicker{C: ticker.C, ticker: ticker, interval: minInterval,
+		minInterval: minInterval, maxInterval: maxInterval}
+}
+
+//Adapt doubles the interval when fewer than idleActiveShare of the agents sent
+//offers last tick, and halves it when more than busyActiveShare did, keeping it
+//within [minInterval, maxInterval].
+//activeShare - the share [0.0,1.0] of agents that sent offers
+func (t *AdaptiveTicker) Adapt(activeShare float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	interval := t.interval
+	switch {
+	case activeShare < idleActiveShare:
+		interval = interval * 2
+	case activeShare > busyActiveShare:
+		interval = interval / 2
+	}
+	if interval < t.minInterval {
+		interval = t.minInterval
+	}
+	if interval > t.maxInterval {
+		interval = t.maxInterval
+	}
+	if interval != t.interval {
+		t.interval = interval
+		t.ticker.Reset(interval)
+	}
+}
+
+//Interval returns how long the ticker currently waits between ticks.
+func (t *AdaptiveTicker) Interval() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.interval
+}
+
+//Stop stops the ticker.  No more ticks are delivered.
+func (t *AdaptiveTicker) Stop() {
+	t.ticker.Stop()
+}
+
+//activeShare returns the share of agents whose offers were taken in the last
+//collect.
+func (m *Market) activeShare() float64 {
+	if len(m.askChannels) == 0 {
+		return 0
+	}
+	return float64(len(m.collected)) / float64(len(m.askChannels))
+}
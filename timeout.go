@@ -0,0 +1,24 @@
+// GoEconGo project timeout.go
+package main
+
+import "time"
+
+//The deathReason of an agent that gave up waiting on the market.
+const timeoutDeath = "timeout"
+
+//responseDeadline returns when an agent waiting on the market for the results of
+//its offers gives up on it, or nil if it waits forever.
+func responseDeadline(agent *traderAgent) <-chan time.Time {
+	if agent.responseTimeout <= 0 {
+		return nil
+	}
+	return time.After(agent.responseTimeout)
+}
+
+//timeOut marks an agent as having given up waiting on the market.  It dies, and
+//the market fills its slot as it would for any other death.
+func timeOut(agent *traderAgent) {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	agent.deathReason = timeoutDeath
+}
@@ -0,0 +1,33 @@
+// GoEconGo project tracing.go
+package main
+
+import "context"
+
+//A Tracer wraps pieces of the simulation's work in named spans.  StartSpan opens a
+//span under whatever span ctx carries, and returns a context carrying the new span
+//along with the function that ends it.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, func())
+}
+
+//startSpan opens a span with a tracer, doing nothing at all if there is no tracer.
+func startSpan(tracer Tracer, ctx context.Context, name string) (context.Context, func()) {
+	if tracer == nil {
+		return ctx, func() {}
+	}
+	return tracer.StartSpan(ctx, name)
+}
+
+//spanContext returns a context carrying the span of the tick the agent is trading
+//in, for the agent's own spans to open under.  The agent must be locked.
+func (agent *traderAgent) spanContext() context.Context {
+	if agent.tickSpan == nil {
+		return context.Background()
+	}
+	return agent.tickSpan
+}
+
+//span opens a span with the market's configured Tracer.
+func (m *Market) span(ctx context.Context, name string) (context.Context, func()) {
+	return startSpan(m.config.Tracer, ctx, name)
+}
@@ -0,0 +1,65 @@
+// GoEconGo project tradehistory.go
+package main
+
+//How many trades each agent remembers, unless the SimulationConfig says otherwise.
+const defaultTradeHistoryLength = 100
+
+//A tradeRecord is one accepted offer, as the market delivered it to an agent.
+//tick - the tick it traded on
+//commodity - the name of what was traded
+//quantity - how many units changed hands
+//price - the price of each unit
+//buying - whether the agent bought (rather than sold)
+type tradeRecord struct {
+	tick      int
+	commodity string
+	quantity  int
+	price     float64
+	buying    bool
+}
+
+//recordTrade adds a trade to an agent's TradeHistory, forgetting the oldest once it
+//holds the configured TradeHistoryLength (0 or less keeps none).
+func recordTrade(agent *traderAgent, record tradeRecord) {
+	limit := agent.settings().TradeHistoryLength
+	if limit <= 0 {
+		return
+	}
+	agent.TradeHistory = append(agent.TradeHistory, record)
+	if over := len(agent.TradeHistory) - limit; over > 0 {
+		agent.TradeHistory = append(agent.TradeHistory[:0], agent.TradeHistory[over:]...)
+	}
+}
+
+//recordDelivery logs the accepted spot offers among the results the market just
+//sent an agent in its TradeHistory.
+//slot - the agent's channels
+func (m *Market) recordDelivery(slot uint64, asksOut []asks, bidsOut []bids) {
+	agent, ok := m.agents[slot]
+	if !ok {
+		return
+	}
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	for _, askSet := range asksOut {
+		if askSet.numberAccepted > 0 && askSet.offeredAsk.deliveryTick == 0 {
+			recordTrade(agent, tradeRecord{
+				tick:      m.tick,
+				commodity: askSet.offeredAsk.item.name,
+				quantity:  askSet.offeredAsk.quantity * askSet.numberAccepted,
+				price:     askSet.offeredAsk.sellFor,
+			})
+		}
+	}
+	for _, bidSet := range bidsOut {
+		if bidSet.numberAccepted > 0 && bidSet.offeredBid.deliveryTick == 0 {
+			recordTrade(agent, tradeRecord{
+				tick:      m.tick,
+				commodity: bidSet.offeredBid.item.name,
+				quantity:  bidSet.offeredBid.quantity * bidSet.numberAccepted,
+				price:     bidSet.offeredBid.buyFor,
+				buying:    true,
+			})
+		}
+	}
+}
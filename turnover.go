@@ -0,0 +1,121 @@
+// GoEconGo project turnover.go
+package main
+
+//How many ticks the metrics server and the market report measure turnover over.
+const turnoverWindow = 10
+
+//Producers that sell at least this many times what they hold, over a window, are
+//selling everything they make - so when buyers still go short, supply is what's
+//short.
+const supplyLimitedTurnover = 1.0
+
+//turnover works out how many units of a commodity an agent sold over the last
+//windowTicks ticks, and how many it held on average at the end of each of them,
+//from its TradeHistory.  Holdings are worked back from what it holds now by
+//undoing its trades, so production and consumption are left out, and anything
+//older than the TradeHistory remembers is missed.  The agent must be locked, or
+//not running.
+//name - the commodity to measure ("" is everything together)
+func turnover(agent *traderAgent, windowTicks int, name string) (sold int, averageHeld float64) {
+	if windowTicks < 1 {
+		return 0, 0
+	}
+	held := 0
+	for com, num := range agent.inventory {
+		if name == "" || com.name == name {
+			held += num
+		}
+	}
+	//What was bought and sold on each tick of the window, newest first
+	bought := make([]int, windowTicks)
+	soldOn := make([]int, windowTicks)
+	for _, record := range agent.TradeHistory {
+		back := agent.tick - record.tick
+		if back < 0 || back >= windowTicks || (name != "" && record.commodity != name) {
+			continue
+		}
+		if record.buying {
+			bought[back] += record.quantity
+		} else {
+			soldOn[back] += record.quantity
+			sold += record.quantity
+		}
+	}
+	total := 0
+	for back := 0; back < windowTicks; back++ {
+		if held < 0 {
+			held = 0
+		}
+		total += held
+		held = held - bought[back] + soldOn[back]
+	}
+	return sold, float64(total) / float64(windowTicks)
+}
+
+//InventoryTurnover measures how quickly an agent sells what it holds: the units
+//it sold over the last windowTicks ticks, over the units it held on average.  Very
+//low turnover is hoarding; very high is living hand to mouth.  It is worked out
+//from the agent's TradeHistory (see turnover), and an agent holding next to
+//nothing is taken to hold one unit.  The agent must be locked, or not running.
+func InventoryTurnover(agent *traderAgent, windowTicks int) float64 {
+	sold, averageHeld := turnover(agent, windowTicks, "")
+	if averageHeld < 1 {
+		averageHeld = 1
+	}
+	return float64(sold) / averageHeld
+}
+
+//roleTurnover returns the mean InventoryTurnover of each role's agents, by role.
+func (m *Market) roleTurnover(windowTicks int) map[string]float64 {
+	totals := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, slot := range m.agentSlots() {
+		agent := m.agents[slot]
+		agent.mu.Lock()
+		totals[agent.role] += InventoryTurnover(agent, windowTicks)
+		counts[agent.role]++
+		agent.mu.Unlock()
+	}
+	for role := range totals {
+		totals[role] = totals[role] / float64(counts[role])
+	}
+	return totals
+}
+
+//BottleneckCauses tells apart why each commodity DetectBottleneck scores as a
+//bottleneck.  Where the producers are selling everything they make, supply is
+//short ("supply"); where they are sitting on stock while buyers go short, buyers
+//are not bidding enough for it ("demand").  Commodities without any bids gone
+//unbought, or with nobody making them, have no cause.
+//Returns the cause of every bottleneck, by commodity name.
+func BottleneckCauses(sim *Simulation, windowTicks int) map[string]string {
+	m := sim.Market
+	causes := make(map[string]string)
+	scores := DetectBottleneck(sim, windowTicks)
+	for _, role := range m.roleOrder {
+		product := m.products[role].name
+		if scores[product] <= 0 {
+			continue
+		}
+		sold, averageHeld := 0, 0.0
+		for _, slot := range m.agentSlots() {
+			agent := m.agents[slot]
+			agent.mu.Lock()
+			if agent.role == role {
+				agentSold, agentHeld := turnover(agent, windowTicks, product)
+				sold += agentSold
+				averageHeld += agentHeld
+			}
+			agent.mu.Unlock()
+		}
+		if sold == 0 && averageHeld == 0 {
+			continue
+		}
+		if averageHeld < 1 || float64(sold)/averageHeld >= supplyLimitedTurnover {
+			causes[product] = "supply"
+		} else {
+			causes[product] = "demand"
+		}
+	}
+	return causes
+}
@@ -0,0 +1,77 @@
+// GoEconGo project utility_test.go
+package main
+
+import "testing"
+
+func TestUtilityWeights(t *testing.T) {
+	config := testConfig()
+	config.UtilityWeights["Farmer"] = map[string]float64{"Food": 2}
+	config.UtilityWeights["Miner"] = map[string]float64{"Ore": 2}
+	sim := newTestSimulation(t, config, 2)
+	m := sim.Market
+	food, ore, wood := m.commodities["Food"], m.commodities["Ore"], m.commodities["Wood"]
+	for _, agent := range agentsOf(m, "Farmer") {
+		agent.mu.Lock()
+		if agent.utilityWeight(food) != 2 || agent.utilityWeight(ore) != 1 {
+			t.Errorf("farmer %v weights food %v and ore %v, want 2 and 1", agent.id, agent.utilityWeight(food), agent.utilityWeight(ore))
+		}
+		agent.mu.Unlock()
+	}
+	for _, agent := range agentsOf(m, "Miner") {
+		agent.mu.Lock()
+		if agent.utilityWeight(food) != 1 || agent.utilityWeight(ore) != 2 {
+			t.Errorf("miner %v weights food %v and ore %v, want 1 and 2", agent.id, agent.utilityWeight(food), agent.utilityWeight(ore))
+		}
+		agent.mu.Unlock()
+	}
+
+	//Under the same beliefs, everything worth 3, a farmer turning a unit of wood
+	//into 2 food values food twice over, and thinks it much more worth doing; a
+	//miner using up food to mine thinks it less so.
+	value := func(role string, weights map[*commodity]float64) float64 {
+		agent := newTestAgent(&m.config, sim.Economy.ProductionSet(role), 100, make(map[*commodity]int))
+		for _, com := range []*commodity{food, ore, wood} {
+			agent.priceBelief[com] = priceRange{low: 2, high: 4}
+		}
+		agent.UtilityWeights = weights
+		return getAverageProductionValue(agent, 0)
+	}
+	if plain, weighted := value("Farmer", nil), value("Farmer", map[*commodity]float64{food: 2}); plain != 2*3-3 || weighted != 2*2*3-3 {
+		t.Errorf("farming is worth %v, and %v weighting food 2, want %v and %v", plain, weighted, 2*3-3, 2*2*3-3)
+	}
+	if plain, weighted := value("Miner", nil), value("Miner", map[*commodity]float64{food: 2}); plain != 2*3-3 || weighted != 2*3-2*3 {
+		t.Errorf("mining is worth %v, and %v weighting food 2, want %v and %v", plain, weighted, 2*3-3, 2*3-2*3)
+	}
+
+	//So under the same beliefs, and with no wood to hand, the farmers bid more for
+	//wood than a farmer weighting food 1 as the miners do - who, making nothing from
+	//wood, don't bid for it at all.
+	woodBid := func(agent *traderAgent) (float64, int) {
+		agent.mu.Lock()
+		defer agent.mu.Unlock()
+		for _, com := range m.commodities {
+			agent.priceBelief[com] = priceRange{low: 2, high: 4}
+		}
+		agent.inventory = make(map[*commodity]int)
+		for _, bidSet := range generateBids(agent) {
+			if bidSet.offeredBid.item == wood && bidSet.offeredBid.deliveryTick == 0 {
+				return bidSet.offeredBid.buyFor, bidSet.numberOffered
+			}
+		}
+		return 0, 0
+	}
+	m.holdOffers()
+	farmer := agentsOf(m, "Farmer")[0]
+	weighted, weightedUnits := woodBid(farmer)
+	farmer.UtilityWeights = map[*commodity]float64{food: 1}
+	plain, plainUnits := woodBid(farmer)
+	if weightedUnits == 0 || weighted <= plain {
+		t.Errorf("a farmer weighting food 2 bid %v for %v wood, want more than the %v a farmer weighting it 1 bids", weighted, weightedUnits, plain)
+	}
+	if plainUnits != weightedUnits {
+		t.Errorf("farmers weighting food 2 and 1 bid for %v and %v wood, want the same", weightedUnits, plainUnits)
+	}
+	if miner, units := woodBid(agentsOf(m, "Miner")[0]); units != 0 {
+		t.Errorf("a miner bid %v for %v wood, want no bid", miner, units)
+	}
+}
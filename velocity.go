@@ -0,0 +1,50 @@
+// GoEconGo project velocity.go
+package main
+
+//How many ticks the metrics server measures the velocity of money over.
+const velocityWindow = 10
+
+//moneySupply adds up the funds of every living agent.
+func (m *Market) moneySupply() float64 {
+	supply := 0.0
+	for _, agent := range m.agents {
+		agent.mu.Lock()
+		supply += agent.funds
+		agent.mu.Unlock()
+	}
+	return supply
+}
+
+//recordVelocity records what traded this tick, and the money supply at the end of
+//it, for VelocityOfMoney.
+func (m *Market) recordVelocity() {
+	m.valueRing.push(m.metrics.tradedValue())
+	m.moneyRing.push(m.moneySupply())
+}
+
+//VelocityOfMoney measures how hard the economy's money is working: what traded on
+//the books over the last ticks ticks (each trade's clearing price times its
+//quantity), over the mean money supply (every living agent's funds) across them.
+//A rising velocity suggests the economy is heating up, and a falling one that
+//agents are hoarding.  Until there is history, or while there is no money, it is 0.
+func VelocityOfMoney(sim *Simulation, ticks int) float64 {
+	return sim.Market.velocityOfMoney(ticks)
+}
+
+//velocityOfMoney is VelocityOfMoney on the market itself.
+func (m *Market) velocityOfMoney(ticks int) float64 {
+	values := m.valueRing.last(ticks)
+	supplies := m.moneyRing.last(ticks)
+	if len(values) == 0 || len(supplies) == 0 {
+		return 0
+	}
+	traded := 0.0
+	for _, value := range values {
+		traded += value
+	}
+	meanSupply, _ := meanStdDev(supplies)
+	if meanSupply <= 0 {
+		return 0
+	}
+	return traded / meanSupply
+}
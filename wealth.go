@@ -0,0 +1,178 @@
+// GoEconGo project wealth.go
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+//NetWorth returns what an agent is worth: its funds, plus everything it holds at
+//market prices.  The agent must be locked, or not running.
+func NetWorth(agent *traderAgent) float64 {
+	worth := agent.funds
+	for com, num := range agent.inventory {
+		worth += float64(num) * com.averagePrice
+	}
+	return worth
+}
+
+//sortedFunds returns the funds of every agent, lowest first.
+func sortedFunds(agents []traderAgent) []float64 {
+	funds := make([]float64, len(agents))
+	for i, agent := range agents {
+		funds[i] = agent.funds
+	}
+	sort.Float64s(funds)
+	return funds
+}
+
+//GiniCoefficient measures how unequally funds are spread among agents, from 0
+//(everyone has the same) towards 1 (one agent has everything).  It is one less
+//twice the area under the Lorenz curve, which is summed up in trapezoids.  No
+//agents, a single agent, or agents with no funds between them measure 0.
+//agents - the agents to measure
+func GiniCoefficient(agents []traderAgent) float64 {
+	funds := sortedFunds(agents)
+	if len(funds) < 2 {
+		return 0
+	}
+	total := 0.0
+	for _, f := range funds {
+		total += f
+	}
+	if total <= 0 {
+		return 0
+	}
+	n := float64(len(funds))
+	area := 0.0
+	cumulative := 0.0
+	for _, f := range funds {
+		previous := cumulative / total
+		cumulative += f
+		area += (previous + cumulative/total) / 2 / n
+	}
+	return 1 - 2*area
+}
+
+//WealthPercentile returns the funds below which a share of agents fall,
+//interpolating between the two nearest agents.  No agents measure 0.
+//agents - the agents to measure
+//p - the share [0.0,1.0] of agents (0.5 is the median)
+func WealthPercentile(agents []traderAgent, p float64) float64 {
+	funds := sortedFunds(agents)
+	if len(funds) == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return funds[0]
+	}
+	if p >= 1 {
+		return funds[len(funds)-1]
+	}
+	position := p * float64(len(funds)-1)
+	below := int(position)
+	if below+1 >= len(funds) {
+		return funds[below]
+	}
+	fraction := position - float64(below)
+	return funds[below] + fraction*(funds[below+1]-funds[below])
+}
+
+//LorenzCurve works out the Lorenz curve of agents' net worth (see NetWorth): a
+//point for no agents and then for each agent, poorest first, of the share of
+//agents [0.0,1.0] counted so far and the share of net worth they hold between
+//them.  No agents, or agents worth nothing between them, give the line of equality.
+//agents - the agents to measure
+func LorenzCurve(agents []traderAgent) [][2]float64 {
+	worths := make([]float64, len(agents))
+	total := 0.0
+	for i := range agents {
+		worths[i] = NetWorth(&agents[i])
+		total += worths[i]
+	}
+	if len(worths) == 0 || total <= 0 {
+		return [][2]float64{{0, 0}, {1, 1}}
+	}
+	sort.Float64s(worths)
+	n := float64(len(worths))
+	curve := make([][2]float64, 0, len(worths)+1)
+	curve = append(curve, [2]float64{0, 0})
+	cumulative := 0.0
+	for i, worth := range worths {
+		cumulative += worth
+		curve = append(curve, [2]float64{float64(i+1) / n, cumulative / total})
+	}
+	return curve
+}
+
+//GiniFromLorenz measures inequality from a Lorenz curve rather than from the
+//agents: one less twice the area under the curve, summed up in trapezoids between
+//its points, however far apart they are.
+//curve - points of [population share, wealth share], lowest population first
+func GiniFromLorenz(curve [][2]float64) float64 {
+	area := 0.0
+	for i := 1; i < len(curve); i++ {
+		area += (curve[i][0] - curve[i-1][0]) * (curve[i][1] + curve[i-1][1]) / 2
+	}
+	return 1 - 2*area
+}
+
+//sampleCurve thins a curve out to at most points points, evenly spread along it,
+//keeping its first and last.
+func sampleCurve(curve [][2]float64, points int) [][2]float64 {
+	if points < 2 || len(curve) <= points {
+		return curve
+	}
+	sampled := make([][2]float64, points)
+	for i := range sampled {
+		sampled[i] = curve[i*(len(curve)-1)/(points-1)]
+	}
+	return sampled
+}
+
+//A HistogramBucket counts the agents worth between Low and High.
+type HistogramBucket struct {
+	Low   float64
+	High  float64
+	Count float64
+}
+
+//WealthHistogram sorts agents into buckets of equal width by net worth (see
+//NetWorth), spanning the poorest agent's to the richest's, poorest first.  Each
+//bucket holds the agents worth at least its Low and less than its High, but the
+//last also holds the richest.  Agents all worth the same fill a single bucket, and
+//no agents, or no buckets, give none.
+//agents - the agents to measure
+//buckets - how many buckets to sort them into
+func WealthHistogram(agents []traderAgent, buckets int) []HistogramBucket {
+	if len(agents) == 0 || buckets < 1 {
+		return nil
+	}
+	worths := make([]float64, len(agents))
+	for i := range agents {
+		worths[i] = NetWorth(&agents[i])
+	}
+	low, high := worths[0], worths[0]
+	for _, worth := range worths {
+		low = math.Min(low, worth)
+		high = math.Max(high, worth)
+	}
+	if high == low {
+		return []HistogramBucket{{Low: low, High: high, Count: float64(len(worths))}}
+	}
+	width := (high - low) / float64(buckets)
+	histogram := make([]HistogramBucket, buckets)
+	for i := range histogram {
+		histogram[i].Low = low + float64(i)*width
+		histogram[i].High = low + float64(i+1)*width
+	}
+	histogram[buckets-1].High = high
+	for _, worth := range worths {
+		i := int((worth - low) / width)
+		if i >= buckets {
+			i = buckets - 1
+		}
+		histogram[i].Count++
+	}
+	return histogram
+}
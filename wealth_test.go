@@ -0,0 +1,84 @@
+// GoEconGo project wealth_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+//agentsWorth returns agents that aren't running, holding nothing but funds.
+func agentsWorth(funds ...float64) []traderAgent {
+	agents := make([]traderAgent, len(funds))
+	for i, f := range funds {
+		agents[i] = traderAgent{funds: f}
+	}
+	return agents
+}
+
+func TestLorenzCurve(t *testing.T) {
+	curve := LorenzCurve(agentsWorth(3, 1, 4, 2))
+	want := [][2]float64{{0, 0}, {0.25, 0.1}, {0.5, 0.3}, {0.75, 0.6}, {1, 1}}
+	if len(curve) != len(want) {
+		t.Fatalf("got %v points, want %v", len(curve), len(want))
+	}
+	for i := range want {
+		if math.Abs(curve[i][0]-want[i][0]) > 1e-9 || math.Abs(curve[i][1]-want[i][1]) > 1e-9 {
+			t.Errorf("point %v: got %v, want %v", i, curve[i], want[i])
+		}
+	}
+	for _, agents := range [][]traderAgent{nil, agentsWorth(0, 0)} {
+		if curve := LorenzCurve(agents); len(curve) != 2 || curve[0] != [2]float64{0, 0} || curve[1] != [2]float64{1, 1} {
+			t.Errorf("%v agents worth nothing gave %v, want the line of equality", len(agents), curve)
+		}
+	}
+}
+
+func TestGiniFromLorenz(t *testing.T) {
+	tests := []struct {
+		name   string
+		agents []traderAgent
+		want   float64
+	}{
+		{"equal", agentsWorth(5, 5, 5, 5), 0},
+		{"one to four", agentsWorth(3, 1, 4, 2), 0.25},
+		{"one has everything", agentsWorth(0, 0, 0, 10), 0.75},
+		{"uneven", agentsWorth(1, 1, 2, 3, 5, 8, 13, 21, 34), 438.0 / 792},
+	}
+	for _, test := range tests {
+		fromCurve := GiniFromLorenz(LorenzCurve(test.agents))
+		direct := GiniCoefficient(test.agents)
+		if math.Abs(fromCurve-direct) > 1e-9 {
+			t.Errorf("%v: the Lorenz curve gives %v, and GiniCoefficient %v", test.name, fromCurve, direct)
+		}
+		if math.Abs(fromCurve-test.want) > 1e-9 {
+			t.Errorf("%v: got %v, want %v", test.name, fromCurve, test.want)
+		}
+	}
+}
+
+func TestLorenzCurveMetrics(t *testing.T) {
+	sim := newTestSimulation(t, testConfig(), 10)
+	RunTicks(5, sim)
+	var exposed struct {
+		Gini   float64      `json:"gini"`
+		Lorenz [][2]float64 `json:"lorenz"`
+	}
+	var out bytes.Buffer
+	if err := sim.Market.Metrics().ExposeJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &exposed); err != nil {
+		t.Fatal(err)
+	}
+	curve := exposed.Lorenz
+	if len(curve) < 2 || len(curve) > lorenzPoints || curve[0] != [2]float64{0, 0} || math.Abs(curve[len(curve)-1][1]-1) > 1e-9 {
+		t.Fatalf("exposed a Lorenz curve of %v", curve)
+	}
+	for i := 1; i < len(curve); i++ {
+		if curve[i][0] < curve[i-1][0] || curve[i][1] < curve[i-1][1] {
+			t.Errorf("the exposed Lorenz curve falls from %v to %v", curve[i-1], curve[i])
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// GoEconGo project work.go
+package main
+
+//pendingWork is a production an agent has started but not finished.
+//method - the method being run
+//remaining - how many more ticks until it is done
+//outputs - what it will make, worked out when it was started
+type pendingWork struct {
+	method    *productionMethod
+	remaining int
+	outputs   []commoditySet
+}
+
+//working reports whether an agent is busy with production it hasn't finished.
+func working(agent *traderAgent) bool {
+	return len(agent.workQueue) > 0
+}
+
+//advanceWork moves an agent's unfinished production on by a tick, delivering
+//whatever finishes.
+//Returns whether the agent is still working afterwards.
+func advanceWork(agent *traderAgent) bool {
+	var unfinished []pendingWork
+	for _, work := range agent.workQueue {
+		work.remaining--
+		if work.remaining <= 0 {
+			deliverOutputs(agent, work.outputs)
+			continue
+		}
+		unfinished = append(unfinished, work)
+	}
+	agent.workQueue = unfinished
+	return working(agent)
+}
+
+//deliverOutputs adds what a production made to an agent's inventory, and charges
+//the carbon tax on anything dirty.
+func deliverOutputs(agent *traderAgent, outputs []commoditySet) {
+	for _, output := range outputs {
+		agent.inventory[output.item] = agent.inventory[output.item] + output.quantity
+		//Pay the carbon tax on anything dirty.
+		if output.item.IsExternality {
+			tax := agent.settings().CarbonTaxRate * float64(output.quantity)
+			agent.funds = agent.funds - tax
+			agent.ledger.Record(agent.id, 0, tax, agent.tick, ledgerCarbonTax)
+			agent.Cost = agent.Cost + tax
+			agent.carbonTaxPaid = agent.carbonTaxPaid + tax
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// GoEconGo project work_test.go
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCarbonTax(t *testing.T) {
+	config := testConfig()
+	config.CarbonTaxRate = 0.25
+	m, smelter, _, pollution := smeltingMarket(config)
+	other := newTestAgent(&m.config, smelter.job, 100, map[*commodity]int{m.commodities["Ore"]: 10})
+	m.agents[2] = other
+	for i := 0; i < 3; i++ {
+		before := smelter.funds
+		performProduction(smelter)
+		if paid := before - smelter.funds; paid != 0.25 {
+			t.Errorf("smelt %v paid %v in tax, want 0.25 for its 1 pollution", i+1, paid)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		performProduction(other)
+	}
+	m.collectCarbonTax()
+	produced := smelter.inventory[pollution] + other.inventory[pollution]
+	if got, want := m.statistics.CarbonTaxRevenue, 0.25*float64(produced); math.Abs(got-want) > 1e-9 || produced != 8 {
+		t.Errorf("%v pollution raised %v, want %v", produced, got, want)
+	}
+	if smelter.carbonTaxPaid != 0 || other.carbonTaxPaid != 0 {
+		t.Errorf("tax left uncollected")
+	}
+}
+
+//pollutionAt runs the test economy, with refiners making a unit of pollution
+//whenever they refine, for 50 ticks under a carbon tax rate and returns how much
+//pollution was made.
+func pollutionAt(t *testing.T, rate float64) float64 {
+	config := testConfig()
+	config.CarbonTaxRate = rate
+	economy := testSimConfig(t, 10)
+	pollution := &commodity{name: "Pollution", IsExternality: true}
+	economy.CommodityList()["Pollution"] = pollution
+	for _, method := range economy.ProductionSet("Refiner").methods {
+		method.outputs = append(method.outputs, commoditySet{item: pollution, quantity: 1})
+	}
+	sim := simulateEconomy(t, config, economy)
+	RunTicks(50, sim)
+	return sim.Market.statistics.CarbonTaxRevenue / rate
+}
+
+func TestCarbonTaxReducesPollution(t *testing.T) {
+	low, high := pollutionAt(t, 0.01), pollutionAt(t, 20)
+	if low == 0 {
+		t.Fatal("refiners made no pollution")
+	}
+	if high >= low {
+		t.Errorf("a tax of 20 left %v pollution, want less than the %v a tax of 0.01 does", high, low)
+	}
+}